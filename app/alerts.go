@@ -0,0 +1,239 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/alert"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/scalesql/isitsql/internal/hadr"
+	"github.com/scalesql/isitsql/internal/mssql/agent"
+	"github.com/scalesql/isitsql/internal/threshold"
+	"github.com/sirupsen/logrus"
+)
+
+// alertInterval is how often the alert conditions are evaluated
+const alertInterval = 30 * time.Second
+
+// failedJobWindow is how far back we look for failed agent jobs
+const failedJobWindow = 24 * time.Hour
+
+// recentFailedJobs counts the failed runs of each job in the
+// failedJobWindow before now.  Cancelled and retried runs aren't failures.
+func recentFailedJobs(jobs []agent.JobHistoryRow, now time.Time) map[string]int {
+	failed := make(map[string]int)
+	for _, j := range jobs {
+		if j.RunStatus != 0 || now.Sub(j.RunTimeNative) > failedJobWindow {
+			continue
+		}
+		failed[j.JobName.String]++
+	}
+	return failed
+}
+
+// lastServerConditions are the job and threshold conditions for each
+// server from its last good poll.  They are used while its polls fail.
+var lastServerConditions = struct {
+	sync.Mutex
+	m map[string][]alert.Condition
+}{m: make(map[string][]alert.Condition)}
+
+// launchAlertEngine evaluates the alert conditions on a schedule
+func launchAlertEngine() {
+	defer failure.HandlePanic()
+	logrus.Debug("Launch Alert Engine...")
+
+	AlertEngine.Subscribe(func(a alert.Alert) {
+		WinLogln(a.String())
+	})
+
+	ticker := time.NewTicker(alertInterval)
+	for range ticker.C {
+		evaluateThresholds()
+		AlertEngine.Evaluate(getAlertConditions())
+	}
+}
+
+// agAlertLevel returns the total send and redo queues in KB and
+// whether the AG is in an alert or warning state.  Zero thresholds are ignored.
+func agAlertLevel(ag hadr.AG, cfg appConfig) (send, redo int64, isAlert, isWarn bool) {
+	if cfg.AGAlertMB == 0 {
+		cfg.AGAlertMB = math.MaxInt64
+	}
+	if cfg.AGWarnMB == 0 {
+		cfg.AGWarnMB = math.MaxInt64
+	}
+	for _, r := range ag.Replicas {
+		send += r.SendQueue
+		redo += r.RedoQueue
+	}
+	sendMB := send / 1024
+	redoMB := redo / 1024
+	if ag.IsHealthy() && sendMB <= cfg.AGAlertMB && sendMB <= cfg.AGWarnMB && redoMB <= cfg.AGAlertMB && redoMB <= cfg.AGWarnMB {
+		return send, redo, false, false
+	}
+	// if the AG isn't online, or we are over the Alert levels
+	if ag.State != "ONLINE" || sendMB > cfg.AGAlertMB || redoMB > cfg.AGAlertMB {
+		return send, redo, true, false
+	}
+	return send, redo, false, true
+}
+
+// getAlertConditions gathers everything that should be alerting right now.
+// It uses the same rules as the page banner and the backups page.
+// Servers and AGs in a maintenance window are skipped.
+func getAlertConditions() []alert.Condition {
+	conditions := make([]alert.Condition, 0)
+	cfg := getGlobalConfig()
+
+	// Poll errors, failed jobs, and thresholds
+	lastServerConditions.Lock()
+	defer lastServerConditions.Unlock()
+	previous := lastServerConditions.m
+	lastServerConditions.m = make(map[string][]alert.Condition)
+	for _, s := range servers.CloneAll() {
+		if _, silenced := serverMaintenance(s.MapKey, s.Tags); silenced {
+			continue
+		}
+		if s.LastPollError != "" {
+			conditions = append(conditions, alert.Condition{
+				Entity:      s.MapKey,
+				DisplayName: s.DisplayName(),
+				Name:        "poll_error",
+				Severity:    alert.SeverityCritical,
+				Message:     s.LastPollErrorClean(120),
+			})
+			// keep what we had from the last good poll so those
+			// alerts don't resolve and fire again around a failed poll
+			kept := previous[s.MapKey]
+			lastServerConditions.m[s.MapKey] = kept
+			conditions = append(conditions, kept...)
+			continue
+		}
+		serverConditions := make([]alert.Condition, 0)
+		failed := recentFailedJobs(s.FailedJobs, time.Now())
+		for _, r := range ThresholdRules.Results(s.MapKey) {
+			c := alert.Condition{
+				Entity:      s.MapKey,
+				DisplayName: s.DisplayName(),
+				Name:        "threshold",
+				Target:      r.Rule,
+				Severity:    alert.SeverityWarning,
+				Message:     r.String(),
+			}
+			if r.Level == threshold.LevelCritical {
+				c.Severity = alert.SeverityCritical
+			}
+			serverConditions = append(serverConditions, c)
+		}
+		for job, n := range failed {
+			serverConditions = append(serverConditions, alert.Condition{
+				Entity:      s.MapKey,
+				DisplayName: s.DisplayName(),
+				Name:        "job_failed",
+				Target:      job,
+				Severity:    alert.SeverityWarning,
+				Message:     fmt.Sprintf("job '%s' failed %d time(s) in the last %s", job, n, failedJobWindow),
+			})
+		}
+		lastServerConditions.m[s.MapKey] = serverConditions
+		conditions = append(conditions, serverConditions...)
+	}
+
+	// Repository
+	_, err := GlobalRepository.RepositoryError()
+	if err != nil {
+		conditions = append(conditions, alert.Condition{
+			Entity:      "isitsql",
+			DisplayName: "IsItSQL: Repository",
+			Name:        "repository_error",
+			Severity:    alert.SeverityCritical,
+			Message:     err.Error(),
+		})
+	}
+
+	// Availability Groups
+	for _, ag := range hadr.PublicAGMap.Groups() {
+		send, redo, isAlert, isWarn := agAlertLevel(ag, cfg)
+		if !isAlert && !isWarn {
+			continue
+		}
+		if _, silenced := agMaintenance(ag); silenced {
+			continue
+		}
+		c := alert.Condition{
+			Entity:      fmt.Sprintf("%s:%s", "AG", ag.DisplayName),
+			DisplayName: fmt.Sprintf("AG: %s (%s)", ag.DisplayName, ag.PrimaryReplica),
+			Name:        "ag_health",
+			Severity:    alert.SeverityWarning,
+			Message:     fmt.Sprintf("%s: %s  (send: %s;  redo: %s)", ag.State, ag.Health, KBToString(send), KBToString(redo)),
+		}
+		if isAlert {
+			c.Severity = alert.SeverityCritical
+		}
+		conditions = append(conditions, c)
+	}
+
+	// Backups
+	instanceAlerts, dbAlerts, _, _ := getBackupAlerts()
+	for ik, msg := range instanceAlerts {
+		conditions = append(conditions, alert.Condition{
+			Entity:      fmt.Sprintf("%s\\%s", ik.Domain, ik.ServerName),
+			DisplayName: fmt.Sprintf("%s (%s)", ik.ServerName, ik.Domain),
+			Name:        "backup",
+			Severity:    alert.SeverityWarning,
+			Message:     msg,
+		})
+	}
+	for _, d := range dbAlerts {
+		conditions = append(conditions, alert.Condition{
+			Entity:      d.ServerMapKey,
+			DisplayName: fmt.Sprintf("%s (%s)", d.ServerName, d.Domain),
+			Name:        "backup",
+			Target:      d.DatabaseName,
+			Severity:    alert.SeverityWarning,
+			Message:     fmt.Sprintf("%s: last full: %s; last log: %s", d.DatabaseName, backupAge(d.LastBackup, d.CurrentTime), backupAge(d.LastLogBackup, d.CurrentTime)),
+		})
+	}
+	return conditions
+}
+
+// backupAge returns how long ago a backup was taken as a short string
+func backupAge(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return durationToShortString(t, now)
+}
+
+// alertsJSON returns the active and recent alerts
+func alertsJSON(w http.ResponseWriter, req *http.Request) {
+	result := struct {
+		Evaluated time.Time     `json:"evaluated"`
+		Active    []alert.Alert `json:"active"`
+		Recent    []alert.Alert `json:"recent"`
+	}{
+		Evaluated: AlertEngine.LastEvaluation(),
+		Active:    AlertEngine.Active(),
+		Recent:    AlertEngine.Recent(),
+	}
+	js, err := json.Marshal(result)
+	if err != nil {
+		WinLogln(errors.Wrap(err, "alerts.json.marshal"))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(js)
+	if err != nil {
+		WinLogln(errors.Wrap(err, "alerts.json.write"))
+	}
+}
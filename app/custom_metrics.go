@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/custommetric"
+	"github.com/scalesql/isitsql/internal/mrepo"
+)
+
+// setCustomMetrics replaces the metrics from the HCL files
+func setCustomMetrics(mm []custommetric.Metric) {
+	before := CustomMetrics.Metrics()
+	CustomMetrics.Replace(mm)
+	if len(before) != len(mm) {
+		WinLogf("custom metrics: %d", len(mm))
+	}
+}
+
+// pollCustomMetrics runs the custom metrics for the tags of the server.
+// A failed query doesn't stop the others.  Metrics that no longer
+// apply to the server are removed.
+func (s *SqlServerWrapper) pollCustomMetrics(ctx context.Context) error {
+	s.RLock()
+	serverTags := s.Tags
+	s.RUnlock()
+
+	list := CustomMetrics.Server(serverTags)
+	keep := make(map[string]bool, len(list))
+	failed := make([]string, 0)
+	for _, m := range list {
+		keep[m.Key()] = true
+		err := s.GetMetric(ctx, m.Key(), m.Query, m.Accumulating)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", m.Name, err))
+		}
+	}
+
+	s.Lock()
+	for k := range s.Metrics {
+		if strings.HasPrefix(k, custommetric.Prefix) && !keep[k] {
+			delete(s.Metrics, k)
+		}
+	}
+	s.Unlock()
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// customMetricValue is the last value of a custom metric on a server
+type customMetricValue struct {
+	Name         string    `json:"name"`
+	Unit         string    `json:"unit,omitempty"`
+	Accumulating bool      `json:"accumulating,omitempty"`
+	EventTime    time.Time `json:"event_time"`
+	Polled       bool      `json:"polled"`
+	Value        int64     `json:"value"`
+	PerSecond    int64     `json:"value_per_second,omitempty"`
+}
+
+// Chart is the value to chart.  Accumulating metrics chart the change per second.
+func (v customMetricValue) Chart() int64 {
+	if v.Accumulating {
+		return v.PerSecond
+	}
+	return v.Value
+}
+
+// customMetricStale is the age of a value after which the custom
+// metric is treated as failing.  They are polled with the big poll.
+const customMetricStale = 2 * bigPollInterval
+
+// CustomMetricValues returns the last value of each custom metric
+// that has been polled on the server.  Values older than
+// customMetricStale at now are left out so a query that keeps failing
+// isn't reported with its last good value.  The caller handles locking.
+func (s *SqlServer) CustomMetricValues(now time.Time) []customMetricValue {
+	list := make([]customMetricValue, 0)
+	for _, m := range CustomMetrics.Server(s.Tags) {
+		last, err := s.GetLastMetric(m.Key())
+		if err != nil || last == nil {
+			continue
+		}
+		if now.Sub(last.EventTime) > customMetricStale {
+			continue
+		}
+		list = append(list, customMetricValue{
+			Name:         m.Name,
+			Unit:         m.Unit,
+			Accumulating: m.Accumulating,
+			EventTime:    last.EventTime,
+			Polled:       last.PolledValue,
+			Value:        last.Value,
+			PerSecond:    last.ValuePerSecond,
+		})
+	}
+	return list
+}
+
+// ChartedMetrics returns the custom metrics with values to chart on the server page
+func (s *SqlServer) ChartedMetrics() []custommetric.Metric {
+	list := make([]custommetric.Metric, 0)
+	for _, m := range CustomMetrics.Server(s.Tags) {
+		if _, ok := s.Metrics[m.Key()]; ok {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// repositoryCustomMetrics returns the polled values for the repository
+func repositoryCustomMetrics(values []customMetricValue) []mrepo.CustomMetric {
+	list := make([]mrepo.CustomMetric, 0, len(values))
+	for _, v := range values {
+		if !v.Polled {
+			continue
+		}
+		list = append(list, mrepo.CustomMetric{
+			Name:      v.Name,
+			Unit:      v.Unit,
+			Value:     v.Value,
+			PerSecond: v.PerSecond,
+		})
+	}
+	return list
+}
@@ -4,9 +4,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/scalesql/isitsql/internal/alert"
 	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/scalesql/isitsql/internal/custommetric"
 	"github.com/scalesql/isitsql/internal/dwaits"
+	"github.com/scalesql/isitsql/internal/logring"
+	"github.com/scalesql/isitsql/internal/maint"
 	"github.com/scalesql/isitsql/internal/mrepo"
+	"github.com/scalesql/isitsql/internal/notify"
+	"github.com/scalesql/isitsql/internal/otlp"
+	"github.com/scalesql/isitsql/internal/store"
+	"github.com/scalesql/isitsql/internal/threshold"
 	//"github.com/scalesql/isitsql/internal/settings"
 )
 
@@ -70,3 +78,25 @@ var DynamicWaitRepository *dwaits.Repository
 
 // Yet another global.  This is painful.
 var GlobalRepository *mrepo.Repository
+
+// AlertEngine tracks alerts as they fire and resolve
+var AlertEngine = alert.NewEngine(200)
+
+// GlobalNotifier sends alert transitions to webhooks
+var GlobalNotifier = notify.New(logring.New(200))
+
+// MaintenanceWindows silence alerts for servers and AGs
+var MaintenanceWindows = maint.NewSet()
+
+// CustomMetrics are the SQL metrics from the HCL files
+var CustomMetrics = custommetric.NewSet()
+
+// ThresholdRules evaluates the user-defined threshold rules
+var ThresholdRules = threshold.NewEvaluator()
+
+// GlobalOTLP pushes metrics to an OpenTelemetry collector.  It is nil if not configured.
+var GlobalOTLP *otlp.Exporter
+
+// GlobalStore holds the server cache and rings between restarts.  It is
+// in memory until setupStore opens the file.
+var GlobalStore store.Store = store.NewMemory()
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/hadr"
+	"github.com/scalesql/isitsql/internal/maint"
+)
+
+// setMaintenanceWindows replaces the windows from the HCL files
+func setMaintenanceWindows(ww []maint.Window) {
+	before := MaintenanceWindows.Windows()
+	MaintenanceWindows.Replace(ww)
+	if len(before) != len(ww) {
+		WinLogf("maintenance windows: %d", len(ww))
+	}
+}
+
+// serverMaintenance returns the active maintenance window for a server
+func serverMaintenance(key string, tags []string) (maint.Window, bool) {
+	return MaintenanceWindows.Server(key, tags)
+}
+
+// agMaintenance returns the active maintenance window for an AG.
+// It matches the AG name or display name.
+func agMaintenance(ag hadr.AG) (maint.Window, bool) {
+	w, ok := MaintenanceWindows.AG(ag.Name)
+	if ok {
+		return w, ok
+	}
+	return MaintenanceWindows.AG(ag.DisplayName)
+}
+
+// InMaintenance is true if the server is in an active maintenance window
+func (s *SqlServer) InMaintenance() bool {
+	_, ok := serverMaintenance(s.MapKey, s.Tags)
+	return ok
+}
+
+// MaintenanceTitle describes the active maintenance window for the badge
+func (s *SqlServer) MaintenanceTitle() string {
+	w, ok := serverMaintenance(s.MapKey, s.Tags)
+	if !ok {
+		return ""
+	}
+	msg := fmt.Sprintf("%s: until %s", w.Name, w.Until(time.Now()).Format("Mon, 02 Jan 15:04"))
+	if w.Comment != "" {
+		msg += " (" + w.Comment + ")"
+	}
+	return msg
+}
+
+// maintenanceFormValues holds the maintenance window for the settings form
+type maintenanceFormValues struct {
+	Name      string
+	Servers   string
+	Tags      string
+	AGNames   string
+	Recurring bool
+	Start     string
+	End       string
+	Cron      string
+	Duration  string
+	Comment   string
+}
+
+// formFromWindow fills the form from a window
+func formFromWindow(w maint.Window) maintenanceFormValues {
+	fv := maintenanceFormValues{
+		Name:      w.Name,
+		Servers:   strings.Join(w.Servers, ", "),
+		Tags:      strings.Join(w.Tags, ", "),
+		AGNames:   strings.Join(w.AGs, ", "),
+		Recurring: w.Recurring(),
+		Cron:      w.Cron,
+		Comment:   w.Comment,
+	}
+	if w.Recurring() {
+		fv.Duration = w.Duration.String()
+	} else {
+		fv.Start = w.Start.Format("2006-01-02T15:04")
+		fv.End = w.End.Format("2006-01-02T15:04")
+	}
+	return fv
+}
+
+// splitCSV splits a comma separated list and drops empty values
+func splitCSV(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,376 @@
+package app
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/scalesql/isitsql/internal/hadr"
+)
+
+// mssqlLabels are on every per-server series
+var mssqlLabels = []string{"server_key", "display_name", "tags"}
+
+// mssqlGauge is one per-server gauge.  value returns false if there is nothing to report.
+type mssqlGauge struct {
+	desc  *prometheus.Desc
+	value func(s *SqlServer) (float64, bool)
+}
+
+func newMSSQLGauge(name, help string, value func(s *SqlServer) (float64, bool)) mssqlGauge {
+	return mssqlGauge{
+		desc:  prometheus.NewDesc("mssql_"+name, help, mssqlLabels, nil),
+		value: value,
+	}
+}
+
+// mssqlSample is one value of a series with an extra label
+type mssqlSample struct {
+	label string
+	value float64
+}
+
+// mssqlSeries is a per-server metric with an extra label such as a database or wait group
+type mssqlSeries struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	samples   func(s *SqlServer) []mssqlSample
+}
+
+func newMSSQLSeries(name, help, label string, valueType prometheus.ValueType, samples func(s *SqlServer) []mssqlSample) mssqlSeries {
+	labels := append(append([]string{}, mssqlLabels...), label)
+	return mssqlSeries{
+		desc:      prometheus.NewDesc("mssql_"+name, help, labels, nil),
+		valueType: valueType,
+		samples:   samples,
+	}
+}
+
+// mssqlCollector exposes the polled values for each server.
+// It reads cloned servers so a scrape never blocks polling.
+type mssqlCollector struct {
+	snapshot  func() SqlServerArray
+	liveWaits func(key string) map[string]int64
+	now       func() time.Time
+	gauges    []mssqlGauge
+	series    []mssqlSeries
+}
+
+func newMSSQLCollector(snapshot func() SqlServerArray, liveWaits func(key string) map[string]int64) *mssqlCollector {
+	diskPerSecond := func(get func(s *SqlServer) int64) func(s *SqlServer) (float64, bool) {
+		return func(s *SqlServer) (float64, bool) {
+			if s.DiskIODelta.SampleMS <= 0 {
+				return 0, false
+			}
+			return float64(get(s)) * 1000 / float64(s.DiskIODelta.SampleMS), true
+		}
+	}
+	c := &mssqlCollector{
+		snapshot:  snapshot,
+		liveWaits: liveWaits,
+		now:       time.Now,
+	}
+	c.gauges = []mssqlGauge{
+		newMSSQLGauge("cpu_sql_percent", "Percent of CPU used by SQL Server", func(s *SqlServer) (float64, bool) {
+			cpu, ok := s.CPUUsage.GetNewest()
+			if !ok || cpu == nil {
+				return 0, false
+			}
+			return float64(cpu.SQL), true
+		}),
+		newMSSQLGauge("cpu_other_percent", "Percent of CPU used by other processes", func(s *SqlServer) (float64, bool) {
+			cpu, ok := s.CPUUsage.GetNewest()
+			if !ok || cpu == nil {
+				return 0, false
+			}
+			return float64(cpu.Other), true
+		}),
+		newMSSQLGauge("cpu_count", "Number of CPUs", func(s *SqlServer) (float64, bool) {
+			return float64(s.CpuCount), s.CpuCount > 0
+		}),
+		newMSSQLGauge("batches_per_second", "Batch requests per second", func(s *SqlServer) (float64, bool) {
+			m, err := s.GetLastMetric("sql")
+			if err != nil || m == nil {
+				return 0, false
+			}
+			return float64(m.ValuePerSecond), true
+		}),
+		newMSSQLGauge("page_life_expectancy_seconds", "Page life expectancy", func(s *SqlServer) (float64, bool) {
+			return float64(s.PLE), s.PLE > 0
+		}),
+		newMSSQLGauge("memory_used_bytes", "Memory used by SQL Server", func(s *SqlServer) (float64, bool) {
+			return float64(s.SqlServerMemoryKB) * 1024, s.SqlServerMemoryKB > 0
+		}),
+		newMSSQLGauge("memory_max_bytes", "Lower of max server memory and physical memory", func(s *SqlServer) (float64, bool) {
+			return float64(s.MemoryCap()) * 1024, s.PhysicalMemoryKB > 0
+		}),
+		newMSSQLGauge("memory_physical_bytes", "Physical memory of the host", func(s *SqlServer) (float64, bool) {
+			return float64(s.PhysicalMemoryKB) * 1024, s.PhysicalMemoryKB > 0
+		}),
+		newMSSQLGauge("disk_reads_per_second", "Reads per second over the last poll", diskPerSecond(func(s *SqlServer) int64 { return s.DiskIODelta.Reads })),
+		newMSSQLGauge("disk_writes_per_second", "Writes per second over the last poll", diskPerSecond(func(s *SqlServer) int64 { return s.DiskIODelta.Writes })),
+		newMSSQLGauge("disk_read_bytes_per_second", "Bytes read per second over the last poll", diskPerSecond(func(s *SqlServer) int64 { return s.DiskIODelta.ReadBytes })),
+		newMSSQLGauge("disk_write_bytes_per_second", "Bytes written per second over the last poll", diskPerSecond(func(s *SqlServer) int64 { return s.DiskIODelta.WriteBytes })),
+		newMSSQLGauge("disk_read_latency_seconds", "Average read latency over the last poll", func(s *SqlServer) (float64, bool) {
+			if s.DiskIODelta.Reads <= 0 {
+				return 0, s.DiskIODelta.SampleMS > 0
+			}
+			return float64(s.DiskIODelta.ReadStall) / float64(s.DiskIODelta.Reads) / 1000, true
+		}),
+		newMSSQLGauge("disk_write_latency_seconds", "Average write latency over the last poll", func(s *SqlServer) (float64, bool) {
+			if s.DiskIODelta.Writes <= 0 {
+				return 0, s.DiskIODelta.SampleMS > 0
+			}
+			return float64(s.DiskIODelta.WriteStall) / float64(s.DiskIODelta.Writes) / 1000, true
+		}),
+		newMSSQLGauge("databases", "Number of databases", func(s *SqlServer) (float64, bool) {
+			return float64(s.DatabaseCount), !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("data_size_bytes", "Size of all data files", func(s *SqlServer) (float64, bool) {
+			return float64(s.DataSizeKB) * 1024, !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("log_size_bytes", "Size of all log files", func(s *SqlServer) (float64, bool) {
+			return float64(s.LogSizeKB) * 1024, !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("poll_duration_seconds", "Duration of the last poll", func(s *SqlServer) (float64, bool) {
+			return s.PollDuration.Seconds(), !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("last_poll_timestamp_seconds", "Time of the last successful poll", func(s *SqlServer) (float64, bool) {
+			return float64(s.LastPollTime.Unix()), !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("poll_error", "1 if the last poll failed", func(s *SqlServer) (float64, bool) {
+			if s.LastPollError != "" {
+				return 1, true
+			}
+			return 0, true
+		}),
+		newMSSQLGauge("agent_jobs_running", "Number of running agent jobs", func(s *SqlServer) (float64, bool) {
+			return float64(len(s.RunningJobs)), !s.LastPollTime.IsZero()
+		}),
+		newMSSQLGauge("agent_jobs_failed", "Number of failed agent job runs in the last 24 hours", func(s *SqlServer) (float64, bool) {
+			var n int
+			for _, runs := range recentFailedJobs(s.FailedJobs, c.now()) {
+				n += runs
+			}
+			return float64(n), !s.LastPollTime.IsZero()
+		}),
+	}
+	c.series = []mssqlSeries{
+		newMSSQLSeries("wait_seconds_total", "Wait time by wait group since SQL Server started", "wait_group", prometheus.CounterValue, func(s *SqlServer) []mssqlSample {
+			if s.LastWaits == nil {
+				return nil
+			}
+			return waitSamples(s.LastWaits.GroupTotals())
+		}),
+		newMSSQLSeries("live_wait_seconds_total", "Wait time of running sessions by wait group since IsItSQL started", "wait_group", prometheus.CounterValue, func(s *SqlServer) []mssqlSample {
+			if c.liveWaits == nil {
+				return nil
+			}
+			return waitSamples(c.liveWaits(s.MapKey))
+		}),
+		newMSSQLSeries("database_full_backup_age_seconds", "Seconds since the last full backup", "database", prometheus.GaugeValue, func(s *SqlServer) []mssqlSample {
+			return c.backupAges(s, false)
+		}),
+		newMSSQLSeries("database_log_backup_age_seconds", "Seconds since the last log backup", "database", prometheus.GaugeValue, func(s *SqlServer) []mssqlSample {
+			return c.backupAges(s, true)
+		}),
+		newMSSQLSeries("custom_metric", "Custom metrics from the HCL files.  Accumulating metrics are per second.", "metric", prometheus.GaugeValue, func(s *SqlServer) []mssqlSample {
+			return customSamples(s.CustomMetricValues(c.now()))
+		}),
+	}
+	return c
+}
+
+// waitSamples converts milliseconds by wait group to seconds
+func waitSamples(waits map[string]int64) []mssqlSample {
+	samples := make([]mssqlSample, 0, len(waits))
+	for wg, ms := range waits {
+		samples = append(samples, mssqlSample{label: wg, value: float64(ms) / 1000})
+	}
+	return samples
+}
+
+// customSamples returns the last polled value of each custom metric
+func customSamples(values []customMetricValue) []mssqlSample {
+	samples := make([]mssqlSample, 0, len(values))
+	for _, v := range values {
+		if !v.Polled {
+			continue
+		}
+		samples = append(samples, mssqlSample{label: v.Name, value: float64(v.Chart())})
+	}
+	return samples
+}
+
+// backupAges returns the age of the last full or log backup for each database.
+// Databases that have never been backed up are left out.  So are log backups
+// for databases that don't need them.
+func (c *mssqlCollector) backupAges(s *SqlServer, log bool) []mssqlSample {
+	now := c.now()
+	samples := make([]mssqlSample, 0, len(s.Databases))
+	for _, d := range s.Databases {
+		if d == nil || d.Name == "tempdb" {
+			continue
+		}
+		last := d.LastBackup
+		if log {
+			if d.RecoveryModelDesc != "FULL" && d.RecoveryModelDesc != "BULK_LOGGED" {
+				continue
+			}
+			last = d.LastLogBackup
+		}
+		if last.IsZero() {
+			continue
+		}
+		samples = append(samples, mssqlSample{label: d.Name, value: now.Sub(last).Seconds()})
+	}
+	return samples
+}
+
+// Describe sends the descriptors of all the gauges
+func (c *mssqlCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range c.gauges {
+		ch <- g.desc
+	}
+	for _, sr := range c.series {
+		ch <- sr.desc
+	}
+}
+
+// Collect sends the current value of each gauge for each server
+func (c *mssqlCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.snapshot() {
+		labels := []string{s.MapKey, s.DisplayName(), promTags(s.Tags)}
+		for _, g := range c.gauges {
+			v, ok := g.value(&s)
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v, labels...)
+		}
+		for _, sr := range c.series {
+			for _, sample := range sr.samples(&s) {
+				ch <- prometheus.MustNewConstMetric(sr.desc, sr.valueType, sample.value, append(labels, sample.label)...)
+			}
+		}
+	}
+}
+
+// promTags formats tags as a single label value: ",a,b,".
+// The outer commas make regex matches like `.*,prod,.*` simple.
+func promTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	sorted := make([]string, len(tags))
+	copy(sorted, tags)
+	sort.Strings(sorted)
+	return "," + strings.Join(sorted, ",") + ","
+}
+
+// agLabels are on every availability group replica series
+var agLabels = []string{"ag", "display_name", "domain", "replica", "role"}
+
+// agGauge is one availability group replica gauge
+type agGauge struct {
+	desc  *prometheus.Desc
+	value func(r *hadr.Replica) (float64, bool)
+}
+
+func newAGGauge(name, help string, value func(r *hadr.Replica) (float64, bool)) agGauge {
+	return agGauge{
+		desc:  prometheus.NewDesc("mssql_ag_replica_"+name, help, agLabels, nil),
+		value: value,
+	}
+}
+
+// agCollector exposes the queues and health of each availability group replica
+type agCollector struct {
+	groups func() []hadr.AG
+	gauges []agGauge
+}
+
+func newAGCollector(groups func() []hadr.AG) *agCollector {
+	// latency is how long it takes to clear the queue at the current rate
+	latency := func(queue, rate int64) (float64, bool) {
+		if queue <= 0 {
+			return 0, true
+		}
+		if rate <= 0 {
+			return 0, false
+		}
+		return float64(queue) / float64(rate), true
+	}
+	return &agCollector{
+		groups: groups,
+		gauges: []agGauge{
+			newAGGauge("send_queue_bytes", "Log waiting to be sent to the replica", func(r *hadr.Replica) (float64, bool) {
+				return float64(r.SendQueue) * 1024, true
+			}),
+			newAGGauge("send_rate_bytes_per_second", "Rate log is sent to the replica", func(r *hadr.Replica) (float64, bool) {
+				return float64(r.SendRate) * 1024, true
+			}),
+			newAGGauge("redo_queue_bytes", "Log waiting to be redone on the replica", func(r *hadr.Replica) (float64, bool) {
+				return float64(r.RedoQueue) * 1024, true
+			}),
+			newAGGauge("redo_rate_bytes_per_second", "Rate log is redone on the replica", func(r *hadr.Replica) (float64, bool) {
+				return float64(r.RedoRate) * 1024, true
+			}),
+			newAGGauge("send_latency_seconds", "Estimated time to send the queued log", func(r *hadr.Replica) (float64, bool) {
+				return latency(r.SendQueue, r.SendRate)
+			}),
+			newAGGauge("redo_latency_seconds", "Estimated time to redo the queued log", func(r *hadr.Replica) (float64, bool) {
+				return latency(r.RedoQueue, r.RedoRate)
+			}),
+			newAGGauge("healthy", "1 if the replica is connected and healthy", func(r *hadr.Replica) (float64, bool) {
+				if r.IsHealthy {
+					return 1, true
+				}
+				return 0, true
+			}),
+		},
+	}
+}
+
+// Describe sends the descriptors of all the gauges
+func (c *agCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range c.gauges {
+		ch <- g.desc
+	}
+}
+
+// Collect sends the current value of each gauge for each replica
+func (c *agCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ag := range c.groups() {
+		for _, r := range ag.Replicas {
+			if r == nil {
+				continue
+			}
+			labels := []string{ag.Name, ag.DisplayName, ag.Domain, r.Name, strings.ToLower(r.Role)}
+			for _, g := range c.gauges {
+				v, ok := g.value(r)
+				if !ok {
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v, labels...)
+			}
+		}
+	}
+}
+
+// mssqlRegistry holds the per-server collectors for /metrics/mssql
+var mssqlRegistry = prometheus.NewRegistry()
+
+func init() {
+	mssqlRegistry.MustRegister(newMSSQLCollector(servers.CloneAll, func(key string) map[string]int64 {
+		return DynamicWaitRepository.Totals(key)
+	}))
+	mssqlRegistry.MustRegister(newAGCollector(func() []hadr.AG {
+		return hadr.PublicAGMap.Groups()
+	}))
+}
+
+// mssqlMetricsHandler serves /metrics/mssql
+var mssqlMetricsHandler = promhttp.HandlerFor(mssqlRegistry, promhttp.HandlerOpts{})
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scalesql/isitsql/internal/cpuring"
+	"github.com/scalesql/isitsql/internal/custommetric"
+	"github.com/scalesql/isitsql/internal/diskio"
+	"github.com/scalesql/isitsql/internal/hadr"
+	"github.com/scalesql/isitsql/internal/metricvaluering"
+	"github.com/scalesql/isitsql/internal/mssql/agent"
+	"github.com/scalesql/isitsql/internal/waitmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMSSQLCollector(t *testing.T) {
+	assert := assert.New(t)
+	s1 := SqlServer{
+		MapKey:            "d40-sql2016",
+		FriendlyName:      "D40",
+		Tags:              []string{"prod", "dev"},
+		CPUUsage:          cpuring.New(10),
+		PLE:               300,
+		SqlServerMemoryKB: 1024,
+		PhysicalMemoryKB:  4096,
+		MaxMemoryKB:       2048,
+		LastPollTime:      time.Unix(1700000000, 0),
+		DiskIODelta:       diskio.VirtualFileStats{SampleMS: 2000, Reads: 10, ReadStall: 50, Writes: 4, WriteStall: 8},
+	}
+	s1.CPUUsage.Enqueue(&cpuring.CPU{At: time.Now(), SQL: 40, Other: 5})
+	s2 := SqlServer{MapKey: "down", LastPollError: "login failed"}
+
+	c := newMSSQLCollector(func() SqlServerArray { return SqlServerArray{s1, s2} }, nil)
+	expected := `
+# HELP mssql_cpu_sql_percent Percent of CPU used by SQL Server
+# TYPE mssql_cpu_sql_percent gauge
+mssql_cpu_sql_percent{display_name="D40",server_key="d40-sql2016",tags=",dev,prod,"} 40
+# HELP mssql_disk_read_latency_seconds Average read latency over the last poll
+# TYPE mssql_disk_read_latency_seconds gauge
+mssql_disk_read_latency_seconds{display_name="D40",server_key="d40-sql2016",tags=",dev,prod,"} 0.005
+# HELP mssql_disk_reads_per_second Reads per second over the last poll
+# TYPE mssql_disk_reads_per_second gauge
+mssql_disk_reads_per_second{display_name="D40",server_key="d40-sql2016",tags=",dev,prod,"} 5
+# HELP mssql_memory_max_bytes Lower of max server memory and physical memory
+# TYPE mssql_memory_max_bytes gauge
+mssql_memory_max_bytes{display_name="D40",server_key="d40-sql2016",tags=",dev,prod,"} 2.097152e+06
+# HELP mssql_poll_error 1 if the last poll failed
+# TYPE mssql_poll_error gauge
+mssql_poll_error{display_name="D40",server_key="d40-sql2016",tags=",dev,prod,"} 0
+mssql_poll_error{display_name="down",server_key="down",tags=""} 1
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"mssql_cpu_sql_percent", "mssql_disk_read_latency_seconds", "mssql_disk_reads_per_second",
+		"mssql_memory_max_bytes", "mssql_poll_error")
+	assert.NoError(err)
+
+	// the server that can't be polled only has the error
+	assert.Equal(2, testutil.CollectAndCount(c, "mssql_poll_error"))
+	assert.Equal(1, testutil.CollectAndCount(c, "mssql_page_life_expectancy_seconds"))
+}
+
+func TestMSSQLCollectorSeries(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	s1 := SqlServer{
+		MapKey:       "srv1",
+		LastPollTime: now,
+		LastWaits: &waitmap.Waits{Waits: map[string]waitmap.Wait{
+			"MADE_UP_WAIT": {Wait: "MADE_UP_WAIT", WaitTime: 2500},
+		}},
+		Databases: map[int]*Database{
+			2: {Name: "tempdb", RecoveryModelDesc: "SIMPLE"},
+			5: {Name: "sales", RecoveryModelDesc: "FULL", LastBackup: now.Add(-time.Hour), LastLogBackup: now.Add(-5 * time.Minute)},
+			6: {Name: "stage", RecoveryModelDesc: "SIMPLE", LastBackup: now.Add(-2 * time.Hour)},
+			7: {Name: "new", RecoveryModelDesc: "FULL"},
+		},
+		RunningJobs: agent.JobList{{Name: "etl"}},
+		FailedJobs: []agent.JobHistoryRow{
+			{RunStatus: 0, RunTimeNative: now.Add(-time.Hour)},
+			{RunStatus: 0, RunTimeNative: now.Add(-2 * time.Hour)},
+			{RunStatus: 3, RunTimeNative: now.Add(-time.Hour)},   // cancelled
+			{RunStatus: 0, RunTimeNative: now.AddDate(0, 0, -3)}, // too old
+		},
+		Metrics: map[string]Metric{},
+	}
+	CustomMetrics.Replace([]custommetric.Metric{
+		{Name: "queue_depth", Query: "SELECT 1"},
+		{Name: "orders", Query: "SELECT 2", Accumulating: true},
+		{Name: "not_polled", Query: "SELECT 3"},
+		{Name: "failing", Query: "SELECT 4"},
+	})
+	defer CustomMetrics.Replace(nil)
+	for name, mv := range map[string]metricvaluering.MetricValue{
+		"custom.queue_depth": {EventTime: now, PolledValue: true, Value: 42},
+		"custom.orders":      {EventTime: now, PolledValue: true, Value: 600, ValuePerSecond: 10},
+		"custom.failing":     {EventTime: now.Add(-10 * time.Minute), PolledValue: true, Value: 7}, // the last good value
+	} {
+		var m Metric
+		m.V2.Enqueue(&mv)
+		s1.Metrics[name] = m
+	}
+	live := func(key string) map[string]int64 {
+		return map[string]int64{"CPU": 1500}
+	}
+	c := newMSSQLCollector(func() SqlServerArray { return SqlServerArray{s1} }, live)
+	c.now = func() time.Time { return now }
+
+	expected := `
+# HELP mssql_agent_jobs_failed Number of failed agent job runs in the last 24 hours
+# TYPE mssql_agent_jobs_failed gauge
+mssql_agent_jobs_failed{display_name="srv1",server_key="srv1",tags=""} 2
+# HELP mssql_agent_jobs_running Number of running agent jobs
+# TYPE mssql_agent_jobs_running gauge
+mssql_agent_jobs_running{display_name="srv1",server_key="srv1",tags=""} 1
+# HELP mssql_custom_metric Custom metrics from the HCL files.  Accumulating metrics are per second.
+# TYPE mssql_custom_metric gauge
+mssql_custom_metric{display_name="srv1",metric="orders",server_key="srv1",tags=""} 10
+mssql_custom_metric{display_name="srv1",metric="queue_depth",server_key="srv1",tags=""} 42
+# HELP mssql_database_full_backup_age_seconds Seconds since the last full backup
+# TYPE mssql_database_full_backup_age_seconds gauge
+mssql_database_full_backup_age_seconds{database="sales",display_name="srv1",server_key="srv1",tags=""} 3600
+mssql_database_full_backup_age_seconds{database="stage",display_name="srv1",server_key="srv1",tags=""} 7200
+# HELP mssql_database_log_backup_age_seconds Seconds since the last log backup
+# TYPE mssql_database_log_backup_age_seconds gauge
+mssql_database_log_backup_age_seconds{database="sales",display_name="srv1",server_key="srv1",tags=""} 300
+# HELP mssql_live_wait_seconds_total Wait time of running sessions by wait group since IsItSQL started
+# TYPE mssql_live_wait_seconds_total counter
+mssql_live_wait_seconds_total{display_name="srv1",server_key="srv1",tags="",wait_group="CPU"} 1.5
+# HELP mssql_wait_seconds_total Wait time by wait group since SQL Server started
+# TYPE mssql_wait_seconds_total counter
+mssql_wait_seconds_total{display_name="srv1",server_key="srv1",tags="",wait_group="MADE_UP_WAIT"} 2.5
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"mssql_agent_jobs_failed", "mssql_agent_jobs_running", "mssql_custom_metric",
+		"mssql_database_full_backup_age_seconds", "mssql_database_log_backup_age_seconds",
+		"mssql_live_wait_seconds_total", "mssql_wait_seconds_total")
+	assert.NoError(err)
+}
+
+func TestAGCollector(t *testing.T) {
+	assert := assert.New(t)
+	ag := hadr.AG{
+		Name:        "ag1",
+		DisplayName: "Sales AG",
+		Domain:      "corp",
+		Replicas: []*hadr.Replica{
+			{Name: "node1", Role: "PRIMARY", IsHealthy: true},
+			{Name: "node2", Role: "SECONDARY", SendQueue: 100, SendRate: 50, RedoQueue: 10},
+		},
+	}
+	c := newAGCollector(func() []hadr.AG { return []hadr.AG{ag} })
+	expected := `
+# HELP mssql_ag_replica_healthy 1 if the replica is connected and healthy
+# TYPE mssql_ag_replica_healthy gauge
+mssql_ag_replica_healthy{ag="ag1",display_name="Sales AG",domain="corp",replica="node1",role="primary"} 1
+mssql_ag_replica_healthy{ag="ag1",display_name="Sales AG",domain="corp",replica="node2",role="secondary"} 0
+# HELP mssql_ag_replica_send_latency_seconds Estimated time to send the queued log
+# TYPE mssql_ag_replica_send_latency_seconds gauge
+mssql_ag_replica_send_latency_seconds{ag="ag1",display_name="Sales AG",domain="corp",replica="node1",role="primary"} 0
+mssql_ag_replica_send_latency_seconds{ag="ag1",display_name="Sales AG",domain="corp",replica="node2",role="secondary"} 2
+# HELP mssql_ag_replica_send_queue_bytes Log waiting to be sent to the replica
+# TYPE mssql_ag_replica_send_queue_bytes gauge
+mssql_ag_replica_send_queue_bytes{ag="ag1",display_name="Sales AG",domain="corp",replica="node1",role="primary"} 0
+mssql_ag_replica_send_queue_bytes{ag="ag1",display_name="Sales AG",domain="corp",replica="node2",role="secondary"} 102400
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"mssql_ag_replica_healthy", "mssql_ag_replica_send_latency_seconds", "mssql_ag_replica_send_queue_bytes")
+	assert.NoError(err)
+
+	// a queue with no rate has no latency
+	assert.Equal(1, testutil.CollectAndCount(c, "mssql_ag_replica_redo_latency_seconds"))
+}
+
+func TestPromTags(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", promTags(nil))
+	assert.Equal(",a,b,", promTags([]string{"b", "a"}))
+}
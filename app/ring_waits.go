@@ -9,9 +9,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-// DefaultCapacity of an uninitialized Ring buffer.
+// DefaultCapacity of an uninitialized Ring buffer.  It holds an hour of big polls.
 // Changing this value only affects ring buffers created after it is changed.
-var DefaultCapacity = 60
+var DefaultCapacity = int(time.Hour/bigPollInterval) + 1
 
 // DefaultDuration is used to limit the window of events returned from the wait ring
 var DefaultDuration = 61 * time.Minute
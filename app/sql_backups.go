@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -17,7 +18,7 @@ type databaseBackups struct {
 	LogPhysicalDevice  string    `json:"log_physical_device,omitempty"`
 }
 
-func (s *SqlServerWrapper) pollBackups() error {
+func (s *SqlServerWrapper) pollBackups(ctx context.Context) error {
 	var rowCount int
 	var err error
 
@@ -30,7 +31,7 @@ func (s *SqlServerWrapper) pollBackups() error {
 	// 	return nil
 	// }
 
-	if rowCount, err = s.setBackupRowCount(); err != nil {
+	if rowCount, err = s.setBackupRowCount(ctx); err != nil {
 		return err
 	}
 
@@ -41,13 +42,13 @@ func (s *SqlServerWrapper) pollBackups() error {
 		return nil
 	}
 
-	err = s.setBackups()
+	err = s.setBackups(ctx)
 	return err
 }
 
-func (s *SqlServerWrapper) setBackupRowCount() (int, error) {
+func (s *SqlServerWrapper) setBackupRowCount(ctx context.Context) (int, error) {
 
-	row := s.DB.QueryRow(`
+	row := s.DB.QueryRowContext(ctx, `
 	
 		;WITH CTE AS ( 
 		select 
@@ -157,7 +158,7 @@ func (s *SqlServerWrapper) setBackupRowCount() (int, error) {
 // 	return nil
 // }
 
-func (s *SqlServerWrapper) setBackups() error {
+func (s *SqlServerWrapper) setBackups(ctx context.Context) error {
 
 	var backupQuery string
 
@@ -218,7 +219,7 @@ func (s *SqlServerWrapper) setBackups() error {
 	`
 	}
 
-	rows, err := s.DB.Query(backupQuery)
+	rows, err := s.DB.QueryContext(ctx, backupQuery)
 	if err != nil {
 		return errors.Wrap(err, "backup-query")
 	}
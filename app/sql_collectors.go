@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/collector"
+	"github.com/scalesql/isitsql/internal/mssql/agent"
+)
+
+// bigPollInterval is how often the big poll runs.  The quick poll runs every 10 seconds.
+const bigPollInterval = 51 * time.Second
+
+// quickCollectors run on every poll.  They connect and read what the
+// other collectors need so any failure ends the poll.
+var quickCollectors = newQuickCollectors()
+
+// pollCollectors run on the big poll.  A failure is recorded for the
+// collector and the rest still run.
+var pollCollectors = newPollCollectors()
+
+// serverCollector is a collector for a monitored server
+type serverCollector = collector.Collector[*SqlServerWrapper]
+
+// collect adapts a poll method.  The context carries the deadline of the
+// collector and is cancelled when the server is removed.
+func collect(spec collector.Spec, fn func(*SqlServerWrapper, context.Context) error) serverCollector {
+	return collector.New(spec, func(ctx context.Context, s *SqlServerWrapper) error {
+		return fn(s, ctx)
+	})
+}
+
+// collectMetric reads a metric from a query
+func collectMetric(name, stmt string, accumulating bool) serverCollector {
+	return collect(collector.Spec{Name: "metric." + name}, func(s *SqlServerWrapper, ctx context.Context) error {
+		return s.GetMetric(ctx, name, stmt, accumulating)
+	})
+}
+
+func newRegistry() collector.Registry[*SqlServerWrapper] {
+	return collector.Registry[*SqlServerWrapper]{
+		Version: func(s *SqlServerWrapper) int {
+			s.RLock()
+			defer s.RUnlock()
+			return s.MajorVersion
+		},
+		OnChange: logCollectorChange,
+	}
+}
+
+func newQuickCollectors() *collector.Registry[*SqlServerWrapper] {
+	r := newRegistry()
+	r.Register(
+		collect(collector.Spec{Name: "resetdb", Policy: collector.Stop, Connect: true}, func(s *SqlServerWrapper, _ context.Context) error {
+			return s.resetDB()
+		}),
+		collect(collector.Spec{Name: "getname", Policy: collector.Stop, Connect: true}, (*SqlServerWrapper).getName),
+		collect(collector.Spec{Name: "serverinfo", Policy: collector.Stop, Connect: true}, (*SqlServerWrapper).getServerInfo),
+		// listing the AGs and their nodes can be slow on a busy cluster
+		collect(collector.Spec{Name: "ag", MinVersion: 12, Policy: collector.Stop, Timeout: 90 * time.Second}, (*SqlServerWrapper).pollAG),
+	)
+	return &r
+}
+
+func newPollCollectors() *collector.Registry[*SqlServerWrapper] {
+	r := newRegistry()
+	r.Register(
+		collect(collector.Spec{Name: "connection", Policy: collector.Stop}, func(s *SqlServerWrapper, ctx context.Context) error {
+			err := s.getConnectionInfo(ctx)
+			if err != nil {
+				return err
+			}
+			s.Lock()
+			s.Stats = s.DB.Stats()
+			s.Unlock()
+			return nil
+		}),
+		collect(collector.Spec{Name: "memory"}, (*SqlServerWrapper).GetServerMemory),
+		collect(collector.Spec{Name: "os"}, (*SqlServerWrapper).PollOS),
+		collect(collector.Spec{Name: "container", MinVersion: 15}, (*SqlServerWrapper).PollContainer),
+		collect(collector.Spec{Name: "cpu"}, (*SqlServerWrapper).GetCPU2),
+		collectMetric("sql", "SELECT [cntr_value] FROM sys.dm_os_performance_counters WHERE [counter_name] = 'Batch Requests/sec'", true),
+		collectMetric("bytesread", "select SUM(num_of_bytes_read) from sys.dm_io_virtual_file_stats(NULL, NULL)", true),
+		collectMetric("byteswritten", "select SUM(num_of_bytes_written) from sys.dm_io_virtual_file_stats(NULL, NULL)", true),
+		collect(collector.Spec{Name: "metric.ple"}, func(s *SqlServerWrapper, ctx context.Context) error {
+			err := s.GetMetric(ctx, "ple", "SELECT [cntr_value] FROM sys.dm_os_performance_counters WHERE [counter_name] = 'Page life expectancy' and instance_name = ''", false)
+			if err != nil {
+				return err
+			}
+			val, err := s.GetLastMetric("ple")
+			if err == nil {
+				s.Lock()
+				s.PLE = val.Value
+				s.Unlock()
+			}
+			return nil
+		}),
+		collect(collector.Spec{Name: "metric.custom", Timeout: time.Minute}, (*SqlServerWrapper).pollCustomMetrics),
+		collect(collector.Spec{Name: "waits"}, (*SqlServerWrapper).PollWaits),
+		collect(collector.Spec{Name: "diskio"}, (*SqlServerWrapper).getDiskIO),
+		collect(collector.Spec{Name: "backups", Interval: 5 * time.Minute, Timeout: time.Minute}, (*SqlServerWrapper).pollBackups),
+		collect(collector.Spec{Name: "databases"}, (*SqlServerWrapper).getDatabases),
+		collect(collector.Spec{Name: "snapshots"}, (*SqlServerWrapper).getSnapshots),
+		collect(collector.Spec{Name: "installdate", Interval: time.Hour}, (*SqlServerWrapper).getInstallDate),
+		collect(collector.Spec{Name: "ip", MinVersion: 11, Interval: 15 * time.Minute}, (*SqlServerWrapper).getIP),
+		collector.New(collector.Spec{Name: "jobs.running"}, func(ctx context.Context, s *SqlServerWrapper) error {
+			running, err := agent.FetchRunningJobs(ctx, s.MapKey, s.DB)
+			if err != nil {
+				return err
+			}
+			s.Lock()
+			s.RunningJobs = running
+			s.Unlock()
+			return nil
+		}),
+		collector.New(collector.Spec{Name: "jobs.failed"}, func(ctx context.Context, s *SqlServerWrapper) error {
+			failed, err := agent.FetchRecentFailures(ctx, s.MapKey, s.DB)
+			if err != nil {
+				return err
+			}
+			s.Lock()
+			s.FailedJobs = failed
+			s.Unlock()
+			return nil
+		}),
+		// Job completions are only needed for the repository
+		collector.New(collector.Spec{Name: "jobs.completed"}, func(ctx context.Context, s *SqlServerWrapper) error {
+			if !s.RepositoryAllowed() {
+				return nil
+			}
+			completed, err := agent.FetchRecentCompletions(ctx, s.MapKey, s.DB, GlobalRepository.LastJobInstance(s.MapKey))
+			if err != nil {
+				return errors.Wrap(err, "fetchrecentcompletions")
+			}
+			GlobalRepository.WriteJobRuns(s.MapKey, s.ServerName, completed)
+			return nil
+		}),
+	)
+	return &r
+}
+
+// logCollectorChange logs when a collector starts failing or recovers.
+// Collectors that stop the poll are logged as the poll error.
+func logCollectorChange(s *SqlServerWrapper, spec collector.Spec, st collector.Status) {
+	if spec.Policy == collector.Stop {
+		return
+	}
+	s.RLock()
+	name := s.DisplayName()
+	s.RUnlock()
+	if st.Failing() {
+		WinLogln(fmt.Sprintf("%s: %s: %s", name, st.Name, st.LastError))
+		return
+	}
+	WinLogln(fmt.Sprintf("%s: %s: *** Error Cleared ***", name, st.Name))
+}
+
+// CollectorStatus returns how each collector last went for the server
+func (s *SqlServerWrapper) CollectorStatus() []collector.Status {
+	return s.collectors.Statuses()
+}
+
+// FailingCollectors returns the collectors whose last run failed
+func (s *SqlServerWrapper) FailingCollectors() []collector.Status {
+	return s.collectors.Failing()
+}
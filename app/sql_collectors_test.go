@@ -0,0 +1,28 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/scalesql/isitsql/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorPolicies(t *testing.T) {
+	assert := assert.New(t)
+	for _, spec := range quickCollectors.Specs() {
+		assert.Equal(collector.Stop, spec.Policy, spec.Name)
+	}
+	// only the connection stops the big poll
+	stops := make([]string, 0)
+	names := make(map[string]bool)
+	for _, spec := range pollCollectors.Specs() {
+		names[spec.Name] = true
+		if spec.Policy == collector.Stop {
+			stops = append(stops, spec.Name)
+		}
+	}
+	assert.Equal([]string{"connection"}, stops)
+	for _, name := range []string{"backups", "databases", "snapshots", "jobs.running", "jobs.failed"} {
+		assert.True(names[name], name)
+	}
+}
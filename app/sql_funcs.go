@@ -17,6 +17,7 @@ import (
 	"github.com/billgraziano/mssqlodbc"
 	"github.com/kardianos/osext"
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/breaker"
 	"github.com/scalesql/isitsql/internal/dwaits"
 	"github.com/scalesql/isitsql/internal/settings"
 	"github.com/sirupsen/logrus"
@@ -308,9 +309,15 @@ func (list *ServerList) Delete(key string) error {
 
 	list.SortKeys()
 	list.mapTags()
-	s.stop <- struct{}{}
+	pollScheduler.Remove(key)
+	s.cancel()
 	s.WaitBox.Stop()
 
+	err := removeCache(key)
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "removecache"))
+	}
+
 	WinLogln(fmt.Sprintf("Deleting: %s (%s)", s.DisplayName(), key))
 
 	return nil
@@ -344,6 +351,10 @@ func (list *ServerList) UpdateFromSettings(key string, c settings.SQLServer) err
 		dirty = true
 		s.IgnoreBackupsList = c.IgnoreBackupsList
 	}
+	if !equalBool(s.Repository, c.Repository) {
+		dirty = true
+		s.Repository = c.Repository
+	}
 	if s.FQDN != c.FQDN {
 		dirty = true
 		s.FQDN = c.FQDN
@@ -356,6 +367,14 @@ func (list *ServerList) UpdateFromSettings(key string, c settings.SQLServer) err
 		list.mapTags()
 
 		WinLogln(fmt.Sprintf("Updating: %s (%s)", s.DisplayName(), key))
+
+		// the change may fix a failing server so don't wait for the backoff
+		s.RLock()
+		backingOff := !s.BackoffSince.IsZero()
+		s.RUnlock()
+		if backingOff {
+			s.PollNow()
+		}
 	}
 	return nil
 }
@@ -387,6 +406,7 @@ func (list *ServerList) AddFromSettings(key string, c settings.SQLServer, pollNo
 	// Let's see if we have anything cached
 	// All errors are logged in this function
 	s.SqlServer = GetCachedServer(key)
+	s.loadRings(key)
 
 	s.FriendlyName = c.FriendlyName
 	s.FQDN = c.FQDN
@@ -395,6 +415,7 @@ func (list *ServerList) AddFromSettings(key string, c settings.SQLServer, pollNo
 	s.MapKey = key
 	s.IgnoreBackups = c.IgnoreBackups
 	s.IgnoreBackupsList = c.IgnoreBackupsList
+	s.Repository = c.Repository
 
 	// Set the connection string
 	err = s.SetConectionString(key, c)
@@ -422,7 +443,8 @@ func (list *ServerList) AddFromSettings(key string, c settings.SQLServer, pollNo
 	// This limits us to 3 logins per hour which seems reasonable
 	db.SetConnMaxLifetime(20 * time.Minute)
 	s.DB = db
-	s.stop = make(chan struct{}, 1)
+	s.ctx, s.cancel = context.WithCancel(pollContext)
+	s.breaker = breaker.New(pollFailuresBeforeBackoff, pollBackoffBase, maxPollBackoff)
 
 	list.Lock()
 	list.Servers[key] = &s
@@ -436,14 +458,14 @@ func (list *ServerList) AddFromSettings(key string, c settings.SQLServer, pollNo
 	// 		globalPool.Poll(keyToPoll)
 	// 	}(key)
 	// }
-	go PollRoutine(&s)
+	pollScheduler.Add(key)
 
 	//cfg := getGlobalConfig()
 	//if cfg.DynamicWaits {
 	if s.WaitBox == nil {
 		s.WaitBox = &dwaits.Box{}
 	}
-	err = s.WaitBox.Start(context.Background(), DynamicWaitRepository, key, s.ConnectionType, s.ConnectionString)
+	err = s.WaitBox.Start(s.ctx, DynamicWaitRepository, key, s.ConnectionType, s.ConnectionString)
 	if err != nil {
 		errmsg := fmt.Sprintf("%s: %s", key, errors.Wrap(err, "waitbox.start"))
 		logrus.Error(errmsg)
@@ -464,3 +486,11 @@ func (d *Database) ShortDuration(t time.Time) string {
 	}
 	return durationToShortString(t, time.Now())
 }
+
+// equalBool compares optional settings
+func equalBool(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
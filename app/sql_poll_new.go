@@ -1,52 +1,125 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
-	"os"
-	"path/filepath"
 	"time"
 
+	"github.com/scalesql/isitsql/internal/collector"
 	"github.com/scalesql/isitsql/internal/failure"
-	"github.com/kardianos/osext"
+	"github.com/scalesql/isitsql/internal/pollq"
+	"github.com/scalesql/isitsql/internal/store"
 	"github.com/pkg/errors"
 )
 
-// PollRoutine is the new long lived poll
-func PollRoutine(s *SqlServerWrapper) {
+// pollContext is the parent of the context of each server.
+// StopPolling cancels it so queries in flight return at shutdown.
+var pollContext, StopPolling = context.WithCancel(context.Background())
+
+// pollInterval is how often each server is polled
+const pollInterval = 10 * time.Second
+
+// defaultPollWorkers is how many servers can poll at once.  Zero is no
+// limit.  max_concurrent in the [polling] section of isitsql.toml sets one.
+const defaultPollWorkers = 0
+
+// pollFailuresBeforeBackoff is how many polls in a row can fail to reach
+// a server before its polls are delayed.  The first delay is
+// pollBackoffBase and it doubles up to maxPollBackoff.
+const (
+	pollFailuresBeforeBackoff = 3
+	pollBackoffBase           = 20 * time.Second
+)
+
+// maxPollBackoff is the longest delay between the polls of a failing server
+// unless max_backoff_minutes is set in the [polling] section of isitsql.toml
+var maxPollBackoff = 5 * time.Minute
+
+// pollScheduler queues the polls of every server and runs them on an
+// optional number of workers.  Servers that haven't polled recently go first.
+var pollScheduler = pollq.New(defaultPollWorkers, pollInterval, pollServer)
+
+// pollServer runs one poll of a server for the scheduler.  The first poll
+// only reads the server details.  It returns how long until the next poll.
+func pollServer(key string) time.Duration {
 	defer failure.HandlePanic()
 
-	newpoll(s, true)
-
-	// delay up to 10 seconds to avoid thundering heard
-	// and spread the load out
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(s.MapKey)) // if errors, just use zero
-	msdelay := h.Sum32() % 10000
-	time.Sleep(time.Duration(msdelay) * time.Millisecond)
-	newpoll(s, false)
-
-	ticker := time.NewTicker(10 * time.Second)
-	for {
-		select {
-		case <-s.stop:
-			WinLogln("Polling stop:", s.MapKey)
-			return
-		case <-ticker.C:
-			newpoll(s, false)
+	servers.RLock()
+	s, ok := servers.Servers[key]
+	servers.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	// The scheduler never runs two polls of a server at once
+	if !s.quickPolled {
+		s.quickPolled = true
+		err := newpoll(s, true)
+		if err != nil {
+			return s.backoff(err)
+		}
+
+		// delay up to 10 seconds to avoid thundering heard
+		// and spread the load out
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(s.MapKey)) // if errors, just use zero
+		msdelay := h.Sum32() % 10000
+		return time.Duration(msdelay+1) * time.Millisecond
+	}
+
+	return s.backoff(newpoll(s, false))
+}
+
+// backoff records the result of a poll and returns how long until the
+// next one.  After pollFailuresBeforeBackoff polls in a row that can't
+// reach the server the delay doubles each time up to the max backoff.  Zero is the poll interval.
+func (s *SqlServerWrapper) backoff(err error) time.Duration {
+	// the server was deleted or the service is stopping
+	if s.ctx.Err() != nil {
+		return 0
+	}
+	s.Lock()
+	defer s.Unlock()
+	// only failing to reach the server backs off.  The server
+	// answered if a later collector failed.
+	if err == nil || !collector.Unreachable(err) {
+		failures := s.breaker.Failures()
+		if s.breaker.Succeed() {
+			WinLogln(fmt.Sprintf("%s: polling resumed after %d failed polls", s.DisplayName(), failures))
 		}
+		s.BackoffSince = time.Time{}
+		s.NextPoll = time.Time{}
+		return 0
+	}
+	delay := s.breaker.Fail(time.Now())
+	if delay == 0 {
+		return 0
 	}
+	if s.BackoffSince.IsZero() {
+		WinLogln(fmt.Sprintf("%s: backing off after %d failed polls", s.DisplayName(), s.breaker.Failures()))
+	}
+	s.BackoffSince = s.breaker.OpenSince()
+	s.NextPoll = time.Now().Add(delay)
+	return delay
+}
+
+// PollNow polls the server right away even if it is backing off.
+// It returns false if a poll is already running or waiting.
+func (s *SqlServerWrapper) PollNow() bool {
+	return pollScheduler.Trigger(s.MapKey)
 }
 
-func newpoll(m *SqlServerWrapper, forcequick bool) {
+// newpoll runs a poll and returns its error
+func newpoll(m *SqlServerWrapper, forcequick bool) error {
 
 	// If we're already polling, don't start again
 	m.RLock()
 	ispolling := m.IsPolling
 	m.RUnlock()
 	if ispolling {
-		return
+		return nil
 	}
 
 	var bigpoll = false
@@ -67,6 +140,10 @@ func newpoll(m *SqlServerWrapper, forcequick bool) {
 	m.Unlock()
 
 	bigpoll, err := m.getAllMetrics(forcequick)
+	// the server was deleted or the service is stopping
+	if m.ctx.Err() != nil {
+		return nil
+	}
 	if err != nil {
 		serverName := m.MapKey
 		// TODO shouldn't this be protected?
@@ -118,25 +195,21 @@ func newpoll(m *SqlServerWrapper, forcequick bool) {
 			WinLogln(err)
 		}
 	}
+	return err
 }
 
 func (sw *SqlServerWrapper) writeCache() error {
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		return errors.Wrap(err, "osext.executablefolder")
-	}
-	dir := filepath.Join(wd, "cache")
-	fileName := filepath.Join(dir, fmt.Sprintf("server.%s.json", sw.MapKey))
 	//bb, err := json.MarshalIndent(sw.SqlServer, "", "\t")
 	sw.RLock()
+	key := sw.MapKey
 	bb, err := json.Marshal(sw.SqlServer)
 	sw.RUnlock()
 	if err != nil {
 		return errors.Wrap(err, "json.marshalindent")
 	}
-	err = os.WriteFile(fileName, bb, 0600)
+	err = GlobalStore.Put(store.BucketServers, key, bb)
 	if err != nil {
-		return errors.Wrap(err, "os.writefile")
+		return errors.Wrap(err, "store.put")
 	}
 	return nil
 }
@@ -11,8 +11,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/scalesql/isitsql/internal/logonce"
-	"github.com/scalesql/isitsql/internal/mssql/agent"
+	"github.com/scalesql/isitsql/internal/mrepo"
 	"github.com/scalesql/isitsql/internal/waitmap"
 	"github.com/scalesql/isitsql/internal/waitring"
 	"github.com/sirupsen/logrus"
@@ -38,274 +37,49 @@ func (s *ServerList) PollServers() {
 }
 
 // getAllMetrics polls a SQL Server and updates metrics.  It returns a flag
-// indicating if this was a big poll (to write the cache) and an error
+// indicating if this was a big poll (to write the cache) and an error.
+// The collectors are in sql_collectors.go.  A failed collector on the big
+// poll is recorded for that collector and doesn't end the poll.
 func (s *SqlServerWrapper) getAllMetrics(forcequick bool) (bool, error) {
-	var err error
-	longPollError := errors.New("poll timeout after one minute")
-	pollStartTime := time.Now()
+	ctx, cancel := context.WithTimeout(s.ctx, longPollThreshold)
+	defer cancel()
 
 	thisSortPriority := 999999
 
-	err = s.resetDB()
+	err := quickCollectors.Run(ctx, s, &s.collectors)
 	if err != nil {
 		s.Lock()
 		s.SortPriority = thisSortPriority - 1
 		s.Unlock()
-		return false, errors.Wrap(err, "resetDB")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return false, errors.Wrap(longPollError, "resetdb")
-	}
-
-	err = s.getName()
-	if err != nil {
-		s.Lock()
-		s.SortPriority = thisSortPriority - 1
-		s.Unlock()
-		return false, errors.Wrap(err, "getName")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return false, errors.Wrap(longPollError, "longpoll: getname")
-	}
-
-	err = s.getServerInfo()
-	if err != nil {
-		return false, errors.Wrap(err, "getServerInfo")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return false, errors.Wrap(longPollError, "getserverinfo")
-	}
-
-	// Get availability groups
-	s.RLock()
-	majorVersion := s.MajorVersion
-	s.RUnlock()
-
-	if majorVersion >= 12 {
-		if err = s.pollAG(); err != nil {
-			return false, errors.Wrap(err, "pollAG")
-		}
+		return false, err
 	}
 
 	if forcequick {
 		return false, nil
 	}
 
-	err = s.getConnectionInfo()
-	if err != nil {
-		return false, err
-	}
-
-	// Get the database stats
-	s.Lock()
-	s.Stats = s.DB.Stats()
-	s.Unlock()
-
-	// Get the database connection
-
 	// Is it time for a big poll?
 	s.RLock()
-	//displayName := s.DisplayName()
 	lastBigPoll := s.LastBigPoll
 	reset := s.ResetOnThisPoll
 	s.RUnlock()
 
-	if !reset && time.Since(lastBigPoll) < time.Duration(51*time.Second) {
+	if !reset && time.Since(lastBigPoll) < bigPollInterval {
 		return false, nil
 	}
+	if reset {
+		s.collectors.Reset()
+	}
 
 	// Start a big poll
-	//WinLogln("Big Poll:", displayName)
 	s.Lock()
 	s.LastBigPoll = time.Now()
 	s.Unlock()
 
-	err = s.GetServerMemory()
-	if err != nil {
-		return true, errors.Wrap(err, "GetServerMemory")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "getservermemory")
-	}
-
-	err = s.PollOS()
-	if err != nil {
-		return true, errors.Wrap(err, "pollos")
-	}
-
-	// keep going if there is an error
-	err = s.PollContainer()
-	if err != nil {
-		WinLogln(errors.Wrap(err, "pollcontainer"))
-	}
-
-	// err = s.GetCPUUsage()
-	// if err != nil {
-	// 	return true, errors.Wrap(err, "GetCpuUsage")
-	// }
-
-	err = s.GetCPU2()
-	if err != nil {
-		return true, errors.Wrap(err, "getcpu2")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "getcpu")
-	}
-
-	err = s.GetMetric(
-		"sql",
-		"SELECT [cntr_value] FROM sys.dm_os_performance_counters WHERE [counter_name] = 'Batch Requests/sec'",
-		true)
-	if err != nil {
-		return true, errors.Wrap(err, "get sql/second")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "sqlbatches")
-	}
-
-	err = s.GetMetric(
-		"bytesread",
-		"select SUM(num_of_bytes_read) from sys.dm_io_virtual_file_stats(NULL, NULL)",
-		true)
-	if err != nil {
-		return true, errors.Wrap(err, "bytesread")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "bytesread")
-	}
-
-	err = s.GetMetric(
-		"byteswritten",
-		"select SUM(num_of_bytes_written) from sys.dm_io_virtual_file_stats(NULL, NULL)",
-		true)
-	if err != nil {
-		return true, errors.Wrap(err, "byteswritten")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "byteswritten")
-	}
-
-	err = s.GetMetric(
-		"ple",
-		"SELECT [cntr_value] FROM sys.dm_os_performance_counters WHERE [counter_name] = 'Page life expectancy' and instance_name = ''",
-		false)
-	if err != nil {
-		return true, errors.Wrap(err, "ple")
-	}
-
-	val, err := s.GetLastMetric("ple")
-	if err == nil {
-		s.Lock()
-		s.PLE = val.Value
-		s.Unlock()
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "ple")
-	}
-
-	err = s.PollWaits()
-	if err != nil {
-		return true, errors.Wrap(err, "pollwaits")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "pollwaits")
-	}
-
-	// // if err = s.pollWaits2(); err != nil {
-	// // 	return errors.Wrap(err, "pollWaits2")
-	// // }
-
-	// if time.Since(pollStartTime) > longPollThreshold {
-	// 	return true, errors.Wrap(longPollErrorlongPollThreshold, "pollwaits2")
-	// }
-
-	// Get Disk IO
-	if err = s.getDiskIO(); err != nil {
-		return true, errors.Wrap(err, "getDiskIO")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "getdiskio")
-	}
-
-	// Poll on the third time and every fifth time through
-	// This gets the AG backups much quicker
-	s.RLock()
-	lastBackupPoll := s.LastBackupPoll
-	s.RUnlock()
-
-	// poll backups every five minutes
-	if time.Since(lastBackupPoll) > 5*time.Minute {
-		if err = s.pollBackups(); err != nil {
-			return true, errors.Wrap(err, "pollBackups")
-		}
-	}
-
-	// Get AGs and databases
-	if s.MajorVersion >= 12 {
-		if err = s.pollAG(); err != nil {
-			return false, errors.Wrap(err, "pollAG")
-		}
-	}
-
-	err = s.getDatabases()
-	if err != nil {
-		return true, errors.Wrap(err, "getDatabases")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "getdatabases")
-	}
-
-	err = s.getSnapshots()
-	if err != nil {
-		return true, errors.Wrap(err, "getsnapshots")
-	}
-
-	if time.Since(pollStartTime) > longPollThreshold {
-		return true, errors.Wrap(longPollError, "getsnapshots")
-	}
-
-	// Installed
-	err = s.getInstallDate()
-	if err != nil {
-		return true, errors.Wrap(err, "getinstalldate")
-	}
-
-	// IP Addresses
-	if majorVersion > 10 {
-		err = s.getIP()
-		if err != nil {
-			logonce.Error(err.Error())
-		}
-	}
-
-	// Get the running jobs and recent failed jobs
-	running, err := agent.FetchRunningJobs(context.TODO(), s.MapKey, s.DB)
-	if err != nil {
-		return true, err
-	}
-	s.Lock()
-	s.RunningJobs = running
-	s.Unlock()
-
-	failed, err := agent.FetchRecentFailures(context.TODO(), s.MapKey, s.DB)
+	err = pollCollectors.Run(ctx, s, &s.collectors)
 	if err != nil {
 		return true, err
 	}
-	s.Lock()
-	s.FailedJobs = failed
-	s.Unlock()
 
 	s.Lock()
 	s.SortPriority = thisSortPriority
@@ -336,9 +110,14 @@ func (s *SqlServerWrapper) WriteToRepository() {
 	mm["memory_used_mb"] = s.SqlServerMemoryKB / 1024
 	delta := s.DiskIODelta
 
-	// get the requestWaits
-	requestWaits := s.WaitBox.Repository().Last(s.MapKey) // waitring.Waitlist
-	serverWaits := s.LastWaits                            // waitmap.Waits
+	// get the requestWaits.  There are none until the wait box starts.
+	var requestWaits waitring.WaitList
+	if repo := s.WaitBox.Repository(); repo != nil {
+		requestWaits = repo.Last(s.MapKey)
+	}
+	serverWaits := s.LastWaits // nil until the waits collector succeeds
+	sizes, backups := repositoryDatabases(s.Databases)
+	custom := repositoryCustomMetrics(s.SqlServer.CustomMetricValues(time.Now()))
 	s.RUnlock()
 
 	// set the per second values
@@ -361,19 +140,56 @@ func (s *SqlServerWrapper) WriteToRepository() {
 	}
 
 	ts := time.Now()
+	if GlobalOTLP != nil {
+		GlobalOTLP.Record(otlpPoints(ts, mm, requestWaits, serverWaits)...)
+	}
+	if !s.RepositoryAllowed() {
+		return
+	}
 	GlobalRepository.WriteMetrics(ts, mm)
 	GlobalRepository.WriteWaits(s.MapKey, s.ServerName, "request_wait", startTime, requestWaits)
 
 	// Convert serverWaits to waitring.WaitList
 	// so we can write it to the repository
-	sw := waitring.WaitList{
-		TS:    serverWaits.EventTime,
-		Waits: serverWaits.WaitSummary,
+	if serverWaits != nil {
+		sw := waitring.WaitList{
+			TS:    serverWaits.EventTime,
+			Waits: serverWaits.WaitSummary,
+		}
+		GlobalRepository.WriteWaits(s.MapKey, s.ServerName, "server_wait", startTime, sw)
+	}
+	GlobalRepository.WriteDatabaseSizes(s.MapKey, s.ServerName, ts, sizes)
+	GlobalRepository.WriteBackups(s.MapKey, s.ServerName, backups)
+	GlobalRepository.WriteCustomMetrics(s.MapKey, s.ServerName, ts, custom)
+}
+
+// RepositoryAllowed returns if the server is written to the repository
+// based on its tags and its repository setting
+func (s *SqlServerWrapper) RepositoryAllowed() bool {
+	s.RLock()
+	defer s.RUnlock()
+	ok, _ := GlobalRepository.Allow(s.Tags, s.Repository)
+	return ok
+}
+
+// repositoryDatabases returns the database sizes and last backups for the repository
+func repositoryDatabases(dbs map[int]*Database) ([]mrepo.DatabaseSize, []mrepo.Backup) {
+	sizes := make([]mrepo.DatabaseSize, 0, len(dbs))
+	backups := make([]mrepo.Backup, 0, len(dbs)*2)
+	for _, d := range dbs {
+		if d == nil {
+			continue
+		}
+		sizes = append(sizes, mrepo.DatabaseSize{Name: d.Name, DataKB: d.DataSizeKB, LogKB: d.LogSizeKB})
+		backups = append(backups,
+			mrepo.Backup{Database: d.Name, Type: mrepo.BackupFull, Started: d.LastBackup, Device: d.LastBackupDevice, Instance: d.LastBackupInstance},
+			mrepo.Backup{Database: d.Name, Type: mrepo.BackupLog, Started: d.LastLogBackup, Device: d.LastLogBackupDevice, Instance: d.LastLogBackupInstance},
+		)
 	}
-	GlobalRepository.WriteWaits(s.MapKey, s.ServerName, "server_wait", startTime, sw)
+	return sizes, backups
 }
 
-func (sw *SqlServerWrapper) getIP() error {
+func (sw *SqlServerWrapper) getIP(ctx context.Context) error {
 	// TODO: parse and lookup the FQDN to get an IP address and port
 	// Because containers won't know their IP address
 	// TODO: only get unique values for "result" below
@@ -400,7 +216,7 @@ func (sw *SqlServerWrapper) getIP() error {
 	`
 
 	allips := make([]netip.AddrPort, 0)
-	rows, err := sw.DB.Query(dbQuery)
+	rows, err := sw.DB.QueryContext(ctx, dbQuery)
 	if err != nil {
 		return errors.Wrap(err, "query")
 	}
@@ -524,12 +340,12 @@ func (sw *SqlServerWrapper) lookupIPs() ([]netip.AddrPort, error) {
 	return result, nil
 }
 
-func (sw *SqlServerWrapper) getInstallDate() error {
+func (sw *SqlServerWrapper) getInstallDate(ctx context.Context) error {
 	sw.RLock()
 	db := sw.DB
 	sw.RUnlock()
 
-	row := db.QueryRow(`
+	row := db.QueryRowContext(ctx, `
 		SELECT TOP 1 
 		CAST(COALESCE(create_date, '0001-01-01') AS DATETIME) AS installed
 		FROM sys.server_principals WITH (NOLOCK)
@@ -1,13 +1,14 @@
 package app
 
 import (
+	"context"
 	"time"
 
 	"github.com/scalesql/isitsql/internal/cpuring"
 	"github.com/pkg/errors"
 )
 
-func (s *SqlServerWrapper) GetCPU2() error {
+func (s *SqlServerWrapper) GetCPU2(ctx context.Context) error {
 	s.RLock()
 	db := s.DB
 	incontainer := s.InContainer
@@ -50,7 +51,7 @@ order by [timestamp] asc
 
 	`
 
-	rows, err := db.Query(stmt)
+	rows, err := db.QueryContext(ctx, stmt)
 	if err != nil {
 		return errors.Wrap(err, "db.query")
 	}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"time"
@@ -13,7 +14,7 @@ import (
 	"golang.org/x/text/language"
 )
 
-func (s *SqlServerWrapper) getDatabases() error {
+func (s *SqlServerWrapper) getDatabases(ctx context.Context) error {
 	var err error
 	dbs := make(map[int]*Database)
 	status := make(map[string]int)
@@ -27,7 +28,7 @@ func (s *SqlServerWrapper) getDatabases() error {
 	currentTime := s.CurrentTime
 	s.RUnlock()
 
-	tempdbdata, tempdblog, err := s.getTempDBSize()
+	tempdbdata, tempdblog, err := s.getTempDBSize(ctx)
 	if err != nil {
 		return errors.Wrap(err, "gettempdbsize")
 	}
@@ -146,7 +147,7 @@ func (s *SqlServerWrapper) getDatabases() error {
 
 	// TODO this query can generate values greater than INT
 	// TODO need to trap this error
-	rows, err := s.DB.Query(dbQuery)
+	rows, err := s.DB.QueryContext(ctx, dbQuery)
 	if err != nil {
 		return errors.Wrap(err, "query")
 	}
@@ -280,7 +281,7 @@ func (s *SqlServerWrapper) getDatabases() error {
 
 	if ServerVersion >= 12 {
 		// Get the AG stuff
-		agdatabases, err := hadr.GetReplicaDatabases(s.DB)
+		agdatabases, err := hadr.GetReplicaDatabases(ctx, s.DB)
 		if err != nil {
 			return errors.Wrap(err, "hadr.getreplicadatabases")
 		}
@@ -327,14 +328,14 @@ func (s *SqlServerWrapper) getDatabases() error {
 
 // getTempDBSize returns the size of tempdb using the data and log files in the database
 // for a more accurate value
-func (s *SqlServerWrapper) getTempDBSize() (dataKB int, logKB int, err error) {
+func (s *SqlServerWrapper) getTempDBSize(ctx context.Context) (dataKB int, logKB int, err error) {
 	query := `
 		SELECT 
 			DataSizeKB = CAST(SUM(CASE WHEN type_desc <> 'LOG' THEN CAST(size AS BIGINT) ELSE 0 END ) * 8 AS BIGINT),
 			LogSizeKB = CAST(SUM(CASE WHEN type_desc = 'LOG' THEN CAST(size AS BIGINT) ELSE 0 END ) * 8 AS BIGINT)
 		FROM tempdb.sys.database_files;
 	`
-	rows, err := s.DB.Query(query)
+	rows, err := s.DB.QueryContext(ctx, query)
 	if err != nil {
 		return 0, 0, errors.Wrap(err, "query")
 	}
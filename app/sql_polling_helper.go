@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/dustin/go-humanize"
+	"github.com/scalesql/isitsql/internal/threshold"
 
 	"regexp"
 )
@@ -110,9 +111,18 @@ func (s *SqlServer) MemoryCap() int64 {
 
 // GetTableCssClass determines if the row should be red to alert
 func (s *SqlServer) GetTableCssClass() string {
+	if s.InMaintenance() {
+		return ""
+	}
 	if s.LastPollError != "" {
 		return "alert alert-danger"
 	}
+	switch ThresholdRules.Level(s.MapKey) {
+	case threshold.LevelCritical:
+		return "alert alert-danger"
+	case threshold.LevelWarning:
+		return "alert alert-warning"
+	}
 	return ""
 
 }
@@ -208,6 +218,20 @@ func (s *SqlServer) ProductVersionString(v string) string {
 	return "SQL Server " + v[0:4]
 }
 
+// BackingOff is true while the polls are delayed after failed polls
+func (s *SqlServer) BackingOff() bool {
+	return !s.BackoffSince.IsZero()
+}
+
+// BackoffTitle describes the backoff for the badge
+func (s *SqlServer) BackoffTitle() string {
+	if !s.BackingOff() {
+		return ""
+	}
+	return fmt.Sprintf("Polls are delayed after failing since %s.  Next poll: %s",
+		s.BackoffSince.Format("Jan 2 15:04:05"), s.NextPoll.Format("15:04:05"))
+}
+
 // LastPollErrorClean fixes up the text error string and limits it to 45 characters
 func (s *SqlServer) LastPollErrorClean(length int) string {
 	// s.RLock()
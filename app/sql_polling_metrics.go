@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 )
 
 // GetMetric sets a single metric value
-func (s *SqlServerWrapper) GetMetric(metric, stmt string, accumulating bool) error {
+func (s *SqlServerWrapper) GetMetric(ctx context.Context, metric, stmt string, accumulating bool) error {
 
 	var m metricvaluering.MetricValue
 	s.RLock()
@@ -64,7 +65,7 @@ func (s *SqlServerWrapper) GetMetric(metric, stmt string, accumulating bool) err
 	m.PolledValue = false
 	m.EventTime = time.Now()
 
-	row := db.QueryRow(stmt)
+	row := db.QueryRowContext(ctx, stmt)
 	err := row.Scan(&m.AggregateValue)
 	if err != nil {
 		// if our polling failed, put it back with a default value
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"regexp"
 	"strings"
@@ -8,13 +9,13 @@ import (
 
 var atatVersionRegex = regexp.MustCompile(`(?m) on\s(?P<os>.*)\s<(?P<arch>.*)>`)
 
-func (wrap *SqlServerWrapper) PollContainer() error {
+func (wrap *SqlServerWrapper) PollContainer(ctx context.Context) error {
 	// These fields only exist in SQL Server 2019 and higher
 	if wrap.MajorVersion < 15 {
 		return nil
 	}
 	var containerType int
-	err := wrap.DB.QueryRow("select container_type from sys.dm_os_sys_info").Scan(&containerType)
+	err := wrap.DB.QueryRowContext(ctx, "select container_type from sys.dm_os_sys_info").Scan(&containerType)
 	// if err == sql.ErrNoRows, we will parse an empty string and get "unknown"
 	if err != nil {
 		if err != sql.ErrNoRows {
@@ -32,9 +33,9 @@ func (wrap *SqlServerWrapper) PollContainer() error {
 }
 
 // PollOS reads @@VERSION for the operating system information
-func (wrap *SqlServerWrapper) PollOS() error {
+func (wrap *SqlServerWrapper) PollOS(ctx context.Context) error {
 	var rawVersion string
-	err := wrap.DB.QueryRow("SELECT @@VERSION").Scan(&rawVersion)
+	err := wrap.DB.QueryRowContext(ctx, "SELECT @@VERSION").Scan(&rawVersion)
 	// if err == sql.ErrNoRows, we will parse an empty string and get "unknown"
 	if err != nil {
 		if err != sql.ErrNoRows {
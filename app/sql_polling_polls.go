@@ -13,7 +13,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (s *SqlServerWrapper) GetActiveSessions() ([]*ActiveSession, error) {
+func (s *SqlServerWrapper) GetActiveSessions(ctx context.Context) ([]*ActiveSession, error) {
 	s.RLock()
 	majorVersion := s.MajorVersion
 	s.RUnlock()
@@ -121,7 +121,7 @@ func (s *SqlServerWrapper) GetActiveSessions() ([]*ActiveSession, error) {
 
 	`
 
-	rows, err := s.DB.Query(stmt)
+	rows, err := s.DB.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +151,7 @@ func (s *SqlServerWrapper) GetActiveSessions() ([]*ActiveSession, error) {
 	return sessions, nil
 }
 
-func (s *SqlServerWrapper) getDiskIO() error {
+func (s *SqlServerWrapper) getDiskIO(ctx context.Context) error {
 	s.RLock()
 	p := s.DiskIO
 	db := s.DB
@@ -160,7 +160,7 @@ func (s *SqlServerWrapper) getDiskIO() error {
 
 	var io diskio.VirtualFileStats
 	var err error
-	if io, err = diskio.GetFileStats(db); err != nil {
+	if io, err = diskio.GetFileStats(ctx, db); err != nil {
 		return err
 	}
 	s.Lock()
@@ -172,7 +172,7 @@ func (s *SqlServerWrapper) getDiskIO() error {
 	return nil
 }
 
-func (s *SqlServerWrapper) pollAG() error {
+func (s *SqlServerWrapper) pollAG(ctx context.Context) error {
 	var err error
 	//aglist := make(map[string]*hadr.AG)
 
@@ -181,7 +181,7 @@ func (s *SqlServerWrapper) pollAG() error {
 	sn := s.ServerName
 	s.RUnlock()
 
-	aglist, err := hadr.GetAGList(db, sn)
+	aglist, err := hadr.GetAGList(ctx, db, sn)
 	if err != nil {
 		WinLogf("%s: %s", sn, errors.Wrap(err, "getaglist"))
 		//WinLogln("GetAGList", err)
@@ -193,7 +193,7 @@ func (s *SqlServerWrapper) pollAG() error {
 		hadr.PublicAGMap.Set(k, ag)
 	}
 
-	err = hadr.SetLatency(db)
+	err = hadr.SetLatency(ctx, db)
 	if err != nil {
 		WinLogln("SetLantencies", err)
 		return errors.Wrap(err, "hadr.setlatencies")
@@ -249,7 +249,7 @@ func (s *SqlServerWrapper) pollAG() error {
 	return nil
 }
 
-func (s *SqlServerWrapper) getName() error {
+func (s *SqlServerWrapper) getName(ctx context.Context) error {
 	s.RLock()
 	db := s.DB
 	s.RUnlock()
@@ -258,8 +258,6 @@ func (s *SqlServerWrapper) getName() error {
 	// ServerProperty('EngineEdition')
 	// If Azure, set the StartTime to IsItSQL start time
 	// Not sure about managed instances
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	row := db.QueryRowContext(ctx, `
 		USE [master];
 		SELECT 
@@ -300,12 +298,10 @@ func (s *SqlServerWrapper) getName() error {
 	return nil
 }
 
-func (s *SqlServerWrapper) getConnectionInfo() error {
+func (s *SqlServerWrapper) getConnectionInfo(ctx context.Context) error {
 	s.RLock()
 	db := s.DB
 	s.RUnlock()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	start := time.Now()
 	row := db.QueryRowContext(ctx, `
 			SELECT	TOP 1 
@@ -336,13 +332,11 @@ func (s *SqlServerWrapper) getConnectionInfo() error {
 	return nil
 }
 
-func (s *SqlServerWrapper) getServerInfo() error {
+func (s *SqlServerWrapper) getServerInfo(ctx context.Context) error {
 
 	s.RLock()
 	db := s.DB
 	s.RUnlock()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	row := db.QueryRowContext(ctx, `
     select 
 		cpu_count
@@ -396,7 +390,7 @@ func (s *SqlServerWrapper) getServerInfo() error {
 }
 
 // GetServerMemory gets the RAM available and used
-func (s *SqlServerWrapper) GetServerMemory() error {
+func (s *SqlServerWrapper) GetServerMemory(ctx context.Context) error {
 
 	s.RLock()
 	db := s.DB
@@ -408,14 +402,14 @@ func (s *SqlServerWrapper) GetServerMemory() error {
 
 	// Check for SQL Server 2005
 	if majorVersion == 9 {
-		row := db.QueryRow("SELECT physical_memory_in_bytes / 1024  FROM sys.dm_os_sys_info; ")
+		row := db.QueryRowContext(ctx, "SELECT physical_memory_in_bytes / 1024  FROM sys.dm_os_sys_info; ")
 
 		err := row.Scan(&pm)
 		if err != nil {
 			return errors.Wrap(err, "sql9: usedmemory")
 		}
 
-		row = db.QueryRow("SELECT cntr_value FROM sys.dm_os_performance_counters WHERE counter_name IN ('Total Server Memory (KB)'); ")
+		row = db.QueryRowContext(ctx, "SELECT cntr_value FROM sys.dm_os_performance_counters WHERE counter_name IN ('Total Server Memory (KB)'); ")
 
 		err = row.Scan(&sm)
 		if err != nil {
@@ -423,21 +417,21 @@ func (s *SqlServerWrapper) GetServerMemory() error {
 		}
 
 	} else {
-		row := db.QueryRow("select available_physical_memory_kb, total_physical_memory_kb, system_memory_state_desc  from sys.dm_os_sys_memory; ")
+		row := db.QueryRowContext(ctx, "select available_physical_memory_kb, total_physical_memory_kb, system_memory_state_desc  from sys.dm_os_sys_memory; ")
 
 		err := row.Scan(&am, &pm, &memstate)
 		if err != nil {
 			return errors.Wrap(err, "sql10: totalmemory")
 		}
 
-		row = db.QueryRow("select physical_memory_in_use_kb from sys.dm_os_process_memory; ")
+		row = db.QueryRowContext(ctx, "select physical_memory_in_use_kb from sys.dm_os_process_memory; ")
 
 		err = row.Scan(&sm)
 		if err != nil {
 			return errors.Wrap(err, "sql10: usedmemory")
 		}
 
-		row = db.QueryRow("SELECT CAST(value_in_use AS BIGINT) AS max_memory FROM sys.configurations WHERE [name] = 'max server memory (MB)'")
+		row = db.QueryRowContext(ctx, "SELECT CAST(value_in_use AS BIGINT) AS max_memory FROM sys.configurations WHERE [name] = 'max server memory (MB)'")
 		err = row.Scan(&max)
 		if err != nil {
 			return errors.Wrap(err, "sql10: maxmemory")
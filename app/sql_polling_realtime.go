@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"time"
 )
 
@@ -17,7 +18,7 @@ type queryStats struct {
 	AvgLogicalReads   int64
 }
 
-func (s *SqlServerWrapper) getQueryStats() ([]queryStats, error) {
+func (s *SqlServerWrapper) getQueryStats(ctx context.Context) ([]queryStats, error) {
 	var qs []queryStats
 
 	query := `
@@ -276,7 +277,7 @@ func (s *SqlServerWrapper) getQueryStats() ([]queryStats, error) {
     
     `
 
-	rows, err := s.DB.Query(query)
+	rows, err := s.DB.QueryContext(ctx, query)
 	if err != nil {
 		WinLogln("Error running query: ", err)
 		return qs, err
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/scalesql/isitsql/internal/mrepo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteToRepositoryWithoutWaits(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	repo, err := mrepo.NewRepository(mrepo.Config{Driver: mrepo.DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer repo.Close()
+	saved := GlobalRepository
+	GlobalRepository = repo
+	defer func() { GlobalRepository = saved }()
+
+	// the waits collector hasn't succeeded so LastWaits is nil
+	var s SqlServerWrapper
+	s.MapKey = "srv1"
+	s.ServerName = "SQL01"
+	s.Databases = map[int]*Database{5: {Name: "app", DataSizeKB: 1024, LogSizeKB: 512}}
+	assert.Nil(s.LastWaits)
+	assert.NotPanics(s.WriteToRepository)
+
+	// the metrics and the database sizes after the waits are written
+	repo.Flush()
+	assert.Equal(int64(2), repo.Stats().Written)
+}
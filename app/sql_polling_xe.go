@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/xml"
 	"strconv"
 	"time"
@@ -56,7 +57,7 @@ type xEvent struct {
 	DatabaseID  int
 }
 
-func (s *SqlServerWrapper) getXESessions() ([]*xEvent, error) {
+func (s *SqlServerWrapper) getXESessions(ctx context.Context) ([]*xEvent, error) {
 
 	var err error
 
@@ -72,7 +73,7 @@ func (s *SqlServerWrapper) getXESessions() ([]*xEvent, error) {
 	s.RLock()
 	db := s.DB
 	s.RUnlock()
-	rows, err := db.Query(stmt)
+	rows, err := db.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,7 +15,7 @@ type Snapshot struct {
 	Size       int64
 }
 
-func (s *SqlServerWrapper) getSnapshots() error {
+func (s *SqlServerWrapper) getSnapshots(ctx context.Context) error {
 	var err error
 	snaps := make([]Snapshot, 0)
 
@@ -34,7 +35,7 @@ func (s *SqlServerWrapper) getSnapshots() error {
 		WHERE snap.source_database_id IS NOT NULL
 		ORDER BY snap.[name];
 	`
-	rows, err := s.DB.Query(dbQuery)
+	rows, err := s.DB.QueryContext(ctx, dbQuery)
 	if err != nil {
 		return errors.Wrap(err, "query")
 	}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"html/template"
 	"net"
@@ -9,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/scalesql/isitsql/internal/breaker"
+	"github.com/scalesql/isitsql/internal/collector"
 	"github.com/scalesql/isitsql/internal/cpuring"
 	"github.com/scalesql/isitsql/internal/diskio"
 	"github.com/scalesql/isitsql/internal/dwaits"
@@ -47,8 +50,17 @@ func (s *SqlServer) DisplayName() string {
 type SqlServerWrapper struct {
 	sync.RWMutex
 	SqlServer
-	DB   *sql.DB `json:"-"`
-	stop chan struct{}
+	DB *sql.DB `json:"-"`
+	// ctx is the parent of every polling query.  It is cancelled when
+	// the server is deleted or the service stops.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// collectors has the last run and error of each collector
+	collectors collector.Schedule
+	// quickPolled is set after the first poll that only reads the server details
+	quickPolled bool
+	// breaker delays the polls of a server that keeps failing
+	breaker breaker.Breaker
 }
 
 func (wr *SqlServerWrapper) CloneSqlServer() SqlServer {
@@ -96,6 +108,10 @@ type SqlServer struct {
 	LastPollError string        `json:"last_poll_error,omitempty"`
 	LastPollFail  time.Time     `json:"last_poll_fail,omitempty"`
 	PollCount     int           `json:"-"` // should be zero at startup
+	// BackoffSince is when polling started backing off after failed
+	// polls and NextPoll is the next attempt.  Both are zero when polling is normal.
+	BackoffSince time.Time `json:"-"`
+	NextPoll     time.Time `json:"-"`
 
 	// All the fields are populated by the system
 	ServerName   string `json:"server_name,omitempty"` // ServerName holds @@SERVERNAME
@@ -111,13 +127,13 @@ type SqlServer struct {
 	SqlServerMemoryKB int64             `json:"sql_server_memory_kb,omitempty"`
 	MaxMemoryKB       int64             `json:"max_memory_kb,omitempty"`
 	MemoryStateDesc   string            `json:"memory_state_desc,omitempty"`
-	CPUUsage          cpuring.Ring      `json:"cpu_usage,omitempty"`
+	CPUUsage          cpuring.Ring      `json:"-"` // saved by snapshotRings
 	LastCpu           int               `json:"last_cpu,omitempty"`
 	LastSQLCPU        int               `json:"last_sqlcpu,omitempty"`
 	CoresUsedSQL      float32           `json:"cores_used_sql,omitempty"`
 	CoresUsedOther    float32           `json:"cores_used_other,omitempty"`
 	Databases         map[int]*Database `json:"databases,omitempty"`
-	Metrics           map[string]Metric `json:"metrics,omitempty"`
+	Metrics           map[string]Metric `json:"-"` // saved by snapshotRings
 	Snapshots         []Snapshot        `json:"snapshots,omitempty"`
 
 	ProductLevel       string    `json:"product_level,omitempty"`
@@ -131,6 +147,7 @@ type SqlServer struct {
 	PLE                int64     `json:"ple,omitempty"`
 	SqlPerSecond       int64     `json:"sql_per_second,omitempty"`
 	LastWaits          *waitmap.Waits
+	WaitHistory        WaitRing `json:"-"` // saved with the other rings in the store
 
 	SortPriority int `json:"sort_priority,omitempty"` // higher values end up higher in the list
 
@@ -148,6 +165,7 @@ type SqlServer struct {
 	LastBackupPoll    time.Time                   `json:"last_backup_poll,omitempty"`
 	IgnoreBackups     bool                        `json:"ignore_backups,omitempty"`
 	IgnoreBackupsList []string                    `json:"ignore_backups_list,omitempty"`
+	Repository        *bool                       `json:"repository,omitempty"` // overrides the repository tag filter
 
 	OSName      string      `json:"os_name"`
 	OSArch      string      `json:"os_arch"`
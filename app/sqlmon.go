@@ -20,6 +20,7 @@ import (
 	"github.com/scalesql/isitsql/internal/fileio"
 	"github.com/scalesql/isitsql/internal/hadr"
 	"github.com/scalesql/isitsql/internal/settings"
+	"github.com/scalesql/isitsql/internal/tier"
 	"github.com/scalesql/isitsql/internal/waitmap"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/slices"
@@ -158,6 +159,9 @@ func PushC2Servers(c2map c2.ConfigMaps) error {
 		agn := []string{k.Domain, k.Name, v}
 		agNames = append(agNames, agn)
 	}
+	setMaintenanceWindows(c2map.Maintenance)
+	setCustomMetrics(c2map.Metrics)
+
 	n, dirty, err := hadr.PublicAGMap.SetAGNames(agNames)
 	if err != nil {
 		WinLogln(errors.Wrap(err, "agmap.setagnames"))
@@ -216,6 +220,7 @@ func PushC2Servers(c2map c2.ConfigMaps) error {
 		server.Tags = v.Tags
 		server.IgnoreBackups = v.IgnoreBackups
 		server.IgnoreBackupsList = v.IgnoreBackupsList
+		server.Repository = v.Repository
 		srv, exists := servers.CloneOne(k)
 
 		if exists {
@@ -224,7 +229,8 @@ func PushC2Servers(c2map c2.ConfigMaps) error {
 				srv.CredentialKey != server.CredentialKey ||
 				!slices.Equal(srv.Tags, server.Tags) ||
 				srv.IgnoreBackups != server.IgnoreBackups ||
-				!slices.Equal(srv.IgnoreBackupsList, server.IgnoreBackupsList) {
+				!slices.Equal(srv.IgnoreBackupsList, server.IgnoreBackupsList) ||
+				!equalBool(srv.Repository, server.Repository) {
 				err = servers.UpdateFromSettings(k, server)
 				if err != nil {
 					WinLogln(err)
@@ -262,7 +268,13 @@ func setupAsync() error {
 		return errors.Wrap(err, "settings.setupconfigdir")
 	}
 
-	// Read the settings file.  We don't need it now but can't continue without it
+	// the server cache, rings, and settings are kept in cache/isitsql.db
+	err = setupStore()
+	if err != nil {
+		WinLogErr(errors.Wrap(err, "setupstore"))
+	}
+
+	// Read the settings.  We don't need them now but can't continue without them
 	s, err := settings.ReadConfig()
 	if err != nil {
 		return errors.Wrap(err, "readconfig")
@@ -304,7 +316,7 @@ func setupAsync() error {
 	globalStats.ClientGUID = s.ClientGUID
 	globalStats.Unlock()
 
-	// We only save so that we'll get any defaults if the file doesn't exist
+	// We only save so that we'll get any defaults if they don't exist
 	err = s.Save()
 	if err != nil {
 		return errors.Wrap(err, "saveconfig")
@@ -316,6 +328,24 @@ func setupAsync() error {
 		WinLogErr(errors.Wrap(err, "setuprepository"))
 	}
 
+	// push metrics to an OpenTelemetry collector
+	err = setupOTLP()
+	if err != nil {
+		WinLogErr(errors.Wrap(err, "setupotlp"))
+	}
+
+	// setup the webhooks for alerts
+	err = setupNotifications()
+	if err != nil {
+		WinLogErr(errors.Wrap(err, "setupnotifications"))
+	}
+
+	// read the threshold rules
+	err = setupThresholds()
+	if err != nil {
+		WinLogErr(errors.Wrap(err, "setupthresholds"))
+	}
+
 	if getGlobalConfig().EnableProfiler {
 		WinLogln("pprof enabled on http://localhost:6060/debug/pprof/ ")
 		go func() {
@@ -330,12 +360,34 @@ func setupAsync() error {
 	// }
 
 	// clean up old log entries
-	err = bucket.PurgeFiles("log", "isitsql", "log", 24*90*time.Hour)
+	err = bucket.PurgeFiles("log", "isitsql", "log", 24*90*time.Hour, 0)
 	if err != nil {
 		return errors.Wrap(err, "bucket.purgefiles")
 	}
 
-	DynamicWaitRepository, err = dwaits.NewRepository(context.Background())
+	bucket.Build = version // written in the header of the cache files
+
+	// the [cache] settings compress and limit the wait files
+	// and [history] sets how long the CPU and metric charts go back
+	// and [polling] limits how many servers poll at once
+	var cacheOptions bucket.Options
+	tc, err := readTOMLConfig()
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "readtomlconfig"))
+	} else {
+		cacheOptions = tc.Cache
+		tier.SetHours(tc.History.Hours)
+		if tc.Polling.MaxConcurrent > 0 {
+			pollScheduler.SetWorkers(tc.Polling.MaxConcurrent)
+		}
+		if tc.Polling.MaxBackoffMinutes > 0 {
+			maxPollBackoff = time.Duration(tc.Polling.MaxBackoffMinutes) * time.Minute
+		}
+	}
+	logrus.Debugf("history: window: %s", tier.Window())
+	logrus.Debugf("polling: workers: %d  max backoff: %s", pollScheduler.Workers(), maxPollBackoff)
+	pollScheduler.Start(pollContext)
+	DynamicWaitRepository, err = dwaits.NewRepository(context.Background(), cacheOptions)
 	if err != nil {
 		logrus.Error(errors.Wrap(err, "dwaits.newrepository"))
 	}
@@ -408,7 +460,7 @@ func setupConnectionsAsync() error {
 		return errors.Wrap(err, "readconnections")
 	}
 
-	// We only save so that we'll get any defaults if the file doesn't exist
+	// We only save so that we'll get any defaults if they don't exist
 	err = stgSQL.Save()
 	if err != nil {
 		return errors.Wrap(err, "stgsql.save")
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/alert"
+	"github.com/scalesql/isitsql/internal/notify"
+)
+
+// setupNotifications configures the webhooks and email from isitsql.toml
+// and sends alert transitions to them
+func setupNotifications() error {
+	config, err := readTOMLConfig()
+	if err != nil {
+		return err
+	}
+	for _, cfg := range config.Webhooks {
+		wh, err := notify.NewWebhook(cfg)
+		if err != nil {
+			WinLogErr(errors.Wrap(err, "notify.newwebhook"))
+			continue
+		}
+		GlobalNotifier.Add(wh)
+		WinLogf("NOTIFY: webhook: %s", wh.Name())
+	}
+	if config.SMTP.Host != "" {
+		err = setupEmail(config.SMTP)
+		if err != nil {
+			WinLogErr(errors.Wrap(err, "setupemail"))
+		}
+	}
+	if GlobalNotifier.Count() == 0 {
+		return nil
+	}
+	go GlobalNotifier.Run(context.Background())
+	AlertEngine.Subscribe(func(a alert.Alert) {
+		GlobalNotifier.Notify(alertPayload(a))
+	})
+	return nil
+}
+
+// setupEmail adds the SMTP sender.  The login comes from a saved credential.
+func setupEmail(cfg notify.SMTPConfig) error {
+	user, pwd, err := lookupCredential(cfg.Credential)
+	if err != nil {
+		return err
+	}
+	cfg.Username = user
+	cfg.Password = pwd
+	email, err := notify.NewEmail(cfg)
+	if err != nil {
+		return errors.Wrap(err, "notify.newemail")
+	}
+	GlobalNotifier.Add(email)
+	msg := fmt.Sprintf("NOTIFY: %s to=%s starttls=%t", email.Name(), strings.Join(cfg.To, ","), cfg.StartTLS)
+	if email.Interval() > 0 {
+		msg += fmt.Sprintf(" digest=%s", email.Interval())
+	}
+	WinLogf(msg)
+	return nil
+}
+
+// alertPayload converts an alert transition to a notification
+func alertPayload(a alert.Alert) notify.Payload {
+	return notify.Payload{
+		ServerKey:   a.Entity,
+		DisplayName: a.DisplayName,
+		Condition:   a.Condition,
+		Target:      a.Target,
+		Severity:    string(a.Severity),
+		State:       string(a.State),
+		FirstSeen:   a.FirstSeen,
+		LastSeen:    a.LastSeen,
+		ResolvedAt:  a.ResolvedAt,
+		Message:     a.Message,
+	}
+}
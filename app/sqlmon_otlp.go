@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/build"
+	"github.com/scalesql/isitsql/internal/otlp"
+	"github.com/scalesql/isitsql/internal/waitmap"
+	"github.com/scalesql/isitsql/internal/waitring"
+)
+
+// otlpUnits are the units of the values written to the repository
+var otlpUnits = map[string]string{
+	"cpu_cores":             "{cpu}",
+	"cpu_sql_pct":           "%",
+	"cpu_other_pct":         "%",
+	"batches_per_second":    "{batch}/s",
+	"page_life_expectancy":  "s",
+	"memory_used_mb":        "MiBy",
+	"disk_read_iops":        "{operation}/s",
+	"disk_write_iops":       "{operation}/s",
+	"disk_read_kb_sec":      "KiBy/s",
+	"disk_write_kb_sec":     "KiBy/s",
+	"disk_read_latency_ms":  "ms",
+	"disk_write_latency_ms": "ms",
+}
+
+// setupOTLP reads the [otlp] section of isitsql.toml and starts pushing metrics
+func setupOTLP() error {
+	config, err := readTOMLConfig()
+	if err != nil {
+		return err
+	}
+	if config.OTLP.Endpoint == "" {
+		return nil
+	}
+	exporter, err := otlp.New(config.OTLP, build.Version())
+	if err != nil {
+		return errors.Wrap(err, "otlp.new")
+	}
+	GlobalOTLP = exporter
+	go exporter.Run(context.Background())
+	WinLogf("OTLP: endpoint='%s' interval=%s", exporter.URL(), exporter.Interval())
+	return nil
+}
+
+// otlpPoints converts the values we write to the repository into OTLP points.
+// Each metric is named mssql.<column> and the waits are mssql.waits.server
+// and mssql.waits.request with a wait_group attribute.
+func otlpPoints(ts time.Time, mm map[string]any, requestWaits waitring.WaitList, serverWaits *waitmap.Waits) []otlp.Point {
+	attrs := map[string]string{
+		"server_key":  toString(mm["server_key"]),
+		"server_name": toString(mm["server_name"]),
+	}
+	points := make([]otlp.Point, 0, len(mm)+len(requestWaits.Waits))
+	for k, v := range mm {
+		f, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		points = append(points, otlp.Point{Name: "mssql." + k, Unit: otlpUnits[k], TS: ts, Attributes: attrs, Value: f})
+	}
+	waitPoints := func(name string, ts time.Time, waits map[string]int64) {
+		for wg, ms := range waits {
+			points = append(points, otlp.Point{
+				Name:       name,
+				Unit:       "ms",
+				TS:         ts,
+				Attributes: map[string]string{"server_key": attrs["server_key"], "server_name": attrs["server_name"], "wait_group": wg},
+				Value:      float64(ms),
+			})
+		}
+	}
+	if !requestWaits.TS.IsZero() {
+		waitPoints("mssql.waits.request", requestWaits.TS, requestWaits.Waits)
+	}
+	if serverWaits != nil {
+		waitPoints("mssql.waits.server", serverWaits.EventTime, serverWaits.WaitSummary)
+	}
+	return points
+}
+
+// toFloat converts the numeric values from the repository map
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toString(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
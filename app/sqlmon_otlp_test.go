@@ -0,0 +1,41 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/otlp"
+	"github.com/scalesql/isitsql/internal/waitmap"
+	"github.com/scalesql/isitsql/internal/waitring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPPoints(t *testing.T) {
+	assert := assert.New(t)
+	ts := time.Unix(1700000000, 0)
+	mm := map[string]any{
+		"server_key":           "srv1",
+		"server_name":          "SQL01",
+		"server_start":         ts.Add(-time.Hour),
+		"cpu_sql_pct":          40,
+		"page_life_expectancy": int64(300),
+	}
+	request := waitring.WaitList{TS: ts, Waits: map[string]int64{"CPU": 1500}}
+	server := &waitmap.Waits{EventTime: ts, WaitSummary: map[string]int64{"Disk IO": 2500}}
+
+	points := otlpPoints(ts, mm, request, server)
+	require.Len(t, points, 4)
+	byName := make(map[string]otlp.Point)
+	for _, p := range points {
+		byName[p.Name] = p
+	}
+	assert.Equal(40.0, byName["mssql.cpu_sql_pct"].Value)
+	assert.Equal("%", byName["mssql.cpu_sql_pct"].Unit)
+	assert.Equal("SQL01", byName["mssql.page_life_expectancy"].Attributes["server_name"])
+	assert.Equal("CPU", byName["mssql.waits.request"].Attributes["wait_group"])
+	assert.Equal(2500.0, byName["mssql.waits.server"].Value)
+
+	// no waits yet
+	assert.Len(otlpPoints(ts, mm, waitring.WaitList{}, nil), 2)
+}
@@ -7,78 +7,121 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/pelletier/go-toml/v2"
+	"github.com/kardianos/osext"
 	"github.com/pkg/errors"
 	"github.com/scalesql/isitsql/internal/mrepo"
 	"github.com/scalesql/isitsql/internal/settings"
 	"github.com/sirupsen/logrus"
 )
 
-// setupRepository sets up the SQL Server database repository
+// setupRepository sets up the metric repository database.
+// The driver picks SQL Server, PostgreSQL, or SQLite.
 func setupRepository() error {
-	exe, err := os.Executable()
+	config, err := readTOMLConfig()
 	if err != nil {
-		return errors.Wrap(err, "os.executable")
-	}
-	wd := filepath.Dir(exe)
-	fileName := filepath.Join(wd, "isitsql.toml")
-
-	// if the file doesn't exist, then we are done
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		return nil // no config file, so nothing to do
-	}
-
-	// Read the file
-	fileBody, err := os.ReadFile(fileName)
-	if err != nil {
-		return errors.Wrap(err, "os.readfile")
-	}
-	var config IsItSQLTOML
-	err = toml.Unmarshal(fileBody, &config)
-	if err != nil {
-		return errors.Wrap(err, "toml.unmarshal")
+		return err
 	}
+	rc := config.Repository
 
 	// if there are no repository settings, then we are done
-	if config.Repository.Host == "" && config.Repository.Database == "" && config.Repository.Credential == "" {
+	if rc.Driver == "" && rc.Host == "" && rc.Database == "" && rc.Credential == "" {
 		return nil
 	}
-	if config.Repository.Host == "" {
+	backend, err := mrepo.NewBackend(rc.Driver)
+	if err != nil {
+		return errors.Wrap(err, "toml")
+	}
+	if backend.Name() != mrepo.DriverSQLite && rc.Host == "" {
 		return errors.New("toml: host missing")
 	}
-	if config.Repository.Database == "" {
+	if rc.Database == "" {
 		return errors.New("toml: database missing")
 	}
 
-	var user, pwd string
-	if config.Repository.Credential != "" { // we have a credential
-		credName := config.Repository.Credential
-		conns, err := settings.ReadConnectionsDecrypted()
+	// the SQLite file is relative to the EXE
+	if backend.Name() == mrepo.DriverSQLite && !filepath.IsAbs(rc.Database) {
+		exe, err := os.Executable()
 		if err != nil {
-			return errors.Wrap(err, "settings.readconnections")
-		}
-		found := false
-		for _, cred := range conns.SQLCredentials {
-			if strings.EqualFold(credName, cred.Name) {
-				found = true
-				user = cred.Login
-				pwd = cred.Password
-			}
-		}
-		if !found {
-			return fmt.Errorf("credential not found: %s", credName)
+			return errors.Wrap(err, "os.executable")
 		}
+		rc.Database = filepath.Join(filepath.Dir(exe), rc.Database)
 	}
 
-	repository, err := mrepo.NewRepository(config.Repository.Host, config.Repository.Database, user, pwd, logrus.WithContext(context.Background()), &GLOBAL_RINGLOG)
+	filter := mrepo.Filter{Include: rc.IncludeTags, Exclude: rc.ExcludeTags}
+	if err = filter.Validate(); err != nil {
+		return errors.Wrap(err, "toml")
+	}
+
+	user, pwd, err := lookupCredential(rc.Credential)
+	if err != nil {
+		return err
+	}
+
+	// rows are spooled under the cache folder while the repository is down
+	wd, err := osext.ExecutableFolder()
+	if err != nil {
+		return errors.Wrap(err, "osext.executablefolder")
+	}
+	if rc.SpoolMaxMB <= 0 {
+		rc.SpoolMaxMB = 256
+	}
+
+	cfg := mrepo.Config{
+		Driver:   backend.Name(),
+		Host:     rc.Host,
+		Database: rc.Database,
+		User:     user,
+		Password: pwd,
+		Options:  rc.Options,
+
+		SpoolDir:   filepath.Join(wd, "cache", "repository"),
+		SpoolMaxMB: rc.SpoolMaxMB,
+
+		RetentionDays:       rc.RetentionDays,
+		HourlyRetentionDays: rc.HourlyRetentionDays,
+		DailyRetentionDays:  rc.DailyRetentionDays,
+
+		Filter: filter,
+	}
+	repository, err := mrepo.NewRepository(cfg, logrus.WithContext(context.Background()), &GLOBAL_RINGLOG)
 	GlobalRepository = repository // set it with whatever we have
 	if err != nil {
 		return errors.Wrap(err, "mrepo.newrepository") // but log the error
 	}
-	msg := fmt.Sprintf("REPOSITORY: host='%s' database='%s'", config.Repository.Host, config.Repository.Database)
+	msg := fmt.Sprintf("REPOSITORY: driver=%s database='%s'", backend.Name(), rc.Database)
+	if rc.Host != "" {
+		msg = fmt.Sprintf("REPOSITORY: driver=%s host='%s' database='%s'", backend.Name(), rc.Host, rc.Database)
+	}
 	if user != "" {
 		msg += fmt.Sprintf(" user=%s", user)
 	}
+	if rc.RetentionDays > 0 {
+		msg += fmt.Sprintf(" retention_days=%d", rc.RetentionDays)
+	}
+	if len(rc.IncludeTags) > 0 {
+		msg += fmt.Sprintf(" include_tags=%s", strings.Join(rc.IncludeTags, ","))
+	}
+	if len(rc.ExcludeTags) > 0 {
+		msg += fmt.Sprintf(" exclude_tags=%s", strings.Join(rc.ExcludeTags, ","))
+	}
 	WinLogf(msg)
 	return nil
 }
+
+// lookupCredential returns the login and password for a saved credential.
+// An empty name returns empty values.
+func lookupCredential(credName string) (string, string, error) {
+	if credName == "" {
+		return "", "", nil
+	}
+	conns, err := settings.ReadConnectionsDecrypted()
+	if err != nil {
+		return "", "", errors.Wrap(err, "settings.readconnections")
+	}
+	for _, cred := range conns.SQLCredentials {
+		if strings.EqualFold(credName, cred.Name) {
+			return cred.Login, cred.Password, nil
+		}
+	}
+	return "", "", fmt.Errorf("credential not found: %s", credName)
+}
@@ -2,36 +2,22 @@ package app
 
 import (
 	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
-	"github.com/kardianos/osext"
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/store"
 )
 
 // GetCachedServer hydrates a server from cache.  It logs most errors
 // and returns an empty SqlServer
 func GetCachedServer(key string) SqlServer {
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		WinLogln(errors.Wrap(err, "executableFolder"))
-		return SqlServer{}
-	}
-
-	dir := filepath.Join(wd, "cache")
-	fileName := filepath.Join(dir, fmt.Sprintf("server.%s.json", key))
-
-	// if the file doesn't exist, then return emty SqlServer with no error
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+	// if the key doesn't exist, then return emty SqlServer with no error
+	fileBody, err := GlobalStore.Get(store.BucketServers, key)
+	if errors.Is(err, store.ErrNotFound) {
 		return SqlServer{}
 	}
-
-	/* #nosec G304 */
-	fileBody, err := os.ReadFile(fileName)
 	if err != nil {
-		WinLogln(errors.Wrapf(err, "os.readfile: %s", key))
+		WinLogln(errors.Wrapf(err, "store.get: %s", key))
 		return SqlServer{}
 	}
 
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/cpuring"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/scalesql/isitsql/internal/metricvaluering"
+	"github.com/scalesql/isitsql/internal/store"
+	"github.com/scalesql/isitsql/internal/tier"
+	"github.com/scalesql/isitsql/internal/waitmap"
+	"github.com/sirupsen/logrus"
+)
+
+// ringsVersion is the version of the ring snapshots
+const ringsVersion = 1
+
+// ringRetention is how far back restored waits are kept.  The CPU and
+// metrics are kept for the history window.
+const ringRetention = time.Hour
+
+// ringSaveEvery is how often the rings are saved while running
+const ringSaveEvery = 5 * time.Minute
+
+// ringSnapshot holds the in-memory rings of one server.  It is saved to
+// the rings bucket of the store on a timer and at shutdown and read at startup.
+// The dynamic waits aren't included.  They are restored from the bucket files.
+type ringSnapshot struct {
+	Version int                       `json:"version"`
+	Key     string                    `json:"key"`
+	Saved   time.Time                 `json:"saved"`
+	CPU     []*cpuring.CPU            `json:"cpu,omitempty"`
+	Metrics map[string]metricSnapshot `json:"metrics,omitempty"`
+	Waits   []*waitmap.Waits          `json:"waits,omitempty"`
+}
+
+type metricSnapshot struct {
+	Accumulating bool                           `json:"accumulating,omitempty"`
+	Values       []*metricvaluering.MetricValue `json:"values,omitempty"`
+}
+
+// snapshotRings copies the rings.  The caller should hold the lock
+// until the snapshot is marshalled since the values are shared.
+func (s *SqlServer) snapshotRings() ringSnapshot {
+	rs := ringSnapshot{
+		Version: ringsVersion,
+		Key:     s.MapKey,
+		Saved:   time.Now(),
+		CPU:     s.CPUUsage.History(time.Time{}),
+		Metrics: make(map[string]metricSnapshot, len(s.Metrics)),
+		Waits:   s.WaitHistory.Values(),
+	}
+	for name, m := range s.Metrics {
+		rs.Metrics[name] = metricSnapshot{Accumulating: m.Accumulating, Values: m.V2.History(time.Time{})}
+	}
+	return rs
+}
+
+// restoreRings replaces the rings with the points in the snapshot after since.
+// The older points flow back into the tiers as they are added.
+func (s *SqlServer) restoreRings(rs ringSnapshot, since time.Time) {
+	waitsSince := time.Now().Add(-ringRetention)
+	if since.After(waitsSince) {
+		waitsSince = since
+	}
+	var cpu cpuring.Ring
+	for _, v := range rs.CPU {
+		if v != nil && v.At.After(since) {
+			cpu.Enqueue(v)
+		}
+	}
+	s.CPUUsage = cpu
+
+	s.Metrics = make(map[string]Metric, len(rs.Metrics))
+	for name, ms := range rs.Metrics {
+		m := Metric{Accumulating: ms.Accumulating}
+		for _, v := range ms.Values {
+			if v != nil && v.EventTime.After(since) {
+				m.V2.Enqueue(v)
+			}
+		}
+		// an empty metric is filled in on the first poll
+		if m.V2.GetLastValue() != nil {
+			s.Metrics[name] = m
+		}
+	}
+
+	var waits WaitRing
+	for _, v := range rs.Waits {
+		if v != nil && v.EventTime.After(waitsSince) {
+			waits.Enqueue(v)
+		}
+	}
+	s.WaitHistory = waits
+}
+
+// readRings reads the snapshot for a server.  It logs most errors
+// and returns false if there isn't a snapshot to use.
+func readRings(key string) (ringSnapshot, bool) {
+	var rs ringSnapshot
+	bb, err := GlobalStore.Get(store.BucketRings, key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			WinLogln(errors.Wrapf(err, "store.get: %s", key))
+		}
+		return rs, false
+	}
+	err = json.Unmarshal(bb, &rs)
+	if err != nil {
+		WinLogln(errors.Wrapf(err, "json.unmarshal: %s", key))
+		return rs, false
+	}
+	if rs.Version > ringsVersion {
+		WinLogln(fmt.Sprintf("rings: %s: unsupported version: %d", key, rs.Version))
+		return rs, false
+	}
+	return rs, true
+}
+
+// loadRings restores the rings for a new server.  Without a snapshot
+// it keeps the rings from the server cache and reads the older wait files.
+func (s *SqlServer) loadRings(key string) {
+	rs, ok := readRings(key)
+	if !ok {
+		rs = s.snapshotRings()
+		legacy, err := waitmap.ReadWaitFiles(key)
+		if err != nil {
+			logrus.Error(errors.Wrapf(err, "rings: %s: waitmap.readwaitfiles", key))
+		}
+		for i := range legacy {
+			rs.Waits = append(rs.Waits, nonZeroWaits(legacy[i]))
+		}
+	}
+	s.restoreRings(rs, time.Now().Add(-max(tier.Window(), ringRetention)))
+	err := waitmap.PurgeWaitFiles(key, 0)
+	if err != nil {
+		logrus.Error(errors.Wrapf(err, "rings: %s: waitmap.purgewaitfiles", key))
+	}
+}
+
+// saveRings writes the snapshot for a server
+func (sw *SqlServerWrapper) saveRings() error {
+	sw.RLock()
+	key := sw.MapKey
+	bb, err := json.Marshal(sw.snapshotRings())
+	sw.RUnlock()
+	if err != nil {
+		return errors.Wrap(err, "json.marshal")
+	}
+	err = GlobalStore.Put(store.BucketRings, key, bb)
+	if err != nil {
+		return errors.Wrap(err, "store.put")
+	}
+	return nil
+}
+
+// removeCache removes the cached server and rings for a deleted server
+func removeCache(key string) error {
+	err := GlobalStore.Delete(store.BucketServers, key)
+	if err != nil {
+		return errors.Wrap(err, "store.delete")
+	}
+	err = GlobalStore.Delete(store.BucketRings, key)
+	if err != nil {
+		return errors.Wrap(err, "store.delete")
+	}
+	return nil
+}
+
+// SaveRings writes the snapshot for every server
+func (list *ServerList) SaveRings() {
+	start := time.Now()
+	pointers := list.Pointers()
+	for _, sw := range pointers {
+		err := sw.saveRings()
+		if err != nil {
+			logrus.Error(errors.Wrap(err, "saverings"))
+		}
+	}
+	logrus.Debugf("rings: saved: %d (%s)", len(pointers), time.Since(start))
+}
+
+// launchRingSaver saves the rings on a timer
+func launchRingSaver() {
+	defer failure.HandlePanic()
+	ticker := time.NewTicker(ringSaveEvery)
+	for range ticker.C {
+		servers.SaveRings()
+	}
+}
+
+// cpuPointBytes and metricPointBytes are the memory for one point and its pointer
+const (
+	cpuPointBytes    = int64(unsafe.Sizeof(cpuring.CPU{}) + unsafe.Sizeof(uintptr(0)))
+	metricPointBytes = int64(unsafe.Sizeof(metricvaluering.MetricValue{}) + unsafe.Sizeof(uintptr(0)))
+)
+
+// HistoryPoints returns the number of CPU and metric points in memory
+func (s *SqlServer) HistoryPoints() int {
+	n := s.CPUUsage.Points()
+	for _, m := range s.Metrics {
+		n += m.V2.Points()
+	}
+	return n
+}
+
+// HistoryKB returns the memory the CPU and metric rings use when they are full.
+// The tiers are fixed size so this doesn't grow with time.
+func (s *SqlServer) HistoryKB() int64 {
+	bytes := int64(s.CPUUsage.Size()) * cpuPointBytes
+	for _, m := range s.Metrics {
+		bytes += int64(m.V2.Size()) * metricPointBytes
+	}
+	return bytes / 1024
+}
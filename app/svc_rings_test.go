@@ -0,0 +1,77 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/cpuring"
+	"github.com/scalesql/isitsql/internal/metricvaluering"
+	"github.com/scalesql/isitsql/internal/waitmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	var s SqlServer
+	s.MapKey = "key1"
+	s.Metrics = make(map[string]Metric)
+	var m Metric
+	for _, ago := range []time.Duration{90 * time.Minute, 30 * time.Minute, time.Minute} {
+		s.CPUUsage.Enqueue(&cpuring.CPU{At: now.Add(-ago), SQL: 10})
+		m.V2.Enqueue(&metricvaluering.MetricValue{EventTime: now.Add(-ago), Value: 5})
+		s.WaitHistory.Enqueue(&waitmap.Waits{EventTime: now.Add(-ago), WaitSummary: map[string]int64{"CPU": 1}})
+	}
+	m.Accumulating = true
+	s.Metrics["sql"] = m
+	s.Metrics["old"] = Metric{}
+
+	bb, err := json.Marshal(s.snapshotRings())
+	require.NoError(t, err)
+	var rs ringSnapshot
+	require.NoError(t, json.Unmarshal(bb, &rs))
+	assert.Equal("key1", rs.Key)
+	assert.Equal(ringsVersion, rs.Version)
+
+	// the rings are only saved in the snapshot
+	bb, err = json.Marshal(s)
+	require.NoError(t, err)
+	assert.NotContains(string(bb), "cpu_usage")
+	assert.NotContains(string(bb), `"metrics"`)
+
+	var restored SqlServer
+	restored.restoreRings(rs, now.Add(-ringRetention))
+	assert.Equal(2, restored.CPUUsage.Len())
+	sql, ok := restored.Metrics["sql"]
+	assert.True(ok)
+	assert.True(sql.Accumulating)
+	assert.Len(sql.V2.Values(), 2)
+	_, ok = restored.Metrics["old"]
+	assert.False(ok)
+	assert.Len(restored.WaitHistory.Values(), 2)
+
+	// a longer history window keeps the older CPU and metrics but not the waits
+	var longer SqlServer
+	longer.restoreRings(rs, now.Add(-3*time.Hour))
+	assert.Equal(3, longer.CPUUsage.Len())
+	sql = longer.Metrics["sql"]
+	assert.Len(sql.V2.Values(), 3)
+	assert.Len(longer.WaitHistory.Values(), 2)
+}
+
+func TestNonZeroWaits(t *testing.T) {
+	assert := assert.New(t)
+	ww := waitmap.Waits{
+		EventTime: time.Now(),
+		Waits: map[string]waitmap.Wait{
+			"a": {Wait: "a", WaitTime: 10, WaitTimeDelta: 5},
+			"b": {Wait: "b", WaitTime: 10},
+		},
+	}
+	nz := nonZeroWaits(ww)
+	assert.Len(nz.Waits, 1)
+	assert.Len(ww.Waits, 2)
+	assert.Equal(ww.EventTime, nz.EventTime)
+}
@@ -37,6 +37,8 @@ func serviceLauncher() {
 	}
 
 	go launchBatchUpdates()
+	go launchRingSaver()
+	go launchAlertEngine()
 	go launchWebServer()
 	go launchMemoryLogger()
 	go launchPProfLogger()
@@ -94,6 +96,20 @@ loop:
 				// Commented out until I find a better way to save cache files
 				// shutdown()
 
+				// cancel the queries in flight
+				StopPolling()
+
+				// save the last hour of CPU, metrics, and waits
+				servers.SaveRings()
+				if err := GlobalStore.Close(); err != nil {
+					logrus.Error(errors.Wrap(err, "store.close"))
+				}
+
+				// write or spool anything still queued for the repository
+				if err := GlobalRepository.Close(); err != nil {
+					logrus.Error(errors.Wrap(err, "repository.close"))
+				}
+
 				break loop
 			case svc.Pause:
 				WinLogln("Received Pause...")
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kardianos/osext"
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/settings"
+	"github.com/scalesql/isitsql/internal/store"
+	"github.com/sirupsen/logrus"
+)
+
+// storeImports are the files older versions wrote to the cache folder
+var storeImports = []store.Import{
+	{Bucket: store.BucketServers, Prefix: "server.", Suffix: ".json"},
+	{Bucket: store.BucketRings, Prefix: "rings.", Suffix: ".json"},
+}
+
+// setupStore opens cache/isitsql.db and moves any older cache and config
+// files into it.  If the file can't be opened, the cache is kept in memory
+// and the settings stay in the config folder.
+func setupStore() error {
+	wd, err := osext.ExecutableFolder()
+	if err != nil {
+		return errors.Wrap(err, "osext.executablefolder")
+	}
+	dir := filepath.Join(wd, "cache")
+	st, err := store.Open(filepath.Join(dir, store.FileName))
+	if err != nil {
+		return errors.Wrap(err, "store.open")
+	}
+	GlobalStore = st
+	n, err := store.Migrate(st, dir, storeImports...)
+	if err != nil {
+		return errors.Wrap(err, "store.migrate")
+	}
+	if n > 0 {
+		WinLogln(fmt.Sprintf("store: moved %d cache files into %s", n, store.FileName))
+	}
+	n, err = settings.UseStore(st)
+	if err != nil {
+		return errors.Wrap(err, "settings.usestore")
+	}
+	if n > 0 {
+		WinLogln(fmt.Sprintf("store: moved %d config files into %s", n, store.FileName))
+	}
+	logrus.Debugf("store: %s", filepath.Join(dir, store.FileName))
+	return nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/threshold"
+)
+
+// thresholdStale is how old a poll can be before we stop evaluating the rules
+const thresholdStale = 5 * time.Minute
+
+// setupThresholds reads the threshold rules from isitsql.toml
+func setupThresholds() error {
+	config, err := readTOMLConfig()
+	if err != nil {
+		return err
+	}
+	rules := make([]threshold.Rule, 0, len(config.Thresholds))
+	names := make(map[string]bool)
+	for _, r := range config.Thresholds {
+		err = r.Validate()
+		if err != nil {
+			WinLogErr(err)
+			continue
+		}
+		if names[strings.ToLower(r.Name)] {
+			WinLogErr(fmt.Errorf("threshold: duplicate name: '%s'", r.Name))
+			continue
+		}
+		names[strings.ToLower(r.Name)] = true
+		rules = append(rules, r)
+	}
+	ThresholdRules.SetRules(rules)
+	if len(rules) > 0 {
+		WinLogf("THRESHOLDS: %d rule(s)", len(rules))
+	}
+	return nil
+}
+
+// evaluateThresholds checks the rules against the latest values for each server.
+// Servers that can't be polled are skipped.
+func evaluateThresholds() {
+	if len(ThresholdRules.Rules()) == 0 {
+		return
+	}
+	for _, s := range servers.CloneAll() {
+		if s.LastPollError != "" || time.Since(s.LastPollTime) > thresholdStale {
+			ThresholdRules.Forget(s.MapKey)
+			continue
+		}
+		ThresholdRules.Evaluate(s.MapKey, s.Tags, thresholdValues(&s))
+	}
+}
+
+// thresholdValues returns the latest value of each metric that has one
+func thresholdValues(s *SqlServer) map[string]float64 {
+	values := make(map[string]float64)
+	if cpu, ok := s.CPUUsage.GetNewest(); ok && cpu != nil {
+		values[threshold.MetricCPU] = float64(cpu.SQL + cpu.Other)
+		values[threshold.MetricCPUSQL] = float64(cpu.SQL)
+		values[threshold.MetricCPUOther] = float64(cpu.Other)
+	}
+	if s.PLE > 0 {
+		values[threshold.MetricPLE] = float64(s.PLE)
+	}
+	if m, err := s.GetLastMetric("sql"); err == nil && m != nil {
+		values[threshold.MetricBatches] = float64(m.ValuePerSecond)
+	}
+	delta := s.DiskIODelta
+	if delta.Reads > 0 {
+		values[threshold.MetricReadLatency] = float64(delta.ReadStall) / float64(delta.Reads)
+	}
+	if delta.Writes > 0 {
+		values[threshold.MetricWriteLatency] = float64(delta.WriteStall) / float64(delta.Writes)
+	}
+	if s.PhysicalMemoryKB > 0 {
+		values[threshold.MetricMemoryAvailable] = float64(s.AvailableMemoryKB) * 100 / float64(s.PhysicalMemoryKB)
+	}
+	return values
+}
+
+// ThresholdResults returns the threshold rules that are in warning or critical
+func (s *SqlServer) ThresholdResults() []threshold.Result {
+	return ThresholdRules.Results(s.MapKey)
+}
+
+// ThresholdLevel returns the highest threshold level: ok, warning, or critical
+func (s *SqlServer) ThresholdLevel() string {
+	return ThresholdRules.Level(s.MapKey).String()
+}
@@ -1,9 +1,71 @@
 package app
 
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/bucket"
+	"github.com/scalesql/isitsql/internal/notify"
+	"github.com/scalesql/isitsql/internal/otlp"
+	"github.com/scalesql/isitsql/internal/threshold"
+)
+
 type IsItSQLTOML struct {
 	Repository struct {
+		Driver     string `toml:"driver"`
 		Host       string `toml:"host"`
 		Database   string `toml:"database"`
 		Credential string `toml:"credential"`
+		Options    string `toml:"options"`
+		SpoolMaxMB int    `toml:"spool_max_mb"`
+
+		RetentionDays       int `toml:"retention_days"`
+		HourlyRetentionDays int `toml:"hourly_retention_days"`
+		DailyRetentionDays  int `toml:"daily_retention_days"`
+
+		IncludeTags []string `toml:"include_tags"`
+		ExcludeTags []string `toml:"exclude_tags"`
 	} `toml:"repository"`
+	Webhooks   []notify.WebhookConfig `toml:"webhook"`
+	SMTP       notify.SMTPConfig      `toml:"smtp"`
+	Thresholds []threshold.Rule       `toml:"threshold"`
+	OTLP       otlp.Config            `toml:"otlp"`
+	Cache      bucket.Options         `toml:"cache"`
+	History    struct {
+		Hours int `toml:"hours"`
+	} `toml:"history"`
+	Polling struct {
+		MaxConcurrent     int `toml:"max_concurrent"`
+		MaxBackoffMinutes int `toml:"max_backoff_minutes"`
+	} `toml:"polling"`
+}
+
+// readTOMLConfig reads isitsql.toml in the EXE folder.
+// If the file doesn't exist, it returns an empty configuration.
+func readTOMLConfig() (IsItSQLTOML, error) {
+	var config IsItSQLTOML
+	exe, err := os.Executable()
+	if err != nil {
+		return config, errors.Wrap(err, "os.executable")
+	}
+	wd := filepath.Dir(exe)
+	fileName := filepath.Join(wd, "isitsql.toml")
+
+	// if the file doesn't exist, then we are done
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return config, nil // no config file, so nothing to do
+	}
+
+	// Read the file
+	fileBody, err := os.ReadFile(fileName)
+	if err != nil {
+		return config, errors.Wrap(err, "os.readfile")
+	}
+	err = toml.Unmarshal(fileBody, &config)
+	if err != nil {
+		return config, errors.Wrap(err, "toml.unmarshal")
+	}
+	return config, nil
 }
@@ -1,11 +1,11 @@
 package app
 
 import (
+	"context"
 	"time"
 
 	"github.com/scalesql/isitsql/internal/waitmap"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 )
 
 // **************************************************
@@ -148,17 +148,15 @@ import (
 // }
 
 // PollWaits polls the database for waits
-func (s *SqlServerWrapper) PollWaits() error {
+func (s *SqlServerWrapper) PollWaits(ctx context.Context) error {
 	var err error
 	s.RLock()
 	db := s.DB
 	reset := s.ResetOnThisPoll
 	previousWaits := s.LastWaits // we need the previous waits so we can DIFF
-	key := s.MapKey
-	pollCount := s.PollCount
 	s.RUnlock()
 
-	rows, err := db.Query("select wait_type, wait_time_ms from sys.dm_os_wait_stats where wait_time_ms > 0;")
+	rows, err := db.QueryContext(ctx, "select wait_type, wait_time_ms from sys.dm_os_wait_stats where wait_time_ms > 0;")
 	if err != nil {
 		return errors.Wrap(err, "query")
 	}
@@ -201,6 +199,7 @@ func (s *SqlServerWrapper) PollWaits() error {
 	waits.SetWaitGroups()
 	s.Lock()
 	s.LastWaits = &waits
+	s.WaitHistory.Enqueue(nonZeroWaits(waits))
 	s.Unlock()
 
 	// write the waits to the bucket
@@ -209,19 +208,22 @@ func (s *SqlServerWrapper) PollWaits() error {
 	// 	return errors.Wrap(err, "bucketwriter.write")
 	// }
 
-	// write locally
-	err = waitmap.WriteWaitFile(key, waits)
-	if err != nil {
-		logrus.Error(errors.Wrap(err, "s.writewaits"))
-	}
+	return nil
+}
 
-	// purge every 10th poll
-	if pollCount%10 == 9 {
-		err = waitmap.PurgeWaitFiles(key)
-		if err != nil {
-			logrus.Error(errors.Wrap(err, "purgewaitfiles"))
+// nonZeroWaits returns a copy of the waits without the zero deltas.
+// The wait pages only use the deltas so this is what the history keeps.
+func nonZeroWaits(ww waitmap.Waits) *waitmap.Waits {
+	nz := waitmap.Waits{
+		EventTime:   ww.EventTime,
+		Duration:    ww.Duration,
+		Waits:       make(map[string]waitmap.Wait),
+		WaitSummary: ww.WaitSummary,
+	}
+	for k, v := range ww.Waits {
+		if v.WaitTimeDelta > 0 {
+			nz.Waits[k] = v
 		}
 	}
-
-	return nil
+	return &nz
 }
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"html"
 	"html/template"
-	"math"
 	"net/http"
 	"os"
 	"os/user"
@@ -25,12 +24,16 @@ import (
 	"github.com/pkg/errors"
 	"github.com/scalesql/isitsql/internal/appringlog"
 	"github.com/scalesql/isitsql/internal/build"
+	"github.com/scalesql/isitsql/internal/collector"
+	"github.com/scalesql/isitsql/internal/custommetric"
 	"github.com/scalesql/isitsql/internal/diskio"
 	"github.com/scalesql/isitsql/internal/gui"
 	"github.com/scalesql/isitsql/internal/hadr"
 	"github.com/scalesql/isitsql/internal/logring"
 	"github.com/scalesql/isitsql/internal/mssql/session"
+	"github.com/scalesql/isitsql/internal/pollq"
 	"github.com/scalesql/isitsql/internal/settings"
+	"github.com/scalesql/isitsql/internal/tier"
 	"github.com/scalesql/isitsql/internal/waitmap"
 	"github.com/scalesql/isitsql/static"
 	"github.com/sirupsen/logrus"
@@ -196,6 +199,15 @@ func pollingPage(w http.ResponseWriter, req *http.Request) {
 		LastPollTime       time.Time
 		LastPollError      string
 		LastPollErrorClean string
+		// Failing are the collectors that failed on their last run
+		Failing []collector.Status
+		// Queued is waiting for a worker and Wait is how long
+		// the last poll waited.  Lag is how far past due it is.
+		Queued bool
+		Wait   time.Duration
+		Lag    time.Duration
+		// BackoffTitle is set while failed polls are delayed
+		BackoffTitle string
 	}
 
 	// Get the list of keys
@@ -216,6 +228,17 @@ func pollingPage(w http.ResponseWriter, req *http.Request) {
 		p.LastPollError = s.LastPollError
 		p.LastPollErrorClean = s.LastPollErrorClean(45)
 		p.LastPollTime = s.LastPollTime
+		p.BackoffTitle = s.BackoffTitle()
+		servers.RLock()
+		if sw, ok := servers.Servers[s.MapKey]; ok {
+			p.Failing = sw.FailingCollectors()
+		}
+		servers.RUnlock()
+		if st, ok := pollScheduler.Stats(s.MapKey); ok {
+			p.Queued = st.Queued
+			p.Wait = st.Wait.Round(time.Millisecond)
+			p.Lag = st.Lag.Round(time.Second)
+		}
 
 		if p.IsPolling {
 			p.PollDuration = time.Since(p.PollStart)
@@ -223,9 +246,14 @@ func pollingPage(w http.ResponseWriter, req *http.Request) {
 		polls = append(polls, p)
 	}
 
+	sum := pollScheduler.Summary()
+	sum.MaxWait = sum.MaxWait.Round(time.Millisecond)
+	sum.MaxLag = sum.MaxLag.Round(time.Second)
+
 	context := struct {
 		Context
-		Polls []poll
+		Polls     []poll
+		Scheduler pollq.Summary
 	}{
 		Context: Context{
 			Title:       "Is It SQL - Polling",
@@ -235,7 +263,8 @@ func pollingPage(w http.ResponseWriter, req *http.Request) {
 			ErrorList:   getServerErrorList(),
 			AppConfig:   getGlobalConfig(),
 		},
-		Polls: polls,
+		Polls:     polls,
+		Scheduler: sum,
 	}
 	renderFSDynamic(w, "polling", context)
 }
@@ -375,6 +404,7 @@ func logPage(w http.ResponseWriter, req *http.Request) {
 		// HeaderRight string
 		LogEvents []appringlog.RingLogEvent
 		//TagList     map[string]tag
+		Deliveries []logring.Event
 	}{
 		Context: Context{
 			Title:       "Log Events",
@@ -384,7 +414,8 @@ func logPage(w http.ResponseWriter, req *http.Request) {
 			TagList:     globalTagList.getTags(),
 			AppConfig:   getGlobalConfig(),
 		},
-		LogEvents: GLOBAL_RINGLOG.NewestValues(),
+		LogEvents:  GLOBAL_RINGLOG.NewestValues(),
+		Deliveries: GlobalNotifier.Log(),
 	}
 
 	//fmt.Println(len(context.LogEvents))
@@ -641,6 +672,15 @@ func aboutPage(w http.ResponseWriter, req *http.Request) {
 	m["Memory: HeapInUse"] = fmt.Sprintf("%v", humanize.Bytes(mem.HeapInuse))
 	m["Memory: Sys"] = fmt.Sprintf("%v", humanize.Bytes(mem.Sys))
 
+	if GlobalRepository != nil {
+		rs := GlobalRepository.Stats()
+		m["Repository: Driver"] = GlobalRepository.Driver()
+		m["Repository: Connected"] = fmt.Sprintf("%t", rs.Connected)
+		m["Repository: Queue"] = fmt.Sprintf("%d rows", rs.Queue)
+		m["Repository: Spool"] = fmt.Sprintf("%d files (%s)", rs.SpoolFiles, humanize.Bytes(uint64(rs.SpoolBytes)))
+		m["Repository: Rows"] = fmt.Sprintf("written: %d  spooled: %d  replayed: %d  dropped: %d  rejected: %d", rs.Written, rs.Spooled, rs.Replayed, rs.Dropped, rs.Rejected)
+	}
+
 	m["Version"] = build.Version()
 	m["Version: Commit"] = build.Commit()
 	m["Version: Built"] = build.Built().String()
@@ -709,12 +749,6 @@ func getServerErrorList() PageAlerts {
 	}
 
 	cfg := getGlobalConfig()
-	if cfg.AGAlertMB == 0 {
-		cfg.AGAlertMB = math.MaxInt64
-	}
-	if cfg.AGWarnMB == 0 {
-		cfg.AGWarnMB = math.MaxInt64
-	}
 
 	keys := servers.Keys()
 	for _, k := range keys {
@@ -725,7 +759,7 @@ func getServerErrorList() PageAlerts {
 			continue
 		}
 		ptr.RLock()
-		if ptr.LastPollError != "" {
+		if _, silenced := serverMaintenance(ptr.MapKey, ptr.Tags); ptr.LastPollError != "" && !silenced {
 			pa.Errors[ptr.MapKey] = PollError{
 				FriendlyName: ptr.DisplayName(),
 				InstanceName: ptr.ServerName,
@@ -749,15 +783,11 @@ func getServerErrorList() PageAlerts {
 	// Get any AG errors
 	aglist := hadr.PublicAGMap.Groups()
 	for _, ag := range aglist {
-		// compute the total send queue and redo queue
-		var send, redo int64
-		for _, r := range ag.Replicas {
-			send += r.SendQueue
-			redo += r.RedoQueue
+		send, redo, isAlert, isWarn := agAlertLevel(ag, cfg)
+		if !isAlert && !isWarn {
+			continue
 		}
-		sendMB := send / 1024
-		redoMB := redo / 1024
-		if ag.IsHealthy() && sendMB <= cfg.AGAlertMB && sendMB <= cfg.AGWarnMB && redoMB <= cfg.AGAlertMB && redoMB <= cfg.AGWarnMB {
+		if _, silenced := agMaintenance(ag); silenced {
 			continue
 		}
 		// something needs to be displayed to the user
@@ -767,8 +797,7 @@ func getServerErrorList() PageAlerts {
 			Error:        fmt.Sprintf("%s: %s  (send: %s;  redo: %s)", ag.State, ag.Health, KBToString(send), KBToString(redo)),
 			LastPollTime: ag.PollTime}
 
-		// if the AG isn't online, or we are over the Alert levels
-		if ag.State != "ONLINE" || send/1024 > cfg.AGAlertMB || redo/1024 > cfg.AGAlertMB {
+		if isAlert {
 			pa.Errors[mapKey] = pe
 		} else {
 			pa.Warnings[mapKey] = pe
@@ -896,7 +925,9 @@ func serverXEPage(w http.ResponseWriter, req *http.Request) {
 
 	var events []*xEvent
 	var err error
-	events, err = wr.getXESessions()
+	ctx, cancel := context.WithTimeout(req.Context(), 60*time.Second)
+	events, err = wr.getXESessions(ctx)
+	cancel()
 	if err != nil {
 		logrus.Error(errors.Wrap(err, "getxesessions"))
 	}
@@ -1016,10 +1047,17 @@ func newchartPage(w http.ResponseWriter, req *http.Request) {
 		Context
 		Sessions []session.Session
 		Blocking bool
+		// History is true if the repository can show a date range
+		History     bool
+		HistoryFrom string
+		HistoryTo   string
 	}
 
 	pageData.Context = getContext("Server Not Found")
 	pageData.Context.ServerPageActiveTab = "activity"
+	pageData.History = GlobalRepository != nil && GlobalRepository.Driver() != ""
+	pageData.HistoryFrom = req.URL.Query().Get("from")
+	pageData.HistoryTo = req.URL.Query().Get("to")
 
 	server := req.PathValue("server")
 	servers.RLock()
@@ -1057,16 +1095,42 @@ func newchartPage(w http.ResponseWriter, req *http.Request) {
 	renderFSDynamic(w, "server-newchart", pageData)
 }
 
+// chartSpans are the spans offered on the server page that fit in the history window
+func chartSpans() []string {
+	spans := make([]string, 0)
+	for _, hours := range []int{1, 6, 24, 72, 168} {
+		d := time.Duration(hours) * time.Hour
+		if d == time.Hour || d <= tier.Window() {
+			spans = append(spans, fmt.Sprintf("%dh", hours))
+		}
+	}
+	return spans
+}
+
 func serverPage(w http.ResponseWriter, req *http.Request) {
 
 	var pageData struct {
 		Context
 		Sessions []session.Session
 		Blocking bool
+		// History is true if the repository can show a date range
+		History     bool
+		HistoryFrom string
+		HistoryTo   string
+		// Spans are the chart spans kept in memory and Span is the one shown
+		Spans []string
+		Span  string
+		// CustomMetrics are charted below the other charts
+		CustomMetrics []custommetric.Metric
 	}
 
 	pageData.Context = getContext("Server Not Found")
 	pageData.Context.ServerPageActiveTab = "activity"
+	pageData.History = GlobalRepository != nil && GlobalRepository.Driver() != ""
+	pageData.HistoryFrom = req.URL.Query().Get("from")
+	pageData.HistoryTo = req.URL.Query().Get("to")
+	pageData.Spans = chartSpans()
+	pageData.Span = req.URL.Query().Get("span")
 
 	server := req.PathValue("server")
 	servers.RLock()
@@ -1079,6 +1143,9 @@ func serverPage(w http.ResponseWriter, req *http.Request) {
 	}
 	s := wr.CloneSqlServer()
 	pageData.OneServer = &s
+	wr.RLock()
+	pageData.CustomMetrics = wr.SqlServer.ChartedMetrics()
+	wr.RUnlock()
 
 	sessions, err := session.Get(context.Background(), wr.DB, wr.MajorVersion)
 	if err != nil {
@@ -1375,7 +1442,9 @@ func serverQueryStats(w http.ResponseWriter, req *http.Request) {
 		htmlTitle = "Is It Sql"
 	}
 
-	qs, err := wr.getQueryStats()
+	ctx, cancel := context.WithTimeout(req.Context(), 60*time.Second)
+	qs, err := wr.getQueryStats(ctx)
+	cancel()
 	if err != nil {
 		//if err.Error() != "Stmt did not create a result set" {
 		WinLogln("Error getting query stats: ", err)
@@ -1572,20 +1641,7 @@ func ServerWaitPage(w http.ResponseWriter, req *http.Request) {
 	p1.Title = s.ServerName + " Waits"
 
 	//waits := s.Waits
-	results, err := waitmap.ReadWaitFiles(key)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		WinLogln(errors.Wrap(err, "serverwaitpage").Error())
-		logrus.Error(err)
-		return
-	}
-	// logrus.Infof("results: %d (%s)", len(results), time.Since(start))
-	wr := WaitRing{}
-	//start = time.Now()
-	for _, w := range results {
-		waits := w
-		wr.Enqueue(&waits)
-	}
+	wr := s.WaitHistory
 
 	j, err := json.MarshalIndent(wr, "", "    ")
 	if err != nil {
@@ -6,8 +6,7 @@ import (
 	"time"
 
 	"github.com/scalesql/isitsql/internal/metricvaluering"
-	"github.com/scalesql/isitsql/internal/waitmap"
-	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tier"
 	"github.com/sirupsen/logrus"
 )
 
@@ -65,6 +64,12 @@ func ApiServerJson(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// chartSince returns the start of the chart for the span parameter such as 24h.
+// The rings return the averaged tiers for the older part of the span.
+func chartSince(r *http.Request) time.Time {
+	return time.Now().Add(-tier.Span(r.URL.Query().Get("span")))
+}
+
 func ApiCpu(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1.
@@ -92,9 +97,10 @@ func ApiCpu(w http.ResponseWriter, r *http.Request) {
 	//otherCpu = ChartSeries{Name: "Other CPU"}
 
 	var v int64
+	since := chartSince(r)
 
 	m.RLock()
-	cpu := m.CPUUsage.Values()
+	cpu := m.CPUUsage.History(since)
 	m.RUnlock()
 
 	for i := 0; i < len(cpu); i++ {
@@ -115,7 +121,7 @@ func ApiCpu(w http.ResponseWriter, r *http.Request) {
 	sqlMetric, sqlFound := m.Metrics["sql"]
 	m.RUnlock()
 	if sqlFound {
-		allValues = sqlMetric.V2.Values()
+		allValues = sqlMetric.V2.History(since)
 		for i := 0; i < len(allValues); i++ {
 			if allValues[i].ValuePerSecond > 0 {
 				sqlSec.Data = append(sqlSec.Data,
@@ -132,6 +138,71 @@ func ApiCpu(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dataSource)
 }
 
+// APICollectors returns how each collector last went for a server
+func APICollectors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1.
+	w.Header().Set("Content-Type", "application/json")
+
+	servers.RLock()
+	sw, ok := servers.Servers[r.PathValue("server")]
+	servers.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not Found"))
+		return
+	}
+	json.NewEncoder(w).Encode(sw.CollectorStatus())
+}
+
+// APIMetrics returns the last value of each custom metric for a server
+func APIMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1.
+	w.Header().Set("Content-Type", "application/json")
+
+	servers.RLock()
+	sw, ok := servers.Servers[r.PathValue("server")]
+	servers.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not Found"))
+		return
+	}
+	sw.RLock()
+	values := sw.SqlServer.CustomMetricValues(time.Now())
+	sw.RUnlock()
+	json.NewEncoder(w).Encode(values)
+}
+
+// APIMetric returns the chart of a custom metric.  It accepts a span like ApiCpu.
+func APIMetric(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1.
+	w.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0.
+	w.Header().Set("Expires", "0")                                         // Proxies.
+
+	servers.RLock()
+	sw, ok := servers.Servers[r.PathValue("server")]
+	servers.RUnlock()
+	cm, found := CustomMetrics.Get(r.PathValue("metric"))
+	if !ok || !found {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not Found"))
+		return
+	}
+
+	since := chartSince(r)
+	cs := ChartSeries2{Name: cm.Label(), Data: make([]ChartData2, 0)}
+	sw.RLock()
+	m, ok := sw.Metrics[cm.Key()]
+	if ok {
+		cs.GetChartData2(m, 1, since)
+	}
+	sw.RUnlock()
+
+	var dataSource ChartDataSource2
+	dataSource.Series = append(dataSource.Series, cs)
+	json.NewEncoder(w).Encode(dataSource)
+}
+
 // APIServerWaits servers up JSON waits
 func APIServerWaits(w http.ResponseWriter, r *http.Request) {
 
@@ -146,23 +217,18 @@ func APIServerWaits(w http.ResponseWriter, r *http.Request) {
 	// new waits ====================================
 	start := time.Now()
 	// results, err := globalWaitsBucket.ReadWaits(key)
-	results, err := waitmap.ReadWaitFiles(key)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		WinLogln(errors.Wrap(err, "apiserverwaits").Error())
-		logrus.Error(err)
+	servers.RLock()
+	m, ok := servers.Servers[key]
+	servers.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not Found"))
 		return
 	}
-	// logrus.Infof("results: %d (%s)", len(results), time.Since(start))
-	wr := WaitRing{}
-	//start = time.Now()
-	for _, w := range results {
-		waits := w
-		wr.Enqueue(&waits)
-	}
-
-	twg := wr.TopGroups()
-	wv := wr.Values()
+	m.RLock()
+	twg := m.WaitHistory.TopGroups()
+	wv := m.WaitHistory.Values()
+	m.RUnlock()
 	if time.Since(start) > time.Duration(100*time.Millisecond) {
 		logrus.Tracef("apiserverwaits: values: %d (%s)", len(wv), time.Since(start))
 	}
@@ -317,15 +383,16 @@ func ApiDisk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the Disk
+	since := chartSince(r)
 	m.RLock()
 	c1 := ChartSeries2{Name: "Disk Reads"}
-	c1.GetChartData2(m.Metrics["bytesread"], (1024 * 1024))
+	c1.GetChartData2(m.Metrics["bytesread"], (1024 * 1024), since)
 
 	c2 := ChartSeries2{Name: "Disk Writes"}
-	c2.GetChartData2(m.Metrics["byteswritten"], (1024 * 1024))
+	c2.GetChartData2(m.Metrics["byteswritten"], (1024 * 1024), since)
 
 	c3 := ChartSeries2{Name: "Page Life Expectancy"}
-	c3.GetChartData2(m.Metrics["ple"], 1)
+	c3.GetChartData2(m.Metrics["ple"], 1, since)
 	m.RUnlock()
 
 	var dataSource ChartDataSource2
@@ -367,7 +434,7 @@ func ApiDisk(w http.ResponseWriter, r *http.Request) {
 // 	return nil
 // }
 
-func (cs *ChartSeries2) GetChartData2(m Metric, divisor int64) error {
+func (cs *ChartSeries2) GetChartData2(m Metric, divisor int64, since time.Time) error {
 	// var v int64
 
 	if divisor == 0 {
@@ -375,7 +442,7 @@ func (cs *ChartSeries2) GetChartData2(m Metric, divisor int64) error {
 	}
 
 	//fmt.Println(m.V2.Values())
-	allValues := m.V2.Values()
+	allValues := m.V2.History(since)
 
 	//fmt.Println("**************************************************************")
 	// for i := 0; i < len(allValues); i++ {
@@ -383,7 +450,7 @@ func (cs *ChartSeries2) GetChartData2(m Metric, divisor int64) error {
 	// 	//fmt.Println(onev.EventTime, onev.PolledValue, onev.Value)
 	// }
 
-	var arrayValues [METRIC_ARRAY_SIZE]int64
+	arrayValues := make([]int64, len(allValues))
 	//var minTime int64
 	//minTime = int64(^uint(0) >> 1)
 
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/mrepo"
+	"github.com/sirupsen/logrus"
+)
+
+// HistoryDataSource is a ChartDataSource2 read from the repository
+type HistoryDataSource struct {
+	ChartDataSource2
+	Resolution string `json:"resolution"`
+	From       int64  `json:"from"`
+	To         int64  `json:"to"`
+}
+
+// APIHistory serves chart history from the repository.  The chart is cpu,
+// disk, waits (request_wait), or serverwaits (server_wait).  from and to
+// are local times such as 2025-01-02T15:04 and default to the last 24 hours.
+func APIHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1.
+	w.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0.
+	w.Header().Set("Expires", "0")                                         // Proxies.
+
+	key := r.PathValue("server")
+	servers.RLock()
+	_, ok := servers.Servers[key]
+	servers.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not Found"))
+		return
+	}
+
+	from, to, err := historyRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ds HistoryDataSource
+	switch chart := r.PathValue("chart"); chart {
+	case "cpu", "disk":
+		var points []mrepo.MetricPoint
+		points, ds.Resolution, err = GlobalRepository.MetricHistory(r.Context(), key, from, to)
+		if err == nil {
+			ds.Series = historyMetricSeries(chart, points)
+		}
+	case "waits", "serverwaits":
+		table := "request_wait"
+		if chart == "serverwaits" {
+			table = "server_wait"
+		}
+		var points []mrepo.WaitPoint
+		points, ds.Resolution, err = GlobalRepository.WaitHistory(r.Context(), table, key, from, to)
+		if err == nil {
+			ds.Series = historyWaitSeries(points, 5)
+		}
+	default:
+		http.Error(w, "invalid chart", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, mrepo.ErrNoRepository) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "apihistory"))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ds.From = from.Unix() * 1000
+	ds.To = to.Unix() * 1000
+	json.NewEncoder(w).Encode(ds)
+}
+
+// historyRange parses the from and to values.  Missing values are the last 24 hours.
+func historyRange(fromValue, toValue string, now time.Time) (time.Time, time.Time, error) {
+	parse := func(s string) (time.Time, error) {
+		for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02"} {
+			t, err := time.ParseInLocation(layout, s, time.Local)
+			if err == nil {
+				return t, nil
+			}
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, errors.Errorf("invalid time: %s", s)
+		}
+		return t, nil
+	}
+	to := now
+	if toValue != "" {
+		t, err := parse(toValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromValue != "" {
+		t, err := parse(fromValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+// historyMetricSeries returns the same series as ApiCpu or ApiDisk
+func historyMetricSeries(chart string, points []mrepo.MetricPoint) []ChartSeries2 {
+	type column struct {
+		name    string
+		key     string
+		divisor int64
+	}
+	columns := []column{
+		{"Other CPU", "cpu_other_pct", 1},
+		{"SQL CPU", "cpu_sql_pct", 1},
+		{"SQL per Second", "batches_per_second", 1},
+	}
+	if chart == "disk" {
+		columns = []column{
+			{"Disk Reads", "disk_read_kb_sec", 1024},
+			{"Disk Writes", "disk_write_kb_sec", 1024},
+			{"Page Life Expectancy", "page_life_expectancy", 1},
+		}
+	}
+	series := make([]ChartSeries2, len(columns))
+	for i, col := range columns {
+		series[i] = ChartSeries2{Name: col.name, Data: make([]ChartData2, 0, len(points))}
+		for _, pt := range points {
+			d := ChartData2{X: pt.TS.Unix() * 1000}
+			if v, ok := pt.Values[col.key]; ok {
+				y := v / col.divisor
+				d.Y = &y
+			}
+			series[i].Data = append(series[i].Data, d)
+		}
+	}
+	return series
+}
+
+// historyWaitSeries returns the top wait groups by total time, largest first
+func historyWaitSeries(points []mrepo.WaitPoint, top int) []ChartSeries2 {
+	totals := make(map[string]int64)
+	for _, pt := range points {
+		for wg, sec := range pt.Waits {
+			totals[wg] += sec
+		}
+	}
+	groups := make([]string, 0, len(totals))
+	for wg := range totals {
+		groups = append(groups, wg)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if totals[groups[i]] == totals[groups[j]] {
+			return groups[i] < groups[j]
+		}
+		return totals[groups[i]] > totals[groups[j]]
+	})
+	if len(groups) > top {
+		groups = groups[:top]
+	}
+	series := make([]ChartSeries2, len(groups))
+	for i, wg := range groups {
+		series[i] = ChartSeries2{Name: wg, Data: make([]ChartData2, 0, len(points))}
+		for _, pt := range points {
+			y := pt.Waits[wg]
+			series[i].Data = append(series[i].Data, ChartData2{X: pt.TS.Unix() * 1000, Y: &y})
+		}
+	}
+	return series
+}
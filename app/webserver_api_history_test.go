@@ -0,0 +1,64 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/mrepo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRange(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+
+	from, to, err := historyRange("", "", now)
+	require.NoError(t, err)
+	assert.Equal(now.Add(-24*time.Hour), from)
+	assert.Equal(now, to)
+
+	from, to, err = historyRange("2025-03-04T03:00", "2025-03-04T05:30", now)
+	require.NoError(t, err)
+	assert.Equal(time.Date(2025, 3, 4, 3, 0, 0, 0, time.Local), from)
+	assert.Equal(time.Date(2025, 3, 4, 5, 30, 0, 0, time.Local), to)
+
+	from, _, err = historyRange("2025-03-01", "", now)
+	require.NoError(t, err)
+	assert.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local), from)
+
+	_, _, err = historyRange("yesterday", "", now)
+	assert.Error(err)
+	_, _, err = historyRange("2025-03-04T05:00", "2025-03-04T03:00", now)
+	assert.Error(err)
+}
+
+func TestHistorySeries(t *testing.T) {
+	assert := assert.New(t)
+	ts := time.Unix(1700000000, 0)
+	points := []mrepo.MetricPoint{
+		{TS: ts, Values: map[string]int64{"cpu_sql_pct": 40, "cpu_other_pct": 5, "disk_read_kb_sec": 2048}},
+		{TS: ts.Add(time.Minute), Values: map[string]int64{"cpu_sql_pct": 45}},
+	}
+	cpu := historyMetricSeries("cpu", points)
+	require.Len(t, cpu, 3)
+	assert.Equal("Other CPU", cpu[0].Name)
+	assert.Equal("SQL CPU", cpu[1].Name)
+	assert.Equal(int64(1700000000000), cpu[1].Data[0].X)
+	assert.Equal(int64(45), *cpu[1].Data[1].Y)
+	assert.Nil(cpu[0].Data[1].Y)
+
+	disk := historyMetricSeries("disk", points)
+	require.Len(t, disk, 3)
+	assert.Equal("Disk Reads", disk[0].Name)
+	assert.Equal(int64(2), *disk[0].Data[0].Y)
+
+	waits := historyWaitSeries([]mrepo.WaitPoint{
+		{TS: ts, Waits: map[string]int64{"CPU": 10, "Disk": 30, "Lock": 1}},
+		{TS: ts.Add(time.Hour), Waits: map[string]int64{"CPU": 10}},
+	}, 2)
+	require.Len(t, waits, 2)
+	assert.Equal("Disk", waits[0].Name)
+	assert.Equal("CPU", waits[1].Name)
+	assert.Equal(int64(0), *waits[0].Data[1].Y)
+}
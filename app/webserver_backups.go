@@ -11,55 +11,60 @@ import (
 	"github.com/scalesql/isitsql/internal/backup"
 )
 
-func allBackupsPage(w http.ResponseWriter, req *http.Request) {
-	var err error
-	var ignored [][]string
-	htmlTitle := html.EscapeString("Backup Issues - Is It SQL")
-
-	//t0 := time.Now()
+type backupInstanceKey struct {
+	Domain     string
+	ServerName string
+}
 
-	type instanceKey struct {
-		Domain     string
-		ServerName string
-	}
+type backupDBKey struct {
+	Domain       string
+	ServerName   string
+	DatabaseName string
+}
 
-	type dbKey struct {
-		Domain       string
-		ServerName   string
-		DatabaseName string
-	}
+type backupDetail struct {
+	Domain                string
+	ServerName            string
+	DatabaseName          string
+	LastBackup            time.Time
+	LastBackupDevice      string
+	LastBackupInstance    string
+	LastLogBackup         time.Time
+	LastLogBackupDevice   string
+	LastLogBackupInstance string
+	RecoveryModelDesc     string
+	DataSizeKB            int64
+	LogSizeKB             int64
+	CreateDate            time.Time
+	ServerMapKey          string
+	CurrentTime           time.Time
+}
 
-	type backupDetail struct {
-		Domain                string
-		ServerName            string
-		DatabaseName          string
-		LastBackup            time.Time
-		LastBackupDevice      string
-		LastBackupInstance    string
-		LastLogBackup         time.Time
-		LastLogBackupDevice   string
-		LastLogBackupInstance string
-		RecoveryModelDesc     string
-		DataSizeKB            int64
-		LogSizeKB             int64
-		CreateDate            time.Time
-		ServerMapKey          string
-		CurrentTime           time.Time
-	}
+// getBackupAlerts returns the instance backup messages and the databases
+// with backup alerts.  Databases in the ignored backups file are removed.
+// It also returns the ignored backup entries and any error reading them.
+// Servers in a maintenance window are skipped.  It doesn't log since the
+// alert engine calls it every pass.  The backups page logs the errors.
+func getBackupAlerts() (map[backupInstanceKey]string, map[backupDBKey]*backupDetail, [][]string, error) {
 
-	instanceAlerts := make(map[instanceKey]string)
-	dbAlerts := make(map[dbKey]*backupDetail)
+	instanceAlerts := make(map[backupInstanceKey]string)
+	dbAlerts := make(map[backupDBKey]*backupDetail)
 
 	instances := servers.Pointers()
 	for _, s := range instances {
 
 		s.RLock()
 		msg := s.BackupMessage
-		ik := instanceKey{
+		ik := backupInstanceKey{
 			Domain:     s.Domain,
 			ServerName: s.ServerName,
 		}
+		_, silenced := serverMaintenance(s.MapKey, s.Tags)
 		s.RUnlock()
+		// servers in maintenance don't have backup alerts
+		if silenced {
+			continue
+		}
 		// get any instance alerts
 		if msg != "" {
 			s.RLock()
@@ -75,14 +80,14 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 		// get any database alerts
 		s.RLock()
 		for _, d := range s.Databases {
-			var dk dbKey
+			var dk backupDBKey
 			//fmt.Println("      ", d.Name, d.BackupAlert)
 
 			// Since we are pulling backups from any node
 			// We no longer care about the preferred backups
 			// If it has an alert, include it
 			if d.BackupAlert /* && d.IsPreferredBackup */ {
-				dk = dbKey{
+				dk = backupDBKey{
 					Domain:       strings.ToUpper(s.Domain),
 					ServerName:   strings.ToUpper(s.ServerName),
 					DatabaseName: strings.ToUpper(d.Name),
@@ -117,10 +122,7 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Get the databases to ignore
-	ignored, err = backup.GetIgnoredBackups()
-	if err != nil {
-		WinLogln("Error: getIgnoredBackups: ", err)
-	}
+	ignored, err := backup.GetIgnoredBackups()
 	for _, v := range ignored {
 
 		// check for a blank line
@@ -129,9 +131,7 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 		}
 
 		// if we don't have 2 or 3 columns, skip it
-		if len(v) < 2 || len(v) > 3 {
-			//msg := fmt.Sprintln
-			WinLogln(fmt.Sprintln("Invalid 'Ignored Database Entry' in file: ", v))
+		if invalidIgnoredEntry(v) {
 			continue
 		}
 
@@ -144,7 +144,7 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 
 		// delete one database
 		if database != "" {
-			dbk := dbKey{
+			dbk := backupDBKey{
 				Domain:       strings.ToUpper(domain),
 				ServerName:   strings.ToUpper(server),
 				DatabaseName: strings.ToUpper(database),
@@ -159,6 +159,31 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	return instanceAlerts, dbAlerts, ignored, err
+}
+
+// invalidIgnoredEntry is true for a line in the ignored backups file
+// that doesn't have 2 or 3 columns.  Blank lines are skipped.
+func invalidIgnoredEntry(v []string) bool {
+	if len(v) == 1 && strings.TrimSpace(v[0]) == "" {
+		return false
+	}
+	return len(v) < 2 || len(v) > 3
+}
+
+func allBackupsPage(w http.ResponseWriter, req *http.Request) {
+	htmlTitle := html.EscapeString("Backup Issues - Is It SQL")
+
+	instanceAlerts, dbAlerts, ignored, err := getBackupAlerts()
+	if err != nil {
+		WinLogln("Error: getIgnoredBackups: ", err)
+	}
+	for _, v := range ignored {
+		if invalidIgnoredEntry(v) {
+			WinLogln(fmt.Sprintln("Invalid 'Ignored Database Entry' in file: ", v))
+		}
+	}
+
 	////////////////////////////////////////////////////
 	// Set up the summary lines
 	////////////////////////////////////////////////////
@@ -174,9 +199,9 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 		CurrentTime     time.Time
 	}
 
-	instanceSummary := make(map[instanceKey]serverSummary)
+	instanceSummary := make(map[backupInstanceKey]serverSummary)
 	for _, v := range dbAlerts {
-		ik := instanceKey{
+		ik := backupInstanceKey{
 			Domain:     v.Domain,
 			ServerName: v.ServerName,
 		}
@@ -220,9 +245,9 @@ func allBackupsPage(w http.ResponseWriter, req *http.Request) {
 
 	context := struct {
 		Context
-		InstanceAlerts  map[instanceKey]string
-		DatabaseAlerts  map[dbKey]*backupDetail
-		InstanceSummary map[instanceKey]serverSummary
+		InstanceAlerts  map[backupInstanceKey]string
+		DatabaseAlerts  map[backupDBKey]*backupDetail
+		InstanceSummary map[backupInstanceKey]serverSummary
 		IgnoredBackups  []string
 	}{
 		Context: Context{
@@ -0,0 +1,16 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidIgnoredEntry(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(invalidIgnoredEntry([]string{" "}))
+	assert.False(invalidIgnoredEntry([]string{"DOMAIN", "SQL01"}))
+	assert.False(invalidIgnoredEntry([]string{"DOMAIN", "SQL01", "master"}))
+	assert.True(invalidIgnoredEntry([]string{"SQL01"}))
+	assert.True(invalidIgnoredEntry([]string{"DOMAIN", "SQL01", "master", "extra"}))
+}
@@ -193,7 +193,7 @@ func ServerJobHistoryPage(w http.ResponseWriter, req *http.Request) {
 	}
 	Page.JobHistory = history
 
-	steps, err := agent.FetchJobStepLog(key, jobid, pool)
+	steps, err := agent.FetchJobStepLog(context.TODO(), key, jobid, pool)
 	if err != nil {
 		WinLogln(errors.Wrap(err, "agent.fetchjobsteplog"))
 		Page.Problems = []error{errors.Wrap(err, "agent.fetchjobsteplog")}
@@ -322,7 +322,7 @@ func ServerJobStepLogPage(w http.ResponseWriter, req *http.Request) {
 	Page.Title = s.ServerName + "-" + job.Name
 	Page.Job = job
 
-	steps, err := agent.FetchJobStepLog(key, jobid, pool)
+	steps, err := agent.FetchJobStepLog(context.TODO(), key, jobid, pool)
 	if err != nil {
 		WinLogln(errors.Wrap(err, "agent.fetchjobsteplog"))
 		Page.Problems = []error{errors.Wrap(err, "agent.fetchjobsteplog")}
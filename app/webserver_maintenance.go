@@ -0,0 +1,313 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/c2"
+	"github.com/scalesql/isitsql/internal/gui"
+	"github.com/scalesql/isitsql/internal/maint"
+	"github.com/scalesql/isitsql/internal/settings"
+)
+
+// maintenanceRow is one window on the list page
+type maintenanceRow struct {
+	Window   maint.Window
+	Active   bool
+	Until    time.Time
+	Next     time.Time
+	FileName string
+	Editable bool
+}
+
+func maintenanceContext(title string) Context {
+	return Context{
+		Title:           title,
+		UnixNow:         time.Now().Unix() * 1000,
+		ErrorList:       getServerErrorList(),
+		HeaderRight:     fmt.Sprintf("Refreshed: %s (%s)", time.Now().Format("15:04:05"), version),
+		TagList:         globalTagList.getTags(),
+		AppConfig:       getGlobalConfig(),
+		MenuTwoSelected: "maintenance",
+	}
+}
+
+// maintenanceListPage lists the maintenance windows from all the HCL files
+func maintenanceListPage(w http.ResponseWriter, r *http.Request) {
+	context := struct {
+		Context
+		FileConfig bool
+		Rows       []maintenanceRow
+	}{
+		Context: maintenanceContext("Maintenance Windows"),
+		Rows:    make([]maintenanceRow, 0),
+	}
+
+	if AppConfigMode == ModeGUI {
+		context.Message = "GUI Config Mode: Maintenance windows are configured in HCL files in the servers folder"
+		context.MessageClass = gui.MessageClassDanger
+		renderFSDynamic(w, "settings-maintenance-list", context)
+		return
+	}
+	context.FileConfig = true
+
+	now := time.Now()
+	for _, mw := range MaintenanceWindows.Windows() {
+		row := maintenanceRow{
+			Window:   mw,
+			Active:   mw.Active(now),
+			Next:     mw.Next(now),
+			FileName: filepath.Base(mw.File),
+			Editable: strings.EqualFold(filepath.Base(mw.File), c2.MaintenanceFileName),
+		}
+		if row.Active {
+			row.Until = mw.Until(now)
+		}
+		context.Rows = append(context.Rows, row)
+	}
+	renderFSDynamic(w, "settings-maintenance-list", context)
+}
+
+// maintenanceEditPage adds a window to maintenance.hcl, or edits one that is there
+func maintenanceEditPage(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var blocks []c2.MaintenanceBlock
+	var mw maint.Window
+	index := -1
+
+	name := r.PathValue("name")
+	context := struct {
+		Context
+		Window maintenanceFormValues
+		Action string
+	}{
+		Context: maintenanceContext("Maintenance Window"),
+		Window:  maintenanceFormValues{},
+		Action:  "/settings/maintenance/add",
+	}
+	if name != "" {
+		context.Action = "/settings/maintenance/edit/" + name
+	}
+
+	if AppConfigMode == ModeGUI {
+		context.Message = "GUI Config Mode: Maintenance windows are configured in HCL files in the servers folder"
+		context.MessageClass = gui.MessageClassDanger
+		goto RenderForm
+	}
+
+	context.EnableSave, err = settings.CanSave(r)
+	if err != nil {
+		context.Message = errors.Wrap(err, "cansave").Error()
+		context.MessageClass = gui.MessageClassDanger
+		goto RenderForm
+	}
+
+	blocks, err = c2.ReadMaintenanceFile()
+	if err != nil {
+		context.Message = errors.Wrap(err, "c2.readmaintenancefile").Error()
+		context.MessageClass = gui.MessageClassDanger
+		context.EnableSave = false
+		goto RenderForm
+	}
+	if name != "" {
+		for i, mb := range blocks {
+			if strings.EqualFold(mb.Name, name) {
+				index = i
+			}
+		}
+		if index == -1 {
+			context.Message = fmt.Sprintf("Maintenance window '%s' isn't in %s", name, c2.MaintenanceFileName)
+			context.MessageClass = gui.MessageClassDanger
+			context.EnableSave = false
+			goto RenderForm
+		}
+		mw, err = blocks[index].Window("")
+		if err != nil {
+			context.Message = err.Error()
+			context.MessageClass = gui.MessageClassDanger
+		}
+		context.Window = formFromWindow(mw)
+	}
+
+	if r.Method == "POST" {
+		if !context.EnableSave {
+			msg := errors.New("maintenance: post but can't save")
+			GLOBAL_RINGLOG.Enqueue(msg.Error())
+			context.Message = msg.Error()
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+		err = r.ParseForm()
+		if err != nil {
+			context.Message = errors.Wrap(err, "error parsing form").Error()
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+		fv := maintenanceFormValues{
+			Name:      strings.TrimSpace(r.PostFormValue("name")),
+			Servers:   r.PostFormValue("servers"),
+			Tags:      r.PostFormValue("tags"),
+			AGNames:   r.PostFormValue("agNames"),
+			Recurring: r.PostFormValue("kind") == "recurring",
+			Start:     r.PostFormValue("start"),
+			End:       r.PostFormValue("end"),
+			Cron:      strings.TrimSpace(r.PostFormValue("cron")),
+			Duration:  strings.TrimSpace(r.PostFormValue("duration")),
+			Comment:   strings.TrimSpace(r.PostFormValue("comment")),
+		}
+		context.Window = fv
+
+		mw, err = windowFromForm(fv)
+		if err != nil {
+			context.Message = err.Error()
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+
+		// names must be unique across all the files
+		for _, existing := range MaintenanceWindows.Windows() {
+			if strings.EqualFold(existing.Name, mw.Name) && !strings.EqualFold(existing.Name, name) {
+				context.Message = fmt.Sprintf("A maintenance window named '%s' already exists", mw.Name)
+				context.MessageClass = gui.MessageClassDanger
+				goto RenderForm
+			}
+		}
+
+		if index == -1 {
+			blocks = append(blocks, c2.MaintenanceBlockFrom(mw))
+		} else {
+			blocks[index] = c2.MaintenanceBlockFrom(mw)
+		}
+		err = saveMaintenanceFile(blocks)
+		if err != nil {
+			context.Message = fmt.Sprintf("Error saving: %s", err.Error())
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+		http.Redirect(w, r, "/settings/maintenance", http.StatusSeeOther)
+		return
+	}
+
+RenderForm:
+	renderFSDynamic(w, "settings-maintenance-edit", context)
+}
+
+// maintenanceDeletePage removes a window from maintenance.hcl
+func maintenanceDeletePage(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var blocks []c2.MaintenanceBlock
+	index := -1
+
+	name := r.PathValue("name")
+	context := struct {
+		Context
+		Name string
+	}{
+		Context: maintenanceContext("Maintenance Window - Delete"),
+		Name:    name,
+	}
+
+	if AppConfigMode == ModeGUI {
+		context.Message = "GUI Config Mode: Maintenance windows are configured in HCL files in the servers folder"
+		context.MessageClass = gui.MessageClassDanger
+		goto RenderForm
+	}
+
+	context.EnableSave, err = settings.CanSave(r)
+	if err != nil {
+		context.Message = errors.Wrap(err, "cansave").Error()
+		context.MessageClass = gui.MessageClassDanger
+		goto RenderForm
+	}
+
+	blocks, err = c2.ReadMaintenanceFile()
+	if err != nil {
+		context.Message = errors.Wrap(err, "c2.readmaintenancefile").Error()
+		context.MessageClass = gui.MessageClassDanger
+		context.EnableSave = false
+		goto RenderForm
+	}
+	for i, mb := range blocks {
+		if strings.EqualFold(mb.Name, name) {
+			index = i
+		}
+	}
+	if index == -1 {
+		context.Message = fmt.Sprintf("Maintenance window '%s' isn't in %s", name, c2.MaintenanceFileName)
+		context.MessageClass = gui.MessageClassDanger
+		context.EnableSave = false
+		goto RenderForm
+	}
+
+	if r.Method == "POST" {
+		if !context.EnableSave {
+			msg := errors.New("maintenance (delete): post but can't save")
+			GLOBAL_RINGLOG.Enqueue(msg.Error())
+			context.Message = msg.Error()
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+		blocks = append(blocks[:index], blocks[index+1:]...)
+		err = saveMaintenanceFile(blocks)
+		if err != nil {
+			context.Message = fmt.Sprintf("Error saving: %s", err.Error())
+			context.MessageClass = gui.MessageClassDanger
+			goto RenderForm
+		}
+		http.Redirect(w, r, "/settings/maintenance", http.StatusSeeOther)
+		return
+	}
+
+RenderForm:
+	renderFSDynamic(w, "settings-maintenance-delete", context)
+}
+
+// windowFromForm builds and validates a window from the settings form
+func windowFromForm(fv maintenanceFormValues) (maint.Window, error) {
+	mw := maint.Window{
+		Name:    fv.Name,
+		Servers: splitCSV(fv.Servers),
+		Tags:    splitCSV(fv.Tags),
+		AGs:     splitCSV(fv.AGNames),
+		Comment: fv.Comment,
+	}
+	var err error
+	if fv.Recurring {
+		mw.Cron = fv.Cron
+		if fv.Duration != "" {
+			mw.Duration, err = time.ParseDuration(fv.Duration)
+			if err != nil {
+				return mw, errors.Wrap(err, "duration")
+			}
+		}
+	} else {
+		if fv.Start != "" {
+			if mw.Start, err = maint.ParseTime(fv.Start); err != nil {
+				return mw, errors.Wrap(err, "start")
+			}
+		}
+		if fv.End != "" {
+			if mw.End, err = maint.ParseTime(fv.End); err != nil {
+				return mw, errors.Wrap(err, "end")
+			}
+		}
+	}
+	err = mw.Validate()
+	return mw, err
+}
+
+// saveMaintenanceFile writes maintenance.hcl and reloads the HCL files
+// so the change is active right away
+func saveMaintenanceFile(blocks []c2.MaintenanceBlock) error {
+	err := c2.WriteMaintenanceFile(blocks)
+	if err != nil {
+		return errors.Wrap(err, "c2.writemaintenancefile")
+	}
+	WinLogf("maintenance: saved %s: %d window(s)", c2.MaintenanceFileName, len(blocks))
+	processHCLFiles()
+	return nil
+}
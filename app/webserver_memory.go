@@ -4,12 +4,38 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/scalesql/isitsql/internal/tier"
 )
 
+// historyUsage is the CPU and metric history kept for a server
+type historyUsage struct {
+	Points int
+	KB     int64
+}
+
 func memoryPage(w http.ResponseWriter, req *http.Request) {
 	ss := servers.CloneUnique()
 
-	context := Context{
+	var pageData struct {
+		Context
+		// HistoryWindow is how far back the CPU and metric charts go
+		HistoryWindow string
+		HistoryKB     int64
+		History       map[string]historyUsage
+	}
+	pageData.HistoryWindow = fmt.Sprintf("%dh", int(tier.Window().Hours()))
+	pageData.History = make(map[string]historyUsage)
+	for _, sw := range servers.Pointers() {
+		sw.RLock()
+		key := sw.MapKey
+		u := historyUsage{Points: sw.HistoryPoints(), KB: sw.HistoryKB()}
+		sw.RUnlock()
+		pageData.History[key] = u
+		pageData.HistoryKB += u.KB
+	}
+
+	pageData.Context = Context{
 		Title:       "Memory - IsItSQL",
 		HeaderRight: fmt.Sprintf("Refreshed: %s (%s)", time.Now().Format("15:04:05"), version),
 		SortedKeys:  servers.SortedKeys,
@@ -20,5 +46,5 @@ func memoryPage(w http.ResponseWriter, req *http.Request) {
 		Servers:     ss,
 	}
 
-	renderFS(w, "memory", context)
+	renderFSDynamic(w, "memory", pageData)
 }
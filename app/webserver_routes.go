@@ -81,6 +81,7 @@ func launchWebServer() {
 	group.HandleFunc("GET /server/{server}/raw", serverRawPage)
 	group.HandleFunc("GET /server/{server}/json", serverJSONPage)
 	group.HandleFunc("GET /server/{server}/databases", serverDatabasesPage)
+	group.HandleFunc("POST /server/{server}/poll", serverPollNowPage)
 
 	group.HandleFunc("GET /server/{server}/jobs/all", ServerJobsPage)
 	group.HandleFunc("GET /server/{server}/jobs/active", ServerJobsActivePage)
@@ -100,6 +101,10 @@ func launchWebServer() {
 	//group.HandleFunc("GET /apiall/", ApiAll)
 	group.HandleFunc("GET /api/waits/{server}", APIServerWaits)
 	group.HandleFunc("GET /api/waits2/{server}", APIServerWaits2)
+	group.HandleFunc("GET /api/history/{server}/{chart}", APIHistory)
+	group.HandleFunc("GET /api/collectors/{server}", APICollectors)
+	group.HandleFunc("GET /api/metrics/{server}", APIMetrics)
+	group.HandleFunc("GET /api/metrics/{server}/{metric}", APIMetric)
 
 	//group.HandleFunc("GET /hello/{server}", ApiServerJson)
 	group.HandleFunc("GET /dashboard/{servers...}", dashboardPage)
@@ -108,6 +113,7 @@ func launchWebServer() {
 	group.HandleFunc("GET /ag/json", agPage)
 	group.HandleFunc("GET /backups", allBackupsPage)
 	group.HandleFunc("GET /backups/json", allBackupsPage)
+	group.HandleFunc("GET /alerts/json", alertsJSON)
 
 	group.HandleFunc("GET /login", loginPage)
 	group.HandleFunc("POST /login", loginPage)
@@ -138,9 +144,17 @@ func launchWebServer() {
 	group.HandleFunc("GET /settings/servers/delete/{server}", serverDeletePage)
 	group.HandleFunc("POST /settings/servers/delete/{server}", serverDeletePage)
 
+	group.HandleFunc("GET /settings/maintenance", maintenanceListPage)
+	group.HandleFunc("GET /settings/maintenance/add", maintenanceEditPage)
+	group.HandleFunc("POST /settings/maintenance/add", maintenanceEditPage)
+	group.HandleFunc("GET /settings/maintenance/edit/{name}", maintenanceEditPage)
+	group.HandleFunc("POST /settings/maintenance/edit/{name}", maintenanceEditPage)
+	group.HandleFunc("GET /settings/maintenance/delete/{name}", maintenanceDeletePage)
+	group.HandleFunc("POST /settings/maintenance/delete/{name}", maintenanceDeletePage)
+
 	group.HandleFunc("GET /settings/conns", connListPage)
 	group.Handle("GET /metrics/isitsql", promhttp.Handler())
-	// /metrics/mssql
+	group.Handle("GET /metrics/mssql", mssqlMetricsHandler)
 
 	//group.HandleFunc("GET /panic", wrapHTTPErrorHandling(panicApp))
 	//group.HandleFunc("GET /race", wrapHTTPErrorHandling(racePage))
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -45,6 +46,11 @@ func ServerAboutPage(w http.ResponseWriter, req *http.Request) {
 	m["Version"] = fmt.Sprintf("%s %s %s", s.VersionString, s.ProductLevel, s.ProductUpdateLevel)
 	m["IsItSQL: FQDN"] = s.FQDN
 	m["IsItSQL: Tags"] = s.TagString()
+	if ok, reason := GlobalRepository.Allow(s.Tags, s.Repository); ok {
+		m["IsItSQL: Repository"] = fmt.Sprintf("Written (%s)", reason)
+	} else {
+		m["IsItSQL: Repository"] = fmt.Sprintf("Not written (%s)", reason)
+	}
 	m["Cores"] = fmt.Sprintf("%d", s.CpuCount)
 	m["Memory: Used"] = KBToString(s.SqlServerMemoryKB)
 	if s.MaxMemorySet() {
@@ -59,7 +65,9 @@ func ServerAboutPage(w http.ResponseWriter, req *http.Request) {
 	var err error
 	db, ok := servers.GetDB(key)
 	if ok {
-		agnames, err = hadr.GetNames(db)
+		ctx, cancel := context.WithTimeout(req.Context(), 60*time.Second)
+		agnames, err = hadr.GetNames(ctx, db)
+		cancel()
 		if err != nil {
 			logrus.Error(err, "hadr.getnames")
 		}
@@ -86,3 +94,20 @@ func ServerAboutPage(w http.ResponseWriter, req *http.Request) {
 	Page.Values = m
 	renderFSDynamic(w, "server-about", Page)
 }
+
+// serverPollNowPage polls a server right away, even if it is backing off,
+// and goes back to the server page
+func serverPollNowPage(w http.ResponseWriter, req *http.Request) {
+	key := req.PathValue("server")
+	servers.RLock()
+	wr, ok := servers.Servers[key]
+	servers.RUnlock()
+	if !ok {
+		renderErrorPage("Invalid Server", fmt.Sprintf("Server Not Found: %s", key), w)
+		return
+	}
+	if wr.PollNow() {
+		logrus.Debugf("poll now: %s", key)
+	}
+	http.Redirect(w, req, "/server/"+key, http.StatusSeeOther)
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/bucket"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// options are the command line flags
+type options struct {
+	dir    string
+	prefix string
+	list   bool
+	export string
+	out    string
+	filter filter
+}
+
+func parseArgs(args []string) (options, error) {
+	var opts options
+	var keys, from, to string
+	fs := flag.NewFlagSet("bucketdump", flag.ContinueOnError)
+	fs.StringVar(&opts.dir, "dir", "cache", "folder with the bucket files")
+	fs.StringVar(&opts.prefix, "prefix", "w2", "file prefix")
+	fs.BoolVar(&opts.list, "list", false, "list the files")
+	fs.StringVar(&opts.export, "export", "", "export the matching records as ndjson or csv")
+	fs.StringVar(&opts.out, "out", "", "file for the export (default is stdout)")
+	fs.StringVar(&keys, "key", "", "only these map keys (comma separated)")
+	fs.StringVar(&from, "from", "", "only records at or after this time (2006-01-02T15:04 local or RFC3339)")
+	fs.StringVar(&to, "to", "", "only records before this time")
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	switch opts.export {
+	case "", "ndjson", "csv":
+	default:
+		return opts, fmt.Errorf("invalid export: %s", opts.export)
+	}
+	opts.filter.keys = make(map[string]bool)
+	for _, k := range strings.Split(keys, ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			opts.filter.keys[k] = true
+		}
+	}
+	if opts.filter.from, err = parseTime(from); err != nil {
+		return opts, errors.Wrap(err, "from")
+	}
+	if opts.filter.to, err = parseTime(to); err != nil {
+		return opts, errors.Wrap(err, "to")
+	}
+	return opts, nil
+}
+
+func run(args []string, stdout io.Writer) error {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	br, err := bucket.NewReader(opts.prefix, opts.dir)
+	if err != nil {
+		return errors.Wrap(err, "bucket.newreader")
+	}
+	files, err := br.Files()
+	if err != nil {
+		return err
+	}
+	if opts.list {
+		return list(&br, files, stdout)
+	}
+
+	w := stdout
+	if opts.out != "" && opts.export != "" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return errors.Wrap(err, "os.create")
+		}
+		defer f.Close()
+		w = f
+	}
+	var exp exporter
+	switch opts.export {
+	case "ndjson":
+		exp = ndjsonExporter{w: w}
+	case "csv":
+		cw := csv.NewWriter(w)
+		defer cw.Flush()
+		exp = csvExporter{w: cw}
+		err = cw.Write([]string{"map_key", "ts", "name", "value"})
+		if err != nil {
+			return errors.Wrap(err, "csv.write")
+		}
+	}
+
+	sum := summary{Servers: make(map[string]int)}
+	err = readRecords(&br, files, &sum, func(rec record) error {
+		if !opts.filter.match(rec) {
+			return nil
+		}
+		sum.add(rec)
+		if exp != nil {
+			return exp.write(rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the summary goes to stderr when the records go to stdout
+	if exp != nil && w == stdout {
+		stdout = os.Stderr
+	}
+	sum.print(stdout)
+	return nil
+}
+
+// record is a ServerEvent and the time in its payload
+type record struct {
+	bucket.ServerEvent
+	TS time.Time
+}
+
+// readRecords calls fn for each record.  Corrupt files are counted and skipped.
+func readRecords(br *bucket.BucketReader, files []string, sum *summary, fn func(record) error) error {
+	for _, file := range files {
+		sum.Files++
+		fr, err := br.OpenFile(file)
+		if err != nil {
+			log.Printf("%s: %s", file, err)
+			sum.Corrupt++
+			continue
+		}
+		for {
+			payload, err := fr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("%s: %s", file, err)
+				sum.Corrupt++
+				break
+			}
+			sum.Records++
+			var rec record
+			err = json.Unmarshal(payload, &rec.ServerEvent)
+			if err != nil {
+				log.Printf("%s: %s", file, errors.Wrap(err, "json.unmarshal"))
+				continue
+			}
+			rec.TS = payloadTime(rec.Payload)
+			err = fn(rec)
+			if err != nil {
+				fr.Close()
+				return err
+			}
+		}
+		if fr.Truncated {
+			sum.Truncated++
+		}
+		fr.Close()
+	}
+	return nil
+}
+
+// list prints each file with its format, build, and record count
+func list(br *bucket.BucketReader, files []string, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tFORMAT\tBUILD\tBYTES\tRECORDS\tSTATUS")
+	for _, file := range files {
+		var size int64
+		if fi, err := os.Stat(file); err == nil {
+			size = fi.Size()
+		}
+		fr, err := br.OpenFile(file)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t\t\t%d\t\t%s\n", filepath.Base(file), size, err)
+			continue
+		}
+		var n int
+		status := "ok"
+		for {
+			_, err = fr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				status = err.Error()
+				break
+			}
+			n++
+		}
+		if fr.Truncated {
+			status = "truncated"
+		}
+		fr.Close()
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", filepath.Base(file), fr.Format, fr.Header.Build, size, n, status)
+	}
+	return tw.Flush()
+}
+
+// filter picks records by key and time.  Empty values match everything.
+type filter struct {
+	keys     map[string]bool
+	from, to time.Time
+}
+
+func (f filter) match(rec record) bool {
+	if len(f.keys) > 0 && !f.keys[strings.ToLower(rec.MapKey)] {
+		return false
+	}
+	if !f.from.IsZero() && rec.TS.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !rec.TS.Before(f.to) {
+		return false
+	}
+	return true
+}
+
+// summary of the matching records
+type summary struct {
+	Files     int
+	Records   int
+	Matched   int
+	Truncated int
+	Corrupt   int
+	First     time.Time
+	Last      time.Time
+	Servers   map[string]int
+}
+
+func (s *summary) add(rec record) {
+	s.Matched++
+	s.Servers[rec.MapKey]++
+	if rec.TS.IsZero() {
+		return
+	}
+	if s.First.IsZero() || rec.TS.Before(s.First) {
+		s.First = rec.TS
+	}
+	if rec.TS.After(s.Last) {
+		s.Last = rec.TS
+	}
+}
+
+func (s *summary) print(w io.Writer) {
+	fmt.Fprintf(w, "files:    %d (truncated: %d  corrupt: %d)\n", s.Files, s.Truncated, s.Corrupt)
+	fmt.Fprintf(w, "records:  %d (matched: %d)\n", s.Records, s.Matched)
+	if !s.First.IsZero() {
+		fmt.Fprintf(w, "range:    %s to %s\n", s.First.Local().Format(time.RFC3339), s.Last.Local().Format(time.RFC3339))
+	}
+	keys := make([]string, 0, len(s.Servers))
+	for k := range s.Servers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "servers:  %d\n", len(keys))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "  %s\t%d\n", k, s.Servers[k])
+	}
+	tw.Flush()
+}
+
+// exporter writes the matching records
+type exporter interface {
+	write(rec record) error
+}
+
+// ndjsonExporter writes each ServerEvent on a line like the older cache files
+type ndjsonExporter struct {
+	w io.Writer
+}
+
+func (e ndjsonExporter) write(rec record) error {
+	bb, err := json.Marshal(rec.ServerEvent)
+	if err != nil {
+		return errors.Wrap(err, "json.marshal")
+	}
+	_, err = e.w.Write(append(bb, '\n'))
+	return err
+}
+
+// csvExporter writes a row for each number in the payload.
+// Nested names are joined with a dot such as waits.CPU.
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e csvExporter) write(rec record) error {
+	var v any
+	err := json.Unmarshal(rec.Payload, &v)
+	if err != nil {
+		return errors.Wrap(err, "json.unmarshal")
+	}
+	ts := ""
+	if !rec.TS.IsZero() {
+		ts = rec.TS.Format(time.RFC3339)
+	}
+	rows := make([][]string, 0)
+	flatten("", v, func(name string, value float64) {
+		rows = append(rows, []string{rec.MapKey, ts, name, strconv.FormatFloat(value, 'f', -1, 64)})
+	})
+	return e.w.WriteAll(rows)
+}
+
+// flatten calls fn for each number in v in name order
+func flatten(prefix string, v any, fn func(string, float64)) {
+	switch t := v.(type) {
+	case float64:
+		fn(prefix, t)
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+			flatten(name, t[k], fn)
+		}
+	}
+}
+
+// payloadTime returns the ts field of a payload such as a waitring.WaitList
+func payloadTime(payload json.RawMessage) time.Time {
+	var v struct {
+		TS time.Time `json:"ts"`
+	}
+	_ = json.Unmarshal(payload, &v)
+	return v.TS
+}
+
+// parseTime parses local times or RFC3339.  Empty is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/bucket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	assert := assert.New(t)
+	ts := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	rec := record{ServerEvent: bucket.ServerEvent{MapKey: "Key1"}, TS: ts}
+	assert.True(filter{}.match(rec))
+	assert.True(filter{keys: map[string]bool{"key1": true}}.match(rec))
+	assert.False(filter{keys: map[string]bool{"key2": true}}.match(rec))
+	assert.True(filter{from: ts, to: ts.Add(time.Minute)}.match(rec))
+	assert.False(filter{from: ts.Add(time.Second)}.match(rec))
+	assert.False(filter{to: ts}.match(rec))
+	assert.False(filter{from: ts}.match(record{}))
+}
+
+func TestParseTime(t *testing.T) {
+	assert := assert.New(t)
+	tm, err := parseTime("")
+	assert.NoError(err)
+	assert.True(tm.IsZero())
+	tm, err = parseTime("2024-01-02T10:30")
+	assert.NoError(err)
+	assert.Equal(time.Date(2024, 1, 2, 10, 30, 0, 0, time.Local), tm)
+	tm, err = parseTime("2024-01-02T10:30:00Z")
+	assert.NoError(err)
+	assert.True(time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC).Equal(tm))
+	_, err = parseTime("yesterday")
+	assert.Error(err)
+}
+
+func TestFlatten(t *testing.T) {
+	assert := assert.New(t)
+	var v any
+	err := json.Unmarshal([]byte(`{"ts":"2024-01-02T10:30:00Z","waits":{"CPU":5,"IO":1.5},"count":2}`), &v)
+	assert.NoError(err)
+	got := make([]string, 0)
+	flatten("", v, func(name string, value float64) {
+		got = append(got, name)
+	})
+	assert.Equal([]string{"count", "waits.CPU", "waits.IO"}, got)
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+	var bw bucket.BucketWriter
+	require.NoError(bw.Start(dir, "w2"))
+	ts := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		for _, key := range []string{"a", "b"} {
+			require.NoError(bw.Write(key, map[string]any{"ts": ts.Add(time.Duration(i) * time.Minute), "waits": map[string]int{"CPU": i}}))
+		}
+	}
+
+	var out bytes.Buffer
+	require.NoError(run([]string{"-dir", dir}, &out))
+	assert.Contains(out.String(), "records:  6 (matched: 6)")
+	assert.Contains(out.String(), "servers:  2")
+
+	out.Reset()
+	require.NoError(run([]string{"-dir", dir, "-key", "b", "-from", "2024-01-02T10:01:00Z", "-export", "ndjson", "-out", filepath.Join(dir, "out.ndjson")}, &out))
+	assert.Contains(out.String(), "records:  6 (matched: 2)")
+	bb, err := os.ReadFile(filepath.Join(dir, "out.ndjson"))
+	require.NoError(err)
+	assert.Equal(2, strings.Count(string(bb), `"map_key":"b"`))
+
+	out.Reset()
+	require.NoError(run([]string{"-dir", dir, "-key", "b", "-from", "2024-01-02T10:01:00Z", "-export", "csv"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal([]string{"map_key,ts,name,value", "b,2024-01-02T10:01:00Z,waits.CPU,1", "b,2024-01-02T10:02:00Z,waits.CPU,2"}, lines)
+
+	out.Reset()
+	require.NoError(run([]string{"-dir", dir, "-list"}, &out))
+	assert.Contains(out.String(), bucket.FormatBinary)
+
+	assert.Error(run([]string{"-dir", dir, "-export", "xml"}, &out))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/scalesql/isitsql/internal/c2"
 	"github.com/scalesql/isitsql/internal/fileio"
 	"github.com/scalesql/isitsql/internal/settings"
+	"github.com/scalesql/isitsql/internal/store"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -38,6 +39,17 @@ func main() {
 		log.Fatal(errors.Wrap(err, "doagnames"))
 	}
 
+	// the connections are in the store once the service has started
+	st, err := store.Open(filepath.Join(filepath.Dir(configPath), "cache", store.FileName))
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "store.open: is the service running?"))
+	}
+	defer st.Close()
+	_, err = settings.UseStore(st)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "settings.usestore"))
+	}
+
 	err = doConnections(srvpath)
 	if err != nil {
 		log.Fatal(errors.Wrap(err, "doconnections"))
@@ -113,8 +113,8 @@ func lintFiles() {
 		log.Error(msg)
 	}
 	if err != nil || len(msgs) > 0 {
-		log.Errorf("instances: %d  ag: %d  (files: %d)", len(fc.Connections), len(fc.AGs), len(fc.Files))
+		log.Errorf("instances: %d  ag: %d  metrics: %d  (files: %d)", len(fc.Connections), len(fc.AGs), len(fc.Metrics), len(fc.Files))
 	} else {
-		log.Infof("instances: %d  ag: %d  (files: %d)", len(fc.Connections), len(fc.AGs), len(fc.Files))
+		log.Infof("instances: %d  ag: %d  metrics: %d  (files: %d)", len(fc.Connections), len(fc.AGs), len(fc.Metrics), len(fc.Files))
 	}
 }
@@ -0,0 +1,246 @@
+// Package alert tracks monitored conditions as they fire and resolve.
+package alert
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// Severity of an alert
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// State of an alert
+type State string
+
+const (
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Condition is a single problem observed during one evaluation
+type Condition struct {
+	Entity      string   // server key, AG name, etc.
+	DisplayName string   // what the user sees for the entity
+	Name        string   // poll_error, ag_health, backup, etc.
+	Target      string   // optional: database, job, etc.
+	Severity    Severity // warning or critical
+	Message     string
+}
+
+// ID uniquely identifies a condition across evaluations
+func (c Condition) ID() string {
+	parts := []string{c.Entity, c.Name}
+	if c.Target != "" {
+		parts = append(parts, c.Target)
+	}
+	return strings.ToLower(strings.Join(parts, "/"))
+}
+
+// Alert is the tracked state of a condition
+type Alert struct {
+	ID          string     `json:"id"`
+	Entity      string     `json:"entity"`
+	DisplayName string     `json:"display_name"`
+	Condition   string     `json:"condition"`
+	Target      string     `json:"target,omitempty"`
+	Severity    Severity   `json:"severity"`
+	State       State      `json:"state"`
+	Message     string     `json:"message"`
+	FirstSeen   time.Time  `json:"first_seen"`
+	LastSeen    time.Time  `json:"last_seen"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Count       int        `json:"count"` // evaluations this has been seen in
+}
+
+// String describes the alert for logging
+func (a Alert) String() string {
+	name := a.DisplayName
+	if name == "" {
+		name = a.Entity
+	}
+	return fmt.Sprintf("ALERT: %s: %s: %s (%s): %s", strings.ToUpper(string(a.State)), name, a.Condition, a.Severity, a.Message)
+}
+
+// Engine tracks alerts between evaluations
+type Engine struct {
+	mu        sync.RWMutex
+	clock     clock.Clock
+	active    map[string]*Alert
+	recent    []Alert // resolved alerts, oldest first
+	maxRecent int
+	lastEval  time.Time
+	listeners []func(Alert)
+}
+
+// NewEngine returns an Engine that keeps up to maxRecent resolved alerts
+func NewEngine(maxRecent int) *Engine {
+	return &Engine{
+		clock:     clock.New(),
+		active:    make(map[string]*Alert),
+		recent:    make([]Alert, 0, maxRecent),
+		maxRecent: maxRecent,
+	}
+}
+
+// Subscribe registers a function that is called for each transition.
+// It is called after the evaluation completes and outside any lock.
+func (e *Engine) Subscribe(fn func(Alert)) {
+	if e == nil || fn == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// Evaluate compares the current conditions to the active alerts.
+// It returns the alerts that fired, changed severity, or resolved.
+// Conditions that are already firing only update LastSeen.
+func (e *Engine) Evaluate(conditions []Condition) []Alert {
+	if e == nil {
+		return []Alert{}
+	}
+	e.mu.Lock()
+	now := e.clock.Now()
+	e.lastEval = now
+	transitions := make([]Alert, 0)
+	seen := make(map[string]bool, len(conditions))
+
+	for _, c := range conditions {
+		id := c.ID()
+		if seen[id] { // de-duplicate within one evaluation
+			continue
+		}
+		seen[id] = true
+
+		a, ok := e.active[id]
+		if !ok {
+			a = &Alert{
+				ID:          id,
+				Entity:      c.Entity,
+				DisplayName: c.DisplayName,
+				Condition:   c.Name,
+				Target:      c.Target,
+				Severity:    c.Severity,
+				State:       StateFiring,
+				Message:     c.Message,
+				FirstSeen:   now,
+				LastSeen:    now,
+				Count:       1,
+			}
+			e.active[id] = a
+			transitions = append(transitions, *a)
+			continue
+		}
+		a.LastSeen = now
+		a.Message = c.Message
+		a.DisplayName = c.DisplayName
+		a.Count++
+		if a.Severity != c.Severity {
+			a.Severity = c.Severity
+			transitions = append(transitions, *a)
+		}
+	}
+
+	// anything active we didn't see has resolved
+	for id, a := range e.active {
+		if seen[id] {
+			continue
+		}
+		a.State = StateResolved
+		resolvedAt := now
+		a.ResolvedAt = &resolvedAt
+		delete(e.active, id)
+		e.addRecent(*a)
+		transitions = append(transitions, *a)
+	}
+	listeners := slices.Clone(e.listeners)
+	e.mu.Unlock()
+
+	sortAlerts(transitions)
+	for _, t := range transitions {
+		for _, fn := range listeners {
+			fn(t)
+		}
+	}
+	return transitions
+}
+
+// addRecent saves a resolved alert.  The caller must hold the lock.
+func (e *Engine) addRecent(a Alert) {
+	if e.maxRecent <= 0 {
+		return
+	}
+	if len(e.recent) >= e.maxRecent {
+		e.recent = e.recent[1:]
+	}
+	e.recent = append(e.recent, a)
+}
+
+// Active returns the alerts that are firing sorted by severity then first seen
+func (e *Engine) Active() []Alert {
+	if e == nil {
+		return []Alert{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	aa := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		aa = append(aa, *a)
+	}
+	sortAlerts(aa)
+	return aa
+}
+
+// Recent returns resolved alerts with the newest first
+func (e *Engine) Recent() []Alert {
+	if e == nil {
+		return []Alert{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	aa := make([]Alert, len(e.recent))
+	for i, a := range e.recent {
+		aa[len(e.recent)-1-i] = a
+	}
+	return aa
+}
+
+// LastEvaluation returns when the conditions were last evaluated
+func (e *Engine) LastEvaluation() time.Time {
+	if e == nil {
+		return time.Time{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lastEval
+}
+
+// sortAlerts puts critical first, then oldest first, then by ID
+func sortAlerts(aa []Alert) {
+	slices.SortStableFunc(aa, func(a, b Alert) int {
+		if a.Severity != b.Severity {
+			if a.Severity == SeverityCritical {
+				return -1
+			}
+			if b.Severity == SeverityCritical {
+				return 1
+			}
+		}
+		if c := a.FirstSeen.Compare(b.FirstSeen); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
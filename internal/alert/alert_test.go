@@ -0,0 +1,121 @@
+package alert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEngine(n int) (*Engine, *clock.Mock) {
+	e := NewEngine(n)
+	clk := clock.NewMock()
+	clk.Set(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	e.clock = clk
+	return e, clk
+}
+
+func TestFireAndResolve(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	e, clk := testEngine(10)
+
+	c := Condition{Entity: "srv1", Name: "poll_error", Severity: SeverityCritical, Message: "login failed"}
+	tt := e.Evaluate([]Condition{c})
+	require.Len(tt, 1)
+	assert.Equal(StateFiring, tt[0].State)
+	assert.Equal("srv1/poll_error", tt[0].ID)
+	first := clk.Now()
+
+	// repeated firings don't transition
+	clk.Add(time.Minute)
+	tt = e.Evaluate([]Condition{c, c})
+	assert.Len(tt, 0)
+	active := e.Active()
+	require.Len(active, 1)
+	assert.Equal(first, active[0].FirstSeen)
+	assert.Equal(clk.Now(), active[0].LastSeen)
+	assert.Equal(2, active[0].Count)
+	bb, err := json.Marshal(active[0])
+	require.NoError(err)
+	assert.NotContains(string(bb), "resolved_at")
+
+	// cleared
+	clk.Add(time.Minute)
+	tt = e.Evaluate([]Condition{})
+	require.Len(tt, 1)
+	assert.Equal(StateResolved, tt[0].State)
+	require.NotNil(tt[0].ResolvedAt)
+	assert.Equal(clk.Now(), *tt[0].ResolvedAt)
+	bb, err = json.Marshal(tt[0])
+	require.NoError(err)
+	assert.Contains(string(bb), "resolved_at")
+	assert.Len(e.Active(), 0)
+	require.Len(e.Recent(), 1)
+	assert.Equal(first, e.Recent()[0].FirstSeen)
+}
+
+func TestSeverityChange(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEngine(10)
+	c := Condition{Entity: "AG:ag1", Name: "ag_queue", Severity: SeverityWarning}
+	assert.Len(e.Evaluate([]Condition{c}), 1)
+	c.Severity = SeverityCritical
+	tt := e.Evaluate([]Condition{c})
+	assert.Len(tt, 1)
+	assert.Equal(SeverityCritical, tt[0].Severity)
+	assert.Equal(StateFiring, tt[0].State)
+	assert.Len(e.Evaluate([]Condition{c}), 0)
+}
+
+func TestTargets(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEngine(10)
+	tt := e.Evaluate([]Condition{
+		{Entity: "srv1", Name: "backup", Target: "db1"},
+		{Entity: "srv1", Name: "backup", Target: "DB2"},
+	})
+	assert.Len(tt, 2)
+	assert.Len(e.Active(), 2)
+	tt = e.Evaluate([]Condition{{Entity: "srv1", Name: "backup", Target: "db1"}})
+	assert.Len(tt, 1)
+	assert.Equal("srv1/backup/db2", tt[0].ID)
+}
+
+func TestRecentCapacity(t *testing.T) {
+	assert := assert.New(t)
+	e, clk := testEngine(2)
+	for _, name := range []string{"a", "b", "c"} {
+		e.Evaluate([]Condition{{Entity: name, Name: "poll_error"}})
+		clk.Add(time.Minute)
+		e.Evaluate([]Condition{})
+	}
+	recent := e.Recent()
+	assert.Len(recent, 2)
+	assert.Equal("c", recent[0].Entity)
+	assert.Equal("b", recent[1].Entity)
+}
+
+func TestSubscribe(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEngine(10)
+	got := make([]Alert, 0)
+	e.Subscribe(func(a Alert) { got = append(got, a) })
+	e.Evaluate([]Condition{{Entity: "a", Name: "x"}})
+	e.Evaluate([]Condition{{Entity: "a", Name: "x"}})
+	e.Evaluate([]Condition{})
+	assert.Len(got, 2)
+	assert.Equal(StateFiring, got[0].State)
+	assert.Equal(StateResolved, got[1].State)
+}
+
+func TestNilEngine(t *testing.T) {
+	assert := assert.New(t)
+	var e *Engine
+	assert.Len(e.Evaluate([]Condition{{Entity: "a"}}), 0)
+	assert.Len(e.Active(), 0)
+	assert.Len(e.Recent(), 0)
+}
@@ -0,0 +1,71 @@
+/*
+Package breaker backs off polling a target that keeps failing.
+
+After Threshold failures in a row the breaker opens.  Each failure after
+that doubles the delay until the next attempt, starting at Base and
+stopping at Max.  A success closes it.  The caller handles locking.
+*/
+package breaker
+
+import "time"
+
+// Breaker counts the failures in a row for one target
+type Breaker struct {
+	Threshold int           // failures in a row before it opens
+	Base      time.Duration // the first delay once it opens
+	Max       time.Duration // the longest delay
+
+	failures  int
+	openSince time.Time
+}
+
+// New returns a closed Breaker
+func New(threshold int, base, maxDelay time.Duration) Breaker {
+	return Breaker{Threshold: threshold, Base: base, Max: maxDelay}
+}
+
+// Fail records a failure and returns the delay until the next attempt.
+// It returns zero until the breaker opens.
+func (b *Breaker) Fail(now time.Time) time.Duration {
+	b.failures++
+	if b.failures < b.Threshold || b.Base <= 0 {
+		return 0
+	}
+	if b.openSince.IsZero() {
+		b.openSince = now
+	}
+	delay := b.Base
+	for i := b.Threshold; i < b.failures; i++ {
+		delay *= 2
+		if b.Max > 0 && delay >= b.Max {
+			return b.Max
+		}
+	}
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// Succeed closes the breaker and returns true if it was open
+func (b *Breaker) Succeed() bool {
+	wasOpen := b.Open()
+	b.failures = 0
+	b.openSince = time.Time{}
+	return wasOpen
+}
+
+// Open is true while attempts are delayed
+func (b Breaker) Open() bool {
+	return !b.openSince.IsZero()
+}
+
+// OpenSince is when the breaker opened or zero if it is closed
+func (b Breaker) OpenSince() time.Time {
+	return b.openSince
+}
+
+// Failures is the number of failures in a row
+func (b Breaker) Failures() int {
+	return b.failures
+}
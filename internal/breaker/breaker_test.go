@@ -0,0 +1,44 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	assert := assert.New(t)
+	b := New(3, 20*time.Second, time.Minute)
+	now := time.Now()
+
+	assert.Equal(time.Duration(0), b.Fail(now))
+	assert.Equal(time.Duration(0), b.Fail(now))
+	assert.False(b.Open())
+
+	assert.Equal(20*time.Second, b.Fail(now))
+	assert.True(b.Open())
+	assert.Equal(now, b.OpenSince())
+	assert.Equal(40*time.Second, b.Fail(now.Add(time.Minute)))
+	assert.Equal(time.Minute, b.Fail(now.Add(2*time.Minute)))
+	for i := 0; i < 100; i++ {
+		assert.Equal(time.Minute, b.Fail(now))
+	}
+	assert.Equal(now, b.OpenSince())
+	assert.Equal(105, b.Failures())
+
+	assert.True(b.Succeed())
+	assert.False(b.Open())
+	assert.Equal(0, b.Failures())
+	assert.False(b.Succeed())
+	assert.Equal(time.Duration(0), b.Fail(now))
+}
+
+func TestDisabled(t *testing.T) {
+	assert := assert.New(t)
+	var b Breaker
+	for i := 0; i < 5; i++ {
+		assert.Equal(time.Duration(0), b.Fail(time.Now()))
+	}
+	assert.False(b.Open())
+}
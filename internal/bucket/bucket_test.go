@@ -60,11 +60,17 @@ func TestBucket(t *testing.T) {
 	err = bw.rollover()
 	assert.NoError(err)
 
-	written, err := afero.ReadFile(bw.fs, name)
+	written, err := bw.fs.Open(name)
+	assert.NoError(err)
+	defer written.Close()
+	fr, err := NewFileReader(written)
+	assert.NoError(err)
+	assert.Equal(FormatBinary, fr.Format)
+	assert.Equal("test", fr.Header.Prefix)
+	payload, err := fr.Next()
 	assert.NoError(err)
-	println(string(written))
 	var se2 ServerEvent
-	err = json.Unmarshal(written, &se2)
+	err = json.Unmarshal(payload, &se2)
 	assert.NoError(err)
 	assert.Equal("abc", se2.MapKey)
 
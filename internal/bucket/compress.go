@@ -0,0 +1,183 @@
+package bucket
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// Compression for closed bucket files
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Options control how a BucketWriter rolls and compresses files.
+// The zero value rolls every 10 minutes and doesn't compress.
+type Options struct {
+	Compression string `toml:"compression"` // "", "gzip", or "zstd"
+	MaxFileMB   int    `toml:"max_file_mb"` // also roll a file at this size.  Zero only rolls on time.
+	MaxMB       int    `toml:"max_mb"`      // the oldest files are purged past this.  Zero has no limit.
+}
+
+// Validate checks the compression
+func (o Options) Validate() error {
+	switch strings.ToLower(o.Compression) {
+	case CompressNone, CompressGzip, CompressZstd:
+		return nil
+	}
+	return fmt.Errorf("invalid compression: %s", o.Compression)
+}
+
+// exts are the file extensions that are read and purged.  The NDJSON files are from older versions.
+var exts = []string{Ext, Ext + ".gz", Ext + ".zst", ExtNDJSON}
+
+// compressedName returns the name of the compressed file
+func compressedName(name, compression string) string {
+	switch strings.ToLower(compression) {
+	case CompressGzip:
+		return name + ".gz"
+	case CompressZstd:
+		return name + ".zst"
+	}
+	return name
+}
+
+// tmpExt is added to a compressed file until it is complete so
+// readers don't see part of it
+const tmpExt = ".tmp"
+
+// compressFile compresses a closed file and removes the original
+func compressFile(fs afero.Fs, name, compression string) error {
+	target := compressedName(name, compression)
+	if target == name {
+		return nil
+	}
+	src, err := fs.Open(name)
+	if err != nil {
+		return errors.Wrap(err, "open")
+	}
+	defer src.Close()
+	tmp := target + tmpExt
+	dst, err := fs.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660)
+	if err != nil {
+		return errors.Wrap(err, "create")
+	}
+	var w io.WriteCloser
+	if strings.ToLower(compression) == CompressGzip {
+		w = gzip.NewWriter(dst)
+	} else {
+		w, err = zstd.NewWriter(dst)
+		if err != nil {
+			dst.Close()
+			return errors.Wrap(err, "zstd.newwriter")
+		}
+	}
+	_, err = io.Copy(w, src)
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return errors.Wrap(err, "compress")
+	}
+	err = fs.Rename(tmp, target)
+	if err != nil {
+		fs.Remove(tmp)
+		return errors.Wrap(err, "rename")
+	}
+	src.Close()
+	return errors.Wrap(fs.Remove(name), "remove")
+}
+
+// hasCompressed is true if an uncompressed file also has a compressed
+// copy.  The original is left behind if the service stops or the file
+// can't be removed after it is compressed.
+func hasCompressed(fs afero.Fs, name string) bool {
+	if !strings.HasSuffix(name, "."+Ext) {
+		return false
+	}
+	for _, compression := range []string{CompressGzip, CompressZstd} {
+		ok, err := afero.Exists(fs, compressedName(name, compression))
+		if err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCompressed removes the uncompressed files that have a compressed copy
+func removeCompressed(fs afero.Fs, path, prefix string) error {
+	names, err := afero.Glob(fs, filepath.Join(path, fmt.Sprintf("%s_*.%s", prefix, Ext)))
+	if err != nil {
+		return errors.Wrap(err, "afero.glob")
+	}
+	for _, name := range names {
+		if !hasCompressed(fs, name) {
+			continue
+		}
+		err = fs.Remove(name)
+		if err != nil {
+			return errors.Wrap(err, "remove")
+		}
+	}
+	return nil
+}
+
+// removeTemp removes compressed files that weren't finished
+func removeTemp(fs afero.Fs, path, prefix string) error {
+	names, err := afero.Glob(fs, filepath.Join(path, fmt.Sprintf("%s_*%s", prefix, tmpExt)))
+	if err != nil {
+		return errors.Wrap(err, "afero.glob")
+	}
+	for _, name := range names {
+		err = fs.Remove(name)
+		if err != nil {
+			return errors.Wrap(err, "remove")
+		}
+	}
+	return nil
+}
+
+// openFile opens a bucket file and decompresses it based on the extension
+func openFile(fs afero.Fs, name string) (io.ReadCloser, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, corrupt(err, "gzip.newreader")
+		}
+		return readCloser{gz, func() error { gz.Close(); return f.Close() }}, nil
+	case strings.HasSuffix(name, ".zst"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "zstd.newreader")
+		}
+		return readCloser{zr, func() error { zr.Close(); return f.Close() }}, nil
+	}
+	return f, nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error { return rc.close() }
@@ -0,0 +1,110 @@
+package bucket
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedRollover(t *testing.T) {
+	for _, compression := range []string{CompressNone, CompressGzip, CompressZstd} {
+		t.Run(compression, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			fs := afero.NewMemMapFs()
+			clk := clock.NewMock()
+			clk.Set(time.Now())
+			bw := BucketWriter{fs: fs, clock: clk}
+			// left by a crash while compressing
+			require.NoError(afero.WriteFile(fs, "cache/w2_20250101_000000."+Ext+".gz"+tmpExt, []byte("x"), 0644))
+			require.NoError(bw.StartOptions("cache", "w2", Options{Compression: compression, MaxFileMB: 1}))
+
+			// about 3MB of events rolls by size every second
+			big := strings.Repeat("x", 1000)
+			for i := 0; i < 3000; i++ {
+				require.NoError(bw.Write(fmt.Sprintf("k%d", i), FakeEvent{Wait: big, Value: i}))
+				if i%100 == 0 {
+					clk.Add(time.Second)
+				}
+			}
+			clk.Add(15 * time.Minute)
+			require.NoError(bw.rollover())
+			bw.compressing.Wait()
+
+			files, err := afero.ReadDir(fs, "cache")
+			require.NoError(err)
+			assert.GreaterOrEqual(len(files), 4)
+			// the last file is still open
+			assert.True(strings.HasSuffix(files[len(files)-1].Name(), "."+Ext))
+			for _, f := range files[:len(files)-1] {
+				assert.Equal(compressedName("."+Ext, compression), f.Name()[strings.Index(f.Name(), "."):])
+				if compression != CompressNone {
+					assert.Less(f.Size(), int64(100<<10))
+				} else {
+					assert.LessOrEqual(f.Size(), int64(1<<20)+2000)
+				}
+			}
+
+			br := BucketReader{fs: fs, prefix: "w2", path: "cache", Results: make(chan string)}
+			go br.StartReader()
+			var n int
+			for range br.Results {
+				n++
+			}
+			assert.NoError(br.Err)
+			assert.Equal(3000, n)
+			assert.Zero(br.Corrupt + br.Truncated)
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, Options{}.Validate())
+	assert.NoError(t, Options{Compression: "ZSTD"}.Validate())
+	assert.Error(t, Options{Compression: "lz4"}.Validate())
+	bw := BucketWriter{fs: afero.NewMemMapFs()}
+	assert.Error(t, bw.StartOptions("cache", "w2", Options{Compression: "lz4"}))
+}
+
+func TestCompressedCopyLeftBehind(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	fs := afero.NewMemMapFs()
+	dir := "cache"
+	ts := time.Now().UTC()
+	name := filepath.Join(dir, fmt.Sprintf("w2_%s.%s", ts.Format("20060102_150405"), Ext))
+	require.NoError(afero.WriteFile(fs, name, testFile(t, `{"a":1}`, `{"b":2}`), 0644))
+	require.NoError(compressFile(fs, name, CompressGzip))
+	// the original couldn't be removed, maybe because it was open
+	require.NoError(afero.WriteFile(fs, name, testFile(t, `{"a":1}`, `{"b":2}`), 0644))
+	other := filepath.Join(dir, fmt.Sprintf("w2_%s.%s", ts.Add(time.Minute).Format("20060102_150405"), Ext))
+	require.NoError(afero.WriteFile(fs, other, testFile(t, `{"c":3}`), 0644))
+
+	br := BucketReader{fs: fs, prefix: "w2", path: dir, Results: make(chan string)}
+	files, err := br.Files()
+	require.NoError(err)
+	assert.Equal([]string{name + ".gz", other}, files)
+	go br.StartReader()
+	list := make([]string, 0)
+	for str := range br.Results {
+		list = append(list, str)
+	}
+	assert.Equal([]string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, list)
+
+	// the writer removes the original when it starts
+	bw := BucketWriter{fs: fs, clock: clock.NewMock()}
+	require.NoError(bw.StartOptions(dir, "w2", Options{Compression: CompressGzip}))
+	ok, err := afero.Exists(fs, name)
+	require.NoError(err)
+	assert.False(ok)
+	ok, err = afero.Exists(fs, other)
+	require.NoError(err)
+	assert.True(ok)
+}
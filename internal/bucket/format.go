@@ -0,0 +1,226 @@
+package bucket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+File Format
+===========
+magic        8 bytes  "ISQLBKT\n"
+version      uint16
+header size  uint32
+header       JSON (Header)
+records      each is:
+               length  uint32 (zero marks the end of the file)
+               crc32   uint32 (Castagnoli of the payload)
+               payload bytes  (JSON of a ServerEvent)
+
+All integers are little-endian.  A file that doesn't start with the
+magic text is read as NDJSON which is what older versions wrote.
+*/
+
+// File extensions
+const (
+	Ext       = "bucket"
+	ExtNDJSON = "ndjson"
+)
+
+// FileVersion is the version of the file format that is written
+const FileVersion = 1
+
+// SchemaServerEvent is the schema of records holding a ServerEvent
+const SchemaServerEvent = "server_event/1"
+
+// Formats returned by FileReader
+const (
+	FormatBinary = "binary"
+	FormatNDJSON = "ndjson"
+)
+
+const maxRecord = 64 << 20 // anything bigger is a bad length
+
+var magic = []byte("ISQLBKT\n")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Build is the IsItSQL build written in the header of new files
+var Build = "undefined"
+
+// ErrCorrupt is returned when a record fails its checksum or has a bad length
+var ErrCorrupt = errors.New("corrupt record")
+
+// Header describes a bucket file
+type Header struct {
+	Version int       `json:"version"`
+	Build   string    `json:"build"`
+	Prefix  string    `json:"prefix"`
+	Schema  string    `json:"schema"`
+	Created time.Time `json:"created"`
+}
+
+// writeHeader writes the magic text, version, and header
+func writeHeader(w io.Writer, h Header) error {
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return errors.Wrap(err, "header.marshal")
+	}
+	buf := make([]byte, 0, len(magic)+6+len(hb))
+	buf = append(buf, magic...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(h.Version))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(hb)))
+	buf = append(buf, hb...)
+	_, err = w.Write(buf)
+	return err
+}
+
+// writeRecord writes one length-prefixed record with its checksum
+func writeRecord(w io.Writer, payload []byte) error {
+	buf := make([]byte, 0, 8+len(payload))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// writeEnd writes the zero length that marks the end of the file
+func writeEnd(w io.Writer) error {
+	_, err := w.Write(make([]byte, 8))
+	return err
+}
+
+// FileReader reads the records in one bucket file
+type FileReader struct {
+	Header    Header // empty for NDJSON
+	Format    string
+	Truncated bool // the last record was cut off, probably by a crash
+	r         *bufio.Reader
+	closer    io.Closer
+	offset    int64
+	done      bool
+}
+
+// NewFileReader reads the header.  Files without a header are read as NDJSON.
+func NewFileReader(r io.Reader) (*FileReader, error) {
+	fr := &FileReader{r: bufio.NewReader(r), Format: FormatNDJSON}
+	peek, err := fr.r.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return fr, corrupt(err, "peek")
+	}
+	if !bytes.Equal(peek, magic) {
+		return fr, nil
+	}
+	fr.Format = FormatBinary
+	fixed := make([]byte, len(magic)+6)
+	if _, err = io.ReadFull(fr.r, fixed); err != nil {
+		return fr, corrupt(err, "header")
+	}
+	version := binary.LittleEndian.Uint16(fixed[len(magic):])
+	if version > FileVersion {
+		return fr, fmt.Errorf("unsupported bucket version: %d", version)
+	}
+	size := binary.LittleEndian.Uint32(fixed[len(magic)+2:])
+	if size > maxRecord {
+		return fr, errors.Wrap(ErrCorrupt, "header size")
+	}
+	hb := make([]byte, size)
+	if _, err = io.ReadFull(fr.r, hb); err != nil {
+		return fr, corrupt(err, "header")
+	}
+	if err = json.Unmarshal(hb, &fr.Header); err != nil {
+		return fr, corrupt(err, "header.unmarshal")
+	}
+	fr.offset = int64(len(fixed)) + int64(size)
+	return fr, nil
+}
+
+// corrupt wraps a header that can't be read in ErrCorrupt
+// so the reader skips the file
+func corrupt(err error, msg string) error {
+	return errors.Wrapf(ErrCorrupt, "%s: %s", msg, err)
+}
+
+// Next returns the payload of the next record.  It returns io.EOF
+// at the end of the file or a truncated last record.  A record that
+// fails its checksum returns ErrCorrupt and the rest of the file is skipped.
+func (fr *FileReader) Next() ([]byte, error) {
+	if fr.done {
+		return nil, io.EOF
+	}
+	if fr.Format == FormatNDJSON {
+		return fr.nextLine()
+	}
+	prefix := make([]byte, 8)
+	n, err := io.ReadFull(fr.r, prefix)
+	if err != nil {
+		return nil, fr.end(n > 0, err)
+	}
+	length := binary.LittleEndian.Uint32(prefix)
+	sum := binary.LittleEndian.Uint32(prefix[4:])
+	if length == 0 {
+		fr.done = true
+		return nil, io.EOF
+	}
+	if length > maxRecord {
+		fr.done = true
+		return nil, errors.Wrapf(ErrCorrupt, "offset %d: length %d", fr.offset, length)
+	}
+	payload := make([]byte, length)
+	n, err = io.ReadFull(fr.r, payload)
+	if err != nil {
+		return nil, fr.end(true, err)
+	}
+	if crc32.Checksum(payload, crcTable) != sum {
+		fr.done = true
+		return nil, errors.Wrapf(ErrCorrupt, "offset %d: checksum", fr.offset)
+	}
+	fr.offset += 8 + int64(n)
+	return payload, nil
+}
+
+// nextLine returns the next non-empty line of an NDJSON file
+func (fr *FileReader) nextLine() ([]byte, error) {
+	for {
+		line, err := fr.r.ReadBytes('\n')
+		fr.offset += int64(len(line))
+		if err == io.EOF {
+			// a line without a newline was cut off
+			return nil, fr.end(len(bytes.TrimSpace(line)) > 0, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// Close closes the file if the FileReader opened it
+func (fr *FileReader) Close() error {
+	if fr.closer == nil {
+		return nil
+	}
+	return fr.closer.Close()
+}
+
+// end handles an EOF.  A partial record is marked as truncated.
+func (fr *FileReader) end(partial bool, err error) error {
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	fr.done = true
+	fr.Truncated = partial
+	return io.EOF
+}
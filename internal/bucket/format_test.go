@@ -0,0 +1,134 @@
+package bucket
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFile(t *testing.T, records ...string) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, writeHeader(&buf, Header{Version: FileVersion, Build: "1.2.3", Prefix: "w2", Schema: SchemaServerEvent}))
+	for _, r := range records {
+		require.NoError(t, writeRecord(&buf, []byte(r)))
+	}
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, bb []byte) (*FileReader, []string, error) {
+	fr, err := NewFileReader(bytes.NewReader(bb))
+	require.NoError(t, err)
+	list := make([]string, 0)
+	for {
+		payload, err := fr.Next()
+		if err == io.EOF {
+			return fr, list, nil
+		}
+		if err != nil {
+			return fr, list, err
+		}
+		list = append(list, string(payload))
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	assert := assert.New(t)
+	bb := testFile(t, `{"a":1}`, `{"b":2}`)
+	var end bytes.Buffer
+	require.NoError(t, writeEnd(&end))
+	bb = append(bb, end.Bytes()...)
+
+	fr, list, err := readAll(t, bb)
+	assert.NoError(err)
+	assert.Equal([]string{`{"a":1}`, `{"b":2}`}, list)
+	assert.Equal(FormatBinary, fr.Format)
+	assert.Equal("1.2.3", fr.Header.Build)
+	assert.Equal(SchemaServerEvent, fr.Header.Schema)
+	assert.False(fr.Truncated)
+}
+
+func TestFileReaderTruncated(t *testing.T) {
+	assert := assert.New(t)
+	bb := testFile(t, `{"a":1}`, `{"b":2}`)
+	for _, cut := range []int{3, 10} { // in the length and in the payload
+		fr, list, err := readAll(t, bb[:len(bb)-cut])
+		assert.NoError(err)
+		assert.Equal([]string{`{"a":1}`}, list)
+		assert.True(fr.Truncated)
+	}
+}
+
+func TestFileReaderCorrupt(t *testing.T) {
+	assert := assert.New(t)
+	bb := testFile(t, `{"a":1}`, `{"b":2}`, `{"c":3}`)
+	bb[len(bb)-20] = 'X' // in the second record
+	_, list, err := readAll(t, bb)
+	assert.ErrorIs(err, ErrCorrupt)
+	assert.Equal([]string{`{"a":1}`}, list)
+
+	bb = testFile(t)
+	bb[len(magic)] = FileVersion + 1
+	_, err = NewFileReader(bytes.NewReader(bb))
+	assert.Error(err)
+
+	// a header cut off or that isn't JSON
+	bb = testFile(t)
+	_, err = NewFileReader(bytes.NewReader(bb[:len(magic)+3]))
+	assert.ErrorIs(err, ErrCorrupt)
+	_, err = NewFileReader(bytes.NewReader(bb[:len(bb)-2]))
+	assert.ErrorIs(err, ErrCorrupt)
+	bb[len(magic)+6] = 'X'
+	_, err = NewFileReader(bytes.NewReader(bb))
+	assert.ErrorIs(err, ErrCorrupt)
+}
+
+func TestFileReaderNDJSON(t *testing.T) {
+	assert := assert.New(t)
+	fr, list, err := readAll(t, []byte("{\"a\":1}\n\n{\"b\":2}\n{\"c\""))
+	assert.NoError(err)
+	assert.Equal(FormatNDJSON, fr.Format)
+	assert.Equal([]string{`{"a":1}`, `{"b":2}`}, list)
+	assert.True(fr.Truncated)
+
+	fr, list, err = readAll(t, nil)
+	assert.NoError(err)
+	assert.Empty(list)
+	assert.False(fr.Truncated)
+}
+
+func TestReaderMixedFiles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	fs := afero.NewMemMapFs()
+	dir := "cache"
+	ts := time.Now().UTC()
+	name := func(i int, ext string) string {
+		return filepath.Join(dir, fmt.Sprintf("w2_%s.%s", ts.Add(time.Duration(i)*time.Minute).Format("20060102_150405"), ext))
+	}
+	require.NoError(afero.WriteFile(fs, name(0, ExtNDJSON), []byte("{\"a\":1}\n"), 0644))
+	require.NoError(afero.WriteFile(fs, name(1, Ext), testFile(t, `{"b":2}`), 0644))
+	bad := testFile(t, `{"x":0}`)
+	bad[len(bad)-1] = 'X'
+	require.NoError(afero.WriteFile(fs, name(2, Ext), bad, 0644))
+	cut := testFile(t, `{"c":3}`, `{"y":0}`)
+	require.NoError(afero.WriteFile(fs, name(3, Ext), cut[:len(cut)-2], 0644))
+	require.NoError(afero.WriteFile(fs, name(4, Ext), testFile(t)[:len(magic)+4], 0644))
+
+	br := BucketReader{fs: fs, prefix: "w2", path: dir, Results: make(chan string)}
+	go br.StartReader()
+	list := make([]string, 0)
+	for str := range br.Results {
+		list = append(list, str)
+	}
+	assert.NoError(br.Err)
+	assert.Equal([]string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, list)
+	assert.Equal(2, br.Corrupt)
+	assert.Equal(1, br.Truncated)
+}
@@ -16,8 +16,9 @@ import (
 
 // PurgeFiles purges files based on the time stamp in the file name.
 // It requires \\EXE dir\dir\prefix_yyyymmdd_hhmmss.ext format
-// dir is the subdirectory off the EXE directory
-func PurgeFiles(dir, prefix, ext string, retain time.Duration) error {
+// dir is the subdirectory off the EXE directory.  If maxBytes is
+// more than zero, the oldest files are also purged to keep under it.
+func PurgeFiles(dir, prefix, ext string, retain time.Duration, maxBytes int64) error {
 	fs := afero.NewOsFs()
 	clk := clock.New()
 
@@ -29,7 +30,7 @@ func PurgeFiles(dir, prefix, ext string, retain time.Duration) error {
 	}
 	wd := filepath.Dir(exe)
 	path := filepath.Join(wd, dir)
-	return purgeFilesPath(fs, clk, path, prefix, ext, retain)
+	return purgeFiles(fs, clk, path, prefix, []string{ext}, retain, maxBytes)
 }
 
 // purgeServerFiles cleans out the old per server files
@@ -94,14 +95,17 @@ func PurgeFiles(dir, prefix, ext string, retain time.Duration) error {
 // 	return nil
 // }
 
-func purgeFilesPath(fs afero.Fs, clk clock.Clock, path, prefix, ext string, retain time.Duration) error {
+// purgeFiles deletes the files older than retain and then the oldest
+// files past maxBytes.  The newest file is always kept.  A zero retain
+// or maxBytes skips that check.
+func purgeFiles(fs afero.Fs, clk clock.Clock, path, prefix string, exts []string, retain time.Duration, maxBytes int64) error {
 	if path == "" {
 		path = "."
 	}
 	if prefix == "" {
 		return errors.New("prefix can't be empty")
 	}
-	if ext == "" {
+	if len(exts) == 0 {
 		return errors.New("extension can't be empty")
 	}
 
@@ -114,37 +118,66 @@ func purgeFilesPath(fs afero.Fs, clk clock.Clock, path, prefix, ext string, reta
 		return fmt.Errorf("path not found: %s", path)
 	}
 
-	pattern := filepath.Join(path, fmt.Sprintf("%s_*.%s", prefix, ext))
-	files, err := afero.Glob(fs, pattern)
-	if err != nil {
-		return errors.Wrap(err, "afero.glob")
-	}
-	sort.Strings(files)
-
-	regexStr := fmt.Sprintf(`\\%s_(?P<ts>\d{8}_\d{6})\.%s$`, prefix, ext)
-	rexp, err := regexp.Compile(regexStr)
-	if err != nil {
-		return errors.Wrap(err, "regex.compile")
+	type stampedFile struct {
+		name string
+		ts   time.Time
+		size int64
 	}
-	purgeThreshold := clk.Now().Add(retain * -1)
-
-	for _, name := range files {
-		matches := rexp.FindStringSubmatch(name)
-		if len(matches) < 2 {
-			continue
+	files := make([]stampedFile, 0)
+	for _, ext := range exts {
+		if ext == "" {
+			return errors.New("extension can't be empty")
+		}
+		rexp, err := regexp.Compile(fmt.Sprintf(`^%s_(?P<ts>\d{8}_\d{6})\.%s$`, regexp.QuoteMeta(prefix), regexp.QuoteMeta(ext)))
+		if err != nil {
+			return errors.Wrap(err, "regex.compile")
 		}
-		str := matches[1]
-		ts, err := time.Parse("20060102_150405", str)
+		pattern := filepath.Join(path, fmt.Sprintf("%s_*.%s", prefix, ext))
+		names, err := afero.Glob(fs, pattern)
 		if err != nil {
-			return errors.Wrap(err, "time.parse")
+			return errors.Wrap(err, "afero.glob")
 		}
-		if ts.Before(purgeThreshold) {
-			logrus.Tracef("purgefile: %s", name)
-			err = fs.Remove(name)
+		for _, name := range names {
+			matches := rexp.FindStringSubmatch(filepath.Base(name))
+			if len(matches) < 2 {
+				continue
+			}
+			ts, err := time.Parse("20060102_150405", matches[1])
 			if err != nil {
-				// just log any file delete errors and keep going
-				logrus.Error(errors.Wrap(err, "purgefile"))
+				return errors.Wrap(err, "time.parse")
+			}
+			fi, err := fs.Stat(name)
+			if os.IsNotExist(err) {
+				continue // compressed since the glob
 			}
+			if err != nil {
+				return errors.Wrap(err, "fs.stat")
+			}
+			files = append(files, stampedFile{name: name, ts: ts, size: fi.Size()})
+		}
+	}
+	// newest first
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].ts.Equal(files[j].ts) {
+			return files[i].name > files[j].name
+		}
+		return files[i].ts.After(files[j].ts)
+	})
+
+	purgeThreshold := clk.Now().Add(retain * -1)
+	var total int64
+	for i, f := range files {
+		total += f.size
+		old := retain > 0 && f.ts.Before(purgeThreshold)
+		full := maxBytes > 0 && total > maxBytes && i > 0
+		if !old && !full {
+			continue
+		}
+		logrus.Tracef("purgefile: %s", f.name)
+		err = fs.Remove(f.name)
+		if err != nil {
+			// just log any file delete errors and keep going
+			logrus.Error(errors.Wrap(err, "purgefile"))
 		}
 	}
 	return nil
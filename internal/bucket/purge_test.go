@@ -36,10 +36,52 @@ func TestPurge(t *testing.T) {
 	assert.Len(f1, 5)
 
 	//println("now:      ", clk.Now().Format(time.RFC3339))
-	err = purgeFilesPath(fs, clk, filepath.Join(".", "junk"), "myprefix", "zzz", 24*5*time.Hour)
+	err = purgeFiles(fs, clk, filepath.Join(".", "junk"), "myprefix", []string{"zzz"}, 24*5*time.Hour, 0)
 	assert.NoError(err)
 
 	f2, err := afero.ReadDir(fs, filepath.Join(".", "junk"))
 	require.NoError(err)
 	assert.Len(f2, 3)
 }
+
+func TestPurgeMaxBytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	fs := afero.NewMemMapFs()
+	clk := clock.NewMock()
+	clk.Set(time.Date(2025, time.January, 2, 10, 0, 0, 0, time.UTC))
+	dir := filepath.Join(".", "cache")
+
+	// six files of 100 bytes in three formats.  The newest is the biggest.
+	names := make([]string, 0)
+	for i, ext := range []string{"ndjson", "bucket.gz", "bucket.zst", "bucket.gz", "bucket.zst", "bucket"} {
+		name := filepath.Join(dir, fmt.Sprintf("w2_%s.%s", clk.Now().Format("20060102_150405"), ext))
+		size := 100
+		if i == 5 {
+			size = 500
+		}
+		require.NoError(afero.WriteFile(fs, name, make([]byte, size), 0644))
+		names = append(names, name)
+		clk.Add(10 * time.Minute)
+	}
+	require.NoError(afero.WriteFile(fs, filepath.Join(dir, "w2_junk.bucket"), make([]byte, 1000), 0644))
+
+	// the newest file is kept even though it is over the limit
+	require.NoError(purgeFiles(fs, clk, dir, "w2", exts, 24*time.Hour, 450))
+	left := func() []string {
+		files, err := afero.ReadDir(fs, dir)
+		require.NoError(err)
+		list := make([]string, 0)
+		for _, f := range files {
+			list = append(list, f.Name())
+		}
+		return list
+	}
+	assert.Equal([]string{filepath.Base(names[5]), "w2_junk.bucket"}, left())
+
+	// retention still applies without a limit
+	require.NoError(afero.WriteFile(fs, names[4], make([]byte, 100), 0644))
+	clk.Add(24 * time.Hour)
+	require.NoError(purgeFiles(fs, clk, dir, "w2", exts, 20*time.Minute, 0))
+	assert.Equal([]string{"w2_junk.bucket"}, left())
+}
@@ -1,7 +1,6 @@
 package bucket
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -9,8 +8,8 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
-	"github.com/scalesql/isitsql/internal/failure"
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/failure"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
@@ -21,6 +20,12 @@ type BucketReader struct {
 	Results chan string
 	prefix  string
 	path    string
+
+	// Truncated is the number of files with a cut off last record.
+	// Corrupt is the number of files with a bad record.  The rest of
+	// that file is skipped.
+	Truncated int
+	Corrupt   int
 }
 
 /*
@@ -74,55 +79,93 @@ func (br *BucketReader) StartReader() {
 	}()
 
 	// purge any old files
-	err := purgeFilesPath(br.fs, clock.New(), br.path, br.prefix, "ndjson", 85*time.Minute)
+	err := purgeFiles(br.fs, clock.New(), br.path, br.prefix, exts, 85*time.Minute, 0)
 	if err != nil {
-		logrus.Error(errors.Wrap(err, "purgefilespath"))
+		logrus.Error(errors.Wrap(err, "purgefiles"))
 	}
 
-	// list the files
-	pattern := filepath.Join(br.path, fmt.Sprintf("%s_*.ndjson", br.prefix))
-	files, err := afero.Glob(br.fs, pattern)
+	files, err := br.Files()
 	if err != nil {
-		br.Err = errors.Wrap(err, "filepath.glob")
-		logrus.Error(errors.Wrap(err, "filepath.glob"))
+		br.Err = err
+		logrus.Error(err)
 		return
 	}
-	sort.Strings(files)
 
 	for _, file := range files {
 		logrus.Tracef("startreader: reader: file: %s", file)
-		// open
-		fi, err := br.fs.Open(file)
+		err := br.readFile(file)
+		if errors.Is(err, ErrCorrupt) {
+			br.Corrupt++
+			logrus.Error(errors.Wrapf(err, "startreader: %s", file))
+			continue
+		}
 		if err != nil {
-			logrus.Error(errors.Wrap(err, "startreader: br.fs.open"))
+			logrus.Error(errors.Wrap(err, "startreader"))
 			br.Err = err
 			return
 		}
-		rdr := bufio.NewReader(fi)
-		var str string
-
-		// read the file and send
-		for err == nil {
-			str, err = rdr.ReadString('\n')
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				logrus.Error(errors.Wrap(err, "startreader: rdr.readstring"))
-				br.Err = err
-				return
+	}
+}
+
+// Files returns the files for the prefix, oldest first.
+// The NDJSON files are from older versions.  A file that also has a
+// compressed copy is skipped so its records aren't read twice.
+func (br *BucketReader) Files() ([]string, error) {
+	files := make([]string, 0)
+	for _, ext := range exts {
+		pattern := filepath.Join(br.path, fmt.Sprintf("%s_*.%s", br.prefix, ext))
+		matches, err := afero.Glob(br.fs, pattern)
+		if err != nil {
+			return files, errors.Wrap(err, "filepath.glob")
+		}
+		for _, name := range matches {
+			if hasCompressed(br.fs, name) {
+				continue
 			}
-			br.Results <- str
-			logrus.Tracef("sent bytes: %d", len(str))
+			files = append(files, name)
 		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// OpenFile returns a FileReader for one file.  Compressed files are
+// decompressed.  The FileReader should be closed.
+func (br *BucketReader) OpenFile(file string) (*FileReader, error) {
+	fi, err := openFile(br.fs, file)
+	if err != nil {
+		return nil, errors.Wrap(err, "openfile")
+	}
+	fr, err := NewFileReader(fi)
+	if err != nil {
+		fi.Close()
+		return nil, errors.Wrap(err, "newfilereader")
+	}
+	fr.closer = fi
+	return fr, nil
+}
 
-		// close
-		logrus.Tracef("startreader: reader: closing: %s", file)
-		err = fi.Close()
+// readFile sends the records in one file
+func (br *BucketReader) readFile(file string) error {
+	fr, err := br.OpenFile(file)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	for {
+		payload, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			logrus.Error(errors.Wrap(err, "startreader: fi.close"))
-			br.Err = err
-			return
+			return err
 		}
+		br.Results <- string(payload)
+		logrus.Tracef("sent bytes: %d", len(payload))
+	}
+	if fr.Truncated {
+		br.Truncated++
+		logrus.Infof("startreader: %s: skipped a truncated record", file)
 	}
+	return nil
 }
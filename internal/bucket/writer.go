@@ -9,6 +9,8 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
@@ -22,11 +24,26 @@ type BucketWriter struct {
 	path         string
 	fileDuration time.Duration
 	retain       time.Duration
+	opts         Options
+	size         int64 // bytes written to the current file
 	sync.RWMutex
+
+	// compressing counts the closed files being compressed
+	compressing sync.WaitGroup
 }
 
 // Start a BucketWriter
 func (bw *BucketWriter) Start(path, prefix string) error {
+	return bw.StartOptions(path, prefix, Options{})
+}
+
+// StartOptions starts a BucketWriter that compresses closed files,
+// rolls files by size, or limits the total size based on opts
+func (bw *BucketWriter) StartOptions(path, prefix string, opts Options) error {
+	err := opts.Validate()
+	if err != nil {
+		return err
+	}
 	bw.Lock()
 	defer bw.Unlock()
 	if bw.fs == nil {
@@ -43,9 +60,19 @@ func (bw *BucketWriter) Start(path, prefix string) error {
 	// retention is retain + 2 * fileDuration
 	bw.fileDuration = 10 * time.Minute
 	bw.retain = 60 * time.Minute
+	bw.opts = opts
+
+	err = removeTemp(bw.fs, bw.path, bw.prefix)
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "bucket: removetemp"))
+	}
+	err = removeCompressed(bw.fs, bw.path, bw.prefix)
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "bucket: removecompressed"))
+	}
 
 	// open a new file
-	err := bw.open()
+	err = bw.open()
 	if err != nil {
 		return errors.Wrap(err, "open")
 	}
@@ -72,7 +99,8 @@ func (bw *BucketWriter) Write(mapkey string, v interface{}) error {
 	}
 
 	// rollover if needed
-	if bw.clock.Now().After(bw.fileStart.Add(bw.fileDuration)) || bw.file == nil {
+	full := bw.opts.MaxFileMB > 0 && bw.size >= int64(bw.opts.MaxFileMB)<<20
+	if bw.clock.Now().After(bw.fileStart.Add(bw.fileDuration)) || bw.file == nil || full {
 		err = bw.rollover()
 		if err != nil {
 			return errors.Wrap(err, "bw.rolloverfile")
@@ -81,15 +109,11 @@ func (bw *BucketWriter) Write(mapkey string, v interface{}) error {
 	if bw.file == nil {
 		return nil
 	}
-	f := *bw.file
-	_, err = f.Write(bb)
+	err = writeRecord(*bw.file, bb)
 	if err != nil {
-		return errors.Wrap(err, "write")
-	}
-	_, err = f.WriteString("\n")
-	if err != nil {
-		return errors.Wrap(err, "writestring")
+		return errors.Wrap(err, "writerecord")
 	}
+	bw.size += int64(8 + len(bb))
 
 	// write to the server specific file ==============================================
 	// start := time.Now()
@@ -142,7 +166,13 @@ func (bw *BucketWriter) close() error {
 		return nil
 	}
 	f := *bw.file
-	err := f.Close()
+	err := writeEnd(f)
+	if err != nil {
+		f.Close()
+		bw.file = nil
+		return errors.Wrap(err, "writeend")
+	}
+	err = f.Close()
 	bw.file = nil
 	return err
 }
@@ -157,33 +187,74 @@ func (bw *BucketWriter) open() error {
 			return errors.Wrap(err, "mkdirall")
 		}
 	}
-	name := fmt.Sprintf("%s/%s_%s.ndjson", bw.path, bw.prefix, bw.clock.Now().UTC().Format("20060102_150405"))
-	f, err := bw.fs.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0660)
+	name := bw.fileName()
+	f, err := bw.fs.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660)
 	if err != nil {
 		return errors.Wrap(err, "openfile")
 	}
+	h := Header{
+		Version: FileVersion,
+		Build:   Build,
+		Prefix:  bw.prefix,
+		Schema:  SchemaServerEvent,
+		Created: bw.clock.Now().UTC(),
+	}
+	err = writeHeader(f, h)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "writeheader")
+	}
 	bw.file = &f
 	bw.fileStart = bw.clock.Now()
+	bw.size = 0
 	return nil
 }
 
-// rollover closes the cache file and opens another
+// fileName is the name of a file opened now
+func (bw *BucketWriter) fileName() string {
+	return fmt.Sprintf("%s/%s_%s.%s", bw.path, bw.prefix, bw.clock.Now().UTC().Format("20060102_150405"), Ext)
+}
+
+// rollover closes the cache file and opens another.  The closed
+// file is compressed in the background if that is set so writes
+// don't wait on it.
 func (bw *BucketWriter) rollover() error {
 	var err error
 
+	// file names are by the second so we keep writing until the name changes
+	var closed string
+	if bw.file != nil {
+		closed = (*bw.file).Name()
+		if closed == bw.fileName() {
+			return nil
+		}
+	}
+
 	err = bw.close()
 	if err != nil {
 		return errors.Wrap(err, "close")
 	}
+	if closed != "" && bw.opts.Compression != CompressNone {
+		bw.compressing.Add(1)
+		go func(fs afero.Fs, name, compression string) {
+			defer failure.HandlePanic()
+			defer bw.compressing.Done()
+			err := compressFile(fs, name, compression)
+			if err != nil {
+				// the file is still readable uncompressed
+				logrus.Error(errors.Wrapf(err, "bucket: compress: %s", name))
+			}
+		}(bw.fs, closed, bw.opts.Compression)
+	}
 
 	err = bw.open()
 	if err != nil {
 		return errors.Wrap(err, "open")
 	}
 
-	err = purgeFilesPath(bw.fs, clock.New(), bw.path, bw.prefix, "ndjson", 85*time.Minute)
+	err = purgeFiles(bw.fs, bw.clock, bw.path, bw.prefix, exts, 85*time.Minute, int64(bw.opts.MaxMB)<<20)
 	if err != nil {
-		return errors.Wrap(err, "purgeFilesPath")
+		return errors.Wrap(err, "purgefiles")
 	}
 	return nil
 }
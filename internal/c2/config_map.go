@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/scalesql/isitsql/internal/custommetric"
+	"github.com/scalesql/isitsql/internal/maint"
 	"github.com/scalesql/isitsql/internal/tags"
 	"gobn.github.io/coalesce"
 )
@@ -21,6 +23,8 @@ type ConfigMaps struct {
 	Files       []string
 	Connections ConnectionMap
 	AGs         AGMap
+	Maintenance []maint.Window
+	Metrics     []custommetric.Metric
 }
 
 func makeMap(names []string, files []ConnectionFile) (ConfigMaps, []string) {
@@ -29,7 +33,11 @@ func makeMap(names []string, files []ConnectionFile) (ConfigMaps, []string) {
 	var idregex = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9-_\.]*[a-zA-Z0-9-_]{0,1})$`)
 	cm := make(ConnectionMap, 0)
 	agm := make(AGMap, 0)
-	for _, cf := range files {
+	windows := make([]maint.Window, 0)
+	windowNames := make(map[string]bool)
+	metrics := make([]custommetric.Metric, 0)
+	metricNames := make(map[string]bool)
+	for fileIndex, cf := range files {
 		for _, i := range cf.Instances {
 			conn := Connection{Tags: []string{}, IgnoreBackupsList: []string{}}
 			// Assign any defaults
@@ -74,6 +82,9 @@ func makeMap(names []string, files []ConnectionFile) (ConfigMaps, []string) {
 				//conn.IgnoreBackupsList = *i.IgnoreBackupsList
 				conn.IgnoreBackupsList = tags.Merge(&conn.IgnoreBackupsList, i.IgnoreBackupsList)
 			}
+			if i.Repository != nil {
+				conn.Repository = i.Repository
+			}
 			// lower-case the database list
 			for j := range conn.IgnoreBackupsList {
 				conn.IgnoreBackupsList[j] = strings.ToLower(conn.IgnoreBackupsList[j])
@@ -97,6 +108,39 @@ func makeMap(names []string, files []ConnectionFile) (ConfigMaps, []string) {
 			agm[agkey] = ag.DisplayName
 		}
 		fileConfig.AGs = agm
+
+		fileName := ""
+		if fileIndex < len(names) {
+			fileName = names[fileIndex]
+		}
+		for _, mb := range cf.Maintenance {
+			w, err := mb.Window(fileName)
+			if err != nil {
+				msgs = append(msgs, err.Error())
+				continue
+			}
+			if windowNames[strings.ToLower(w.Name)] {
+				msgs = append(msgs, fmt.Sprintf("duplicate maintenance window: '%s'", w.Name))
+				continue
+			}
+			windowNames[strings.ToLower(w.Name)] = true
+			windows = append(windows, w)
+		}
+		for _, mb := range cf.Metrics {
+			m, err := mb.Metric(fileName)
+			if err != nil {
+				msgs = append(msgs, err.Error())
+				continue
+			}
+			if metricNames[m.Name] {
+				msgs = append(msgs, fmt.Sprintf("duplicate metric: '%s'", m.Name))
+				continue
+			}
+			metricNames[m.Name] = true
+			metrics = append(metrics, m)
+		}
 	}
+	fileConfig.Maintenance = windows
+	fileConfig.Metrics = metrics
 	return fileConfig, msgs
 }
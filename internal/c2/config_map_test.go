@@ -3,7 +3,9 @@ package c2
 import (
 	"testing"
 
+	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEmptyFile(t *testing.T) {
@@ -105,3 +107,92 @@ func TestMergedTags(t *testing.T) {
 	assert.NotNil(conn1)
 	assert.Equal([]string{"a", "base", "new"}, conn1.Tags)
 }
+
+func TestMaintenanceWindows(t *testing.T) {
+	assert := assert.New(t)
+	cf := ConnectionFile{
+		Maintenance: []MaintenanceBlock{
+			{Name: "patch", Servers: ptr([]string{"A"}), Start: ptr("2025-01-02 20:00"), End: ptr("2025-01-02 23:00")},
+			{Name: "weekly", Tags: ptr([]string{"dev"}), Cron: ptr("0 22 * * SAT"), Duration: ptr("4h")},
+		},
+	}
+	cf2 := ConnectionFile{
+		Maintenance: []MaintenanceBlock{
+			{Name: "Patch", Servers: ptr([]string{"b"}), Start: ptr("2025-01-02 20:00"), End: ptr("2025-01-02 23:00")},
+			{Name: "bad", Servers: ptr([]string{"b"}), Cron: ptr("0 22 * * SAT")},
+		},
+	}
+	fc, msgs := makeMap([]string{"f1.hcl", "f2.hcl"}, []ConnectionFile{cf, cf2})
+	assert.Equal(2, len(msgs))
+	assert.Len(fc.Maintenance, 2)
+	assert.Equal([]string{"a"}, fc.Maintenance[0].Servers)
+	assert.Equal("f1.hcl", fc.Maintenance[1].File)
+}
+
+func TestMaintenanceHCL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	blocks := []MaintenanceBlock{
+		{Name: "patch", Servers: ptr([]string{"a", "b"}), Start: ptr("2025-01-02T20:00:00-06:00"), End: ptr("2025-01-02T23:00:00-06:00"), Comment: ptr(`D40\SQL2016 "cu"`)},
+		{Name: "weekly", AGNames: ptr([]string{"ag1"}), Cron: ptr("0 22 * * SAT"), Duration: ptr("4h0m0s")},
+	}
+	bb := FixSlashes(maintenanceHCL(blocks))
+	cf := ConnectionFile{}
+	require.NoError(hclsimple.Decode("maintenance.hcl", bb, nil, &cf))
+	blocks[0].Comment = ptr(`D40\SQL2016 'cu'`)
+	assert.Equal(blocks, cf.Maintenance)
+
+	w, err := cf.Maintenance[1].Window("maintenance.hcl")
+	require.NoError(err)
+	assert.Equal(cf.Maintenance[1], MaintenanceBlockFrom(w))
+}
+
+func TestRepositoryOverride(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	body := []byte(`
+server "a" {
+	repository = false
+}
+server "b" {}
+`)
+	cf := ConnectionFile{}
+	require.NoError(hclsimple.Decode("servers.hcl", body, nil, &cf))
+	fc, msgs := makeMap([]string{"servers.hcl"}, []ConnectionFile{cf})
+	assert.Zero(len(msgs))
+	require.NotNil(fc.Connections["a"].Repository)
+	assert.False(*fc.Connections["a"].Repository)
+	assert.Nil(fc.Connections["b"].Repository)
+}
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	body := []byte(`
+metric "queue_depth" {
+	query = "SELECT COUNT(*) FROM app.dbo.queue"
+	unit = "rows"
+	tags = ["App"]
+}
+metric "orders" {
+	query = "SELECT cntr_value FROM sys.dm_os_performance_counters WHERE counter_name = 'Orders'"
+	accumulating = true
+}
+metric "no-query" {
+	query = ""
+}
+`)
+	cf := ConnectionFile{}
+	require.NoError(hclsimple.Decode("metrics.hcl", body, nil, &cf))
+	cf2 := ConnectionFile{
+		Metrics: []MetricBlock{{Name: "Queue_Depth", Query: "SELECT 1"}},
+	}
+	fc, msgs := makeMap([]string{"metrics.hcl", "f2.hcl"}, []ConnectionFile{cf, cf2})
+	assert.Equal(2, len(msgs))
+	require.Len(fc.Metrics, 2)
+	assert.Equal("queue_depth", fc.Metrics[0].Name)
+	assert.Equal("rows", fc.Metrics[0].Unit)
+	assert.Equal([]string{"app"}, fc.Metrics[0].Tags)
+	assert.Equal("metrics.hcl", fc.Metrics[0].File)
+	assert.True(fc.Metrics[1].Accumulating)
+}
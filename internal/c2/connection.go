@@ -11,6 +11,7 @@ type Connection struct {
 	CredentialName    string
 	IgnoreBackups     bool
 	IgnoreBackupsList []string
+	Repository        *bool // overrides the repository tag filter
 	Alias             bool
 }
 
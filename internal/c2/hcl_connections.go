@@ -1,9 +1,11 @@
 package c2
 
 type ConnectionFile struct {
-	Defaults  *Defaults  `hcl:"defaults,block"`
-	Instances []Instance `hcl:"server,block"`
-	AGNames   []AGName   `hcl:"ag_name,block"`
+	Defaults    *Defaults          `hcl:"defaults,block"`
+	Instances   []Instance         `hcl:"server,block"`
+	AGNames     []AGName           `hcl:"ag_name,block"`
+	Maintenance []MaintenanceBlock `hcl:"maintenance,block"`
+	Metrics     []MetricBlock      `hcl:"metric,block"`
 }
 
 type Defaults struct {
@@ -22,6 +24,7 @@ type Instance struct {
 	Credential        *string   `hcl:"credential"`
 	IgnoreBackups     *bool     `hcl:"ignore_backups"`
 	IgnoreBackupsList *[]string `hcl:"ignore_backups_list"`
+	Repository        *bool     `hcl:"repository"`
 
 	// This an alias for multiple machines
 	// such as a Listener or static DNS
@@ -33,3 +36,26 @@ type AGName struct {
 	Name        string `hcl:"name"`
 	DisplayName string `hcl:"display_name"`
 }
+
+// MaintenanceBlock is a one-off (start and end) or
+// recurring (cron and duration) maintenance window
+type MaintenanceBlock struct {
+	Name     string    `hcl:"name,label"`
+	Servers  *[]string `hcl:"servers"`
+	Tags     *[]string `hcl:"tags"`
+	AGNames  *[]string `hcl:"ag_names"`
+	Start    *string   `hcl:"start"`
+	End      *string   `hcl:"end"`
+	Cron     *string   `hcl:"cron"`
+	Duration *string   `hcl:"duration"`
+	Comment  *string   `hcl:"comment"`
+}
+
+// MetricBlock is a custom SQL metric for the servers with any of the tags
+type MetricBlock struct {
+	Name         string    `hcl:"name,label"`
+	Query        string    `hcl:"query"`
+	Accumulating *bool     `hcl:"accumulating"`
+	Unit         *string   `hcl:"unit"`
+	Tags         *[]string `hcl:"tags"`
+}
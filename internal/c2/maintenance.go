@@ -0,0 +1,175 @@
+package c2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/maint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MaintenanceFileName is the file in /servers that is edited by the web site
+const MaintenanceFileName = "maintenance.hcl"
+
+// Window converts the block to a validated maintenance window
+func (mb MaintenanceBlock) Window(file string) (maint.Window, error) {
+	w := maint.Window{
+		Name:    mb.Name,
+		File:    file,
+		Servers: deref(mb.Servers),
+		Tags:    deref(mb.Tags),
+		AGs:     deref(mb.AGNames),
+	}
+	var err error
+	if mb.Start != nil {
+		if w.Start, err = maint.ParseTime(*mb.Start); err != nil {
+			return w, errors.Wrapf(err, "maintenance: %s: start", mb.Name)
+		}
+	}
+	if mb.End != nil {
+		if w.End, err = maint.ParseTime(*mb.End); err != nil {
+			return w, errors.Wrapf(err, "maintenance: %s: end", mb.Name)
+		}
+	}
+	if mb.Cron != nil {
+		w.Cron = strings.TrimSpace(*mb.Cron)
+	}
+	if mb.Duration != nil {
+		if w.Duration, err = time.ParseDuration(*mb.Duration); err != nil {
+			return w, errors.Wrapf(err, "maintenance: %s: duration", mb.Name)
+		}
+	}
+	if mb.Comment != nil {
+		w.Comment = *mb.Comment
+	}
+	err = w.Validate()
+	return w, err
+}
+
+// MaintenanceBlockFrom converts a window back to a block for writing
+func MaintenanceBlockFrom(w maint.Window) MaintenanceBlock {
+	mb := MaintenanceBlock{Name: w.Name}
+	if len(w.Servers) > 0 {
+		mb.Servers = ptr(w.Servers)
+	}
+	if len(w.Tags) > 0 {
+		mb.Tags = ptr(w.Tags)
+	}
+	if len(w.AGs) > 0 {
+		mb.AGNames = ptr(w.AGs)
+	}
+	if w.Recurring() {
+		mb.Cron = ptr(w.Cron)
+		mb.Duration = ptr(w.Duration.String())
+	} else {
+		mb.Start = ptr(w.Start.Format(time.RFC3339))
+		mb.End = ptr(w.End.Format(time.RFC3339))
+	}
+	if w.Comment != "" {
+		mb.Comment = ptr(w.Comment)
+	}
+	return mb
+}
+
+// MaintenanceFile returns the full path to the maintenance file
+func MaintenanceFile() (string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", errors.Wrap(err, "path")
+	}
+	return filepath.Join(path, MaintenanceFileName), nil
+}
+
+// ReadMaintenanceFile returns the windows in the maintenance file.
+// A missing file has no windows.
+func ReadMaintenanceFile() ([]MaintenanceBlock, error) {
+	fileName, err := MaintenanceFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return []MaintenanceBlock{}, nil
+	}
+	bb, err := ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "readfile")
+	}
+	cf := ConnectionFile{}
+	err = hclsimple.Decode(fileName, bb, nil, &cf)
+	if err != nil {
+		return nil, err
+	}
+	return cf.Maintenance, nil
+}
+
+// WriteMaintenanceFile replaces the maintenance file with these windows
+func WriteMaintenanceFile(blocks []MaintenanceBlock) error {
+	fileName, err := MaintenanceFile()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(fileName), 0750)
+	if err != nil {
+		return errors.Wrap(err, "os.mkdirall")
+	}
+	err = os.WriteFile(fileName, maintenanceHCL(blocks), 0600)
+	if err != nil {
+		return errors.Wrap(err, "os.writefile")
+	}
+	return nil
+}
+
+// maintenanceHCL formats the windows as HCL
+func maintenanceHCL(blocks []MaintenanceBlock) []byte {
+	f := hclwrite.NewEmptyFile()
+	root := f.Body()
+	for i, mb := range blocks {
+		if i > 0 {
+			root.AppendNewline()
+		}
+		body := root.AppendNewBlock("maintenance", []string{mb.Name}).Body()
+		setList(body, "servers", mb.Servers)
+		setList(body, "tags", mb.Tags)
+		setList(body, "ag_names", mb.AGNames)
+		setString(body, "start", mb.Start)
+		setString(body, "end", mb.End)
+		setString(body, "cron", mb.Cron)
+		setString(body, "duration", mb.Duration)
+		setString(body, "comment", mb.Comment)
+	}
+	return f.Bytes()
+}
+
+// hclSafe removes characters that would be escaped with a back slash.
+// ReadFile doubles back slashes so escapes don't survive a read.
+var hclSafe = strings.NewReplacer(`"`, "'", "\r", " ", "\n", " ", "\t", " ")
+
+func setString(body *hclwrite.Body, name string, v *string) {
+	if v == nil {
+		return
+	}
+	body.SetAttributeValue(name, cty.StringVal(hclSafe.Replace(*v)))
+}
+
+func setList(body *hclwrite.Body, name string, v *[]string) {
+	if v == nil || len(*v) == 0 {
+		return
+	}
+	vals := make([]cty.Value, 0, len(*v))
+	for _, s := range *v {
+		vals = append(vals, cty.StringVal(hclSafe.Replace(s)))
+	}
+	body.SetAttributeValue(name, cty.ListVal(vals))
+}
+
+func deref(v *[]string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return *v
+}
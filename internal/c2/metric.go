@@ -0,0 +1,23 @@
+package c2
+
+import (
+	"github.com/scalesql/isitsql/internal/custommetric"
+)
+
+// Metric converts the block to a validated custom metric
+func (mb MetricBlock) Metric(file string) (custommetric.Metric, error) {
+	m := custommetric.Metric{
+		Name:  mb.Name,
+		Query: mb.Query,
+		Tags:  deref(mb.Tags),
+		File:  file,
+	}
+	if mb.Accumulating != nil {
+		m.Accumulating = *mb.Accumulating
+	}
+	if mb.Unit != nil {
+		m.Unit = *mb.Unit
+	}
+	err := m.Validate()
+	return m, err
+}
@@ -0,0 +1,287 @@
+/*
+Package collector runs the steps of a server poll.
+
+Each collector has a name, how often it runs, how long it may take,
+the lowest SQL Server version it supports, and what a failure does to
+the rest of the poll.  A Registry runs them in order for one target and
+a Schedule keeps when each one ran and how it went for that target.
+*/
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is used by collectors without a timeout
+var DefaultTimeout = 30 * time.Second
+
+// ErrPollTimeout is returned when the poll runs out of time before a collector starts
+var ErrPollTimeout = errors.New("poll timeout")
+
+// Policy is what the poll does when a collector fails
+type Policy int
+
+const (
+	// Continue records the error and runs the next collector
+	Continue Policy = iota
+	// Stop records the error and ends the poll.  It is used for the
+	// connection and server details the other collectors need.
+	Stop
+)
+
+func (p Policy) String() string {
+	if p == Stop {
+		return "stop"
+	}
+	return "continue"
+}
+
+// Spec describes when and how a collector runs
+type Spec struct {
+	Name       string
+	Interval   time.Duration // zero runs on every poll
+	Timeout    time.Duration // zero is DefaultTimeout
+	MinVersion int           // the lowest SQL Server major version
+	Policy     Policy
+	// Connect marks a collector whose failure means the target
+	// couldn't be reached rather than that one query failed
+	Connect bool
+}
+
+// Collector gathers one part of a poll for a target such as a server
+type Collector[T any] interface {
+	Spec() Spec
+	Collect(ctx context.Context, target T) error
+}
+
+// Func is a Collector that calls a function
+type Func[T any] struct {
+	spec Spec
+	fn   func(context.Context, T) error
+}
+
+// New returns a Collector that calls fn
+func New[T any](spec Spec, fn func(context.Context, T) error) Func[T] {
+	return Func[T]{spec: spec, fn: fn}
+}
+
+// Spec returns the spec
+func (f Func[T]) Spec() Spec { return f.spec }
+
+// Collect calls the function
+func (f Func[T]) Collect(ctx context.Context, target T) error { return f.fn(ctx, target) }
+
+// Registry is a list of collectors that run in the order they are registered
+type Registry[T any] struct {
+	// Version returns the major version of the target.  It is read before
+	// each collector since an earlier collector may set it.  If it is nil
+	// or returns zero, MinVersion is ignored.
+	Version func(T) int
+
+	// OnChange is called when a collector starts failing, its error
+	// changes, or it succeeds after failing.  It can be nil.
+	OnChange func(target T, spec Spec, st Status)
+
+	collectors []Collector[T]
+}
+
+// Register adds collectors.  It panics on an empty or duplicate name
+// since the registries are built at startup.
+func (r *Registry[T]) Register(cc ...Collector[T]) {
+	for _, c := range cc {
+		name := c.Spec().Name
+		if name == "" {
+			panic("collector: empty name")
+		}
+		for _, existing := range r.collectors {
+			if existing.Spec().Name == name {
+				panic(fmt.Sprintf("collector: duplicate name: %s", name))
+			}
+		}
+		r.collectors = append(r.collectors, c)
+	}
+}
+
+// Specs returns the specs in the order they run
+func (r *Registry[T]) Specs() []Spec {
+	specs := make([]Spec, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		specs = append(specs, c.Spec())
+	}
+	return specs
+}
+
+// Run runs the collectors that are due and records each result in the
+// schedule.  It returns the error of a collector with the Stop policy or
+// ErrPollTimeout if ctx ends first.  Other errors are only recorded.
+func (r *Registry[T]) Run(ctx context.Context, target T, sch *Schedule) error {
+	for _, c := range r.collectors {
+		spec := c.Spec()
+		if r.Version != nil {
+			if v := r.Version(target); v > 0 && v < spec.MinVersion {
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(ErrPollTimeout, spec.Name)
+		}
+		if !sch.due(spec, time.Now()) {
+			continue
+		}
+		err := run(ctx, c, target)
+		st, changed := sch.record(spec.Name, err)
+		if changed && r.OnChange != nil {
+			r.OnChange(target, spec, st)
+		}
+		if err != nil && spec.Policy == Stop {
+			return &StopError{Spec: spec, Err: err}
+		}
+	}
+	return nil
+}
+
+// StopError is returned by Run when a collector with the Stop policy fails
+type StopError struct {
+	Spec Spec
+	Err  error
+}
+
+func (e *StopError) Error() string {
+	return e.Spec.Name + ": " + e.Err.Error()
+}
+
+func (e *StopError) Unwrap() error {
+	return e.Err
+}
+
+// Unreachable is true if err is from a Connect collector
+func Unreachable(err error) bool {
+	var se *StopError
+	return errors.As(err, &se) && se.Spec.Connect
+}
+
+// run calls one collector with its timeout
+func run[T any](ctx context.Context, c Collector[T], target T) error {
+	timeout := c.Spec().Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := c.Collect(cctx, target)
+	if err != nil && errors.Is(cctx.Err(), context.DeadlineExceeded) {
+		return errors.Wrapf(err, "timeout after %s", timeout)
+	}
+	return err
+}
+
+// Status is how a collector last went for one target
+type Status struct {
+	Name        string        `json:"name"`
+	LastRun     time.Time     `json:"last_run"`
+	LastSuccess time.Time     `json:"last_success,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+	LastFail    time.Time     `json:"last_fail,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// Failing is true if the last run failed
+func (st Status) Failing() bool {
+	return st.LastError != ""
+}
+
+// Schedule keeps the status of each collector for one target.
+// The zero value is ready to use.
+type Schedule struct {
+	mu     sync.RWMutex
+	status map[string]*Status
+	order  []string
+	// running holds the start of the collector that is running
+	running map[string]time.Time
+}
+
+// due is true if the collector hasn't run within its interval.  It
+// marks the collector as running until record is called.
+func (sch *Schedule) due(spec Spec, now time.Time) bool {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	st, ok := sch.status[spec.Name]
+	if ok && spec.Interval > 0 && now.Sub(st.LastRun) < spec.Interval {
+		return false
+	}
+	if sch.running == nil {
+		sch.running = make(map[string]time.Time)
+	}
+	sch.running[spec.Name] = now
+	return true
+}
+
+// record saves the result of a run.  It returns true if the
+// collector started failing, failed differently, or recovered.
+func (sch *Schedule) record(name string, err error) (Status, bool) {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	now := time.Now()
+	start, ok := sch.running[name]
+	if !ok {
+		start = now
+	}
+	delete(sch.running, name)
+	if sch.status == nil {
+		sch.status = make(map[string]*Status)
+	}
+	st, ok := sch.status[name]
+	if !ok {
+		st = &Status{Name: name}
+		sch.status[name] = st
+		sch.order = append(sch.order, name)
+	}
+	previous := st.LastError
+	st.LastRun = start
+	st.Duration = now.Sub(start)
+	if err != nil {
+		st.LastError = err.Error()
+		st.LastFail = now
+	} else {
+		st.LastError = ""
+		st.LastSuccess = now
+	}
+	return *st, previous != st.LastError
+}
+
+// Reset makes every collector due on the next run.  It is used when
+// the server restarts or changes.  The errors are kept.
+func (sch *Schedule) Reset() {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	for _, st := range sch.status {
+		st.LastRun = time.Time{}
+	}
+}
+
+// Statuses returns the status of each collector in the order they first ran
+func (sch *Schedule) Statuses() []Status {
+	sch.mu.RLock()
+	defer sch.mu.RUnlock()
+	list := make([]Status, 0, len(sch.order))
+	for _, name := range sch.order {
+		list = append(list, *sch.status[name])
+	}
+	return list
+}
+
+// Failing returns the collectors whose last run failed
+func (sch *Schedule) Failing() []Status {
+	list := make([]Status, 0)
+	for _, st := range sch.Statuses() {
+		if st.Failing() {
+			list = append(list, st)
+		}
+	}
+	return list
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type target struct {
+	version int
+	ran     []string
+}
+
+func step(spec Spec, err error) Collector[*target] {
+	return New(spec, func(_ context.Context, t *target) error {
+		t.ran = append(t.ran, spec.Name)
+		return err
+	})
+}
+
+func TestRegistryRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	changes := make([]Status, 0)
+	r := Registry[*target]{
+		Version:  func(t *target) int { return t.version },
+		OnChange: func(_ *target, _ Spec, st Status) { changes = append(changes, st) },
+	}
+	r.Register(
+		step(Spec{Name: "connect", Policy: Stop}, nil),
+		step(Spec{Name: "backups", Interval: time.Hour}, errors.New("permission denied")),
+		step(Spec{Name: "ag", MinVersion: 12}, nil),
+		step(Spec{Name: "databases"}, nil),
+	)
+	assert.Len(r.Specs(), 4)
+
+	var sch Schedule
+	tgt := &target{version: 11}
+	require.NoError(r.Run(context.Background(), tgt, &sch))
+	// the failed collector doesn't stop the ones after it
+	assert.Equal([]string{"connect", "backups", "databases"}, tgt.ran)
+	failing := sch.Failing()
+	require.Len(failing, 1)
+	assert.Equal("backups", failing[0].Name)
+	assert.Equal("permission denied", failing[0].LastError)
+	assert.True(failing[0].LastSuccess.IsZero())
+	require.Len(changes, 1)
+	assert.Equal("backups", changes[0].Name)
+
+	// backups isn't due for an hour
+	tgt.ran = nil
+	tgt.version = 16
+	require.NoError(r.Run(context.Background(), tgt, &sch))
+	assert.Equal([]string{"connect", "ag", "databases"}, tgt.ran)
+	assert.Len(sch.Statuses(), 4)
+
+	sch.Reset()
+	tgt.ran = nil
+	require.NoError(r.Run(context.Background(), tgt, &sch))
+	assert.Contains(tgt.ran, "backups")
+	assert.Len(sch.Failing(), 1)
+	assert.Len(changes, 1)
+}
+
+func TestRegistryStop(t *testing.T) {
+	assert := assert.New(t)
+	var r Registry[*target]
+	r.Register(
+		step(Spec{Name: "connect", Policy: Stop}, errors.New("login failed")),
+		step(Spec{Name: "databases"}, nil),
+	)
+	var sch Schedule
+	tgt := &target{}
+	err := r.Run(context.Background(), tgt, &sch)
+	assert.EqualError(err, "connect: login failed")
+	assert.Equal([]string{"connect"}, tgt.ran)
+	assert.False(Unreachable(err))
+
+	// only a Connect collector means the target can't be reached
+	var unreachable Registry[*target]
+	unreachable.Register(step(Spec{Name: "connect", Policy: Stop, Connect: true}, errors.New("login failed")))
+	err = unreachable.Run(context.Background(), tgt, &Schedule{})
+	assert.EqualError(err, "connect: login failed")
+	assert.True(Unreachable(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.Run(ctx, tgt, &sch)
+	assert.ErrorIs(err, ErrPollTimeout)
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	assert := assert.New(t)
+	var r Registry[*target]
+	r.Register(New(Spec{Name: "slow", Timeout: 10 * time.Millisecond}, func(ctx context.Context, _ *target) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	var sch Schedule
+	assert.NoError(r.Run(context.Background(), &target{}, &sch))
+	st := sch.Statuses()
+	assert.Len(st, 1)
+	assert.Contains(st[0].LastError, "timeout after 10ms")
+	assert.GreaterOrEqual(st[0].Duration, 10*time.Millisecond)
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	var r Registry[*target]
+	r.Register(step(Spec{Name: "a"}, nil))
+	assert.Panics(t, func() { r.Register(step(Spec{Name: "a"}, nil)) })
+	assert.Panics(t, func() { r.Register(step(Spec{}, nil)) })
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tier"
 )
 
 // CPU records the usage of CPU at a particular time
@@ -30,6 +31,11 @@ type Ring struct {
 	buff []*CPU
 	head int
 	tail int
+
+	// older holds the points that age out of this ring averaged
+	// over bucket.Period.  It is nil without a longer history.
+	older  *Ring
+	bucket tier.Bucket[*CPU]
 }
 
 // New returns a new Ring of size
@@ -65,6 +71,9 @@ func (r *Ring) Len() int {
 // Enqueue a value into the Ring buffer.
 func (r *Ring) Enqueue(i *CPU) {
 	r.checkInit()
+	if r.head != -1 && r.mod(r.head+1) == r.tail {
+		r.age(r.get(r.tail))
+	}
 	r.set(r.head+1, i)
 	old := r.head
 	r.head = r.mod(r.head + 1)
@@ -131,6 +140,44 @@ func (r *Ring) Values() []*CPU {
 	return arr
 }
 
+// History returns the points after since from the oldest tier to the newest.
+// The older tiers are averaged so there are fewer points for longer spans.
+func (r *Ring) History(since time.Time) []*CPU {
+	arr := make([]*CPU, 0)
+	if r.older != nil {
+		arr = r.older.History(since)
+		for _, v := range r.bucket.Pending() {
+			if v.At.After(since) {
+				arr = append(arr, v)
+			}
+		}
+	}
+	for _, v := range r.Values() {
+		if v != nil && v.At.After(since) {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
+
+// Points returns the number of points in all the tiers
+func (r *Ring) Points() int {
+	n := r.Len()
+	if r.older != nil {
+		n += len(r.bucket.Pending()) + r.older.Points()
+	}
+	return n
+}
+
+// Size returns the number of points all the tiers can hold
+func (r *Ring) Size() int {
+	n := r.Capacity()
+	if r.older != nil {
+		n += r.older.Size()
+	}
+	return n
+}
+
 // MarshalJSON marshals to a byte array
 func (r Ring) MarshalJSON() ([]byte, error) {
 	var wr struct {
@@ -199,7 +246,48 @@ func (r *Ring) checkInit() {
 			r.buff[i] = w
 		}
 		r.head, r.tail = -1, 0
+		r.setTiers(tier.Default)
+	}
+}
+
+// setTiers adds the older tiers
+func (r *Ring) setTiers(sizes tier.Sizes) {
+	if sizes.Minute == 0 {
+		return
+	}
+	r.older = newTier(sizes.Minute)
+	r.bucket.Period = tier.Minute
+	if sizes.Five > 0 {
+		r.older.older = newTier(sizes.Five)
+		r.older.bucket.Period = tier.FiveMinute
+	}
+}
+
+// newTier returns an empty Ring for an older tier
+func newTier(size int) *Ring {
+	return &Ring{buff: make([]*CPU, size), head: -1}
+}
+
+// age moves a point that is leaving the ring to the older tier
+func (r *Ring) age(v *CPU) {
+	if r.older == nil || v == nil {
+		return
+	}
+	start, done := r.bucket.Add(v.At, v)
+	if len(done) > 0 {
+		r.older.Enqueue(average(start, done))
+	}
+}
+
+// average returns one point for a period
+func average(start time.Time, list []*CPU) *CPU {
+	var sql, other int
+	for _, v := range list {
+		sql += v.SQL
+		other += v.Other
 	}
+	n := len(list)
+	return &CPU{At: start, SQL: (sql + n/2) / n, Other: (other + n/2) / n}
 }
 
 // extend the Ring to the specified size.  Will reduce
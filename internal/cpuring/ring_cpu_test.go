@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/scalesql/isitsql/internal/tier"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -86,3 +87,52 @@ func TestCPURingEmpty(t *testing.T) {
 
 	r2.Enqueue(&CPU{time.Now(), 5, 55})
 }
+
+func TestCpuRingHistory(t *testing.T) {
+	assert := assert.New(t)
+	defer tier.SetHours(0)
+	tier.SetHours(8)
+	var r Ring
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	// one point a minute for 10 hours
+	for i := 0; i < 600; i++ {
+		r.Enqueue(&CPU{At: start.Add(time.Duration(i) * time.Minute), SQL: i % 2 * 10, Other: 2})
+	}
+	assert.Equal(60, r.Len())
+	assert.Equal(60+300+24, r.Size())
+
+	end := start.Add(599 * time.Minute)
+	all := r.History(time.Time{})
+	assert.Len(all, r.Points())
+	for i := 1; i < len(all); i++ {
+		assert.True(all[i-1].At.Before(all[i].At))
+	}
+	// the 5-minute tier
+	five := r.older.older.Values()
+	assert.Equal(5*time.Minute, five[1].At.Sub(five[0].At))
+	assert.InDelta(5, five[0].SQL, 1)
+
+	assert.Len(r.History(end.Add(-time.Hour)), 60)
+	assert.Len(r.History(end.Add(-2*time.Hour)), 120)
+	// raw, 1 pending raw, minute, 4 pending minutes, and 11 five-minute points
+	assert.Len(r.History(end.Add(-7*time.Hour)), 60+1+300+4+11)
+
+	// two points a minute are averaged
+	var r2 Ring
+	for i := 0; i < 64; i++ {
+		r2.Enqueue(&CPU{At: start.Add(time.Duration(i) * 30 * time.Second), SQL: i % 2 * 10, Other: 2})
+	}
+	minute := r2.older.Values()
+	assert.Len(minute, 1)
+	assert.Equal(5, minute[0].SQL)
+	assert.Equal(2, minute[0].Other)
+
+	// without tiers nothing is kept past the capacity
+	tier.SetHours(0)
+	var r1 Ring
+	for i := 0; i < 100; i++ {
+		r1.Enqueue(&CPU{At: start.Add(time.Duration(i) * time.Minute)})
+	}
+	assert.Equal(60, r1.Points())
+	assert.Len(r1.History(time.Time{}), 60)
+}
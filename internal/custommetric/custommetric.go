@@ -0,0 +1,152 @@
+// Package custommetric holds the SQL metrics defined in the HCL files.
+// Each one is a query that returns a single number.  It runs on the
+// servers with any of its tags or on every server if it has no tags.
+package custommetric
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tags"
+)
+
+// Prefix is added to the name in the metrics of a server
+// so a custom metric can't replace a built-in one
+const Prefix = "custom."
+
+// nameRegex keeps names safe for map keys, URLs, and Prometheus labels
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Metric is a query that returns one value
+type Metric struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Accumulating metrics only go up, like a counter since the server
+	// started.  They are charted as the change per second.
+	Accumulating bool     `json:"accumulating,omitempty"`
+	Unit         string   `json:"unit,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	File         string   `json:"file,omitempty"`
+}
+
+// Validate checks the metric and normalizes the name and tags to lower-case
+func (m *Metric) Validate() error {
+	m.Name = strings.ToLower(strings.TrimSpace(m.Name))
+	if m.Name == "" {
+		return errors.New("metric: name is required")
+	}
+	if !nameRegex.MatchString(m.Name) {
+		return fmt.Errorf("metric: '%s': use up to 64 letters, numbers, and underscores starting with a letter", m.Name)
+	}
+	m.Query = strings.TrimSpace(m.Query)
+	if m.Query == "" {
+		return fmt.Errorf("metric: %s: query is required", m.Name)
+	}
+	m.Unit = strings.TrimSpace(m.Unit)
+	m.Tags = tags.Merge(&m.Tags)
+	return nil
+}
+
+// Key is the name of the metric in the metrics of a server
+func (m Metric) Key() string {
+	return Prefix + m.Name
+}
+
+// Label is the name and unit for a chart
+func (m Metric) Label() string {
+	label := m.Name
+	if m.Accumulating {
+		label += " per second"
+	}
+	if m.Unit != "" {
+		label += " (" + m.Unit + ")"
+	}
+	return label
+}
+
+// MatchServer returns true if the metric has no tags or shares a tag with the server
+func (m Metric) MatchServer(serverTags []string) bool {
+	if len(m.Tags) == 0 {
+		return true
+	}
+	for _, t := range m.Tags {
+		for _, st := range serverTags {
+			if strings.EqualFold(t, st) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Set is the list of configured metrics
+type Set struct {
+	mu      sync.RWMutex
+	metrics []Metric
+}
+
+// NewSet returns an empty Set
+func NewSet() *Set {
+	return &Set{metrics: make([]Metric, 0)}
+}
+
+// Replace all the metrics.  They should already be validated.
+func (s *Set) Replace(mm []Metric) {
+	if s == nil {
+		return
+	}
+	sorted := make([]Metric, len(mm))
+	copy(sorted, mm)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = sorted
+}
+
+// Metrics returns all the metrics sorted by name
+func (s *Set) Metrics() []Metric {
+	if s == nil {
+		return []Metric{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Metric{}, s.metrics...)
+}
+
+// Server returns the metrics for a server sorted by name
+func (s *Set) Server(serverTags []string) []Metric {
+	list := make([]Metric, 0)
+	if s == nil {
+		return list
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.metrics {
+		if m.MatchServer(serverTags) {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// Get returns a metric by name
+func (s *Set) Get(name string) (Metric, bool) {
+	if s == nil {
+		return Metric{}, false
+	}
+	name = strings.ToLower(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
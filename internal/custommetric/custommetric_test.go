@@ -0,0 +1,55 @@
+package custommetric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	m := Metric{Name: " Queue_Depth ", Query: " SELECT 1 ", Unit: " rows ", Tags: []string{"App", "app"}}
+	assert.NoError(m.Validate())
+	assert.Equal("queue_depth", m.Name)
+	assert.Equal("SELECT 1", m.Query)
+	assert.Equal([]string{"app"}, m.Tags)
+	assert.Equal("custom.queue_depth", m.Key())
+	assert.Equal("queue_depth (rows)", m.Label())
+
+	bad := []Metric{
+		{Query: "SELECT 1"},
+		{Name: "no query"},
+		{Name: "1st", Query: "SELECT 1"},
+		{Name: "has-dash", Query: "SELECT 1"},
+	}
+	for _, m := range bad {
+		assert.Error(m.Validate(), m.Name)
+	}
+}
+
+func TestSet(t *testing.T) {
+	assert := assert.New(t)
+	mm := []Metric{
+		{Name: "orders", Query: "SELECT 1", Tags: []string{"app"}},
+		{Name: "batches", Query: "SELECT 2", Accumulating: true},
+	}
+	for i := range mm {
+		assert.NoError(mm[i].Validate())
+	}
+	s := NewSet()
+	s.Replace(mm)
+	assert.Equal("batches", s.Metrics()[0].Name)
+	assert.Equal("batches per second", s.Metrics()[0].Label())
+
+	assert.Len(s.Server([]string{"App"}), 2)
+	assert.Len(s.Server(nil), 1)
+	m, ok := s.Get("Orders")
+	assert.True(ok)
+	assert.Equal("SELECT 1", m.Query)
+	_, ok = s.Get("missing")
+	assert.False(ok)
+
+	var nilSet *Set
+	assert.Len(nilSet.Server(nil), 0)
+	assert.Len(nilSet.Metrics(), 0)
+}
@@ -1,6 +1,7 @@
 package diskio
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
@@ -19,11 +20,11 @@ type VirtualFileStats struct {
 }
 
 // GetFileStats returns the file stats
-func GetFileStats(db *sql.DB) (VirtualFileStats, error) {
+func GetFileStats(ctx context.Context, db *sql.DB) (VirtualFileStats, error) {
 	var s VirtualFileStats
 	var err error
 
-	row := db.QueryRow(`
+	row := db.QueryRowContext(ctx, `
         SELECT	
             MAX(sample_ms) AS [Milliseconds],
             SUM(num_of_reads) AS [Reads],
@@ -21,16 +21,19 @@ import (
 // Repository holds the repository of real-time waits
 type Repository struct {
 	servers map[string]*waitring.Ring
+	totals  map[string]map[string]int64 // map_key -> wait group -> ms since startup
 	bw      bucket.BucketWriter
 	mu      sync.RWMutex
 	open    bool
 }
 
-// NewRepository returns a new Repository
-func NewRepository(ctx context.Context) (*Repository, error) {
+// NewRepository returns a new Repository.  opts control the compression
+// and size of the cache files.
+func NewRepository(ctx context.Context, opts bucket.Options) (*Repository, error) {
 	r := Repository{}
 	r.open = true
 	r.servers = make(map[string]*waitring.Ring)
+	r.totals = make(map[string]map[string]int64)
 
 	// start a writer
 	exe, err := os.Executable()
@@ -39,7 +42,11 @@ func NewRepository(ctx context.Context) (*Repository, error) {
 	}
 	dir := filepath.Dir(exe)
 	dir = filepath.Join(dir, "cache")
-	r.bw.Start(dir, "w2")
+	err = r.bw.StartOptions(dir, "w2", opts)
+	if err != nil {
+		r.bw.Start(dir, "w2") // keep caching without the options
+		return &r, errors.Wrap(err, "bw.startoptions")
+	}
 	return &r, nil
 }
 
@@ -106,6 +113,9 @@ Loop:
 	}
 	loadDuration := time.Since(loadStart)
 	logrus.Info(fmt.Sprintf("W2: Read: %s  Used: %s (%s)", humanize.Comma(nr), humanize.Comma(nw), loadDuration.String()))
+	if br.Truncated > 0 || br.Corrupt > 0 {
+		logrus.Warnf("W2: Read: truncated files: %d  corrupt files: %d", br.Truncated, br.Corrupt)
+	}
 
 	return nil
 }
@@ -149,6 +159,18 @@ func (r *Repository) Write(key string, waits waitring.WaitList) error {
 
 	ring.Enqueue(waits)
 	r.bw.Write(key, waits)
+
+	// history isn't counted so the totals only go up while we run
+	r.mu.Lock()
+	totals, ok := r.totals[key]
+	if !ok {
+		totals = make(map[string]int64)
+		r.totals[key] = totals
+	}
+	for wg, ms := range waits.Waits {
+		totals[wg] += ms
+	}
+	r.mu.Unlock()
 	return nil
 }
 
@@ -204,6 +226,21 @@ func (r *Repository) Top(key string, n int) waitring.SortedMapInt64 {
 	return ring.Top(5)
 }
 
+// Totals returns the wait time in milliseconds for each wait group
+// since IsItSQL started.  These only increase so they work as counters.
+func (r *Repository) Totals(key string) map[string]int64 {
+	totals := make(map[string]int64)
+	if r == nil {
+		return totals
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for wg, ms := range r.totals[key] {
+		totals[wg] = ms
+	}
+	return totals
+}
+
 func (r *Repository) Delete(key string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -211,4 +248,5 @@ func (r *Repository) Delete(key string) {
 		return
 	}
 	delete(r.servers, key)
+	delete(r.totals, key)
 }
@@ -66,7 +66,7 @@ func (ag *AG) IsHealthy() bool {
 }
 
 // GetNames returns all AG names and Listener names hosted by a server
-func GetNames(db *sql.DB) ([]string, error) {
+func GetNames(ctx context.Context, db *sql.DB) ([]string, error) {
 	var stmt string
 	list := make([]string, 0)
 	if DEV {
@@ -88,8 +88,6 @@ func GetNames(db *sql.DB) ([]string, error) {
 			FROM	sys.availability_group_listeners
 		`
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	rows, err := db.QueryContext(ctx, stmt)
 	if err != nil {
 		return []string{}, errors.Wrap(err, "db.query")
@@ -109,7 +107,7 @@ func GetNames(db *sql.DB) ([]string, error) {
 }
 
 // GetAGList gets a list of all AGs hosted on this node
-func GetAGList(db *sql.DB, serverName string) (map[string]*AG, error) {
+func GetAGList(ctx context.Context, db *sql.DB, serverName string) (map[string]*AG, error) {
 	m := make(map[string]*AG)
 	var sql string
 	var err error
@@ -146,8 +144,6 @@ func GetAGList(db *sql.DB, serverName string) (map[string]*AG, error) {
 			`
 	}
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
-	defer cancel()
 	rows, err := db.QueryContext(ctx, sql)
 	if err != nil {
 		dur := time.Since(start).String()
@@ -175,7 +171,7 @@ func GetAGList(db *sql.DB, serverName string) (map[string]*AG, error) {
 			ag.isHealthy = true
 		}
 
-		err = ag.getNodes(db, ag.GUID)
+		err = ag.getNodes(ctx, db, ag.GUID)
 		if err != nil {
 			return m, errors.Wrap(err, "ag-get-nodes")
 		}
@@ -229,7 +225,7 @@ func GetAGList(db *sql.DB, serverName string) (map[string]*AG, error) {
 `
 	}
 
-	r2, err := db.Query(sql)
+	r2, err := db.QueryContext(ctx, sql)
 	if err != nil {
 		return m, errors.Wrap(err, "listener")
 	}
@@ -250,7 +246,7 @@ func GetAGList(db *sql.DB, serverName string) (map[string]*AG, error) {
 	return m, nil
 }
 
-func (ag *AG) getNodes(db *sql.DB, aguid string) error {
+func (ag *AG) getNodes(ctx context.Context, db *sql.DB, aguid string) error {
 	var rows *sql.Rows
 	var err error
 	var sql string
@@ -305,8 +301,6 @@ func (ag *AG) getNodes(db *sql.DB, aguid string) error {
 
 					`
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	rows, err = db.QueryContext(ctx, sql, aguid, aguid)
 	if err != nil {
 		return errors.Wrap(err, "query ag details")
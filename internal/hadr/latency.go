@@ -1,6 +1,7 @@
 package hadr
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/jmoiron/sqlx"
@@ -16,14 +17,15 @@ type Latency struct {
 	SecondaryLagSeconds int    `db:"secondary_lag_seconds"`
 }
 
-func SetLatency(db *sql.DB) error {
+// SetLatency reads the replica queues into PublicAGMap
+func SetLatency(ctx context.Context, db *sql.DB) error {
 	sqlxdb := sqlx.NewDb(db, "mssql")
 	latenciesFromDB := []Latency{}
 	stmt := getLatencyStatement
 	if DEV {
 		stmt += getLatencyStatementDEV
 	}
-	err := sqlxdb.Select(&latenciesFromDB, stmt)
+	err := sqlxdb.SelectContext(ctx, &latenciesFromDB, stmt)
 	if err != nil {
 		return errors.Wrap(err, "sqlx.select")
 	}
@@ -1,6 +1,7 @@
 package hadr
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -77,7 +78,7 @@ func (d *ReplicaDatabase) State() string {
 }
 
 // GetReplicaDatabases returns a list of databases that are on the current node
-func GetReplicaDatabases(db *sql.DB) (map[int]ReplicaDatabase, error) {
+func GetReplicaDatabases(ctx context.Context, db *sql.DB) (map[int]ReplicaDatabase, error) {
 	m := make(map[int]ReplicaDatabase)
 	//var sql string
 	var err error
@@ -90,7 +91,7 @@ func GetReplicaDatabases(db *sql.DB) (map[int]ReplicaDatabase, error) {
 	if DEV {
 		stmt += DEVgetDatabasesStatement
 	}
-	err = sqlxdb.Select(&dbs, stmt)
+	err = sqlxdb.SelectContext(ctx, &dbs, stmt)
 	if err != nil {
 		return m, errors.Wrap(err, "sqlx.select")
 	}
@@ -0,0 +1,160 @@
+package maint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule is a parsed five field cron expression:
+// minute hour day-of-month month day-of-week
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// if both day fields are restricted, either can match
+	domStar, dowStar bool
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseCron parses a standard five field cron expression.
+// Fields support *, lists, ranges, steps and month and day names.
+func ParseCron(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields: '%s'", expr)
+	}
+	var err error
+	s := &Schedule{}
+	if s.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, errors.Wrap(err, "minute")
+	}
+	if s.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, errors.Wrap(err, "hour")
+	}
+	if s.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, errors.Wrap(err, "day of month")
+	}
+	if s.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, errors.Wrap(err, "month")
+	}
+	if s.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, errors.Wrap(err, "day of week")
+	}
+	// 7 is also Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// parseField returns a bit set of the values in a field
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step: '%s'", part)
+			}
+			step = n
+			part = part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			ends := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseValue(ends[0], names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(ends[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			n, err := parseValue(part, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			hi = n
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("out of range: '%s' (%d-%d)", field, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: '%s'", s)
+	}
+	return n, nil
+}
+
+// dayMatches applies the cron rule that if both day fields
+// are restricted, a match on either is enough
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute at or after t.
+// It returns the zero time if nothing matches in the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	if trunc := t.Truncate(time.Minute); !trunc.Equal(t) {
+		t = trunc.Add(time.Minute)
+	}
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
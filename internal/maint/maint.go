@@ -0,0 +1,243 @@
+// Package maint holds maintenance windows.  Servers and Availability
+// Groups in an active window are still polled but don't alert.
+package maint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tags"
+)
+
+// TimeLayouts are the accepted formats for one-off start and end times.
+// Times without an offset are in local time.
+var TimeLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// Window is a one-off or recurring maintenance window
+type Window struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	AGs     []string `json:"ag_names,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+	File    string   `json:"file,omitempty"`
+
+	// One-off windows have a start and end
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// Recurring windows start on a cron schedule and last for a duration
+	Cron     string        `json:"cron,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+
+	schedule *Schedule
+}
+
+// ParseTime parses a one-off start or end time
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range TimeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: '%s' (use YYYY-MM-DD HH:MM)", s)
+}
+
+// Validate checks the window, normalizes the targets to lower-case
+// and parses the cron schedule.  It must be called before Active.
+func (w *Window) Validate() error {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return errors.New("maintenance: name is required")
+	}
+	w.Servers = tags.Merge(&w.Servers)
+	w.Tags = tags.Merge(&w.Tags)
+	w.AGs = tags.Merge(&w.AGs)
+	if len(w.Servers)+len(w.Tags)+len(w.AGs) == 0 {
+		return fmt.Errorf("maintenance: %s: servers, tags, or ag_names are required", w.Name)
+	}
+	oneOff := !w.Start.IsZero() || !w.End.IsZero()
+	if oneOff && w.Cron != "" {
+		return fmt.Errorf("maintenance: %s: use start and end or cron and duration", w.Name)
+	}
+	if w.Cron != "" {
+		if w.Duration <= 0 {
+			return fmt.Errorf("maintenance: %s: duration is required with cron", w.Name)
+		}
+		s, err := ParseCron(w.Cron)
+		if err != nil {
+			return errors.Wrapf(err, "maintenance: %s", w.Name)
+		}
+		w.schedule = s
+		return nil
+	}
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("maintenance: %s: start and end are required", w.Name)
+	}
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("maintenance: %s: end must be after start", w.Name)
+	}
+	return nil
+}
+
+// Recurring is true for cron windows
+func (w Window) Recurring() bool {
+	return w.Cron != ""
+}
+
+// Active returns true if the window is open at t
+func (w Window) Active(t time.Time) bool {
+	if !w.Recurring() {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+	start := w.schedule.Next(t.Add(-w.Duration).Add(time.Nanosecond))
+	return !start.IsZero() && !start.After(t)
+}
+
+// Until returns when the window that is open at t closes
+func (w Window) Until(t time.Time) time.Time {
+	if !w.Recurring() {
+		return w.End
+	}
+	start := w.schedule.Next(t.Add(-w.Duration).Add(time.Nanosecond))
+	return start.Add(w.Duration)
+}
+
+// Next returns the next time the window opens after t.
+// It returns the zero time if it never opens again.
+func (w Window) Next(t time.Time) time.Time {
+	if !w.Recurring() {
+		if w.Start.After(t) {
+			return w.Start
+		}
+		return time.Time{}
+	}
+	return w.schedule.Next(t.Add(time.Nanosecond))
+}
+
+// Schedule describes when the window is open
+func (w Window) Schedule() string {
+	if w.Recurring() {
+		return fmt.Sprintf("%s for %s", w.Cron, w.Duration)
+	}
+	return fmt.Sprintf("%s to %s", w.Start.Format("2006-01-02 15:04"), w.End.Format("2006-01-02 15:04"))
+}
+
+// Targets describes what the window applies to
+func (w Window) Targets() string {
+	parts := make([]string, 0, 3)
+	if len(w.Servers) > 0 {
+		parts = append(parts, "servers: "+strings.Join(w.Servers, ", "))
+	}
+	if len(w.Tags) > 0 {
+		parts = append(parts, "tags: "+strings.Join(w.Tags, ", "))
+	}
+	if len(w.AGs) > 0 {
+		parts = append(parts, "AGs: "+strings.Join(w.AGs, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// MatchServer returns true if the window targets the server key or any of its tags
+func (w Window) MatchServer(key string, serverTags []string) bool {
+	key = strings.ToLower(key)
+	for _, s := range w.Servers {
+		if s == key {
+			return true
+		}
+	}
+	for _, t := range w.Tags {
+		for _, st := range serverTags {
+			if strings.EqualFold(t, st) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchAG returns true if the window targets the AG name
+func (w Window) MatchAG(name string) bool {
+	name = strings.ToLower(name)
+	for _, ag := range w.AGs {
+		if ag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is the list of configured windows
+type Set struct {
+	mu      sync.RWMutex
+	windows []Window
+	clock   func() time.Time
+}
+
+// NewSet returns an empty Set
+func NewSet() *Set {
+	return &Set{windows: make([]Window, 0), clock: time.Now}
+}
+
+// Replace all the windows.  They should already be validated.
+func (s *Set) Replace(ww []Window) {
+	if s == nil {
+		return
+	}
+	sorted := make([]Window, len(ww))
+	copy(sorted, ww)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = sorted
+}
+
+// Windows returns all the windows sorted by name
+func (s *Set) Windows() []Window {
+	if s == nil {
+		return []Window{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Window{}, s.windows...)
+}
+
+// Server returns the active window for a server, if any
+func (s *Set) Server(key string, serverTags []string) (Window, bool) {
+	if s == nil {
+		return Window{}, false
+	}
+	now := s.clock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, w := range s.windows {
+		if w.MatchServer(key, serverTags) && w.Active(now) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// AG returns the active window for an Availability Group, if any
+func (s *Set) AG(name string) (Window, bool) {
+	if s == nil {
+		return Window{}, false
+	}
+	now := s.clock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, w := range s.windows {
+		if w.MatchAG(name) && w.Active(now) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
@@ -0,0 +1,150 @@
+package maint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	assert := assert.New(t)
+	good := []string{"* * * * *", "0 22 * * SAT", "*/15 1-3 1,15 jan-mar mon-fri", "30 2 * * 7", "0 0 ? * ?"}
+	for _, expr := range good {
+		_, err := ParseCron(expr)
+		assert.NoError(err, expr)
+	}
+	bad := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * funday", "*/0 * * * *", "5-1 * * * *"}
+	for _, expr := range bad {
+		_, err := ParseCron(expr)
+		assert.Error(err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	// Thursday
+	from := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s, err := ParseCron("0 22 * * SAT")
+	require.NoError(err)
+	assert.Equal(time.Date(2025, 1, 4, 22, 0, 0, 0, time.UTC), s.Next(from))
+
+	s, err = ParseCron("*/15 * * * *")
+	require.NoError(err)
+	assert.Equal(time.Date(2025, 1, 2, 3, 15, 0, 0, time.UTC), s.Next(from))
+	// a matching minute is returned
+	assert.Equal(time.Date(2025, 1, 2, 3, 15, 0, 0, time.UTC), s.Next(time.Date(2025, 1, 2, 3, 15, 0, 0, time.UTC)))
+
+	// 7 is Sunday
+	s, err = ParseCron("0 0 * * 7")
+	require.NoError(err)
+	assert.Equal(time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC), s.Next(from))
+
+	// day of month or day of week
+	s, err = ParseCron("0 0 15 * MON")
+	require.NoError(err)
+	assert.Equal(time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), s.Next(from))
+	assert.Equal(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), s.Next(time.Date(2025, 1, 14, 1, 0, 0, 0, time.UTC)))
+
+	// never matches
+	s, err = ParseCron("0 0 31 feb *")
+	require.NoError(err)
+	assert.True(s.Next(from).IsZero())
+}
+
+func TestOneOffWindow(t *testing.T) {
+	assert := assert.New(t)
+	w := Window{
+		Name:    "patch",
+		Servers: []string{"D40-SQL2016"},
+		Start:   time.Date(2025, 1, 2, 20, 0, 0, 0, time.UTC),
+		End:     time.Date(2025, 1, 2, 23, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(w.Validate())
+	assert.Equal([]string{"d40-sql2016"}, w.Servers)
+	assert.False(w.Active(time.Date(2025, 1, 2, 19, 59, 0, 0, time.UTC)))
+	assert.True(w.Active(time.Date(2025, 1, 2, 20, 0, 0, 0, time.UTC)))
+	assert.False(w.Active(time.Date(2025, 1, 2, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(w.Start, w.Next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(w.Next(time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)).IsZero())
+}
+
+func TestRecurringWindow(t *testing.T) {
+	assert := assert.New(t)
+	w := Window{Name: "weekly", Tags: []string{"Dev"}, Cron: "0 22 * * SAT", Duration: 4 * time.Hour}
+	assert.NoError(w.Validate())
+	sat := time.Date(2025, 1, 4, 22, 0, 0, 0, time.UTC)
+	assert.False(w.Active(sat.Add(-time.Minute)))
+	assert.True(w.Active(sat))
+	assert.True(w.Active(sat.Add(3*time.Hour + 59*time.Minute)))
+	assert.Equal(sat.Add(4*time.Hour), w.Until(sat.Add(time.Hour)))
+	assert.False(w.Active(sat.Add(4 * time.Hour)))
+	assert.Equal(sat.AddDate(0, 0, 7), w.Next(sat))
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2025, 1, 2, 20, 0, 0, 0, time.UTC)
+	bad := []Window{
+		{Servers: []string{"a"}, Start: start, End: start.Add(time.Hour)},
+		{Name: "no targets", Start: start, End: start.Add(time.Hour)},
+		{Name: "no end", Servers: []string{"a"}, Start: start},
+		{Name: "backwards", Servers: []string{"a"}, Start: start, End: start.Add(-time.Hour)},
+		{Name: "both", Servers: []string{"a"}, Start: start, End: start.Add(time.Hour), Cron: "* * * * *", Duration: time.Hour},
+		{Name: "no duration", Servers: []string{"a"}, Cron: "* * * * *"},
+		{Name: "bad cron", Servers: []string{"a"}, Cron: "* * *", Duration: time.Hour},
+	}
+	for _, w := range bad {
+		assert.Error(w.Validate(), w.Name)
+	}
+}
+
+func TestSet(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2025, 1, 2, 21, 0, 0, 0, time.UTC)
+	ww := []Window{
+		{Name: "b-servers", Servers: []string{"srv1"}, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Name: "a-tags", Tags: []string{"patching"}, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Name: "c-ag", AGs: []string{"AG1"}, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Name: "d-later", Servers: []string{"srv2"}, Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)},
+	}
+	for i := range ww {
+		assert.NoError(ww[i].Validate())
+	}
+	s := NewSet()
+	s.clock = func() time.Time { return now }
+	s.Replace(ww)
+	assert.Equal("a-tags", s.Windows()[0].Name)
+
+	w, ok := s.Server("SRV1", nil)
+	assert.True(ok)
+	assert.Equal("b-servers", w.Name)
+	_, ok = s.Server("srv3", []string{"prod", "Patching"})
+	assert.True(ok)
+	_, ok = s.Server("srv2", nil)
+	assert.False(ok)
+	_, ok = s.AG("ag1")
+	assert.True(ok)
+	_, ok = s.AG("ag2")
+	assert.False(ok)
+
+	var nilSet *Set
+	_, ok = nilSet.Server("srv1", nil)
+	assert.False(ok)
+	assert.Len(nilSet.Windows(), 0)
+}
+
+func TestParseTime(t *testing.T) {
+	assert := assert.New(t)
+	got, err := ParseTime("2025-01-02 20:00")
+	assert.NoError(err)
+	assert.Equal(time.Date(2025, 1, 2, 20, 0, 0, 0, time.Local), got)
+	got, err = ParseTime("2025-01-02T20:00:00Z")
+	assert.NoError(err)
+	assert.True(got.Equal(time.Date(2025, 1, 2, 20, 0, 0, 0, time.UTC)))
+	_, err = ParseTime("tomorrow")
+	assert.Error(err)
+}
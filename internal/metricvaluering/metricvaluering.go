@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tier"
 )
 
 /*
@@ -26,6 +27,11 @@ type MetricValueRing struct {
 	head int // the most recent value written
 	tail int // the least recent value written
 
+	// older holds the values that age out of this ring averaged
+	// over bucket.Period.  It is nil without a longer history.
+	older  *MetricValueRing
+	bucket tier.Bucket[*MetricValue]
+
 	//sync.RWMutex // all locking is done at the server level
 }
 
@@ -64,6 +70,9 @@ func (r *MetricValueRing) Enqueue(i *MetricValue) {
 	// r.Lock()
 	// defer r.Unlock()
 	r.checkInit()
+	if r.head != -1 && r.mod(r.head+1) == r.tail {
+		r.age(r.get(r.tail))
+	}
 	r.set(r.head+1, i)
 	old := r.head
 	r.head = r.mod(r.head + 1)
@@ -146,6 +155,50 @@ func (r *MetricValueRing) Values() []*MetricValue {
 	return arr
 }
 
+/*
+History returns the values after since from the oldest tier to the newest.
+The older tiers are averaged so there are fewer values for longer spans.
+*/
+func (r *MetricValueRing) History(since time.Time) []*MetricValue {
+	arr := make([]*MetricValue, 0)
+	if r.older != nil {
+		arr = r.older.History(since)
+		for _, v := range r.bucket.Pending() {
+			if v.EventTime.After(since) {
+				arr = append(arr, v)
+			}
+		}
+	}
+	for _, v := range r.Values() {
+		if v != nil && v.EventTime.After(since) {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
+
+/*
+Points returns the number of values in all the tiers
+*/
+func (r *MetricValueRing) Points() int {
+	n := len(r.Values())
+	if r.older != nil {
+		n += len(r.bucket.Pending()) + r.older.Points()
+	}
+	return n
+}
+
+/*
+Size returns the number of values all the tiers can hold
+*/
+func (r *MetricValueRing) Size() int {
+	n := r.Capacity()
+	if r.older != nil {
+		n += r.older.Size()
+	}
+	return n
+}
+
 // MarshalJSON brings back the JSON
 func (r MetricValueRing) MarshalJSON() ([]byte, error) {
 	//fmt.Println("metricvaluering: marshaljson")
@@ -224,7 +277,60 @@ func (r *MetricValueRing) checkInit() {
 			r.buff[i] = nil
 		}
 		r.head, r.tail = -1, 0
+		r.setTiers(tier.Default)
+	}
+}
+
+// setTiers adds the older tiers
+func (r *MetricValueRing) setTiers(sizes tier.Sizes) {
+	if sizes.Minute == 0 {
+		return
+	}
+	r.older = newTier(sizes.Minute)
+	r.bucket.Period = tier.Minute
+	if sizes.Five > 0 {
+		r.older.older = newTier(sizes.Five)
+		r.older.bucket.Period = tier.FiveMinute
+	}
+}
+
+// newTier returns an empty ring for an older tier
+func newTier(size int) *MetricValueRing {
+	return &MetricValueRing{buff: make([]*MetricValue, size), head: -1}
+}
+
+// age moves a value that is leaving the ring to the older tier
+func (r *MetricValueRing) age(v *MetricValue) {
+	if r.older == nil || v == nil {
+		return
+	}
+	start, done := r.bucket.Add(v.EventTime, v)
+	if len(done) > 0 {
+		r.older.Enqueue(average(start, done))
+	}
+}
+
+// average returns one value for a period.  Values that weren't
+// polled aren't averaged.  The aggregate is the last one.
+func average(start time.Time, list []*MetricValue) *MetricValue {
+	mv := MetricValue{EventTime: start}
+	var n, value, perSecond int64
+	for _, v := range list {
+		mv.DeltaDuration += v.DeltaDuration
+		mv.AggregateValue = v.AggregateValue
+		if !v.PolledValue {
+			continue
+		}
+		mv.PolledValue = true
+		value += v.Value
+		perSecond += v.ValuePerSecond
+		n++
+	}
+	if n > 0 {
+		mv.Value = value / n
+		mv.ValuePerSecond = perSecond / n
 	}
+	return &mv
 }
 
 func (r *MetricValueRing) extend(size int) {
@@ -0,0 +1,89 @@
+package mrepo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+)
+
+// Driver names for the [repository] section
+const (
+	DriverSQLServer = "sqlserver"
+	DriverPostgres  = "postgres"
+	DriverSQLite    = "sqlite"
+)
+
+// Config holds the connection settings for a repository
+type Config struct {
+	Driver   string
+	Host     string
+	Database string // for SQLite, this is the path to the file
+	User     string
+	Password string
+	Options  string // extra connection options such as "sslmode=disable"
+
+	// SpoolDir holds rows while the repository is down.  Empty disables the spool.
+	SpoolDir   string
+	SpoolMaxMB int
+
+	// Retention in days for the raw rows and the rollups.  Zero keeps them.
+	RetentionDays       int
+	HourlyRetentionDays int
+	DailyRetentionDays  int
+
+	// Filter picks the servers to write by tag
+	Filter Filter
+}
+
+// Backend is a database that can hold the repository.
+// Each backend has its own migrations.
+type Backend interface {
+	// Name of the driver
+	Name() string
+	// Open returns a connection pool.  It shouldn't connect.
+	Open(cfg Config) (*sqlx.DB, error)
+	// Dialect that goose uses for the migrations
+	Dialect() goose.Dialect
+	// Migrations is the folder in the embedded files
+	Migrations() string
+	// Table returns the quoted table name
+	Table(name string) string
+	// DeleteBatch returns a DELETE of at most n rows where column < ?.
+	// The table should already be quoted.
+	DeleteBatch(table, column string, n int) string
+	// InsertIgnore returns an INSERT of rows of ? values that skips
+	// rows matching an existing row on keys.  The table should already be quoted.
+	InsertIgnore(table string, columns, keys []string, rows int) string
+	// Timestamp, Date, and Time convert the ts, ts_date, and ts_time values
+	Timestamp(t time.Time) any
+	Date(t time.Time) any
+	Time(t time.Time) any
+}
+
+// valuesList returns rows of ? placeholders for a VALUES clause
+func valuesList(columns, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columns), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
+
+// insertOnConflict is used by PostgreSQL and SQLite
+func insertOnConflict(table string, columns, keys []string, rows int) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s) DO NOTHING",
+		table, strings.Join(columns, ", "), valuesList(len(columns), rows), strings.Join(keys, ", "))
+}
+
+// NewBackend returns the backend for a driver.  An empty driver is SQL Server.
+func NewBackend(driver string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", DriverSQLServer, "mssql":
+		return sqlServerBackend{}, nil
+	case DriverPostgres, "postgresql", "pg":
+		return postgresBackend{}, nil
+	case DriverSQLite, "sqlite3":
+		return sqliteBackend{}, nil
+	}
+	return nil, fmt.Errorf("invalid driver: '%s' (use %s, %s, or %s)", driver, DriverSQLServer, DriverPostgres, DriverSQLite)
+}
@@ -0,0 +1,75 @@
+package mrepo
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+// postgresBackend stores the repository in PostgreSQL
+type postgresBackend struct{}
+
+func (postgresBackend) Name() string { return DriverPostgres }
+
+func (postgresBackend) Open(cfg Config) (*sqlx.DB, error) {
+	dsn, err := postgresDSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(db, "postgres"), nil
+}
+
+func (postgresBackend) Dialect() goose.Dialect { return goose.DialectPostgres }
+
+func (postgresBackend) Migrations() string { return "migrations_postgres" }
+
+func (postgresBackend) Table(name string) string { return name }
+
+func (postgresBackend) DeleteBatch(table, column string, n int) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s < ? LIMIT %d)", table, table, column, n)
+}
+
+func (postgresBackend) InsertIgnore(table string, columns, keys []string, rows int) string {
+	return insertOnConflict(table, columns, keys, rows)
+}
+
+func (postgresBackend) Timestamp(t time.Time) any { return t }
+
+func (postgresBackend) Date(t time.Time) any { return t.Format("2006-01-02") }
+
+func (postgresBackend) Time(t time.Time) any { return t.Format("15:04:05") }
+
+// postgresDSN builds a URL connection string.  The host can include a port.
+// Options are added to the query string.
+func postgresDSN(cfg Config) (string, error) {
+	if cfg.Host == "" || cfg.Database == "" {
+		return "", fmt.Errorf("host and database must be provided")
+	}
+	query, err := url.ParseQuery(cfg.Options)
+	if err != nil {
+		return "", errors.Wrap(err, "options")
+	}
+	if query.Get("application_name") == "" {
+		query.Set("application_name", "isitsql")
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		Host:     cfg.Host,
+		Path:     "/" + cfg.Database,
+		RawQuery: query.Encode(),
+	}
+	if cfg.User != "" {
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+	}
+	return u.String(), nil
+}
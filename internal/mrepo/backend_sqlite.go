@@ -0,0 +1,56 @@
+package mrepo
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite" // pure GO SQLite driver
+)
+
+// sqliteBackend stores the repository in a local SQLite file
+type sqliteBackend struct{}
+
+func (sqliteBackend) Name() string { return DriverSQLite }
+
+// Open uses one connection so writes from different servers don't get SQLITE_BUSY
+func (sqliteBackend) Open(cfg Config) (*sqlx.DB, error) {
+	if cfg.Database == "" {
+		return nil, fmt.Errorf("database file must be provided")
+	}
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	dsn := "file:" + filepath.ToSlash(cfg.Database) + "?" + query.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	// sqlx uses the driver name to pick the bind variables
+	return sqlx.NewDb(db, "sqlite3"), nil
+}
+
+func (sqliteBackend) Dialect() goose.Dialect { return goose.DialectSQLite3 }
+
+func (sqliteBackend) Migrations() string { return "migrations_sqlite" }
+
+func (sqliteBackend) Table(name string) string { return name }
+
+func (sqliteBackend) DeleteBatch(table, column string, n int) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE %s < ? LIMIT %d)", table, table, column, n)
+}
+
+func (sqliteBackend) InsertIgnore(table string, columns, keys []string, rows int) string {
+	return insertOnConflict(table, columns, keys, rows)
+}
+
+func (sqliteBackend) Timestamp(t time.Time) any { return t.UTC() }
+
+func (sqliteBackend) Date(t time.Time) any { return t.Format("2006-01-02") }
+
+func (sqliteBackend) Time(t time.Time) any { return t.Format("15:04:05") }
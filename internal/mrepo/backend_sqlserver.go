@@ -0,0 +1,55 @@
+package mrepo
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+)
+
+// sqlServerBackend stores the repository in SQL Server 2016 or higher using columnstore tables
+type sqlServerBackend struct{}
+
+func (sqlServerBackend) Name() string { return DriverSQLServer }
+
+func (sqlServerBackend) Open(cfg Config) (*sqlx.DB, error) {
+	connstr, err := makeconnstr(cfg.Host, cfg.Database, cfg.User, cfg.Password)
+	if err != nil {
+		return nil, fmt.Errorf("connstr: %w", err)
+	}
+	db, err := sql.Open("sqlserver", connstr.String())
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(db, "sqlserver"), nil
+}
+
+func (sqlServerBackend) Dialect() goose.Dialect { return goose.DialectMSSQL }
+
+func (sqlServerBackend) Migrations() string { return "migrations" }
+
+func (sqlServerBackend) Table(name string) string { return fmt.Sprintf("[dbo].[%s]", name) }
+
+func (sqlServerBackend) DeleteBatch(table, column string, n int) string {
+	return fmt.Sprintf("DELETE TOP (%d) FROM %s WHERE %s < ?", n, table, column)
+}
+
+// InsertIgnore uses NOT EXISTS since SQL Server doesn't have ON CONFLICT
+func (sqlServerBackend) InsertIgnore(table string, columns, keys []string, rows int) string {
+	match := make([]string, 0, len(keys))
+	for _, k := range keys {
+		match = append(match, fmt.Sprintf("t.%s = v.%s", k, k))
+	}
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM (VALUES %s) AS v (%s) WHERE NOT EXISTS (SELECT 1 FROM %s t WHERE %s)",
+		table, cols, cols, valuesList(len(columns), rows), cols, table, strings.Join(match, " AND "))
+}
+
+func (sqlServerBackend) Timestamp(t time.Time) any { return t }
+
+func (sqlServerBackend) Date(t time.Time) any { return t }
+
+func (sqlServerBackend) Time(t time.Time) any { return t }
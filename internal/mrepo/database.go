@@ -0,0 +1,157 @@
+package mrepo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/mssql/agent"
+)
+
+// sizeEvery is how often database sizes are written.  The snapshot
+// time is truncated to this so a restart doesn't write a second one.
+const sizeEvery = time.Hour
+
+// DatabaseSize is the data and log size of one database
+type DatabaseSize struct {
+	Name   string
+	DataKB int64
+	LogKB  int64
+}
+
+// Backup types
+const (
+	BackupFull = "full"
+	BackupLog  = "log"
+)
+
+// Backup is the last full or log backup of a database
+type Backup struct {
+	Database string
+	Type     string // BackupFull or BackupLog
+	Started  time.Time
+	Device   string
+	Instance string
+}
+
+// WriteDatabaseSizes queues a size snapshot for each database once an hour
+func (r *Repository) WriteDatabaseSizes(key, server string, ts time.Time, dbs []DatabaseSize) {
+	if r == nil || r.queue == nil || len(dbs) == 0 {
+		return
+	}
+	ts = ts.Truncate(sizeEvery)
+	if !r.changed("database_size\x00"+key, ts) {
+		return
+	}
+	recs := make([]record, 0, len(dbs))
+	for _, db := range dbs {
+		recs = append(recs, record{
+			Table:   "database_size",
+			TS:      ts,
+			Key:     key,
+			Server:  server,
+			Values:  map[string]int64{"data_size_kb": db.DataKB, "log_size_kb": db.LogKB},
+			Strings: map[string]string{"database_name": db.Name},
+		})
+	}
+	r.enqueue(recs...)
+}
+
+// WriteBackups queues the backups that haven't been written yet.
+// Databases that have never been backed up are skipped.
+func (r *Repository) WriteBackups(key, server string, backups []Backup) {
+	if r == nil || r.queue == nil {
+		return
+	}
+	recs := make([]record, 0)
+	for _, b := range backups {
+		if b.Started.IsZero() {
+			continue
+		}
+		ts := b.Started.Truncate(time.Second)
+		if !r.changed(strings.Join([]string{"database_backup", key, b.Database, b.Type}, "\x00"), ts) {
+			continue
+		}
+		recs = append(recs, record{
+			Table:  "database_backup",
+			TS:     ts,
+			Key:    key,
+			Server: server,
+			Strings: map[string]string{
+				"database_name":   b.Database,
+				"backup_type":     b.Type,
+				"backup_device":   b.Device,
+				"backup_instance": b.Instance,
+			},
+		})
+	}
+	r.enqueue(recs...)
+}
+
+// WriteJobRuns queues the job history rows after the last one written
+func (r *Repository) WriteJobRuns(key, server string, runs []agent.JobHistoryRow) {
+	if r == nil || r.queue == nil {
+		return
+	}
+	last := r.LastJobInstance(key)
+	next := last
+	recs := make([]record, 0)
+	for _, run := range runs {
+		id := int64(run.InstanceID)
+		if id <= last {
+			continue
+		}
+		next = max(next, id)
+		recs = append(recs, record{
+			Table:  "job_history",
+			TS:     run.RunTimeNative.Truncate(time.Second),
+			Key:    key,
+			Server: server,
+			Values: map[string]int64{
+				"instance_id":       id,
+				"step_id":           int64(run.StepID),
+				"run_status":        int64(run.RunStatus),
+				"duration_sec":      int64(run.RunDurationNative.Seconds()),
+				"retries_attempted": int64(run.RetriesAttempted),
+			},
+			Strings: map[string]string{
+				"job_id":          strings.ToLower(run.JobID.String()),
+				"job_name":        run.JobName.String,
+				"run_status_desc": run.RunStatusDescription,
+				"message":         run.Message.String,
+			},
+		})
+	}
+	r.seenMu.Lock()
+	if r.lastJob == nil {
+		r.lastJob = make(map[string]int64)
+	}
+	r.lastJob[key] = next
+	r.seenMu.Unlock()
+	r.enqueue(recs...)
+}
+
+// LastJobInstance returns the last job history instance_id written for
+// a server.  It is zero after a restart.  Rows already in the repository
+// are skipped when they are written again.
+func (r *Repository) LastJobInstance(key string) int64 {
+	if r == nil {
+		return 0
+	}
+	r.seenMu.Lock()
+	defer r.seenMu.Unlock()
+	return r.lastJob[key]
+}
+
+// changed records ts for name and reports if it is different from the last one
+func (r *Repository) changed(name string, ts time.Time) bool {
+	r.seenMu.Lock()
+	defer r.seenMu.Unlock()
+	if r.seen == nil {
+		r.seen = make(map[string]time.Time)
+	}
+	if last, ok := r.seen[name]; ok && last.Equal(ts) {
+		return false
+	}
+	r.seen[name] = ts
+	return true
+}
@@ -0,0 +1,83 @@
+package mrepo
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/scalesql/isitsql/internal/mssql/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseWritesIdempotent(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	ts := time.Date(2025, 1, 2, 11, 34, 0, 0, time.UTC)
+	sizes := []DatabaseSize{{Name: "master", DataKB: 4096, LogKB: 1024}, {Name: "app", DataKB: 8192, LogKB: 2048}}
+	backups := []Backup{
+		{Database: "app", Type: BackupFull, Started: ts.Add(-time.Hour), Device: "app.bak"},
+		{Database: "app", Type: BackupLog, Started: ts.Add(-time.Minute).Add(300 * time.Millisecond), Device: "app.trn"},
+		{Database: "master", Type: BackupFull}, // never backed up
+	}
+	runs := []agent.JobHistoryRow{
+		{InstanceID: 10, JobName: sql.NullString{String: "backup", Valid: true}, RunStatus: 1, RunStatusDescription: "Succeeded", RunTimeNative: ts.Add(-time.Hour), RunDurationNative: 90 * time.Second},
+		{InstanceID: 12, JobName: sql.NullString{String: "check", Valid: true}, RunStatus: 0, RunStatusDescription: "Failed", RunTimeNative: ts.Add(-time.Minute)},
+	}
+
+	count := func(r *Repository) (n [3]int) {
+		for i, table := range []string{"database_size", "database_backup", "job_history"} {
+			require.NoError(t, r.pool.Get(&n[i], "SELECT COUNT(*) FROM "+table))
+		}
+		return n
+	}
+
+	// polls write the same values again and a restart forgets what was written
+	for restart := 0; restart < 2; restart++ {
+		r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+		require.NoError(t, err)
+		for poll := 0; poll < 3; poll++ {
+			r.WriteDatabaseSizes("srv1", "SQL01", ts.Add(time.Duration(poll)*time.Minute), sizes)
+			r.WriteBackups("srv1", "SQL01", backups)
+			r.WriteJobRuns("srv1", "SQL01", runs)
+		}
+		r.Flush()
+		assert.Equal([3]int{2, 2, 2}, count(r))
+		assert.Equal(int64(12), r.LastJobInstance("srv1"))
+		require.NoError(t, r.Close())
+	}
+
+	// a new hour, a new backup, and a new job run
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+	backups[1].Started = ts
+	runs = append(runs, agent.JobHistoryRow{InstanceID: 15, RunStatus: 1, RunTimeNative: ts})
+	r.WriteDatabaseSizes("srv1", "SQL01", ts.Add(time.Hour), sizes)
+	r.WriteBackups("srv1", "SQL01", backups)
+	r.WriteJobRuns("srv1", "SQL01", runs)
+	r.Flush()
+	assert.Equal([3]int{4, 3, 3}, count(r))
+
+	var kb int64
+	require.NoError(t, r.pool.Get(&kb, "SELECT data_size_kb FROM database_size WHERE database_name = 'app' LIMIT 1"))
+	assert.Equal(int64(8192), kb)
+	var sec int64
+	require.NoError(t, r.pool.Get(&sec, "SELECT duration_sec FROM job_history WHERE instance_id = 10"))
+	assert.Equal(int64(90), sec)
+}
+
+func TestKeyedUnique(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 11, 0, 0, 0, time.UTC)
+	recs := []record{
+		{TS: ts, Key: "srv1", Values: map[string]int64{"data_size_kb": 1}, Strings: map[string]string{"database_name": "a"}},
+		{TS: ts, Key: "srv1", Values: map[string]int64{"data_size_kb": 2}, Strings: map[string]string{"database_name": "b"}},
+		{TS: ts, Key: "srv1", Values: map[string]int64{"data_size_kb": 3}, Strings: map[string]string{"database_name": "a"}},
+	}
+	list := keyedTables["database_size"].unique(recs)
+	require.Len(t, list, 2)
+	assert.Equal(t, int64(3), list[0].Values["data_size_kb"])
+}
@@ -0,0 +1,68 @@
+package mrepo
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter picks the servers that are written to the repository by their tags.
+// Each entry is a pattern such as "dev" or "dev-*".  Matching ignores case.
+type Filter struct {
+	Include []string // if set, only servers with a matching tag are written
+	Exclude []string // servers with a matching tag are never written
+}
+
+// Validate checks the patterns
+func (f Filter) Validate() error {
+	for _, list := range [][]string{f.Include, f.Exclude} {
+		for _, p := range list {
+			if _, err := path.Match(strings.ToLower(p), ""); err != nil {
+				return fmt.Errorf("invalid tag pattern: %s", p)
+			}
+		}
+	}
+	return nil
+}
+
+// Allow returns if a server is written to the repository and why.
+// A server's own setting overrides the tags.
+func (f Filter) Allow(tags []string, override *bool) (bool, string) {
+	if override != nil {
+		if *override {
+			return true, "server setting"
+		}
+		return false, "server setting"
+	}
+	if t, ok := matchTag(f.Exclude, tags); ok {
+		return false, "excluded by tag: " + t
+	}
+	if len(f.Include) == 0 {
+		return true, "all servers"
+	}
+	if t, ok := matchTag(f.Include, tags); ok {
+		return true, "included by tag: " + t
+	}
+	return false, "no included tag"
+}
+
+// matchTag returns the first tag that matches any pattern
+func matchTag(patterns, tags []string) (string, bool) {
+	for _, t := range tags {
+		for _, p := range patterns {
+			ok, _ := path.Match(strings.ToLower(p), strings.ToLower(t))
+			if ok {
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Allow returns if a server is written to the repository and why
+func (r *Repository) Allow(tags []string, override *bool) (bool, string) {
+	if r == nil {
+		return false, "not configured"
+	}
+	return r.cfg.Filter.Allow(tags, override)
+}
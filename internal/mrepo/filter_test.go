@@ -0,0 +1,45 @@
+package mrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterAllow(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		filter   Filter
+		tags     []string
+		override *bool
+		want     bool
+		reason   string
+	}{
+		{"empty", Filter{}, []string{"dev"}, nil, true, "all servers"},
+		{"excluded", Filter{Exclude: []string{"dev*"}}, []string{"prod", "Dev-Box"}, nil, false, "excluded by tag: Dev-Box"},
+		{"included", Filter{Include: []string{"prod"}}, []string{"PROD"}, nil, true, "included by tag: PROD"},
+		{"not included", Filter{Include: []string{"prod"}}, []string{"test"}, nil, false, "no included tag"},
+		{"no tags", Filter{Include: []string{"prod"}}, nil, nil, false, "no included tag"},
+		{"exclude wins", Filter{Include: []string{"prod"}, Exclude: []string{"dev"}}, []string{"prod", "dev"}, nil, false, "excluded by tag: dev"},
+		{"override off", Filter{}, []string{"prod"}, &no, false, "server setting"},
+		{"override on", Filter{Exclude: []string{"dev"}}, []string{"dev"}, &yes, true, "server setting"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, reason := tc.filter.Allow(tc.tags, tc.override)
+			assert.Equal(t, tc.want, ok)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	assert.NoError(t, Filter{Include: []string{"prod", "dev-*"}}.Validate())
+	assert.Error(t, Filter{Exclude: []string{"dev["}}.Validate())
+
+	var r *Repository
+	ok, reason := r.Allow([]string{"prod"}, nil)
+	assert.False(t, ok)
+	assert.Equal(t, "not configured", reason)
+}
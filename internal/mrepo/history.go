@@ -0,0 +1,193 @@
+package mrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Resolutions for history
+const (
+	ResolutionRaw    = "raw"
+	ResolutionHourly = "hourly"
+	ResolutionDaily  = "daily"
+)
+
+// Spans up to these use raw or hourly rows.  Anything longer uses daily rows.
+const (
+	rawSpan    = 2 * 24 * time.Hour
+	hourlySpan = 62 * 24 * time.Hour
+)
+
+// ErrNoRepository is returned when there is no repository to read
+var ErrNoRepository = errors.New("repository not configured")
+
+// MetricPoint is one row of metric history.
+// For the hourly and daily rollups, these are the averages.
+type MetricPoint struct {
+	TS     time.Time
+	Values map[string]int64
+}
+
+// WaitPoint is the wait time in seconds for each wait group
+type WaitPoint struct {
+	TS    time.Time
+	Waits map[string]int64
+}
+
+// Resolution returns the resolution for a span
+func Resolution(from, to time.Time) string {
+	span := to.Sub(from)
+	switch {
+	case span <= rawSpan:
+		return ResolutionRaw
+	case span <= hourlySpan:
+		return ResolutionHourly
+	}
+	return ResolutionDaily
+}
+
+// resolutions returns the resolution for a span and the coarser ones after it
+func resolutions(from, to time.Time) []string {
+	list := []string{ResolutionRaw, ResolutionHourly, ResolutionDaily}
+	res := Resolution(from, to)
+	for list[0] != res {
+		list = list[1:]
+	}
+	return list
+}
+
+// MetricHistory returns the metrics for a server between from and to
+// and the resolution used.  If there are no rows at the resolution for
+// the span, it tries the rollups since the raw rows may be purged.
+func (r *Repository) MetricHistory(ctx context.Context, key string, from, to time.Time) ([]MetricPoint, string, error) {
+	if r == nil || r.pool == nil {
+		return nil, "", ErrNoRepository
+	}
+	var points []MetricPoint
+	var res string
+	var err error
+	for _, res = range resolutions(from, to) {
+		points, err = r.metricHistory(ctx, res, key, from, to)
+		if err != nil {
+			return nil, res, errors.Wrap(err, res)
+		}
+		if len(points) > 0 {
+			break
+		}
+	}
+	return points, res, nil
+}
+
+func (r *Repository) metricHistory(ctx context.Context, res, key string, from, to time.Time) ([]MetricPoint, error) {
+	table, tsColumn := metricTable, "ts"
+	columns := metricColumns
+	if res != ResolutionRaw {
+		table, tsColumn = metricTable+"_"+res, "bucket_ts"
+		columns = make([]string, 0, len(metricColumns))
+		for _, col := range metricColumns {
+			columns = append(columns, col+"_avg")
+		}
+	}
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE server_key = ? AND %s >= ? AND %s < ? ORDER BY %s",
+		tsColumn, strings.Join(columns, ", "), r.backend.Table(table), tsColumn, tsColumn, tsColumn)
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	rows, err := r.pool.QueryContext(ctx, r.pool.Rebind(query), key, r.backend.Timestamp(from), r.backend.Timestamp(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "query")
+	}
+	defer rows.Close()
+	points := make([]MetricPoint, 0)
+	for rows.Next() {
+		var ts any
+		values := make([]sql.NullFloat64, len(columns))
+		dest := []any{&ts}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan")
+		}
+		pt := MetricPoint{Values: make(map[string]int64)}
+		pt.TS, err = toTime(ts)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if v.Valid {
+				pt.Values[metricColumns[i]] = int64(math.Round(v.Float64))
+			}
+		}
+		points = append(points, pt)
+	}
+	return points, errors.Wrap(rows.Err(), "rows")
+}
+
+// WaitHistory returns the waits for a server from request_wait or server_wait
+// and the resolution used.  Spans over two days use the hourly or daily
+// sums.  If there are no rows at the resolution for the span, it tries
+// the rollups since the raw rows may be purged.
+func (r *Repository) WaitHistory(ctx context.Context, table, key string, from, to time.Time) ([]WaitPoint, string, error) {
+	if r == nil || r.pool == nil {
+		return nil, "", ErrNoRepository
+	}
+	if !slices.Contains(waitTables, table) {
+		return nil, "", fmt.Errorf("invalid wait table: %s", table)
+	}
+	var points []WaitPoint
+	var res string
+	var err error
+	for _, res = range resolutions(from, to) {
+		points, err = r.waitHistory(ctx, res, table, key, from, to)
+		if err != nil {
+			return nil, res, errors.Wrap(err, res)
+		}
+		if len(points) > 0 {
+			break
+		}
+	}
+	return points, res, nil
+}
+
+func (r *Repository) waitHistory(ctx context.Context, res, table, key string, from, to time.Time) ([]WaitPoint, error) {
+	tsColumn := "ts"
+	if res != ResolutionRaw {
+		table, tsColumn = table+"_"+res, "bucket_ts"
+	}
+	query := fmt.Sprintf("SELECT %s, wait_type, wait_time_sec FROM %s WHERE server_key = ? AND %s >= ? AND %s < ? ORDER BY %s",
+		tsColumn, r.backend.Table(table), tsColumn, tsColumn, tsColumn)
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	rows, err := r.pool.QueryContext(ctx, r.pool.Rebind(query), key, r.backend.Timestamp(from), r.backend.Timestamp(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "query")
+	}
+	defer rows.Close()
+	points := make([]WaitPoint, 0)
+	for rows.Next() {
+		var ts any
+		var wait string
+		var seconds int64
+		err = rows.Scan(&ts, &wait, &seconds)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan")
+		}
+		t, err := toTime(ts)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) == 0 || !points[len(points)-1].TS.Equal(t) {
+			points = append(points, WaitPoint{TS: t, Waits: make(map[string]int64)})
+		}
+		points[len(points)-1].Waits[wait] += seconds
+	}
+	return points, errors.Wrap(rows.Err(), "rows")
+}
@@ -0,0 +1,107 @@
+package mrepo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolution(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	assert.Equal(ResolutionRaw, Resolution(now.Add(-time.Hour), now))
+	assert.Equal(ResolutionRaw, Resolution(now.Add(-48*time.Hour), now))
+	assert.Equal(ResolutionHourly, Resolution(now.AddDate(0, 0, -7), now))
+	assert.Equal(ResolutionDaily, Resolution(now.AddDate(0, -6, 0), now))
+}
+
+func TestHistory(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+	ctx := context.Background()
+
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local)
+	recs := make([]record, 0)
+	for ts := start; ts.Before(start.AddDate(0, 0, 5)); ts = ts.Add(time.Minute) {
+		recs = append(recs,
+			record{Table: metricTable, TS: ts, Key: "srv1", Server: "SQL01", Values: map[string]int64{"cpu_sql_pct": int64(ts.Minute() % 2), "page_life_expectancy": 300}},
+			record{Table: metricTable, TS: ts, Key: "srv2", Server: "SQL02", Values: map[string]int64{"cpu_sql_pct": 50}},
+			record{Table: "request_wait", TS: ts, Key: "srv1", Server: "SQL01", Start: start, Wait: "CPU", WaitSec: 2},
+		)
+	}
+	require.NoError(t, r.insert(recs))
+	_, err = r.maintenance(ctx, start.AddDate(0, 0, 5))
+	require.NoError(t, err)
+
+	// an hour is raw
+	points, res, err := r.MetricHistory(ctx, "srv1", start.Add(3*time.Hour), start.Add(4*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(ResolutionRaw, res)
+	require.Len(t, points, 60)
+	assert.True(points[0].TS.Equal(start.Add(3 * time.Hour)))
+	assert.Equal(int64(0), points[0].Values["cpu_sql_pct"])
+	assert.Equal(int64(1), points[1].Values["cpu_sql_pct"])
+	assert.Equal(int64(300), points[0].Values["page_life_expectancy"])
+	_, ok := points[0].Values["memory_used_mb"]
+	assert.False(ok)
+
+	// four days is hourly averages
+	points, res, err = r.MetricHistory(ctx, "srv1", start, start.AddDate(0, 0, 4))
+	require.NoError(t, err)
+	assert.Equal(ResolutionHourly, res)
+	require.Len(t, points, 96)
+	assert.Equal(int64(1), points[0].Values["cpu_sql_pct"]) // 0.5 rounds up
+
+	// without raw rows, an hour comes from the rollup
+	_, err = r.pool.Exec("DELETE FROM server_metric")
+	require.NoError(t, err)
+	points, res, err = r.MetricHistory(ctx, "srv2", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(ResolutionHourly, res)
+	require.Len(t, points, 1)
+	assert.Equal(int64(50), points[0].Values["cpu_sql_pct"])
+
+	// waits are summed by hour for longer spans
+	waits, res, err := r.WaitHistory(ctx, "request_wait", "srv1", start, start.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	assert.Equal(ResolutionHourly, res)
+	require.Len(t, waits, 72)
+	assert.Equal(int64(120), waits[0].Waits["CPU"])
+
+	waits, res, err = r.WaitHistory(ctx, "request_wait", "srv1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(ResolutionRaw, res)
+	assert.Len(waits, 60)
+
+	// and by day past two months
+	waits, res, err = r.WaitHistory(ctx, "request_wait", "srv1", start, start.AddDate(0, 3, 0))
+	require.NoError(t, err)
+	assert.Equal(ResolutionDaily, res)
+	require.Len(t, waits, 5)
+	assert.Equal(int64(2*24*60), waits[0].Waits["CPU"])
+
+	// without raw rows, an hour comes from the rollup
+	_, err = r.pool.Exec("DELETE FROM request_wait")
+	require.NoError(t, err)
+	waits, res, err = r.WaitHistory(ctx, "request_wait", "srv1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(ResolutionHourly, res)
+	require.Len(t, waits, 1)
+	assert.Equal(int64(120), waits[0].Waits["CPU"])
+
+	_, _, err = r.WaitHistory(ctx, "server_metric", "srv1", start, start.Add(time.Hour))
+	assert.Error(err)
+
+	var nilRepo *Repository
+	_, _, err = nilRepo.MetricHistory(ctx, "srv1", start, start.Add(time.Hour))
+	assert.ErrorIs(err, ErrNoRepository)
+}
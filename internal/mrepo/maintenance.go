@@ -0,0 +1,665 @@
+package mrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maintainEvery = time.Hour
+	maintainDelay = 2 * time.Minute
+	purgeBatch    = 5000
+	purgePause    = 250 * time.Millisecond
+	maxBuckets    = 24 * 7 // per table per pass so catching up doesn't hog the repository
+)
+
+// pendingTable holds the hours that got rows after they were rolled up
+const pendingTable = "rollup_pending"
+
+// period is an hourly or daily rollup
+type period struct {
+	table     string
+	retention int
+	start     func(time.Time) time.Time
+	next      func(time.Time) time.Time
+	// source is the table the buckets are built from and sourceTS its time column
+	source   string
+	sourceTS string
+	build    func(ctx context.Context, table string, from, to time.Time) error
+}
+
+func (r *Repository) periods() []period {
+	return []period{
+		{
+			table:     "server_metric_hourly",
+			retention: r.cfg.HourlyRetentionDays,
+			start:     hourStart,
+			next:      func(t time.Time) time.Time { return t.Add(time.Hour) },
+			source:    metricTable,
+			sourceTS:  "ts",
+			build:     r.rollupBucket,
+		},
+		{
+			table:     "server_metric_daily",
+			retention: r.cfg.DailyRetentionDays,
+			start:     func(t time.Time) time.Time { return truncateDate(t.Local()) },
+			next:      func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+			source:    "server_metric_hourly",
+			sourceTS:  "bucket_ts",
+			build:     r.rollupDaily,
+		},
+	}
+}
+
+// waitTables are the raw wait tables.  Each has an hourly and a daily
+// table with the seconds summed by wait type.
+var waitTables = []string{"request_wait", "server_wait"}
+
+// waitPeriods returns the hourly and daily rollups for a wait table
+func (r *Repository) waitPeriods(table string) (period, period) {
+	periods := r.periods()
+	hourly, daily := periods[0], periods[1]
+	hourly.table, hourly.source = table+"_hourly", table
+	hourly.build = r.rollupWaits(table, "ts")
+	daily.table, daily.source = table+"_daily", hourly.table
+	daily.build = r.rollupWaits(hourly.table, "bucket_ts")
+	return hourly, daily
+}
+
+// chain is an hourly rollup and the daily rollup built from it
+type chain struct {
+	hourly, daily period
+	// the times the rollups are complete through
+	hourlyThrough, dailyThrough time.Time
+	// the buckets built
+	hours, days int
+}
+
+// hourStart is the start of the local hour
+func hourStart(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// MaintenanceResult counts the work done in one pass
+type MaintenanceResult struct {
+	Hourly int   // hourly buckets built
+	Daily  int   // daily buckets built
+	Waits  int   // hourly and daily wait buckets built
+	Purged int64 // rows deleted
+}
+
+// maintain builds the rollups and purges old rows every hour
+func (r *Repository) maintain(ctx context.Context) {
+	defer failure.HandlePanic()
+	defer close(r.maintDone)
+	timer := time.NewTimer(r.maintainDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if r.connected.Load() {
+			result, err := r.maintenance(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				logrus.Error(errors.Wrap(err, "REPOSITORY: maintenance"))
+				r.applog.Enqueue(fmt.Sprintf("REPOSITORY: maintenance: %s", err.Error()))
+			}
+			if result.Hourly+result.Daily+result.Waits > 0 || result.Purged > 0 {
+				logrus.Infof("REPOSITORY: maintenance: hourly=%d daily=%d waits=%d purged=%d", result.Hourly, result.Daily, result.Waits, result.Purged)
+			}
+		}
+		timer.Reset(r.maintainEvery)
+	}
+}
+
+// maintenance builds any completed rollup buckets, rebuilds the
+// buckets that got late rows, and then purges rows past their
+// retention.  Raw metrics and waits are only purged once they are in
+// the hourly rollup and hourly rows once they are in the daily rollup.
+func (r *Repository) maintenance(ctx context.Context, now time.Time) (MaintenanceResult, error) {
+	var result MaintenanceResult
+	periods := r.periods()
+	chains := []*chain{{hourly: periods[0], daily: periods[1]}}
+	for _, table := range waitTables {
+		hourly, daily := r.waitPeriods(table)
+		chains = append(chains, &chain{hourly: hourly, daily: daily})
+	}
+	// the first chain is the metrics and the rest are the waits
+	done := func(err error) (MaintenanceResult, error) {
+		result.Hourly, result.Daily = chains[0].hours, chains[0].days
+		for _, c := range chains[1:] {
+			result.Waits += c.hours + c.days
+		}
+		return result, err
+	}
+
+	// rows written from here on for hours before this one are marked
+	// so the buckets they fall in get rebuilt
+	r.markRolled(hourStart(now))
+	for _, c := range chains {
+		err := r.rollupChain(ctx, c, now)
+		if err != nil {
+			return done(err)
+		}
+	}
+	// the rows a bucket is built from may be purged past their
+	// retention so those buckets are kept as they are
+	var hourlyKept, dailyKept time.Time
+	if r.cfg.RetentionDays > 0 {
+		hourlyKept = now.AddDate(0, 0, -r.cfg.RetentionDays)
+	}
+	if r.cfg.HourlyRetentionDays > 0 {
+		dailyKept = now.AddDate(0, 0, -r.cfg.HourlyRetentionDays)
+	}
+	err := r.rollupPending(ctx, chains, hourlyKept, dailyKept)
+	if err != nil {
+		return done(errors.Wrap(err, pendingTable))
+	}
+
+	if r.cfg.RetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -r.cfg.RetentionDays)
+		n, err := r.purge(ctx, customMetricTable, "ts", cutoff)
+		result.Purged += n
+		if err != nil {
+			return done(errors.Wrap(err, customMetricTable))
+		}
+		for _, c := range chains {
+			before := cutoff
+			if c.hourlyThrough.Before(before) {
+				before = c.hourlyThrough
+			}
+			n, err := r.purge(ctx, c.hourly.source, "ts", before)
+			result.Purged += n
+			if err != nil {
+				return done(errors.Wrap(err, c.hourly.source))
+			}
+		}
+	}
+	for _, c := range chains {
+		for _, p := range []period{c.hourly, c.daily} {
+			if p.retention <= 0 {
+				continue
+			}
+			cutoff := now.AddDate(0, 0, -p.retention)
+			if p.table == c.hourly.table && c.dailyThrough.Before(cutoff) {
+				cutoff = c.dailyThrough
+			}
+			n, err := r.purge(ctx, p.table, "bucket_ts", cutoff)
+			result.Purged += n
+			if err != nil {
+				return done(errors.Wrap(err, p.table))
+			}
+		}
+	}
+	return done(nil)
+}
+
+// rollupChain builds the hourly buckets through now and then the
+// daily buckets from them
+func (r *Repository) rollupChain(ctx context.Context, c *chain, now time.Time) error {
+	n, through, err := r.rollup(ctx, c.hourly, now)
+	c.hours += n
+	c.hourlyThrough = through
+	if err != nil {
+		return errors.Wrap(err, c.hourly.table)
+	}
+	n, through, err = r.rollup(ctx, c.daily, c.hourlyThrough)
+	c.days += n
+	c.dailyThrough = through
+	if err != nil {
+		return errors.Wrap(err, c.daily.table)
+	}
+	return nil
+}
+
+// rollup builds the buckets after the last one in the table up to
+// the bucket that holds through.  It returns the buckets built and the
+// time the rollup is complete through.
+func (r *Repository) rollup(ctx context.Context, p period, through time.Time) (int, time.Time, error) {
+	var from time.Time
+	last, err := r.bucketTime(ctx, fmt.Sprintf("SELECT MAX(bucket_ts) FROM %s", r.backend.Table(p.table)))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if !last.IsZero() {
+		from = p.next(p.start(last))
+	} else {
+		first, err := r.bucketTime(ctx, fmt.Sprintf("SELECT MIN(%s) FROM %s", p.sourceTS, r.backend.Table(p.source)))
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		if first.IsZero() {
+			return 0, through, nil // nothing to roll up
+		}
+		from = p.start(first)
+	}
+	end := p.start(through)
+	var n int
+	for ; from.Before(end) && n < maxBuckets; from = p.next(from) {
+		if ctx.Err() != nil {
+			return n, from, ctx.Err()
+		}
+		err = p.build(ctx, p.table, from, p.next(from))
+		if err != nil {
+			return n, from, err
+		}
+		n++
+	}
+	return n, from, nil
+}
+
+// rollupPending rebuilds the hours in the pending table and the days
+// they fall in for each chain.  Buckets that aren't built yet are left
+// for rollup and buckets before the kept times, whose rows may be
+// purged, are left as they are.
+func (r *Repository) rollupPending(ctx context.Context, chains []*chain, hourlyKept, dailyKept time.Time) error {
+	rows, err := r.pool.QueryContext(ctx, fmt.Sprintf("SELECT bucket_ts FROM %s ORDER BY bucket_ts", r.backend.Table(pendingTable)))
+	if err != nil {
+		return errors.Wrap(err, "query")
+	}
+	pending := make([]any, 0)
+	for rows.Next() {
+		var v any
+		err = rows.Scan(&v)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scan")
+		}
+		pending = append(pending, v)
+	}
+	// close the rows before writing.  SQLite only has one connection.
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "rows")
+	}
+
+	rebuild := make(map[*chain][]time.Time)
+	seen := make(map[*chain]map[time.Time]bool)
+	remove := r.pool.Rebind(fmt.Sprintf("DELETE FROM %s WHERE bucket_ts = ?", r.backend.Table(pendingTable)))
+	for _, v := range pending {
+		h, err := toTime(v)
+		if err != nil {
+			return err
+		}
+		h = hourStart(h)
+		// remove it first so a row written while the bucket is
+		// rebuilt marks it again
+		_, err = r.pool.ExecContext(ctx, remove, v)
+		if err != nil {
+			return errors.Wrap(err, "delete")
+		}
+		if h.Before(hourlyKept) {
+			continue
+		}
+		for _, c := range chains {
+			if !h.Before(c.hourlyThrough) {
+				continue
+			}
+			err = c.hourly.build(ctx, c.hourly.table, h, c.hourly.next(h))
+			if err != nil {
+				return errors.Wrap(err, c.hourly.table)
+			}
+			c.hours++
+			day := c.daily.start(h)
+			if !day.Before(dailyKept) && day.Before(c.dailyThrough) && !seen[c][day] {
+				if seen[c] == nil {
+					seen[c] = make(map[time.Time]bool)
+				}
+				seen[c][day] = true
+				rebuild[c] = append(rebuild[c], day)
+			}
+		}
+	}
+	for _, c := range chains {
+		for _, day := range rebuild[c] {
+			err = c.daily.build(ctx, c.daily.table, day, c.daily.next(day))
+			if err != nil {
+				return errors.Wrap(err, c.daily.table)
+			}
+			c.days++
+		}
+	}
+	return nil
+}
+
+// markRolled raises the time before which written metric rows are
+// marked as pending
+func (r *Repository) markRolled(t time.Time) {
+	for {
+		old := r.rolled.Load()
+		if t.UnixNano() <= old || r.rolled.CompareAndSwap(old, t.UnixNano()) {
+			return
+		}
+	}
+}
+
+// loadRolled sets the time before which written metric rows are
+// marked as pending from the last hourly bucket
+func (r *Repository) loadRolled(ctx context.Context) error {
+	last, err := r.bucketTime(ctx, fmt.Sprintf("SELECT MAX(bucket_ts) FROM %s", r.backend.Table("server_metric_hourly")))
+	if err != nil {
+		return err
+	}
+	if !last.IsZero() {
+		r.markRolled(hourStart(last).Add(time.Hour))
+	}
+	return nil
+}
+
+// pendingHours returns the hours of the metric and wait rows that
+// fall in buckets that may already be built
+func (r *Repository) pendingHours(recs []record) []time.Time {
+	rolled := r.rolled.Load()
+	if rolled == 0 {
+		return nil
+	}
+	hours := make([]time.Time, 0)
+	seen := make(map[time.Time]bool)
+	for _, rec := range recs {
+		if !rolledUp(rec.Table) || rec.TS.UnixNano() >= rolled {
+			continue
+		}
+		h := hourStart(rec.TS)
+		if !seen[h] {
+			seen[h] = true
+			hours = append(hours, h)
+		}
+	}
+	return hours
+}
+
+// rolledUp is true for the tables with hourly and daily rollups
+func rolledUp(table string) bool {
+	return table == metricTable || slices.Contains(waitTables, table)
+}
+
+// rollupBucket replaces the hourly rows for one bucket from the raw metrics
+func (r *Repository) rollupBucket(ctx context.Context, table string, from, to time.Time) error {
+	query := fmt.Sprintf("SELECT server_key, server_name, %s FROM %s WHERE ts >= ? AND ts < ?",
+		strings.Join(metricColumns, ", "), r.backend.Table(metricTable))
+	rows, err := r.pool.QueryContext(ctx, r.pool.Rebind(query), r.backend.Timestamp(from), r.backend.Timestamp(to))
+	if err != nil {
+		return errors.Wrap(err, "query")
+	}
+	servers := make(map[string]*rollupRow)
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key, name string
+		values := make([]sql.NullInt64, len(metricColumns))
+		dest := []any{&key, &name}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scan")
+		}
+		row, ok := servers[key]
+		if !ok {
+			row = &rollupRow{key: key, values: make([][]int64, len(metricColumns))}
+			servers[key] = row
+			keys = append(keys, key)
+		}
+		row.name = name
+		row.samples++
+		for i, v := range values {
+			if v.Valid {
+				row.values[i] = append(row.values[i], v.Int64)
+			}
+		}
+	}
+	// close the rows before the transaction.  SQLite only has one connection.
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "rows")
+	}
+	sort.Strings(keys)
+	list := make([][]any, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, servers[key].args(r.backend.Timestamp(from)))
+	}
+	return r.replaceBucket(ctx, table, from, rollupColumns(), list)
+}
+
+// rollupDaily replaces the daily rows for one bucket from the hourly
+// rows.  The average is weighted by the samples in each hour.  The p95
+// is the highest hourly p95.
+func (r *Repository) rollupDaily(ctx context.Context, table string, from, to time.Time) error {
+	selects := []string{"server_key", "MAX(server_name)", "SUM(samples)"}
+	for _, col := range metricColumns {
+		selects = append(selects,
+			fmt.Sprintf("MIN(%s_min)", col),
+			fmt.Sprintf("SUM(%s_avg * samples) / NULLIF(SUM(CASE WHEN %s_avg IS NULL THEN 0 ELSE samples END), 0)", col, col),
+			fmt.Sprintf("MAX(%s_max)", col),
+			fmt.Sprintf("MAX(%s_p95)", col),
+		)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE bucket_ts >= ? AND bucket_ts < ? GROUP BY server_key ORDER BY server_key",
+		strings.Join(selects, ", "), r.backend.Table("server_metric_hourly"))
+	rows, err := r.pool.QueryContext(ctx, r.pool.Rebind(query), r.backend.Timestamp(from), r.backend.Timestamp(to))
+	if err != nil {
+		return errors.Wrap(err, "query")
+	}
+	list := make([][]any, 0)
+	for rows.Next() {
+		values := make([]any, len(selects))
+		dest := make([]any, len(selects))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scan")
+		}
+		list = append(list, append([]any{r.backend.Timestamp(from)}, values...))
+	}
+	// close the rows before the transaction.  SQLite only has one connection.
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "rows")
+	}
+	return r.replaceBucket(ctx, table, from, rollupColumns(), list)
+}
+
+// waitRollupColumns are the columns of the hourly and daily wait tables
+var waitRollupColumns = []string{"bucket_ts", "server_key", "server_name", "wait_type", "wait_time_sec"}
+
+// rollupWaits returns a build that replaces the wait rows for one
+// bucket with the seconds in source summed by server and wait type
+func (r *Repository) rollupWaits(source, sourceTS string) func(ctx context.Context, table string, from, to time.Time) error {
+	return func(ctx context.Context, table string, from, to time.Time) error {
+		query := fmt.Sprintf("SELECT server_key, MAX(server_name), wait_type, SUM(wait_time_sec) FROM %s WHERE %s >= ? AND %s < ? GROUP BY server_key, wait_type ORDER BY server_key, wait_type",
+			r.backend.Table(source), sourceTS, sourceTS)
+		rows, err := r.pool.QueryContext(ctx, r.pool.Rebind(query), r.backend.Timestamp(from), r.backend.Timestamp(to))
+		if err != nil {
+			return errors.Wrap(err, "query")
+		}
+		list := make([][]any, 0)
+		for rows.Next() {
+			var key, name, wait string
+			var seconds int64
+			err = rows.Scan(&key, &name, &wait, &seconds)
+			if err != nil {
+				rows.Close()
+				return errors.Wrap(err, "scan")
+			}
+			list = append(list, []any{r.backend.Timestamp(from), key, name, wait, seconds})
+		}
+		// close the rows before the transaction.  SQLite only has one connection.
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return errors.Wrap(err, "rows")
+		}
+		return r.replaceBucket(ctx, table, from, waitRollupColumns, list)
+	}
+}
+
+// replaceBucket deletes the rows for a bucket and inserts list.
+// Each entry holds the values in columns order.
+func (r *Repository) replaceBucket(ctx context.Context, table string, from time.Time, columns []string, list [][]any) error {
+	tx, err := r.pool.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begintx")
+	}
+	_, err = tx.ExecContext(ctx, r.pool.Rebind(fmt.Sprintf("DELETE FROM %s WHERE bucket_ts = ?", r.backend.Table(table))), r.backend.Timestamp(from))
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "delete")
+	}
+	per := maxParams / len(columns)
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	for len(list) > 0 {
+		n := min(per, len(list))
+		values := make([]string, 0, n)
+		args := make([]any, 0, n*len(columns))
+		for _, v := range list[:n] {
+			values = append(values, row)
+			args = append(args, v...)
+		}
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", r.backend.Table(table), strings.Join(columns, ", "), strings.Join(values, ", "))
+		_, err = tx.ExecContext(ctx, r.pool.Rebind(query), args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(err, "insert")
+		}
+		list = list[n:]
+	}
+	return errors.Wrap(tx.Commit(), "commit")
+}
+
+// purge deletes rows older than before in small batches
+func (r *Repository) purge(ctx context.Context, table, column string, before time.Time) (int64, error) {
+	query := r.pool.Rebind(r.backend.DeleteBatch(r.backend.Table(table), column, purgeBatch))
+	var total int64
+	for {
+		ctxq, cancel := context.WithTimeout(ctx, writeTimeout)
+		result, err := r.pool.ExecContext(ctxq, query, r.backend.Timestamp(before))
+		cancel()
+		if err != nil {
+			return total, errors.Wrap(err, "delete")
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, errors.Wrap(err, "rowsaffected")
+		}
+		total += n
+		if n < purgeBatch {
+			return total, nil
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(purgePause):
+		}
+	}
+}
+
+// bucketTime runs a MIN or MAX query.  SQLite returns text for these.
+func (r *Repository) bucketTime(ctx context.Context, query string) (time.Time, error) {
+	var v any
+	err := r.pool.QueryRowContext(ctx, query).Scan(&v)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "scan")
+	}
+	return toTime(v)
+}
+
+// toTime converts a scanned time.  SQLite may return text.
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return t, nil
+	case []byte:
+		return parseTime(string(t))
+	case string:
+		return parseTime(t)
+	}
+	return time.Time{}, fmt.Errorf("unexpected time: %T", v)
+}
+
+func parseTime(s string) (time.Time, error) {
+	layouts := []string{
+		"2006-01-02 15:04:05.999999999 -0700 MST",
+		"2006-01-02 15:04:05.999999999-07:00",
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02 15:04:05",
+	}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
+
+// rollupRow holds the samples for one server in a bucket
+type rollupRow struct {
+	key     string
+	name    string
+	samples int
+	values  [][]int64 // in metricColumns order
+}
+
+// args returns the values in rollupColumns order
+func (row *rollupRow) args(ts any) []any {
+	args := []any{ts, row.key, row.name, row.samples}
+	for _, values := range row.values {
+		if len(values) == 0 {
+			args = append(args, nil, nil, nil, nil)
+			continue
+		}
+		s := summarize(values)
+		args = append(args, s.min, s.avg, s.max, s.p95)
+	}
+	return args
+}
+
+// rollupColumns are the columns of the hourly and daily tables
+func rollupColumns() []string {
+	columns := []string{"bucket_ts", "server_key", "server_name", "samples"}
+	for _, col := range metricColumns {
+		columns = append(columns, col+"_min", col+"_avg", col+"_max", col+"_p95")
+	}
+	return columns
+}
+
+type summary struct {
+	min, max, p95 int64
+	avg           float64
+}
+
+// summarize returns the statistics for values.  p95 uses the nearest rank.
+func summarize(values []int64) summary {
+	sorted := append([]int64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total float64
+	for _, v := range sorted {
+		total += float64(v)
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return summary{
+		min: sorted[0],
+		max: sorted[len(sorted)-1],
+		p95: sorted[max(rank, 0)],
+		avg: total / float64(len(sorted)),
+	}
+}
@@ -0,0 +1,210 @@
+package mrepo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	assert := assert.New(t)
+	values := make([]int64, 0, 100)
+	for i := 100; i > 0; i-- {
+		values = append(values, int64(i))
+	}
+	s := summarize(values)
+	assert.Equal(int64(1), s.min)
+	assert.Equal(int64(100), s.max)
+	assert.Equal(int64(95), s.p95)
+	assert.Equal(50.5, s.avg)
+	assert.Equal(int64(100), values[0], "doesn't sort the input")
+
+	s = summarize([]int64{7})
+	assert.Equal(summary{min: 7, max: 7, p95: 7, avg: 7}, s)
+}
+
+func TestMaintenance(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	cfg := Config{Driver: DriverSQLite, Database: file, RetentionDays: 1, HourlyRetentionDays: 2}
+	r, err := NewRepository(cfg, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	// three days of minutes for two servers
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local)
+	recs := make([]record, 0)
+	for ts := start; ts.Before(start.AddDate(0, 0, 3)); ts = ts.Add(time.Minute) {
+		recs = append(recs,
+			record{Table: metricTable, TS: ts, Key: "srv1", Server: "SQL01", Values: map[string]int64{"cpu_sql_pct": int64(ts.Minute())}},
+			record{Table: metricTable, TS: ts, Key: "srv2", Server: "SQL02", Values: map[string]int64{"cpu_sql_pct": 10}},
+			record{Table: "server_wait", TS: ts, Key: "srv1", Server: "SQL01", Start: start, Wait: "CPU", WaitSec: 1},
+		)
+	}
+	require.NoError(t, r.insert(recs))
+
+	now := start.AddDate(0, 0, 3).Add(30 * time.Minute)
+	ctx := context.Background()
+	result, err := r.maintenance(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(72, result.Hourly)
+	assert.Equal(3, result.Daily)
+	assert.Equal(72+3, result.Waits)
+
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric_daily"))
+	assert.Equal(6, n)
+
+	// the waits are summed by hour and day
+	var seconds int64
+	require.NoError(t, r.pool.Get(&seconds, "SELECT wait_time_sec FROM server_wait_hourly WHERE server_key = 'srv1' AND wait_type = 'CPU' AND bucket_ts = ?", r.backend.Timestamp(start.AddDate(0, 0, 2).Add(5*time.Hour))))
+	assert.Equal(int64(60), seconds)
+	require.NoError(t, r.pool.Get(&seconds, "SELECT wait_time_sec FROM server_wait_daily WHERE server_key = 'srv1' AND wait_type = 'CPU' AND bucket_ts = ?", r.backend.Timestamp(start)))
+	assert.Equal(int64(24*60), seconds)
+
+	var row struct {
+		Samples int     `db:"samples"`
+		Min     int64   `db:"cpu_sql_pct_min"`
+		Avg     float64 `db:"cpu_sql_pct_avg"`
+		Max     int64   `db:"cpu_sql_pct_max"`
+		P95     int64   `db:"cpu_sql_pct_p95"`
+		Memory  *int64  `db:"memory_used_mb_max"`
+	}
+	require.NoError(t, r.pool.Get(&row, "SELECT samples, cpu_sql_pct_min, cpu_sql_pct_avg, cpu_sql_pct_max, cpu_sql_pct_p95, memory_used_mb_max FROM server_metric_hourly WHERE server_key = 'srv1' AND bucket_ts = ?", r.backend.Timestamp(start.AddDate(0, 0, 2).Add(5*time.Hour))))
+	assert.Equal(60, row.Samples)
+	assert.Equal(int64(0), row.Min)
+	assert.Equal(29.5, row.Avg)
+	assert.Equal(int64(59), row.Max)
+	assert.Equal(int64(56), row.P95)
+	assert.Nil(row.Memory)
+
+	// the daily rows are built from the hourly rows
+	require.NoError(t, r.pool.Get(&row, "SELECT samples, cpu_sql_pct_min, cpu_sql_pct_avg, cpu_sql_pct_max, cpu_sql_pct_p95, memory_used_mb_max FROM server_metric_daily WHERE server_key = 'srv1' AND bucket_ts = ?", r.backend.Timestamp(start.AddDate(0, 0, 2))))
+	assert.Equal(24*60, row.Samples)
+	assert.Equal(int64(0), row.Min)
+	assert.Equal(29.5, row.Avg)
+	assert.Equal(int64(59), row.Max)
+	assert.Equal(int64(56), row.P95)
+	assert.Nil(row.Memory)
+
+	// raw rows past a day are gone, hourly rows past two days
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric"))
+	assert.Equal(2*(24*60-30), n)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_wait"))
+	assert.Equal(24*60-30, n)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric_hourly"))
+	assert.Equal(2*(72-25), n)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_wait_hourly"))
+	assert.Equal(72-25, n)
+	assert.Equal(int64(3*(2*24*60+30)+3*25), result.Purged)
+
+	// nothing new to do
+	result, err = r.maintenance(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(MaintenanceResult{}, result)
+
+	// an hour later builds one more hourly bucket
+	result, err = r.maintenance(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(1, result.Hourly)
+	assert.Equal(0, result.Daily)
+	assert.Equal(1, result.Waits)
+}
+
+func TestMaintenanceKeepsUnrolledRows(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file, RetentionDays: 1}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	// more old hours than one pass builds
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	recs := make([]record, 0)
+	for ts := start; ts.Before(start.AddDate(0, 0, 10)); ts = ts.Add(time.Hour) {
+		recs = append(recs, record{Table: metricTable, TS: ts, Key: "srv1", Server: "SQL01", Values: map[string]int64{"cpu_sql_pct": 1}})
+	}
+	require.NoError(t, r.insert(recs))
+	result, err := r.maintenance(context.Background(), start.AddDate(0, 0, 10))
+	require.NoError(t, err)
+	assert.Equal(maxBuckets, result.Hourly)
+	// days are only built once their hours are
+	assert.Equal(maxBuckets/24, result.Daily)
+
+	// only the rows in the hourly rollup are purged
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric"))
+	assert.Equal(10*24-maxBuckets, n)
+}
+
+func TestMaintenanceLateRows(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.Local)
+	recs := make([]record, 0)
+	for ts := start; ts.Before(start.AddDate(0, 0, 2)); ts = ts.Add(time.Minute) {
+		recs = append(recs, record{Table: metricTable, TS: ts, Key: "srv1", Server: "SQL01", Values: map[string]int64{"cpu_sql_pct": 10}})
+	}
+	require.NoError(t, r.insert(recs))
+	ctx := context.Background()
+	now := start.AddDate(0, 0, 2).Add(10 * time.Minute)
+	result, err := r.maintenance(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(48, result.Hourly)
+	assert.Equal(2, result.Daily)
+
+	// a spooled row arrives for a server in an hour that is built
+	late := start.Add(5 * time.Hour)
+	require.NoError(t, r.insert([]record{
+		{Table: metricTable, TS: late, Key: "srv2", Server: "SQL02", Values: map[string]int64{"cpu_sql_pct": 90}},
+		{Table: metricTable, TS: late.Add(time.Minute), Key: "srv2", Server: "SQL02", Values: map[string]int64{"cpu_sql_pct": 70}},
+	}))
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM rollup_pending"))
+	assert.Equal(1, n)
+
+	// the hour and day are rebuilt for the metrics and both wait tables
+	result, err = r.maintenance(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(MaintenanceResult{Hourly: 1, Daily: 1, Waits: 4}, result)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM rollup_pending"))
+	assert.Equal(0, n)
+
+	var row struct {
+		Samples int     `db:"samples"`
+		Avg     float64 `db:"cpu_sql_pct_avg"`
+		Max     int64   `db:"cpu_sql_pct_max"`
+	}
+	require.NoError(t, r.pool.Get(&row, "SELECT samples, cpu_sql_pct_avg, cpu_sql_pct_max FROM server_metric_hourly WHERE server_key = 'srv2' AND bucket_ts = ?", r.backend.Timestamp(late)))
+	assert.Equal(2, row.Samples)
+	assert.Equal(80.0, row.Avg)
+	assert.Equal(int64(90), row.Max)
+	require.NoError(t, r.pool.Get(&row, "SELECT samples, cpu_sql_pct_avg, cpu_sql_pct_max FROM server_metric_daily WHERE server_key = 'srv2' AND bucket_ts = ?", r.backend.Timestamp(start)))
+	assert.Equal(2, row.Samples)
+	assert.Equal(80.0, row.Avg)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric_daily"))
+	assert.Equal(3, n)
+
+	// a late wait row is added to the hour too
+	require.NoError(t, r.insert([]record{{Table: "request_wait", TS: late, Key: "srv2", Server: "SQL02", Wait: "CPU", WaitSec: 7}}))
+	_, err = r.maintenance(ctx, now)
+	require.NoError(t, err)
+	var seconds int64
+	require.NoError(t, r.pool.Get(&seconds, "SELECT wait_time_sec FROM request_wait_hourly WHERE server_key = 'srv2' AND bucket_ts = ?", r.backend.Timestamp(late)))
+	assert.Equal(int64(7), seconds)
+
+	// rows for the current hour aren't pending
+	require.NoError(t, r.insert([]record{{Table: metricTable, TS: now, Key: "srv2", Server: "SQL02", Values: map[string]int64{"cpu_sql_pct": 1}}}))
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM rollup_pending"))
+	assert.Equal(0, n)
+}
@@ -1,83 +1,100 @@
 package mrepo
 
 import (
-	"context"
-	"fmt"
-	"strings"
 	"time"
 
 	"github.com/scalesql/isitsql/internal/waitring"
 )
 
-// WriteMetrics writes the collected metrics to the repository.
+// WriteMetrics queues the collected metrics for the repository.
+// Only the keys in metricColumns are written.
 func (r *Repository) WriteMetrics(ts time.Time, m map[string]any) {
-	if r == nil {
+	if r == nil || r.queue == nil {
 		return
 	}
-	if r.pool == nil {
-		return
+	rec := record{
+		Table:  metricTable,
+		TS:     ts,
+		Values: make(map[string]int64),
+	}
+	rec.Key, _ = m["server_key"].(string)
+	rec.Server, _ = m["server_name"].(string)
+	rec.Start, _ = m["server_start"].(time.Time)
+	for _, col := range metricColumns {
+		n, ok := toInt64(m[col])
+		if ok {
+			rec.Values[col] = n
+		}
 	}
-	m["ts"] = ts
-	m["ts_date"] = truncateDate(ts) // truncate to date
-	m["ts_time"] = ts.Truncate(time.Minute)
-	query := insertFromMap("dbo", "server_metric", m)
+	r.enqueue(rec)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel() // ensure the context is cancelled
-	_, err := r.pool.NamedExecContext(ctx, query, m)
-	r.handleError(err)
+// CustomMetric is the last value of a custom metric from the HCL files
+type CustomMetric struct {
+	Name      string
+	Unit      string
+	Value     int64
+	PerSecond int64 // only set for accumulating metrics
 }
 
-// WriteWaits writes the collected waits to the repository.
-func (r *Repository) WriteWaits(key, server, table string, start time.Time, w waitring.WaitList) {
-	if r == nil {
+// WriteCustomMetrics queues the custom metrics polled from a server
+func (r *Repository) WriteCustomMetrics(key, server string, ts time.Time, mm []CustomMetric) {
+	if r == nil || r.queue == nil || len(mm) == 0 {
 		return
 	}
-	if r.pool == nil || len(w.Waits) == 0 {
+	ts = ts.Truncate(time.Second)
+	recs := make([]record, 0, len(mm))
+	for _, m := range mm {
+		recs = append(recs, record{
+			Table:   customMetricTable,
+			TS:      ts,
+			Key:     key,
+			Server:  server,
+			Values:  map[string]int64{"value": m.Value, "value_per_second": m.PerSecond},
+			Strings: map[string]string{"metric_name": m.Name, "unit": m.Unit},
+		})
+	}
+	r.enqueue(recs...)
+}
+
+// WriteWaits queues the collected waits for the repository.
+func (r *Repository) WriteWaits(key, server, table string, start time.Time, w waitring.WaitList) {
+	if r == nil || r.queue == nil {
 		return
 	}
-	rows := []map[string]any{}
+	recs := make([]record, 0, len(w.Waits))
 	for wait, tm := range w.Waits {
 		if tm < 1000 { // skip waits less than 1 second
 			continue
 		}
-		row := map[string]any{
-			"ts":            w.TS,
-			"ts_date":       truncateDate(w.TS), // truncate to date
-			"ts_time":       w.TS.Truncate(time.Minute),
-			"server_key":    key,
-			"server_name":   server,
-			"wait_type":     wait,
-			"wait_time_sec": tm / 1000, // convert to seconds
-			"server_start":  start,
-		}
-		rows = append(rows, row)
-	}
-	if len(rows) == 0 {
-		return // no waits to write
+		recs = append(recs, record{
+			Table:   table,
+			TS:      w.TS,
+			Key:     key,
+			Server:  server,
+			Start:   start,
+			Wait:    wait,
+			WaitSec: tm / 1000, // convert to seconds
+		})
 	}
-
-	query := fmt.Sprintf(`INSERT [dbo].[%s] (ts, ts_date, ts_time, server_key, server_name, server_start, wait_type, wait_time_sec) 
-						VALUES (:ts, :ts_date, :ts_time, :server_key, :server_name, :server_start, :wait_type, :wait_time_sec)`, table)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel() // ensure the context is cancelled
-	_, err := r.pool.NamedExecContext(ctx, query, rows)
-	r.handleError(err)
+	r.enqueue(recs...)
 }
 
-func insertFromMap(schema, table string, data map[string]any) string {
-	var columns []string
-	var namedParams []string
-	for key := range data {
-		columns = append(columns, key)
-		namedParams = append(namedParams, ":"+key) // Using named parameters for sqlx
+// toInt64 converts the numeric values in the metrics map
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
 	}
-
-	columnsStr := strings.Join(columns, ", ")
-	namedParamsStr := strings.Join(namedParams, ", ")
-
-	query := fmt.Sprintf("INSERT INTO [%s].[%s] (%s) VALUES (%s)", schema, table, columnsStr, namedParamsStr)
-	return query
+	return 0, false
 }
 
 // truncateDate returns the date at midnight in the original time zone.
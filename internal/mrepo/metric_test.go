@@ -1,10 +1,14 @@
 package mrepo
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDateTruncate(t *testing.T) {
@@ -37,3 +41,35 @@ func TestTruncateTime(t *testing.T) {
 	assert.Equal(0, truncated.Second())
 	assert.Equal(0, truncated.Nanosecond())
 }
+
+func TestWriteCustomMetrics(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	ts := time.Date(2025, 1, 2, 11, 34, 56, 500, time.UTC)
+	mm := []CustomMetric{
+		{Name: "queue_depth", Unit: "rows", Value: 42},
+		{Name: "orders", Value: 600, PerSecond: 10},
+	}
+	r.WriteCustomMetrics("srv1", "SQL01", ts, mm)
+	r.WriteCustomMetrics("srv1", "SQL01", ts, mm) // the same poll written twice
+	r.WriteCustomMetrics("srv1", "SQL01", ts, nil)
+	r.Flush()
+
+	var rows []struct {
+		Name      string `db:"metric_name"`
+		Unit      string `db:"unit"`
+		Value     int64  `db:"value"`
+		PerSecond int64  `db:"value_per_second"`
+	}
+	err = r.pool.Select(&rows, "SELECT metric_name, unit, value, value_per_second FROM custom_metric ORDER BY metric_name")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal("orders", rows[0].Name)
+	assert.Equal(int64(10), rows[0].PerSecond)
+	assert.Equal("rows", rows[1].Unit)
+	assert.Equal(int64(42), rows[1].Value)
+}
@@ -0,0 +1,119 @@
+-- +goose Up
+SET ANSI_NULLS ON;
+SET QUOTED_IDENTIFIER ON;
+
+CREATE TABLE [dbo].[server_metric_hourly](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[samples] INT NOT NULL,
+	cpu_cores_min BIGINT NULL,
+	cpu_cores_avg FLOAT NULL,
+	cpu_cores_max BIGINT NULL,
+	cpu_cores_p95 BIGINT NULL,
+	cpu_sql_pct_min BIGINT NULL,
+	cpu_sql_pct_avg FLOAT NULL,
+	cpu_sql_pct_max BIGINT NULL,
+	cpu_sql_pct_p95 BIGINT NULL,
+	cpu_other_pct_min BIGINT NULL,
+	cpu_other_pct_avg FLOAT NULL,
+	cpu_other_pct_max BIGINT NULL,
+	cpu_other_pct_p95 BIGINT NULL,
+	batches_per_second_min BIGINT NULL,
+	batches_per_second_avg FLOAT NULL,
+	batches_per_second_max BIGINT NULL,
+	batches_per_second_p95 BIGINT NULL,
+	page_life_expectancy_min BIGINT NULL,
+	page_life_expectancy_avg FLOAT NULL,
+	page_life_expectancy_max BIGINT NULL,
+	page_life_expectancy_p95 BIGINT NULL,
+	memory_used_mb_min BIGINT NULL,
+	memory_used_mb_avg FLOAT NULL,
+	memory_used_mb_max BIGINT NULL,
+	memory_used_mb_p95 BIGINT NULL,
+	disk_read_iops_min BIGINT NULL,
+	disk_read_iops_avg FLOAT NULL,
+	disk_read_iops_max BIGINT NULL,
+	disk_read_iops_p95 BIGINT NULL,
+	disk_read_kb_sec_min BIGINT NULL,
+	disk_read_kb_sec_avg FLOAT NULL,
+	disk_read_kb_sec_max BIGINT NULL,
+	disk_read_kb_sec_p95 BIGINT NULL,
+	disk_read_latency_ms_min BIGINT NULL,
+	disk_read_latency_ms_avg FLOAT NULL,
+	disk_read_latency_ms_max BIGINT NULL,
+	disk_read_latency_ms_p95 BIGINT NULL,
+	disk_write_iops_min BIGINT NULL,
+	disk_write_iops_avg FLOAT NULL,
+	disk_write_iops_max BIGINT NULL,
+	disk_write_iops_p95 BIGINT NULL,
+	disk_write_kb_sec_min BIGINT NULL,
+	disk_write_kb_sec_avg FLOAT NULL,
+	disk_write_kb_sec_max BIGINT NULL,
+	disk_write_kb_sec_p95 BIGINT NULL,
+	disk_write_latency_ms_min BIGINT NULL,
+	disk_write_latency_ms_avg FLOAT NULL,
+	disk_write_latency_ms_max BIGINT NULL,
+	disk_write_latency_ms_p95 BIGINT NULL,
+	CONSTRAINT [pk_server_metric_hourly] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[server_metric_daily](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[samples] INT NOT NULL,
+	cpu_cores_min BIGINT NULL,
+	cpu_cores_avg FLOAT NULL,
+	cpu_cores_max BIGINT NULL,
+	cpu_cores_p95 BIGINT NULL,
+	cpu_sql_pct_min BIGINT NULL,
+	cpu_sql_pct_avg FLOAT NULL,
+	cpu_sql_pct_max BIGINT NULL,
+	cpu_sql_pct_p95 BIGINT NULL,
+	cpu_other_pct_min BIGINT NULL,
+	cpu_other_pct_avg FLOAT NULL,
+	cpu_other_pct_max BIGINT NULL,
+	cpu_other_pct_p95 BIGINT NULL,
+	batches_per_second_min BIGINT NULL,
+	batches_per_second_avg FLOAT NULL,
+	batches_per_second_max BIGINT NULL,
+	batches_per_second_p95 BIGINT NULL,
+	page_life_expectancy_min BIGINT NULL,
+	page_life_expectancy_avg FLOAT NULL,
+	page_life_expectancy_max BIGINT NULL,
+	page_life_expectancy_p95 BIGINT NULL,
+	memory_used_mb_min BIGINT NULL,
+	memory_used_mb_avg FLOAT NULL,
+	memory_used_mb_max BIGINT NULL,
+	memory_used_mb_p95 BIGINT NULL,
+	disk_read_iops_min BIGINT NULL,
+	disk_read_iops_avg FLOAT NULL,
+	disk_read_iops_max BIGINT NULL,
+	disk_read_iops_p95 BIGINT NULL,
+	disk_read_kb_sec_min BIGINT NULL,
+	disk_read_kb_sec_avg FLOAT NULL,
+	disk_read_kb_sec_max BIGINT NULL,
+	disk_read_kb_sec_p95 BIGINT NULL,
+	disk_read_latency_ms_min BIGINT NULL,
+	disk_read_latency_ms_avg FLOAT NULL,
+	disk_read_latency_ms_max BIGINT NULL,
+	disk_read_latency_ms_p95 BIGINT NULL,
+	disk_write_iops_min BIGINT NULL,
+	disk_write_iops_avg FLOAT NULL,
+	disk_write_iops_max BIGINT NULL,
+	disk_write_iops_p95 BIGINT NULL,
+	disk_write_kb_sec_min BIGINT NULL,
+	disk_write_kb_sec_avg FLOAT NULL,
+	disk_write_kb_sec_max BIGINT NULL,
+	disk_write_kb_sec_p95 BIGINT NULL,
+	disk_write_latency_ms_min BIGINT NULL,
+	disk_write_latency_ms_avg FLOAT NULL,
+	disk_write_latency_ms_max BIGINT NULL,
+	disk_write_latency_ms_p95 BIGINT NULL,
+	CONSTRAINT [pk_server_metric_daily] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts])
+) ON [PRIMARY];
+
+-- +goose Down
+DROP TABLE [dbo].[server_metric_daily];
+DROP TABLE [dbo].[server_metric_hourly];
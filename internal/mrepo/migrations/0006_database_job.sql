@@ -0,0 +1,51 @@
+-- +goose Up
+SET ANSI_NULLS ON;
+SET QUOTED_IDENTIFIER ON;
+
+CREATE TABLE [dbo].[database_size](
+	[ts] [datetimeoffset](0) NOT NULL,
+	[ts_date] [date] NOT NULL,
+	[ts_time] [time](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[database_name] [nvarchar](128) NOT NULL,
+	[data_size_kb] BIGINT NOT NULL,
+	[log_size_kb] BIGINT NOT NULL,
+	CONSTRAINT [pk_database_size] PRIMARY KEY CLUSTERED ([server_key], [database_name], [ts])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[database_backup](
+	[ts] [datetimeoffset](0) NOT NULL,
+	[ts_date] [date] NOT NULL,
+	[ts_time] [time](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[database_name] [nvarchar](128) NOT NULL,
+	[backup_type] [varchar](10) NOT NULL,
+	[backup_device] [nvarchar](260) NOT NULL,
+	[backup_instance] [nvarchar](128) NOT NULL,
+	CONSTRAINT [pk_database_backup] PRIMARY KEY CLUSTERED ([server_key], [database_name], [backup_type], [ts])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[job_history](
+	[ts] [datetimeoffset](0) NOT NULL,
+	[ts_date] [date] NOT NULL,
+	[ts_time] [time](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[instance_id] BIGINT NOT NULL,
+	[step_id] INT NOT NULL,
+	[run_status] INT NOT NULL,
+	[duration_sec] BIGINT NOT NULL,
+	[retries_attempted] INT NOT NULL,
+	[job_id] [varchar](36) NOT NULL,
+	[job_name] [nvarchar](128) NOT NULL,
+	[run_status_desc] [varchar](20) NOT NULL,
+	[message] [nvarchar](max) NOT NULL,
+	CONSTRAINT [pk_job_history] PRIMARY KEY CLUSTERED ([server_key], [instance_id])
+) ON [PRIMARY];
+
+-- +goose Down
+DROP TABLE [dbo].[job_history];
+DROP TABLE [dbo].[database_backup];
+DROP TABLE [dbo].[database_size];
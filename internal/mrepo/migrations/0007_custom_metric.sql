@@ -0,0 +1,19 @@
+-- +goose Up
+SET ANSI_NULLS ON;
+SET QUOTED_IDENTIFIER ON;
+
+CREATE TABLE [dbo].[custom_metric](
+	[ts] [datetimeoffset](0) NOT NULL,
+	[ts_date] [date] NOT NULL,
+	[ts_time] [time](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[metric_name] [varchar](64) NOT NULL,
+	[value] BIGINT NOT NULL,
+	[value_per_second] BIGINT NOT NULL,
+	[unit] [nvarchar](128) NOT NULL,
+	CONSTRAINT [pk_custom_metric] PRIMARY KEY CLUSTERED ([server_key], [metric_name], [ts])
+) ON [PRIMARY];
+
+-- +goose Down
+DROP TABLE [dbo].[custom_metric];
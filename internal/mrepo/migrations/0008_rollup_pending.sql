@@ -0,0 +1,11 @@
+-- +goose Up
+SET ANSI_NULLS ON;
+SET QUOTED_IDENTIFIER ON;
+
+CREATE TABLE [dbo].[rollup_pending](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	CONSTRAINT [pk_rollup_pending] PRIMARY KEY CLUSTERED ([bucket_ts])
+) ON [PRIMARY];
+
+-- +goose Down
+DROP TABLE [dbo].[rollup_pending];
@@ -0,0 +1,45 @@
+-- +goose Up
+SET ANSI_NULLS ON;
+SET QUOTED_IDENTIFIER ON;
+
+CREATE TABLE [dbo].[request_wait_hourly](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[wait_type] NVARCHAR(128) NOT NULL,
+	[wait_time_sec] BIGINT NOT NULL,
+	CONSTRAINT [pk_request_wait_hourly] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts], [wait_type])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[request_wait_daily](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[wait_type] NVARCHAR(128) NOT NULL,
+	[wait_time_sec] BIGINT NOT NULL,
+	CONSTRAINT [pk_request_wait_daily] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts], [wait_type])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[server_wait_hourly](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[wait_type] NVARCHAR(128) NOT NULL,
+	[wait_time_sec] BIGINT NOT NULL,
+	CONSTRAINT [pk_server_wait_hourly] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts], [wait_type])
+) ON [PRIMARY];
+
+CREATE TABLE [dbo].[server_wait_daily](
+	[bucket_ts] [datetimeoffset](0) NOT NULL,
+	[server_key] [nvarchar](128) NOT NULL,
+	[server_name] [nvarchar](128) NOT NULL,
+	[wait_type] NVARCHAR(128) NOT NULL,
+	[wait_time_sec] BIGINT NOT NULL,
+	CONSTRAINT [pk_server_wait_daily] PRIMARY KEY CLUSTERED ([server_key], [bucket_ts], [wait_type])
+) ON [PRIMARY];
+
+-- +goose Down
+DROP TABLE [dbo].[server_wait_daily];
+DROP TABLE [dbo].[server_wait_hourly];
+DROP TABLE [dbo].[request_wait_daily];
+DROP TABLE [dbo].[request_wait_hourly];
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE server_metric (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	server_start timestamp(0) NULL,
+	cpu_cores smallint NULL,
+	cpu_sql_pct smallint NULL,
+	cpu_other_pct smallint NULL,
+	batches_per_second int NULL,
+	page_life_expectancy int NULL,
+	memory_used_mb int NULL,
+	disk_read_iops int NULL,
+	disk_read_kb_sec int NULL,
+	disk_read_latency_ms int NULL,
+	disk_write_iops int NULL,
+	disk_write_kb_sec int NULL,
+	disk_write_latency_ms int NULL
+);
+
+CREATE INDEX ix_server_metric ON server_metric (server_key, ts);
+
+-- +goose Down
+DROP TABLE server_metric;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE request_wait (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	server_start timestamp(0) NULL,
+	wait_type varchar(128) NOT NULL,
+	wait_time_sec bigint NOT NULL
+);
+
+CREATE INDEX ix_request_wait ON request_wait (server_key, ts);
+
+-- +goose Down
+DROP TABLE request_wait;
@@ -0,0 +1,124 @@
+-- +goose Up
+CREATE TABLE server_metric_hourly (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	samples int NOT NULL,
+	cpu_cores_min bigint NULL,
+	cpu_cores_avg double precision NULL,
+	cpu_cores_max bigint NULL,
+	cpu_cores_p95 bigint NULL,
+	cpu_sql_pct_min bigint NULL,
+	cpu_sql_pct_avg double precision NULL,
+	cpu_sql_pct_max bigint NULL,
+	cpu_sql_pct_p95 bigint NULL,
+	cpu_other_pct_min bigint NULL,
+	cpu_other_pct_avg double precision NULL,
+	cpu_other_pct_max bigint NULL,
+	cpu_other_pct_p95 bigint NULL,
+	batches_per_second_min bigint NULL,
+	batches_per_second_avg double precision NULL,
+	batches_per_second_max bigint NULL,
+	batches_per_second_p95 bigint NULL,
+	page_life_expectancy_min bigint NULL,
+	page_life_expectancy_avg double precision NULL,
+	page_life_expectancy_max bigint NULL,
+	page_life_expectancy_p95 bigint NULL,
+	memory_used_mb_min bigint NULL,
+	memory_used_mb_avg double precision NULL,
+	memory_used_mb_max bigint NULL,
+	memory_used_mb_p95 bigint NULL,
+	disk_read_iops_min bigint NULL,
+	disk_read_iops_avg double precision NULL,
+	disk_read_iops_max bigint NULL,
+	disk_read_iops_p95 bigint NULL,
+	disk_read_kb_sec_min bigint NULL,
+	disk_read_kb_sec_avg double precision NULL,
+	disk_read_kb_sec_max bigint NULL,
+	disk_read_kb_sec_p95 bigint NULL,
+	disk_read_latency_ms_min bigint NULL,
+	disk_read_latency_ms_avg double precision NULL,
+	disk_read_latency_ms_max bigint NULL,
+	disk_read_latency_ms_p95 bigint NULL,
+	disk_write_iops_min bigint NULL,
+	disk_write_iops_avg double precision NULL,
+	disk_write_iops_max bigint NULL,
+	disk_write_iops_p95 bigint NULL,
+	disk_write_kb_sec_min bigint NULL,
+	disk_write_kb_sec_avg double precision NULL,
+	disk_write_kb_sec_max bigint NULL,
+	disk_write_kb_sec_p95 bigint NULL,
+	disk_write_latency_ms_min bigint NULL,
+	disk_write_latency_ms_avg double precision NULL,
+	disk_write_latency_ms_max bigint NULL,
+	disk_write_latency_ms_p95 bigint NULL,
+	PRIMARY KEY (server_key, bucket_ts)
+);
+
+CREATE TABLE server_metric_daily (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	samples int NOT NULL,
+	cpu_cores_min bigint NULL,
+	cpu_cores_avg double precision NULL,
+	cpu_cores_max bigint NULL,
+	cpu_cores_p95 bigint NULL,
+	cpu_sql_pct_min bigint NULL,
+	cpu_sql_pct_avg double precision NULL,
+	cpu_sql_pct_max bigint NULL,
+	cpu_sql_pct_p95 bigint NULL,
+	cpu_other_pct_min bigint NULL,
+	cpu_other_pct_avg double precision NULL,
+	cpu_other_pct_max bigint NULL,
+	cpu_other_pct_p95 bigint NULL,
+	batches_per_second_min bigint NULL,
+	batches_per_second_avg double precision NULL,
+	batches_per_second_max bigint NULL,
+	batches_per_second_p95 bigint NULL,
+	page_life_expectancy_min bigint NULL,
+	page_life_expectancy_avg double precision NULL,
+	page_life_expectancy_max bigint NULL,
+	page_life_expectancy_p95 bigint NULL,
+	memory_used_mb_min bigint NULL,
+	memory_used_mb_avg double precision NULL,
+	memory_used_mb_max bigint NULL,
+	memory_used_mb_p95 bigint NULL,
+	disk_read_iops_min bigint NULL,
+	disk_read_iops_avg double precision NULL,
+	disk_read_iops_max bigint NULL,
+	disk_read_iops_p95 bigint NULL,
+	disk_read_kb_sec_min bigint NULL,
+	disk_read_kb_sec_avg double precision NULL,
+	disk_read_kb_sec_max bigint NULL,
+	disk_read_kb_sec_p95 bigint NULL,
+	disk_read_latency_ms_min bigint NULL,
+	disk_read_latency_ms_avg double precision NULL,
+	disk_read_latency_ms_max bigint NULL,
+	disk_read_latency_ms_p95 bigint NULL,
+	disk_write_iops_min bigint NULL,
+	disk_write_iops_avg double precision NULL,
+	disk_write_iops_max bigint NULL,
+	disk_write_iops_p95 bigint NULL,
+	disk_write_kb_sec_min bigint NULL,
+	disk_write_kb_sec_avg double precision NULL,
+	disk_write_kb_sec_max bigint NULL,
+	disk_write_kb_sec_p95 bigint NULL,
+	disk_write_latency_ms_min bigint NULL,
+	disk_write_latency_ms_avg double precision NULL,
+	disk_write_latency_ms_max bigint NULL,
+	disk_write_latency_ms_p95 bigint NULL,
+	PRIMARY KEY (server_key, bucket_ts)
+);
+
+-- retention deletes by ts
+CREATE INDEX ix_server_metric_ts ON server_metric (ts);
+CREATE INDEX ix_request_wait_ts ON request_wait (ts);
+CREATE INDEX ix_server_wait_ts ON server_wait (ts);
+
+-- +goose Down
+DROP INDEX ix_server_wait_ts;
+DROP INDEX ix_request_wait_ts;
+DROP INDEX ix_server_metric_ts;
+DROP TABLE server_metric_daily;
+DROP TABLE server_metric_hourly;
@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE database_size (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	database_name varchar(128) NOT NULL,
+	data_size_kb bigint NOT NULL,
+	log_size_kb bigint NOT NULL,
+	PRIMARY KEY (server_key, database_name, ts)
+);
+
+CREATE TABLE database_backup (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	database_name varchar(128) NOT NULL,
+	backup_type varchar(10) NOT NULL,
+	backup_device varchar(260) NOT NULL,
+	backup_instance varchar(128) NOT NULL,
+	PRIMARY KEY (server_key, database_name, backup_type, ts)
+);
+
+CREATE TABLE job_history (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	instance_id bigint NOT NULL,
+	step_id int NOT NULL,
+	run_status int NOT NULL,
+	duration_sec bigint NOT NULL,
+	retries_attempted int NOT NULL,
+	job_id varchar(36) NOT NULL,
+	job_name varchar(128) NOT NULL,
+	run_status_desc varchar(20) NOT NULL,
+	message text NOT NULL,
+	PRIMARY KEY (server_key, instance_id)
+);
+
+-- +goose Down
+DROP TABLE job_history;
+DROP TABLE database_backup;
+DROP TABLE database_size;
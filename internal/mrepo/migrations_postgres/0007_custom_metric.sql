@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE custom_metric (
+	ts timestamptz(0) NOT NULL,
+	ts_date date NOT NULL,
+	ts_time time(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	metric_name varchar(64) NOT NULL,
+	value bigint NOT NULL,
+	value_per_second bigint NOT NULL,
+	unit varchar(128) NOT NULL,
+	PRIMARY KEY (server_key, metric_name, ts)
+);
+
+-- +goose Down
+DROP TABLE custom_metric;
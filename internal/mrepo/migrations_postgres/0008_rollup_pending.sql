@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE rollup_pending (
+	bucket_ts timestamptz(0) NOT NULL,
+	PRIMARY KEY (bucket_ts)
+);
+
+-- +goose Down
+DROP TABLE rollup_pending;
@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE request_wait_hourly (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	wait_type varchar(128) NOT NULL,
+	wait_time_sec bigint NOT NULL,
+	PRIMARY KEY (server_key, bucket_ts, wait_type)
+);
+
+CREATE TABLE request_wait_daily (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	wait_type varchar(128) NOT NULL,
+	wait_time_sec bigint NOT NULL,
+	PRIMARY KEY (server_key, bucket_ts, wait_type)
+);
+
+CREATE TABLE server_wait_hourly (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	wait_type varchar(128) NOT NULL,
+	wait_time_sec bigint NOT NULL,
+	PRIMARY KEY (server_key, bucket_ts, wait_type)
+);
+
+CREATE TABLE server_wait_daily (
+	bucket_ts timestamptz(0) NOT NULL,
+	server_key varchar(128) NOT NULL,
+	server_name varchar(128) NOT NULL,
+	wait_type varchar(128) NOT NULL,
+	wait_time_sec bigint NOT NULL,
+	PRIMARY KEY (server_key, bucket_ts, wait_type)
+);
+
+-- +goose Down
+DROP TABLE server_wait_daily;
+DROP TABLE server_wait_hourly;
+DROP TABLE request_wait_daily;
+DROP TABLE request_wait_hourly;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE server_metric (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	server_start DATETIME NULL,
+	cpu_cores INTEGER NULL,
+	cpu_sql_pct INTEGER NULL,
+	cpu_other_pct INTEGER NULL,
+	batches_per_second INTEGER NULL,
+	page_life_expectancy INTEGER NULL,
+	memory_used_mb INTEGER NULL,
+	disk_read_iops INTEGER NULL,
+	disk_read_kb_sec INTEGER NULL,
+	disk_read_latency_ms INTEGER NULL,
+	disk_write_iops INTEGER NULL,
+	disk_write_kb_sec INTEGER NULL,
+	disk_write_latency_ms INTEGER NULL
+);
+
+CREATE INDEX ix_server_metric ON server_metric (server_key, ts);
+
+-- +goose Down
+DROP TABLE server_metric;
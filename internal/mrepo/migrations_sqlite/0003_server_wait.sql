@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE server_wait (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	server_start DATETIME NULL,
+	wait_type TEXT NOT NULL,
+	wait_time_sec INTEGER NOT NULL
+);
+
+CREATE INDEX ix_server_wait ON server_wait (server_key, ts);
+
+-- +goose Down
+DROP TABLE server_wait;
@@ -0,0 +1,124 @@
+-- +goose Up
+CREATE TABLE server_metric_hourly (
+	bucket_ts DATETIME NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	cpu_cores_min INTEGER NULL,
+	cpu_cores_avg REAL NULL,
+	cpu_cores_max INTEGER NULL,
+	cpu_cores_p95 INTEGER NULL,
+	cpu_sql_pct_min INTEGER NULL,
+	cpu_sql_pct_avg REAL NULL,
+	cpu_sql_pct_max INTEGER NULL,
+	cpu_sql_pct_p95 INTEGER NULL,
+	cpu_other_pct_min INTEGER NULL,
+	cpu_other_pct_avg REAL NULL,
+	cpu_other_pct_max INTEGER NULL,
+	cpu_other_pct_p95 INTEGER NULL,
+	batches_per_second_min INTEGER NULL,
+	batches_per_second_avg REAL NULL,
+	batches_per_second_max INTEGER NULL,
+	batches_per_second_p95 INTEGER NULL,
+	page_life_expectancy_min INTEGER NULL,
+	page_life_expectancy_avg REAL NULL,
+	page_life_expectancy_max INTEGER NULL,
+	page_life_expectancy_p95 INTEGER NULL,
+	memory_used_mb_min INTEGER NULL,
+	memory_used_mb_avg REAL NULL,
+	memory_used_mb_max INTEGER NULL,
+	memory_used_mb_p95 INTEGER NULL,
+	disk_read_iops_min INTEGER NULL,
+	disk_read_iops_avg REAL NULL,
+	disk_read_iops_max INTEGER NULL,
+	disk_read_iops_p95 INTEGER NULL,
+	disk_read_kb_sec_min INTEGER NULL,
+	disk_read_kb_sec_avg REAL NULL,
+	disk_read_kb_sec_max INTEGER NULL,
+	disk_read_kb_sec_p95 INTEGER NULL,
+	disk_read_latency_ms_min INTEGER NULL,
+	disk_read_latency_ms_avg REAL NULL,
+	disk_read_latency_ms_max INTEGER NULL,
+	disk_read_latency_ms_p95 INTEGER NULL,
+	disk_write_iops_min INTEGER NULL,
+	disk_write_iops_avg REAL NULL,
+	disk_write_iops_max INTEGER NULL,
+	disk_write_iops_p95 INTEGER NULL,
+	disk_write_kb_sec_min INTEGER NULL,
+	disk_write_kb_sec_avg REAL NULL,
+	disk_write_kb_sec_max INTEGER NULL,
+	disk_write_kb_sec_p95 INTEGER NULL,
+	disk_write_latency_ms_min INTEGER NULL,
+	disk_write_latency_ms_avg REAL NULL,
+	disk_write_latency_ms_max INTEGER NULL,
+	disk_write_latency_ms_p95 INTEGER NULL,
+	PRIMARY KEY (server_key, bucket_ts)
+);
+
+CREATE TABLE server_metric_daily (
+	bucket_ts DATETIME NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	cpu_cores_min INTEGER NULL,
+	cpu_cores_avg REAL NULL,
+	cpu_cores_max INTEGER NULL,
+	cpu_cores_p95 INTEGER NULL,
+	cpu_sql_pct_min INTEGER NULL,
+	cpu_sql_pct_avg REAL NULL,
+	cpu_sql_pct_max INTEGER NULL,
+	cpu_sql_pct_p95 INTEGER NULL,
+	cpu_other_pct_min INTEGER NULL,
+	cpu_other_pct_avg REAL NULL,
+	cpu_other_pct_max INTEGER NULL,
+	cpu_other_pct_p95 INTEGER NULL,
+	batches_per_second_min INTEGER NULL,
+	batches_per_second_avg REAL NULL,
+	batches_per_second_max INTEGER NULL,
+	batches_per_second_p95 INTEGER NULL,
+	page_life_expectancy_min INTEGER NULL,
+	page_life_expectancy_avg REAL NULL,
+	page_life_expectancy_max INTEGER NULL,
+	page_life_expectancy_p95 INTEGER NULL,
+	memory_used_mb_min INTEGER NULL,
+	memory_used_mb_avg REAL NULL,
+	memory_used_mb_max INTEGER NULL,
+	memory_used_mb_p95 INTEGER NULL,
+	disk_read_iops_min INTEGER NULL,
+	disk_read_iops_avg REAL NULL,
+	disk_read_iops_max INTEGER NULL,
+	disk_read_iops_p95 INTEGER NULL,
+	disk_read_kb_sec_min INTEGER NULL,
+	disk_read_kb_sec_avg REAL NULL,
+	disk_read_kb_sec_max INTEGER NULL,
+	disk_read_kb_sec_p95 INTEGER NULL,
+	disk_read_latency_ms_min INTEGER NULL,
+	disk_read_latency_ms_avg REAL NULL,
+	disk_read_latency_ms_max INTEGER NULL,
+	disk_read_latency_ms_p95 INTEGER NULL,
+	disk_write_iops_min INTEGER NULL,
+	disk_write_iops_avg REAL NULL,
+	disk_write_iops_max INTEGER NULL,
+	disk_write_iops_p95 INTEGER NULL,
+	disk_write_kb_sec_min INTEGER NULL,
+	disk_write_kb_sec_avg REAL NULL,
+	disk_write_kb_sec_max INTEGER NULL,
+	disk_write_kb_sec_p95 INTEGER NULL,
+	disk_write_latency_ms_min INTEGER NULL,
+	disk_write_latency_ms_avg REAL NULL,
+	disk_write_latency_ms_max INTEGER NULL,
+	disk_write_latency_ms_p95 INTEGER NULL,
+	PRIMARY KEY (server_key, bucket_ts)
+);
+
+-- retention deletes by ts
+CREATE INDEX ix_server_metric_ts ON server_metric (ts);
+CREATE INDEX ix_request_wait_ts ON request_wait (ts);
+CREATE INDEX ix_server_wait_ts ON server_wait (ts);
+
+-- +goose Down
+DROP INDEX ix_server_wait_ts;
+DROP INDEX ix_request_wait_ts;
+DROP INDEX ix_server_metric_ts;
+DROP TABLE server_metric_daily;
+DROP TABLE server_metric_hourly;
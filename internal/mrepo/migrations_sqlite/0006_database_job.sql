@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE database_size (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	database_name TEXT NOT NULL,
+	data_size_kb INTEGER NOT NULL,
+	log_size_kb INTEGER NOT NULL,
+	PRIMARY KEY (server_key, database_name, ts)
+);
+
+CREATE TABLE database_backup (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	database_name TEXT NOT NULL,
+	backup_type TEXT NOT NULL,
+	backup_device TEXT NOT NULL,
+	backup_instance TEXT NOT NULL,
+	PRIMARY KEY (server_key, database_name, backup_type, ts)
+);
+
+CREATE TABLE job_history (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	instance_id INTEGER NOT NULL,
+	step_id INTEGER NOT NULL,
+	run_status INTEGER NOT NULL,
+	duration_sec INTEGER NOT NULL,
+	retries_attempted INTEGER NOT NULL,
+	job_id TEXT NOT NULL,
+	job_name TEXT NOT NULL,
+	run_status_desc TEXT NOT NULL,
+	message TEXT NOT NULL,
+	PRIMARY KEY (server_key, instance_id)
+);
+
+-- +goose Down
+DROP TABLE job_history;
+DROP TABLE database_backup;
+DROP TABLE database_size;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE custom_metric (
+	ts DATETIME NOT NULL,
+	ts_date TEXT NOT NULL,
+	ts_time TEXT NOT NULL,
+	server_key TEXT NOT NULL,
+	server_name TEXT NOT NULL,
+	metric_name TEXT NOT NULL,
+	value INTEGER NOT NULL,
+	value_per_second INTEGER NOT NULL,
+	unit TEXT NOT NULL,
+	PRIMARY KEY (server_key, metric_name, ts)
+);
+
+-- +goose Down
+DROP TABLE custom_metric;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE rollup_pending (
+	bucket_ts DATETIME NOT NULL,
+	PRIMARY KEY (bucket_ts)
+);
+
+-- +goose Down
+DROP TABLE rollup_pending;
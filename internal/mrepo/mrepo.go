@@ -1,4 +1,5 @@
-// Package mrepo provides a repository for metrics and waits in SQL Server.
+// Package mrepo provides a repository for metrics and waits.
+// It can be stored in SQL Server, PostgreSQL, or a SQLite file.
 package mrepo
 
 import (
@@ -6,7 +7,7 @@ import (
 	"time"
 )
 
-//go:embed migrations/*.sql
+//go:embed migrations/*.sql migrations_postgres/*.sql migrations_sqlite/*.sql
 var fs embed.FS
 
 const timeout = 3 * time.Second
@@ -1,12 +1,13 @@
 package mrepo
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -17,65 +18,143 @@ import (
 )
 
 type Repository struct {
-	pool   *sqlx.DB
-	applog *appringlog.RingLog
+	pool    *sqlx.DB
+	backend Backend
+	cfg     Config
+	applog  *appringlog.RingLog
 
 	mu       sync.RWMutex
 	err      error
 	occurred time.Time
-}
 
-// TODO: If setup fails, launch a GO routine that attempts to connect every minute.
-// or attempts to connect and then update the database
-// this would only be needed for database upgrades
-// TODO: Only write metrics for certain tags
+	// writes are queued and written by run
+	queue     chan record
+	flushc    chan chan struct{}
+	spool     *spool
+	connected atomic.Bool
+	retry     time.Duration
+	linger    time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	// maintain builds the rollups and purges old rows
+	maintainEvery time.Duration
+	maintainDelay time.Duration
+	maintDone     chan struct{}
+	// rolled is when the hourly rollup is built through in Unix
+	// nanoseconds.  Metric rows before it mark their hour as pending.
+	rolled atomic.Int64
+
+	// seen holds the last database size, backup, and job written for
+	// each server so each poll only queues what changed
+	seenMu  sync.Mutex
+	seen    map[string]time.Time
+	lastJob map[string]int64
 
-// NewRepository returns a new Repository
-func NewRepository(fqdn, database, user, pwd string, log goose.Logger, rl *appringlog.RingLog) (*Repository, error) {
+	written  atomic.Int64
+	spooled  atomic.Int64
+	replayed atomic.Int64
+	dropped  atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewRepository returns a new Repository using the backend for cfg.Driver.
+// It runs that backend's migrations and starts the writer.  If the
+// migrations fail, the error is returned but the writer keeps trying
+// to connect and spools rows until it does.
+func NewRepository(cfg Config, log goose.Logger, rl *appringlog.RingLog) (*Repository, error) {
 	r := Repository{
 		applog:   rl,
 		mu:       sync.RWMutex{},
 		occurred: time.Time{},
-	}
+		cfg:      cfg,
+		retry:    retryEvery,
+		linger:   lingerFor,
 
-	if fqdn == "" || database == "" {
-		return &r, fmt.Errorf("fqdn and database must be provided")
+		maintainEvery: maintainEvery,
+		maintainDelay: maintainDelay,
+
+		seen:    make(map[string]time.Time),
+		lastJob: make(map[string]int64),
 	}
 
-	// connect to the database and set the package level connection
-	connstr, err := makeconnstr(fqdn, database, user, pwd)
+	backend, err := NewBackend(cfg.Driver)
 	if err != nil {
-		return &r, fmt.Errorf("connstr: %w", err)
+		r.occurred = time.Now()
+		r.err = err
+		return &r, err
 	}
+	r.backend = backend
 
-	db, err := sql.Open("sqlserver", connstr.String())
-	// save the connection pool as a sqlx pool
-	// even if we get an error, we will try
+	// save the connection pool even if we get an error, we will try
 	// this works because Open doesn't actually try to connect to the database server
-	r.pool = sqlx.NewDb(db, "sqlserver")
+	pool, err := backend.Open(cfg)
 	if err != nil {
 		r.occurred = time.Now()
 		r.err = err
-		return &r, err
+		return &r, fmt.Errorf("%s: open: %w", backend.Name(), err)
 	}
+	r.pool = pool
 
 	goose.SetLogger(log)
 	goose.SetBaseFS(fs)
-	err = goose.SetDialect(string(goose.DialectMSSQL))
+	err = goose.SetDialect(string(backend.Dialect()))
 	if err != nil {
 		r.occurred = time.Now()
 		r.err = err
 		return &r, fmt.Errorf("goose.setdialect: %w", err)
 	}
 	goose.SetTableName("isitsql_schema_version")
-	err = goose.Up(db, "migrations")
+
+	if cfg.SpoolDir != "" {
+		sp, err := newSpool(cfg.SpoolDir, int64(cfg.SpoolMaxMB)*1024*1024)
+		if err != nil {
+			r.occurred = time.Now()
+			r.err = err
+			return &r, fmt.Errorf("spool: %w", err)
+		}
+		r.spool = sp
+	}
+
+	err = r.migrate()
 	if err != nil {
 		r.occurred = time.Now()
 		r.err = err
-		return &r, fmt.Errorf("goose.up: %w", err)
+	} else {
+		r.connected.Store(true)
 	}
 
-	return &r, nil
+	r.queue = make(chan record, queueSize)
+	r.flushc = make(chan chan struct{})
+	r.done = make(chan struct{})
+	r.maintDone = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.run(ctx)
+	go r.maintain(ctx)
+	return &r, err
+}
+
+// Driver returns the name of the backend
+func (r *Repository) Driver() string {
+	if r == nil || r.backend == nil {
+		return ""
+	}
+	return r.backend.Name()
+}
+
+// Close stops the writer and closes the connection pool.
+// Rows that can't be written are left in the spool.
+func (r *Repository) Close() error {
+	if r == nil || r.pool == nil {
+		return nil
+	}
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+		<-r.maintDone
+	}
+	return r.pool.Close()
 }
 
 // RepositoryError returns the time of the error and the last error
@@ -0,0 +1,376 @@
+package mrepo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/bucket"
+)
+
+const (
+	spoolPrefix   = "repository"
+	rejectPrefix  = "rejected"
+	spoolFileSize = 8 * 1024 * 1024
+	spoolFileAge  = 10 * time.Minute
+)
+
+// spool holds records on disk while the repository is down.
+// It uses the bucket format: one ServerEvent per line.
+// Files are replayed oldest first and removed once they are written.
+type spool struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	fileSize int64 // roll to a new file after this many bytes
+	file     *os.File
+	fileName string
+	opened   time.Time
+	files    []string // closed files waiting to be replayed
+	sizes    map[string]int64
+	dropped  int64
+	// replaying is the file being replayed.  It isn't trimmed.
+	replaying string
+}
+
+// newSpool returns a spool.  Files left from a previous run are replayed.
+func newSpool(dir string, maxBytes int64) (*spool, error) {
+	s := &spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		fileSize: spoolFileSize,
+		sizes:    make(map[string]int64),
+	}
+	// keep a few files under the limit so trimming doesn't drop everything
+	if maxBytes > 0 && maxBytes/4 < s.fileSize {
+		s.fileSize = maxBytes / 4
+	}
+	err := os.MkdirAll(dir, 0760)
+	if err != nil {
+		return s, errors.Wrap(err, "os.mkdirall")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, spoolPrefix+"_*.ndjson"))
+	if err != nil {
+		return s, errors.Wrap(err, "filepath.glob")
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		s.files = append(s.files, f)
+		s.sizes[f] = fi.Size()
+	}
+	return s, nil
+}
+
+// write appends records to the current file
+func (s *spool) write(recs []record) error {
+	if s == nil || len(recs) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil && (s.sizes[s.fileName] >= s.fileSize || time.Since(s.opened) > spoolFileAge) {
+		s.closeFile()
+	}
+	if s.file == nil {
+		// nanoseconds keep the names unique and sortable
+		name := filepath.Join(s.dir, fmt.Sprintf("%s_%s.ndjson", spoolPrefix, time.Now().UTC().Format("20060102_150405.000000000")))
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+		if err != nil {
+			return errors.Wrap(err, "os.openfile")
+		}
+		s.file = f
+		s.fileName = name
+		s.opened = time.Now()
+	}
+	w := bufio.NewWriter(s.file)
+	var n int64
+	for _, rec := range recs {
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			return errors.Wrap(err, "json.marshal")
+		}
+		bb, err := json.Marshal(bucket.ServerEvent{MapKey: rec.Key, Payload: payload})
+		if err != nil {
+			return errors.Wrap(err, "json.marshal")
+		}
+		bb = append(bb, '\n')
+		_, err = w.Write(bb)
+		if err != nil {
+			return errors.Wrap(err, "write")
+		}
+		n += int64(len(bb))
+	}
+	err := w.Flush()
+	if err != nil {
+		return errors.Wrap(err, "flush")
+	}
+	s.sizes[s.fileName] += n
+	s.trim()
+	return nil
+}
+
+// closeFile moves the current file to the replay list.  The caller must hold the lock.
+func (s *spool) closeFile() {
+	if s.file == nil {
+		return
+	}
+	_ = s.file.Close()
+	s.files = append(s.files, s.fileName)
+	s.file = nil
+	s.fileName = ""
+}
+
+// trim removes the oldest closed files while the spool is over the limit.
+// The caller must hold the lock.
+func (s *spool) trim() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	for s.bytes() > s.maxBytes {
+		i := 0
+		if len(s.files) > 0 && s.files[0] == s.replaying {
+			i = 1
+		}
+		if i >= len(s.files) {
+			return
+		}
+		oldest := s.files[i]
+		s.dropped += countLines(oldest)
+		_ = os.Remove(oldest)
+		delete(s.sizes, oldest)
+		s.files = append(s.files[:i], s.files[i+1:]...)
+	}
+}
+
+func (s *spool) bytes() int64 {
+	var total int64
+	for _, n := range s.sizes {
+		total += n
+	}
+	return total
+}
+
+// pending returns true if there are records waiting to be replayed
+func (s *spool) pending() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file != nil || len(s.files) > 0
+}
+
+// stats returns the number of files and bytes in the spool
+// and the number of records dropped to stay under the limit
+func (s *spool) stats() (int, int64, int64) {
+	if s == nil {
+		return 0, 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.files)
+	if s.file != nil {
+		n++
+	}
+	return n, s.bytes(), s.dropped
+}
+
+// replay sends each file to fn in batches, oldest first.  fn returns
+// how many records from the start of the batch it handled.  A file is
+// removed once all its records are handled.  If fn fails, the records
+// that weren't handled are kept for the next replay.  The lock isn't
+// held while fn runs so rows can still be spooled.
+func (s *spool) replay(batchSize int, fn func([]record) (int, error)) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	s.closeFile()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.replaying = ""
+		s.mu.Unlock()
+	}()
+
+	var count int
+	for {
+		s.mu.Lock()
+		if len(s.files) == 0 {
+			s.mu.Unlock()
+			return count, nil
+		}
+		name := s.files[0]
+		s.replaying = name
+		s.mu.Unlock()
+
+		recs, err := readSpoolFile(name)
+		if err != nil {
+			return count, err
+		}
+		for len(recs) > 0 {
+			n, err := fn(recs[:min(batchSize, len(recs))])
+			count += n
+			recs = recs[n:]
+			if err != nil {
+				// keep what is left
+				werr := rewriteSpoolFile(name, recs)
+				if werr != nil {
+					return count, errors.Wrap(werr, "rewrite")
+				}
+				fi, serr := os.Stat(name)
+				if serr == nil {
+					s.mu.Lock()
+					s.sizes[name] = fi.Size()
+					s.mu.Unlock()
+				}
+				return count, err
+			}
+		}
+		err = os.Remove(name)
+		if err != nil {
+			return count, errors.Wrap(err, "os.remove")
+		}
+		s.mu.Lock()
+		delete(s.sizes, name)
+		for i, f := range s.files {
+			if f == name {
+				s.files = append(s.files[:i], s.files[i+1:]...)
+				break
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// rejected is a record the repository wouldn't take and why
+type rejected struct {
+	Error  string `json:"error"`
+	Record record `json:"record"`
+}
+
+// reject writes a record the repository wouldn't take to a file that
+// isn't replayed.  There is one file per day and each stops growing at
+// the size of a spool file.
+func (s *spool) reject(rec record, reason error) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := filepath.Join(s.dir, fmt.Sprintf("%s_%s.ndjson", rejectPrefix, time.Now().UTC().Format("20060102")))
+	if fi, err := os.Stat(name); err == nil && fi.Size() >= s.fileSize {
+		return nil
+	}
+	bb, err := json.Marshal(rejected{Error: reason.Error(), Record: rec})
+	if err != nil {
+		return errors.Wrap(err, "json.marshal")
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		return errors.Wrap(err, "os.openfile")
+	}
+	_, err = f.Write(append(bb, '\n'))
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "write")
+	}
+	return errors.Wrap(f.Close(), "close")
+}
+
+// close the current file.  It will be replayed on the next start.
+func (s *spool) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeFile()
+}
+
+// readSpoolFile reads the records in a file.  Lines that can't be
+// read are skipped.
+func readSpoolFile(name string) ([]record, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.open")
+	}
+	defer f.Close()
+	recs := make([]record, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var se bucket.ServerEvent
+		if json.Unmarshal([]byte(line), &se) != nil {
+			continue
+		}
+		var rec record
+		if json.Unmarshal(se.Payload, &rec) != nil {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, errors.Wrap(scanner.Err(), "scanner")
+}
+
+// rewriteSpoolFile replaces a file with the records
+func rewriteSpoolFile(name string, recs []record) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, rec := range recs {
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		bb, err := json.Marshal(bucket.ServerEvent{MapKey: rec.Key, Payload: payload})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		_, _ = w.Write(append(bb, '\n'))
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// countLines is used to count the records we drop
+func countLines(name string) int64 {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	var n int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
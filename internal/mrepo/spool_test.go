@@ -0,0 +1,137 @@
+package mrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spoolRecs(from, to int) []record {
+	recs := make([]record, 0, to-from)
+	for i := from; i < to; i++ {
+		recs = append(recs, record{Table: metricTable, TS: time.Unix(int64(i), 0), Key: "srv1", Values: map[string]int64{"cpu_sql_pct": int64(i)}})
+	}
+	return recs
+}
+
+func TestSpoolReplay(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	s, err := newSpool(dir, 0)
+	require.NoError(t, err)
+	assert.False(s.pending())
+
+	require.NoError(t, s.write(spoolRecs(0, 5)))
+	s.close()
+	require.NoError(t, s.write(spoolRecs(5, 10)))
+	assert.True(s.pending())
+	files, bytes, _ := s.stats()
+	assert.Equal(2, files)
+	assert.Greater(bytes, int64(0))
+
+	// fail part way through the first file
+	var got []int64
+	calls := 0
+	n, err := s.replay(2, func(recs []record) (int, error) {
+		calls++
+		if calls == 2 {
+			return 0, errors.New("down")
+		}
+		for _, rec := range recs {
+			got = append(got, rec.Values["cpu_sql_pct"])
+		}
+		return len(recs), nil
+	})
+	assert.Error(err)
+	assert.Equal(2, n)
+	assert.True(s.pending())
+
+	// a new spool sees the files and replays in order
+	s, err = newSpool(dir, 0)
+	require.NoError(t, err)
+	n, err = s.replay(2, func(recs []record) (int, error) {
+		for _, rec := range recs {
+			got = append(got, rec.Values["cpu_sql_pct"])
+			assert.Equal("srv1", rec.Key)
+		}
+		return len(recs), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(8, n)
+	assert.Equal([]int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, got)
+	assert.False(s.pending())
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(left)
+}
+
+func TestSpoolLimit(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	s, err := newSpool(dir, 4096)
+	require.NoError(t, err)
+	assert.Equal(int64(1024), s.fileSize)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.write(spoolRecs(i*10, i*10+10)))
+	}
+	files, bytes, dropped := s.stats()
+	assert.LessOrEqual(bytes, int64(4096+s.fileSize*2))
+	assert.Greater(dropped, int64(0))
+
+	var size int64
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(files, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		require.NoError(t, err)
+		size += fi.Size()
+	}
+	assert.Equal(bytes, size)
+
+	// the newest rows are kept
+	var last int64
+	_, err = s.replay(100, func(recs []record) (int, error) {
+		last = recs[len(recs)-1].Values["cpu_sql_pct"]
+		return len(recs), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(int64(999), last)
+}
+
+func TestSpoolWriteDuringReplay(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	s, err := newSpool(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.write(spoolRecs(0, 4)))
+
+	// the inserts run without the lock so new rows can be spooled
+	n, err := s.replay(2, func(recs []record) (int, error) {
+		require.NoError(t, s.write(spoolRecs(10, 11)))
+		return len(recs), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(4, n)
+	assert.True(s.pending())
+
+	var got []int64
+	n, err = s.replay(10, func(recs []record) (int, error) {
+		for _, rec := range recs {
+			got = append(got, rec.Values["cpu_sql_pct"])
+		}
+		return len(recs), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(2, n)
+	assert.Equal([]int64{10, 10}, got)
+
+	require.NoError(t, s.reject(spoolRecs(20, 21)[0], errors.New("bad row")))
+	assert.False(s.pending())
+	left, _ := filepath.Glob(filepath.Join(dir, rejectPrefix+"_*"))
+	assert.Len(left, 1)
+}
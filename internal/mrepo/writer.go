@@ -0,0 +1,516 @@
+package mrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	queueSize    = 10000
+	batchRows    = 500
+	maxParams    = 2000 // SQL Server allows 2100 parameters
+	writeTimeout = 30 * time.Second
+	retryEvery   = 30 * time.Second
+	lingerFor    = time.Second
+)
+
+const metricTable = "server_metric"
+
+// customMetricTable has the metrics defined in the HCL files
+const customMetricTable = "custom_metric"
+
+// metricColumns are the values that can be written to server_metric
+var metricColumns = []string{
+	"cpu_cores",
+	"cpu_sql_pct",
+	"cpu_other_pct",
+	"batches_per_second",
+	"page_life_expectancy",
+	"memory_used_mb",
+	"disk_read_iops",
+	"disk_read_kb_sec",
+	"disk_read_latency_ms",
+	"disk_write_iops",
+	"disk_write_kb_sec",
+	"disk_write_latency_ms",
+}
+
+var baseColumns = []string{"ts", "ts_date", "ts_time", "server_key", "server_name", "server_start"}
+
+// record is one row for the repository.  This is what is queued and spooled.
+// Tables in keyedTables use Values and Strings for their columns.
+type record struct {
+	Table   string            `json:"table"`
+	TS      time.Time         `json:"ts"`
+	Key     string            `json:"server_key"`
+	Server  string            `json:"server_name"`
+	Start   time.Time         `json:"server_start"`
+	Values  map[string]int64  `json:"values,omitempty"`
+	Strings map[string]string `json:"strings,omitempty"`
+	Wait    string            `json:"wait_type,omitempty"`
+	WaitSec int64             `json:"wait_time_sec,omitempty"`
+}
+
+// keyedTable is a table where rows that match on the keys are skipped.
+// The columns follow ts, ts_date, ts_time, server_key, and server_name.
+type keyedTable struct {
+	values  []string // from record.Values
+	strings []string // from record.Strings
+	keys    []string
+}
+
+var keyedTables = map[string]keyedTable{
+	"database_size": {
+		values:  []string{"data_size_kb", "log_size_kb"},
+		strings: []string{"database_name"},
+		keys:    []string{"server_key", "database_name", "ts"},
+	},
+	"database_backup": {
+		strings: []string{"database_name", "backup_type", "backup_device", "backup_instance"},
+		keys:    []string{"server_key", "database_name", "backup_type", "ts"},
+	},
+	"job_history": {
+		values:  []string{"instance_id", "step_id", "run_status", "duration_sec", "retries_attempted"},
+		strings: []string{"job_id", "job_name", "run_status_desc", "message"},
+		keys:    []string{"server_key", "instance_id"},
+	},
+	customMetricTable: {
+		values:  []string{"value", "value_per_second"},
+		strings: []string{"metric_name", "unit"},
+		keys:    []string{"server_key", "metric_name", "ts"},
+	},
+}
+
+func (kt keyedTable) columns() []string {
+	columns := []string{"ts", "ts_date", "ts_time", "server_key", "server_name"}
+	columns = append(columns, kt.values...)
+	return append(columns, kt.strings...)
+}
+
+// Stats describes the write queue and the spool
+type Stats struct {
+	Connected  bool
+	Queue      int
+	SpoolFiles int
+	SpoolBytes int64
+	Written    int64
+	Spooled    int64
+	Replayed   int64
+	Dropped    int64
+	Rejected   int64
+}
+
+// Stats returns the queue and spool counters
+func (r *Repository) Stats() Stats {
+	if r == nil {
+		return Stats{}
+	}
+	files, bytes, dropped := r.spool.stats()
+	return Stats{
+		Connected:  r.connected.Load(),
+		Queue:      len(r.queue),
+		SpoolFiles: files,
+		SpoolBytes: bytes,
+		Written:    r.written.Load(),
+		Spooled:    r.spooled.Load(),
+		Replayed:   r.replayed.Load(),
+		Dropped:    r.dropped.Load() + dropped,
+		Rejected:   r.rejected.Load(),
+	}
+}
+
+// Flush waits until everything in the queue is written or spooled
+func (r *Repository) Flush() {
+	if r == nil || r.flushc == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case r.flushc <- done:
+		<-done
+	case <-r.done:
+	}
+}
+
+// enqueue adds records to the queue.  If the queue is full they go to the spool.
+func (r *Repository) enqueue(recs ...record) {
+	for _, rec := range recs {
+		select {
+		case r.queue <- rec:
+		default:
+			r.spoolRecords([]record{rec})
+		}
+	}
+}
+
+// run writes the queue in batches.  While the repository is down, rows
+// are spooled.  Every retry it reconnects, runs the migrations, and
+// replays the spool in order.
+func (r *Repository) run(ctx context.Context) {
+	defer failure.HandlePanic()
+	defer close(r.done)
+	ticker := time.NewTicker(r.retry)
+	defer ticker.Stop()
+
+	r.reconnect()
+	for {
+		select {
+		case <-ctx.Done():
+			r.drain()
+			r.spool.close()
+			return
+		case rec := <-r.queue:
+			r.write(r.collect(rec, r.linger))
+		case done := <-r.flushc:
+			r.drain()
+			close(done)
+		case <-ticker.C:
+			r.reconnect()
+		}
+	}
+}
+
+// collect builds a batch starting with first.  It waits up to linger for more rows.
+func (r *Repository) collect(first record, linger time.Duration) []record {
+	batch := []record{first}
+	var wait <-chan time.Time
+	if linger > 0 {
+		timer := time.NewTimer(linger)
+		defer timer.Stop()
+		wait = timer.C
+	}
+	for len(batch) < batchRows {
+		if wait == nil {
+			select {
+			case rec := <-r.queue:
+				batch = append(batch, rec)
+			default:
+				return batch
+			}
+			continue
+		}
+		select {
+		case rec := <-r.queue:
+			batch = append(batch, rec)
+		case <-wait:
+			return batch
+		}
+	}
+	return batch
+}
+
+// drain writes whatever is in the queue without waiting
+func (r *Repository) drain() {
+	for {
+		select {
+		case rec := <-r.queue:
+			r.write(r.collect(rec, 0))
+		default:
+			return
+		}
+	}
+}
+
+// write inserts a batch.  Spooled rows go first so the order is kept.
+func (r *Repository) write(batch []record) {
+	if r.connected.Load() && r.spool.pending() {
+		r.reconnect()
+	}
+	if !r.connected.Load() || r.spool.pending() {
+		r.spoolRecords(batch)
+		return
+	}
+	n, err := r.insertRows(batch)
+	r.handleError(err)
+	if err != nil {
+		r.connected.Store(false)
+		r.spoolRecords(batch[n:])
+	}
+}
+
+// insertRows writes the records and returns how many from the start of
+// recs were handled.  Only connection errors are returned.  If the
+// repository is up but rejects the batch, each row is tried alone and
+// the ones it still rejects are set aside so they aren't retried.
+func (r *Repository) insertRows(recs []record) (int, error) {
+	err := r.insert(recs)
+	if err == nil {
+		r.written.Add(int64(len(recs)))
+		return len(recs), nil
+	}
+	if !r.reachable(err) {
+		return 0, err
+	}
+	for i, rec := range recs {
+		err = r.insert([]record{rec})
+		if err == nil {
+			r.written.Add(1)
+			continue
+		}
+		if !r.reachable(err) {
+			return i, err
+		}
+		r.rejectRecord(rec, err)
+	}
+	return len(recs), nil
+}
+
+// reachable checks if an insert failed because of the connection.
+// A timeout is treated as a connection error.
+func (r *Repository) reachable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.pool.PingContext(ctx) == nil
+}
+
+// rejectRecord sets aside a row the repository won't take
+func (r *Repository) rejectRecord(rec record, reason error) {
+	r.rejected.Add(1)
+	msg := fmt.Sprintf("REPOSITORY: rejected row: %s: %s: %s", rec.Table, rec.Key, reason)
+	logrus.Error(msg)
+	r.applog.Enqueue(msg)
+	err := r.spool.reject(rec, reason)
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "spool.reject"))
+	}
+}
+
+// spoolRecords writes to the spool.  Without a spool, the rows are dropped.
+func (r *Repository) spoolRecords(recs []record) {
+	if r.spool == nil {
+		r.dropped.Add(int64(len(recs)))
+		return
+	}
+	err := r.spool.write(recs)
+	if err != nil {
+		r.dropped.Add(int64(len(recs)))
+		r.handleError(errors.Wrap(err, "spool"))
+		return
+	}
+	r.spooled.Add(int64(len(recs)))
+}
+
+// reconnect runs the migrations if we are disconnected and then replays the spool
+func (r *Repository) reconnect() {
+	if !r.connected.Load() {
+		err := r.migrate()
+		if err != nil {
+			r.handleError(err)
+			return
+		}
+		r.connected.Store(true)
+		r.handleError(nil)
+	}
+	if !r.spool.pending() {
+		return
+	}
+	n, err := r.spool.replay(batchRows, r.insertRows)
+	r.replayed.Add(int64(n))
+	if n > 0 {
+		msg := fmt.Sprintf("REPOSITORY: replayed %d spooled rows", n)
+		logrus.Info(msg)
+		r.applog.Enqueue(msg)
+	}
+	if err != nil {
+		r.connected.Store(false)
+		r.handleError(err)
+	}
+}
+
+// migrate checks the connection and brings the schema up to date
+func (r *Repository) migrate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := r.pool.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: ping: %w", r.backend.Name(), err)
+	}
+	err = goose.Up(r.pool.DB, r.backend.Migrations())
+	if err != nil {
+		return fmt.Errorf("goose.up: %w", err)
+	}
+	err = r.loadRolled(ctx)
+	if err != nil {
+		return fmt.Errorf("rolled: %w", err)
+	}
+	return nil
+}
+
+// insert writes the records in one transaction using multi-row inserts
+func (r *Repository) insert(recs []record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	// group by table but keep the order within each table
+	tables := make([]string, 0, 3)
+	rows := make(map[string][]record)
+	for _, rec := range recs {
+		if _, ok := rows[rec.Table]; !ok {
+			tables = append(tables, rec.Table)
+		}
+		rows[rec.Table] = append(rows[rec.Table], rec)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	tx, err := r.pool.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begintx")
+	}
+	for _, table := range tables {
+		columns := tableColumns(table)
+		kt, keyed := keyedTables[table]
+		list := rows[table]
+		if keyed {
+			columns = kt.columns()
+			list = kt.unique(list)
+		}
+		per := maxParams / len(columns)
+		for len(list) > 0 {
+			n := min(per, len(list))
+			var query string
+			var args []any
+			if keyed {
+				query, args = r.insertKeyedQuery(table, kt, list[:n])
+			} else {
+				query, args = r.insertQuery(table, columns, list[:n])
+			}
+			_, err = tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				_ = tx.Rollback()
+				return errors.Wrap(err, table)
+			}
+			list = list[n:]
+		}
+	}
+	// mark the hours that may already be rolled up
+	hours := r.pendingHours(recs)
+	for len(hours) > 0 {
+		n := min(maxParams, len(hours))
+		args := make([]any, 0, n)
+		for _, h := range hours[:n] {
+			args = append(args, r.backend.Timestamp(h))
+		}
+		query := r.backend.InsertIgnore(r.backend.Table(pendingTable), []string{"bucket_ts"}, []string{"bucket_ts"}, n)
+		_, err = tx.ExecContext(ctx, r.pool.Rebind(query), args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(err, pendingTable)
+		}
+		hours = hours[n:]
+	}
+	return errors.Wrap(tx.Commit(), "commit")
+}
+
+// insertQuery builds an INSERT with a VALUES row for each record
+func (r *Repository) insertQuery(table string, columns []string, recs []record) (string, []any) {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := make([]string, 0, len(recs))
+	args := make([]any, 0, len(recs)*len(columns))
+	for _, rec := range recs {
+		values = append(values, row)
+		args = append(args, r.values(rec)...)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", r.backend.Table(table), strings.Join(columns, ", "), strings.Join(values, ", "))
+	return r.pool.Rebind(query), args
+}
+
+// insertKeyedQuery builds an INSERT that skips rows already in the table
+func (r *Repository) insertKeyedQuery(table string, kt keyedTable, recs []record) (string, []any) {
+	columns := kt.columns()
+	args := make([]any, 0, len(recs)*len(columns))
+	for _, rec := range recs {
+		args = append(args,
+			r.backend.Timestamp(rec.TS),
+			r.backend.Date(truncateDate(rec.TS)),
+			r.backend.Time(rec.TS.Truncate(time.Minute)),
+			rec.Key,
+			rec.Server,
+		)
+		for _, col := range kt.values {
+			args = append(args, rec.Values[col])
+		}
+		for _, col := range kt.strings {
+			args = append(args, rec.Strings[col])
+		}
+	}
+	query := r.backend.InsertIgnore(r.backend.Table(table), columns, kt.keys, len(recs))
+	return r.pool.Rebind(query), args
+}
+
+// unique removes records with the same keys.  The last one wins.
+func (kt keyedTable) unique(recs []record) []record {
+	index := make(map[string]int)
+	list := make([]record, 0, len(recs))
+	for _, rec := range recs {
+		var sb strings.Builder
+		for _, k := range kt.keys {
+			switch k {
+			case "ts":
+				sb.WriteString(rec.TS.UTC().String())
+			case "server_key":
+				sb.WriteString(rec.Key)
+			default:
+				if v, ok := rec.Values[k]; ok {
+					sb.WriteString(fmt.Sprint(v))
+				}
+				sb.WriteString(rec.Strings[k])
+			}
+			sb.WriteByte(0)
+		}
+		if i, ok := index[sb.String()]; ok {
+			list[i] = rec
+			continue
+		}
+		index[sb.String()] = len(list)
+		list = append(list, rec)
+	}
+	return list
+}
+
+// values returns the arguments for a record in the order of tableColumns
+func (r *Repository) values(rec record) []any {
+	var start any
+	if !rec.Start.IsZero() {
+		start = r.backend.Timestamp(rec.Start)
+	}
+	args := []any{
+		r.backend.Timestamp(rec.TS),
+		r.backend.Date(truncateDate(rec.TS)),
+		r.backend.Time(rec.TS.Truncate(time.Minute)),
+		rec.Key,
+		rec.Server,
+		start,
+	}
+	if rec.Table != metricTable {
+		return append(args, rec.Wait, rec.WaitSec)
+	}
+	for _, col := range metricColumns {
+		n, ok := rec.Values[col]
+		if !ok {
+			args = append(args, nil)
+			continue
+		}
+		args = append(args, n)
+	}
+	return args
+}
+
+// tableColumns returns the columns we write for a table
+func tableColumns(table string) []string {
+	columns := append([]string{}, baseColumns...)
+	if table == metricTable {
+		return append(columns, metricColumns...)
+	}
+	return append(columns, "wait_type", "wait_time_sec")
+}
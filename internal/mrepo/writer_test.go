@@ -0,0 +1,157 @@
+package mrepo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/scalesql/isitsql/internal/appringlog"
+	"github.com/scalesql/isitsql/internal/waitring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBatch(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "isitsql.db")
+	r, err := NewRepository(Config{Driver: DriverSQLite, Database: file}, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	// more waits than fit in one statement
+	ts := time.Date(2025, 1, 2, 11, 34, 0, 0, time.UTC)
+	waits := make(map[string]int64)
+	for i := 0; i < 600; i++ {
+		waits[time.Duration(i).String()] = 2000
+	}
+	r.WriteWaits("srv1", "SQL01", "request_wait", time.Time{}, waitring.WaitList{TS: ts, Waits: waits})
+	r.WriteMetrics(ts, map[string]any{"server_key": "srv1", "server_name": "SQL01", "cpu_sql_pct": 10, "unknown": 5})
+	r.Flush()
+
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM request_wait WHERE server_start IS NULL"))
+	assert.Equal(600, n)
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric"))
+	assert.Equal(1, n)
+
+	stats := r.Stats()
+	assert.True(stats.Connected)
+	assert.Equal(int64(601), stats.Written)
+	assert.Equal(0, stats.Queue)
+	assert.Equal(0, stats.SpoolFiles)
+}
+
+func TestWriterSpoolReplay(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// the folder for the database doesn't exist yet so it can't connect
+	dbdir := filepath.Join(dir, "db")
+	cfg := Config{
+		Driver:     DriverSQLite,
+		Database:   filepath.Join(dbdir, "isitsql.db"),
+		SpoolDir:   filepath.Join(dir, "spool"),
+		SpoolMaxMB: 10,
+	}
+	r, err := NewRepository(cfg, goose.NopLogger(), &appringlog.RingLog{})
+	require.Error(t, err)
+	require.NotNil(t, r)
+	defer r.Close()
+
+	ts := time.Date(2025, 1, 2, 11, 34, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		r.WriteMetrics(ts.Add(time.Duration(i)*time.Minute), map[string]any{"server_key": "srv1", "server_name": "SQL01", "cpu_sql_pct": i})
+	}
+	r.Flush()
+	stats := r.Stats()
+	assert.False(stats.Connected)
+	assert.Equal(int64(3), stats.Spooled)
+	assert.Equal(1, stats.SpoolFiles)
+	assert.Greater(stats.SpoolBytes, int64(0))
+	_, rerr := r.RepositoryError()
+	assert.Error(rerr)
+
+	// once it connects, the spool is written before new rows
+	require.NoError(t, os.MkdirAll(dbdir, 0700))
+	r.reconnect()
+	r.WriteMetrics(ts.Add(3*time.Minute), map[string]any{"server_key": "srv1", "server_name": "SQL01", "cpu_sql_pct": 3})
+	r.Flush()
+
+	stats = r.Stats()
+	assert.True(stats.Connected)
+	assert.Equal(int64(3), stats.Replayed)
+	assert.Equal(0, stats.SpoolFiles)
+	_, rerr = r.RepositoryError()
+	assert.NoError(rerr)
+
+	var pct []int
+	require.NoError(t, r.pool.Select(&pct, "SELECT cpu_sql_pct FROM server_metric ORDER BY rowid"))
+	assert.Equal([]int{0, 1, 2, 3}, pct)
+}
+
+func TestWriterCloseKeepsSpool(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	cfg := Config{
+		Driver:   DriverSQLite,
+		Database: filepath.Join(dir, "missing", "isitsql.db"),
+		SpoolDir: filepath.Join(dir, "spool"),
+	}
+	r, _ := NewRepository(cfg, goose.NopLogger(), &appringlog.RingLog{})
+	r.WriteMetrics(time.Now(), map[string]any{"server_key": "srv1", "cpu_sql_pct": 1})
+	require.NoError(t, r.Close())
+
+	// the next start picks up the file
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "missing"), 0700))
+	r, err := NewRepository(cfg, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+	r.Flush()
+	r.reconnect()
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric"))
+	assert.Equal(1, n)
+	assert.Equal(0, r.Stats().SpoolFiles)
+}
+
+func TestWriterRejectsBadRows(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	cfg := Config{
+		Driver:   DriverSQLite,
+		Database: filepath.Join(dir, "isitsql.db"),
+		SpoolDir: filepath.Join(dir, "spool"),
+	}
+	r, err := NewRepository(cfg, goose.NopLogger(), &appringlog.RingLog{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	// a table that doesn't exist stands in for a row the repository rejects
+	ts := time.Date(2025, 1, 2, 11, 34, 0, 0, time.UTC)
+	good := record{Table: metricTable, TS: ts, Key: "srv1", Values: map[string]int64{"cpu_sql_pct": 1}}
+	bad := record{Table: "missing_table", TS: ts, Key: "srv1"}
+	r.enqueue(good, bad, good)
+	r.Flush()
+
+	stats := r.Stats()
+	assert.True(stats.Connected)
+	assert.Equal(int64(2), stats.Written)
+	assert.Equal(int64(1), stats.Rejected)
+	assert.Equal(int64(0), stats.Spooled)
+
+	// a rejected row in the spool doesn't stop the replay
+	require.NoError(t, r.spool.write([]record{bad, good}))
+	r.reconnect()
+	stats = r.Stats()
+	assert.True(stats.Connected)
+	assert.Equal(0, stats.SpoolFiles)
+	assert.Equal(int64(2), stats.Replayed)
+	assert.Equal(int64(2), stats.Rejected)
+
+	var n int
+	require.NoError(t, r.pool.Get(&n, "SELECT COUNT(*) FROM server_metric"))
+	assert.Equal(3, n)
+	left, _ := filepath.Glob(filepath.Join(cfg.SpoolDir, rejectPrefix+"_*"))
+	assert.Len(left, 1)
+}
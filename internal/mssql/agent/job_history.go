@@ -151,6 +151,22 @@ ORDER BY instance_id DESC `, queryHistorySelect)
 	return fetchhistory(ctx, key, pool, stmt, jobid)
 }
 
+// FetchRecentCompletions returns the completed runs of all jobs in the
+// last seven days after the afterID instance_id, oldest first
+func FetchRecentCompletions(ctx context.Context, key string, pool *sql.DB, afterID int64) ([]JobHistoryRow, error) {
+	dateStr := time.Now().AddDate(0, 0, -7).Format("20060102")
+	dateInt, err := strconv.Atoi(dateStr)
+	if err != nil {
+		return []JobHistoryRow{}, err
+	}
+	stmt := fmt.Sprintf(`%s
+	AND step_id = 0
+	AND sjh.run_date >= @p1
+	AND sjh.instance_id > @p2
+	ORDER BY instance_id `, queryHistorySelect)
+	return fetchhistory(ctx, key, pool, stmt, "", dateInt, afterID)
+}
+
 // FetchJobMessages returns all the messages for one run of a job
 func FetchJobMessages(ctx context.Context, key string, pool *sql.DB, jobid string, instanceid int) ([]JobHistoryRow, error) {
 	stmt := fmt.Sprintf(`%s
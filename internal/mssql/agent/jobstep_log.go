@@ -22,13 +22,13 @@ type JobStepLog struct {
 }
 
 // FetchJobStepLog gets the step output for a job
-func FetchJobStepLog(key string, jobid string, pool *sql.DB) ([]JobStepLog, error) {
+func FetchJobStepLog(ctx context.Context, key string, jobid string, pool *sql.DB) ([]JobStepLog, error) {
 	var jobUUID mssql.UniqueIdentifier
 	err := jobUUID.Scan(jobid)
 	if err != nil {
 		return []JobStepLog{}, err
 	}
-	e, err := FetchEnvironment(context.TODO(), pool)
+	e, err := FetchEnvironment(ctx, pool)
 	if err != nil {
 		return []JobStepLog{}, err
 	}
@@ -38,7 +38,7 @@ func FetchJobStepLog(key string, jobid string, pool *sql.DB) ([]JobStepLog, erro
 	sqlxdb := sqlx.NewDb(pool, "sqlserver")
 	sqlxdb = sqlxdb.Unsafe()
 	steps := make([]JobStepLog, 0)
-	err = sqlxdb.Select(&steps, jobStepLogQuery, jobUUID)
+	err = sqlxdb.SelectContext(ctx, &steps, jobStepLogQuery, jobUUID)
 	if err != nil {
 		return []JobStepLog{}, err
 	}
@@ -0,0 +1,285 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultEmailConditions are the alert conditions that are emailed
+// if none are configured
+var DefaultEmailConditions = []string{"poll_error", "ag_health", "backup"}
+
+// maxPending is the most payloads held for a digest
+const maxPending = 1000
+
+// SMTPConfig holds the settings for email delivery
+type SMTPConfig struct {
+	Host          string   `toml:"host"`
+	Port          int      `toml:"port"`
+	StartTLS      bool     `toml:"starttls"`
+	Credential    string   `toml:"credential"`
+	From          string   `toml:"from"`
+	To            []string `toml:"to"`
+	SubjectPrefix string   `toml:"subject_prefix"`
+	DigestMinutes int      `toml:"digest_minutes"`
+	Conditions    []string `toml:"conditions"`
+
+	// Username and Password are resolved from the credential
+	Username string `toml:"-"`
+	Password string `toml:"-"`
+}
+
+// Email sends payloads using SMTP.  If a digest interval is set,
+// payloads are held and sent together by Flush.
+type Email struct {
+	cfg        SMTPConfig
+	conditions map[string]bool
+	digest     time.Duration
+	mu         sync.Mutex
+	pending    []Payload
+	now        func() time.Time
+}
+
+// NewEmail returns an Email sender
+func NewEmail(cfg SMTPConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp: host is required")
+	}
+	if cfg.From == "" {
+		return nil, errors.New("smtp: from is required")
+	}
+	if len(cfg.To) == 0 {
+		return nil, errors.New("smtp: to is required")
+	}
+	if cfg.DigestMinutes < 0 {
+		return nil, errors.New("smtp: digest_minutes can't be negative")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 25
+		if cfg.StartTLS {
+			cfg.Port = 587
+		}
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = "[IsItSQL]"
+	}
+	if len(cfg.Conditions) == 0 {
+		cfg.Conditions = DefaultEmailConditions
+	}
+	e := &Email{
+		cfg:        cfg,
+		conditions: make(map[string]bool),
+		digest:     time.Duration(cfg.DigestMinutes) * time.Minute,
+		pending:    make([]Payload, 0),
+		now:        time.Now,
+	}
+	for _, c := range cfg.Conditions {
+		e.conditions[strings.ToLower(strings.TrimSpace(c))] = true
+	}
+	return e, nil
+}
+
+// Name of the sender
+func (e *Email) Name() string {
+	return "smtp:" + e.cfg.Host
+}
+
+// Accepts returns true if the condition is configured to be emailed
+func (e *Email) Accepts(p Payload) bool {
+	return e.conditions[strings.ToLower(p.Condition)]
+}
+
+// Interval returns how often a digest is sent.  Zero sends immediately.
+func (e *Email) Interval() time.Duration {
+	return e.digest
+}
+
+// Send emails a payload.  In digest mode it is held until the next Flush.
+func (e *Email) Send(ctx context.Context, p Payload) error {
+	if e.digest > 0 {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if len(e.pending) >= maxPending {
+			return fmt.Errorf("smtp: digest full: %d pending", len(e.pending))
+		}
+		e.pending = append(e.pending, p)
+		return nil
+	}
+	subject := fmt.Sprintf("%s %s: %s: %s", e.cfg.SubjectPrefix, strings.ToUpper(p.State), p.DisplayName, describe(p))
+	return e.sendMail(ctx, subject, formatPayload(p))
+}
+
+// Flush sends everything held for the digest as one message.
+// It returns the number of payloads sent.  If the send fails,
+// the payloads are kept for the next Flush.
+func (e *Email) Flush(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	batch := e.pending
+	e.pending = make([]Payload, 0)
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	var firing int
+	for _, p := range batch {
+		if p.State == "firing" {
+			firing++
+		}
+	}
+	subject := fmt.Sprintf("%s Digest: %d firing, %d resolved", e.cfg.SubjectPrefix, firing, len(batch)-firing)
+	err := e.sendMail(ctx, subject, e.DigestBody(batch))
+	if err != nil {
+		e.mu.Lock()
+		e.pending = append(batch, e.pending...)
+		if len(e.pending) > maxPending {
+			e.pending = e.pending[len(e.pending)-maxPending:]
+		}
+		e.mu.Unlock()
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// Pending returns the number of payloads waiting for the digest
+func (e *Email) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.pending)
+}
+
+// DigestBody formats a batch of payloads grouped by state
+func (e *Email) DigestBody(batch []Payload) string {
+	sorted := make([]Payload, len(batch))
+	copy(sorted, batch)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].State != sorted[j].State {
+			return sorted[i].State == "firing"
+		}
+		return sorted[i].LastSeen.Before(sorted[j].LastSeen)
+	})
+	var b strings.Builder
+	fmt.Fprintf(&b, "IsItSQL alerts for the last %s\r\n", e.digest)
+	state := ""
+	for _, p := range sorted {
+		if p.State != state {
+			state = p.State
+			fmt.Fprintf(&b, "\r\n%s\r\n", strings.ToUpper(state))
+		}
+		fmt.Fprintf(&b, "  %s  %-8s  %s: %s: %s\r\n", eventTime(p).Format(time.RFC3339), p.Severity, p.DisplayName, describe(p), p.Message)
+	}
+	return b.String()
+}
+
+// describe returns the condition and target
+func describe(p Payload) string {
+	if p.Target == "" {
+		return p.Condition
+	}
+	return fmt.Sprintf("%s (%s)", p.Condition, p.Target)
+}
+
+// eventTime returns when the transition happened
+func eventTime(p Payload) time.Time {
+	if p.State == "resolved" && p.ResolvedAt != nil {
+		return *p.ResolvedAt
+	}
+	if !p.LastSeen.IsZero() {
+		return p.LastSeen
+	}
+	return p.FirstSeen
+}
+
+// formatPayload is the body of an immediate email
+func formatPayload(p Payload) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Server:     %s (%s)\r\n", p.DisplayName, p.ServerKey)
+	fmt.Fprintf(&b, "Condition:  %s\r\n", describe(p))
+	fmt.Fprintf(&b, "Severity:   %s\r\n", p.Severity)
+	fmt.Fprintf(&b, "State:      %s\r\n", p.State)
+	fmt.Fprintf(&b, "First Seen: %s\r\n", p.FirstSeen.Format(time.RFC3339))
+	if p.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved:   %s\r\n", p.ResolvedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "\r\n%s\r\n", p.Message)
+	return b.String()
+}
+
+// message builds the RFC 5322 message
+func (e *Email) message(subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	// server names and messages may not be ASCII.  ASCII is left as it is.
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", e.now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	return buf.Bytes()
+}
+
+// sendMail connects to the server and sends one message
+func (e *Email) sendMail(ctx context.Context, subject, body string) error {
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "smtp.dial")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "smtp.newclient")
+	}
+	defer c.Close()
+
+	if e.cfg.StartTLS {
+		err = c.StartTLS(&tls.Config{ServerName: e.cfg.Host, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return errors.Wrap(err, "smtp.starttls")
+		}
+	}
+	if e.cfg.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host))
+		if err != nil {
+			return errors.Wrap(err, "smtp.auth")
+		}
+	}
+	if err = c.Mail(e.cfg.From); err != nil {
+		return errors.Wrap(err, "smtp.mail")
+	}
+	for _, to := range e.cfg.To {
+		if err = c.Rcpt(to); err != nil {
+			return errors.Wrapf(err, "smtp.rcpt: %s", to)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "smtp.data")
+	}
+	if _, err = w.Write(e.message(subject, body)); err != nil {
+		return errors.Wrap(err, "smtp.write")
+	}
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "smtp.close")
+	}
+	return c.Quit()
+}
@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/logring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpServer is a minimal SMTP server that records each message
+type smtpServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []string
+	auth     []string
+	rcpts    []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &smtpServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.handle(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return srv
+}
+
+func (srv *smtpServer) port() int {
+	return srv.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (srv *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+	reply("220 localhost test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			srv.mu.Lock()
+			srv.auth = append(srv.auth, line)
+			srv.mu.Unlock()
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "MAIL"):
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT"):
+			srv.mu.Lock()
+			srv.rcpts = append(srv.rcpts, line)
+			srv.mu.Unlock()
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			srv.mu.Lock()
+			srv.messages = append(srv.messages, msg.String())
+			srv.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (srv *smtpServer) count() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.messages)
+}
+
+func TestEmailImmediate(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	srv := newSMTPServer(t)
+	e, err := NewEmail(SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     srv.port(),
+		From:     "isitsql@example.com",
+		To:       []string{"dba@example.com", "oncall@example.com"},
+		Username: "user",
+		Password: "secret",
+	})
+	require.NoError(err)
+	require.NoError(e.Send(context.Background(), testPayload))
+	require.Equal(1, srv.count())
+	msg := srv.messages[0]
+	assert.Contains(msg, "Subject: [IsItSQL] FIRING: D40\\SQL2016 \"prod\": poll_error")
+	assert.Contains(msg, "login failed")
+	assert.Len(srv.rcpts, 2)
+	assert.Len(srv.auth, 1)
+}
+
+func TestEmailSubjectEncoding(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	e, err := NewEmail(SMTPConfig{Host: "127.0.0.1", From: "isitsql@example.com", To: []string{"dba@example.com"}})
+	require.NoError(err)
+	msg := string(e.message("[IsItSQL] FIRING: SQL-Zürich: poll_error", "body"))
+	assert.Contains(msg, "Subject: =?utf-8?q?[IsItSQL]_FIRING:_SQL-Z=C3=BCrich:_poll=5Ferror?=\r\n")
+	assert.NotContains(msg, "ü")
+
+	// ASCII isn't encoded
+	msg = string(e.message("[IsItSQL] Digest: 1 firing, 0 resolved", "body"))
+	assert.Contains(msg, "Subject: [IsItSQL] Digest: 1 firing, 0 resolved\r\n")
+}
+
+func TestEmailDigest(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	srv := newSMTPServer(t)
+	e, err := NewEmail(SMTPConfig{
+		Host:          "127.0.0.1",
+		Port:          srv.port(),
+		From:          "isitsql@example.com",
+		To:            []string{"dba@example.com"},
+		DigestMinutes: 15,
+	})
+	require.NoError(err)
+	assert.Equal(15*time.Minute, e.Interval())
+
+	resolved := testPayload
+	resolved.ServerKey = "srv2"
+	resolved.DisplayName = "SRV2"
+	resolved.State = "resolved"
+	resolvedAt := time.Date(2025, 1, 2, 4, 0, 0, 0, time.UTC)
+	resolved.ResolvedAt = &resolvedAt
+	require.NoError(e.Send(context.Background(), testPayload))
+	require.NoError(e.Send(context.Background(), resolved))
+	assert.Equal(0, srv.count())
+	assert.Equal(2, e.Pending())
+
+	n, err := e.Flush(context.Background())
+	require.NoError(err)
+	assert.Equal(2, n)
+	assert.Equal(0, e.Pending())
+	require.Equal(1, srv.count())
+	msg := srv.messages[0]
+	assert.Contains(msg, "Subject: [IsItSQL] Digest: 1 firing, 1 resolved")
+	assert.Less(strings.Index(msg, "FIRING"), strings.Index(msg, "RESOLVED"))
+	assert.Contains(msg, "SRV2")
+
+	// nothing to send
+	n, err = e.Flush(context.Background())
+	require.NoError(err)
+	assert.Equal(0, n)
+	assert.Equal(1, srv.count())
+}
+
+func TestEmailDigestKeepsOnFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close() // nothing listening
+
+	e, err := NewEmail(SMTPConfig{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}, DigestMinutes: 1})
+	require.NoError(t, err)
+	require.NoError(t, e.Send(context.Background(), testPayload))
+	_, err = e.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, e.Pending())
+}
+
+func TestEmailConditions(t *testing.T) {
+	assert := assert.New(t)
+	e, err := NewEmail(SMTPConfig{Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}})
+	require.NoError(t, err)
+	assert.True(e.Accepts(Payload{Condition: "poll_error"}))
+	assert.True(e.Accepts(Payload{Condition: "backup"}))
+	assert.False(e.Accepts(Payload{Condition: "job_failed"}))
+
+	e, err = NewEmail(SMTPConfig{Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}, Conditions: []string{"Job_Failed"}})
+	require.NoError(t, err)
+	assert.True(e.Accepts(Payload{Condition: "job_failed"}))
+	assert.False(e.Accepts(Payload{Condition: "poll_error"}))
+}
+
+func TestEmailBadConfig(t *testing.T) {
+	_, err := NewEmail(SMTPConfig{})
+	assert.Error(t, err)
+	_, err = NewEmail(SMTPConfig{Host: "localhost", From: "a@example.com"})
+	assert.Error(t, err)
+	_, err = NewEmail(SMTPConfig{Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}, DigestMinutes: -1})
+	assert.Error(t, err)
+}
+
+func TestNotifierSkipsFiltered(t *testing.T) {
+	srv := newSMTPServer(t)
+	e, err := NewEmail(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), From: "a@example.com", To: []string{"b@example.com"}})
+	require.NoError(t, err)
+	n := New(logring.New(10))
+	p := testPayload
+	p.Condition = "job_failed"
+	n.deliver(context.Background(), e, p)
+	assert.Len(t, n.Log(), 0)
+	assert.Equal(t, 0, srv.count())
+}
@@ -0,0 +1,220 @@
+// Package notify delivers alert transitions to external systems.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/scalesql/isitsql/internal/logring"
+	"github.com/sirupsen/logrus"
+)
+
+// Payload is what gets sent for each alert transition
+type Payload struct {
+	ServerKey   string     `json:"server_key"`
+	DisplayName string     `json:"display_name"`
+	Condition   string     `json:"condition"`
+	Target      string     `json:"target,omitempty"`
+	Severity    string     `json:"severity"`
+	State       string     `json:"state"`
+	FirstSeen   time.Time  `json:"first_seen"`
+	LastSeen    time.Time  `json:"last_seen"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Message     string     `json:"message"`
+}
+
+// Sender delivers a payload to one destination
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, p Payload) error
+}
+
+// Filter is a Sender that only wants some payloads
+type Filter interface {
+	Accepts(p Payload) bool
+}
+
+// Flusher is a Sender that holds payloads and sends them
+// together every Interval.  A zero Interval sends immediately.
+type Flusher interface {
+	Interval() time.Duration
+	Flush(ctx context.Context) (int, error)
+}
+
+// Notifier queues payloads and delivers them to each Sender
+type Notifier struct {
+	mu      sync.RWMutex
+	senders []Sender
+	queue   chan Payload
+	log     *logring.Logring
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+// New returns a Notifier.  Deliveries are written to log.
+func New(log *logring.Logring) *Notifier {
+	if log == nil {
+		log = logring.New(100)
+	}
+	return &Notifier{
+		senders: make([]Sender, 0),
+		queue:   make(chan Payload, 1000),
+		log:     log,
+		retries: 4,
+		backoff: 2 * time.Second,
+		timeout: 10 * time.Second,
+	}
+}
+
+// Add a Sender to the Notifier
+func (n *Notifier) Add(s Sender) {
+	if n == nil || s == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.senders = append(n.senders, s)
+}
+
+// Count returns the number of configured senders
+func (n *Notifier) Count() int {
+	if n == nil {
+		return 0
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.senders)
+}
+
+// Log returns the delivery log
+func (n *Notifier) Log() []logring.Event {
+	if n == nil {
+		return []logring.Event{}
+	}
+	return n.log.Newest()
+}
+
+// Notify queues a payload for delivery.  It never blocks.
+func (n *Notifier) Notify(p Payload) {
+	if n == nil || n.Count() == 0 {
+		return
+	}
+	select {
+	case n.queue <- p:
+	default:
+		n.log.Enqueuef("DROPPED: queue full: %s: %s", p.ServerKey, p.Condition)
+		logrus.Errorf("notify: queue full: dropped: %s: %s", p.ServerKey, p.Condition)
+	}
+}
+
+// Run delivers queued payloads until the context is cancelled.
+// It should be called in a GO routine.  Each Sender has its own queue
+// and GO routine so a slow or failing Sender doesn't delay the others.
+// Senders should be added before Run.
+func (n *Notifier) Run(ctx context.Context) {
+	defer failure.HandlePanic()
+	n.mu.RLock()
+	senders := append([]Sender{}, n.senders...)
+	n.mu.RUnlock()
+
+	queues := make([]chan Payload, len(senders))
+	for i, s := range senders {
+		queues[i] = make(chan Payload, cap(n.queue))
+		go n.send(ctx, s, queues[i])
+		if f, ok := s.(Flusher); ok && f.Interval() > 0 {
+			go n.flush(ctx, s.Name(), f)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-n.queue:
+			for i, s := range senders {
+				select {
+				case queues[i] <- p:
+				default:
+					n.log.Enqueuef("DROPPED: %s: queue full: %s: %s", s.Name(), p.ServerKey, p.Condition)
+					logrus.Errorf("notify: %s: queue full: dropped: %s: %s", s.Name(), p.ServerKey, p.Condition)
+				}
+			}
+		}
+	}
+}
+
+// send delivers the payloads queued for one Sender
+// until the context is cancelled
+func (n *Notifier) send(ctx context.Context, s Sender, queue <-chan Payload) {
+	defer failure.HandlePanic()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-queue:
+			n.deliver(ctx, s, p)
+		}
+	}
+}
+
+// deliver sends to one Sender with retries and exponential backoff
+func (n *Notifier) deliver(ctx context.Context, s Sender, p Payload) {
+	if f, ok := s.(Filter); ok && !f.Accepts(p) {
+		return
+	}
+	verb := "SENT"
+	if f, ok := s.(Flusher); ok && f.Interval() > 0 {
+		verb = "QUEUED"
+	}
+	var err error
+	wait := n.backoff
+	for attempt := 1; attempt <= n.retries; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, n.timeout)
+		err = s.Send(sendCtx, p)
+		cancel()
+		if err == nil {
+			n.log.Enqueuef("%s: %s: %s: %s (%s) attempt=%d", verb, s.Name(), p.ServerKey, p.Condition, p.State, attempt)
+			return
+		}
+		n.log.Enqueuef("FAILED: %s: %s: %s (%s) attempt=%d: %s", s.Name(), p.ServerKey, p.Condition, p.State, attempt, err)
+		if attempt == n.retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	logrus.Error(fmt.Errorf("notify: %s: giving up: %s: %s: %w", s.Name(), p.ServerKey, p.Condition, err))
+}
+
+// flush sends the held payloads for a Flusher every Interval
+// until the context is cancelled
+func (n *Notifier) flush(ctx context.Context, name string, f Flusher) {
+	defer failure.HandlePanic()
+	ticker := time.NewTicker(f.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sendCtx, cancel := context.WithTimeout(ctx, n.timeout)
+			count, err := f.Flush(sendCtx)
+			cancel()
+			if err != nil {
+				n.log.Enqueuef("FAILED: %s: digest: %s", name, err)
+				logrus.Error(fmt.Errorf("notify: %s: digest: %w", name, err))
+				continue
+			}
+			if count > 0 {
+				n.log.Enqueuef("SENT: %s: digest: %d alert(s)", name, count)
+			}
+		}
+	}
+}
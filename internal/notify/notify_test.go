@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scalesql/isitsql/internal/logring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	bodies []string
+	header http.Header
+	fails  int // fail this many requests first
+}
+
+func (rec *recorder) handler(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.fails > 0 {
+		rec.fails--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	bb, _ := io.ReadAll(r.Body)
+	rec.bodies = append(rec.bodies, string(bb))
+	rec.header = r.Header.Clone()
+}
+
+func (rec *recorder) count() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.bodies)
+}
+
+var testPayload = Payload{
+	ServerKey:   "srv1",
+	DisplayName: `D40\SQL2016 "prod"`,
+	Condition:   "poll_error",
+	Severity:    "critical",
+	State:       "firing",
+	FirstSeen:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	Message:     "login failed",
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	wh, err := NewWebhook(WebhookConfig{URL: srv.URL, Headers: map[string]string{"X-Token": "abc"}})
+	require.NoError(err)
+	require.NoError(wh.Send(context.Background(), testPayload))
+	require.Equal(1, rec.count())
+
+	var got Payload
+	require.NoError(json.Unmarshal([]byte(rec.bodies[0]), &got))
+	assert.Equal(testPayload.ServerKey, got.ServerKey)
+	assert.Equal(testPayload.FirstSeen, got.FirstSeen)
+	assert.Equal("abc", rec.header.Get("X-Token"))
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	require := require.New(t)
+	wh, err := NewWebhook(WebhookConfig{
+		Name:     "slack",
+		URL:      "http://localhost",
+		Template: `{"text": {{ printf "%s: %s %s" (upper .State) .DisplayName .Message | json }}}`,
+	})
+	require.NoError(err)
+	bb, err := wh.Body(testPayload)
+	require.NoError(err)
+	var slack struct {
+		Text string `json:"text"`
+	}
+	require.NoError(json.Unmarshal(bb, &slack))
+	require.Equal(`FIRING: D40\SQL2016 "prod" login failed`, slack.Text)
+}
+
+func TestWebhookBadConfig(t *testing.T) {
+	_, err := NewWebhook(WebhookConfig{})
+	assert.Error(t, err)
+	_, err = NewWebhook(WebhookConfig{URL: "http://localhost", Template: "{{ .Nope "})
+	assert.Error(t, err)
+}
+
+func TestNotifierRetries(t *testing.T) {
+	require := require.New(t)
+	rec := &recorder{fails: 2}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	wh, err := NewWebhook(WebhookConfig{Name: "test", URL: srv.URL})
+	require.NoError(err)
+	n := New(logring.New(10))
+	n.backoff = time.Millisecond
+	n.Add(wh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+	n.Notify(testPayload)
+	require.Eventually(func() bool { return rec.count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// two failures and a success
+	require.Eventually(func() bool { return len(n.Log()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	require.Contains(n.Log()[0].Message, "SENT: test")
+}
+
+func TestNotifierFailingSender(t *testing.T) {
+	require := require.New(t)
+	bad := &recorder{fails: 100}
+	badSrv := httptest.NewServer(http.HandlerFunc(bad.handler))
+	defer badSrv.Close()
+	good := &recorder{}
+	goodSrv := httptest.NewServer(http.HandlerFunc(good.handler))
+	defer goodSrv.Close()
+
+	badWH, err := NewWebhook(WebhookConfig{Name: "bad", URL: badSrv.URL})
+	require.NoError(err)
+	goodWH, err := NewWebhook(WebhookConfig{Name: "good", URL: goodSrv.URL})
+	require.NoError(err)
+	n := New(logring.New(100))
+	n.backoff = time.Hour // the bad sender waits between its retries
+	n.Add(badWH)
+	n.Add(goodWH)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+	n.Notify(testPayload)
+	n.Notify(testPayload)
+	require.Eventually(func() bool { return good.count() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(0, bad.count())
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	rec := &recorder{fails: 100}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	wh, err := NewWebhook(WebhookConfig{Name: "test", URL: srv.URL})
+	require.NoError(t, err)
+	n := New(logring.New(10))
+	n.backoff = time.Millisecond
+	n.retries = 3
+	n.deliver(context.Background(), wh, testPayload)
+	assert.Len(t, n.Log(), 3)
+	assert.Equal(t, 0, rec.count())
+}
+
+func TestNotifyWithoutSenders(t *testing.T) {
+	n := New(nil)
+	n.Notify(testPayload) // doesn't block or queue
+	assert.Len(t, n.queue, 0)
+	var nilNotifier *Notifier
+	nilNotifier.Notify(testPayload)
+	assert.Len(t, nilNotifier.Log(), 0)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookConfig holds the settings for one webhook target
+type WebhookConfig struct {
+	Name     string            `toml:"name"`
+	URL      string            `toml:"url"`
+	Template string            `toml:"template"`
+	Headers  map[string]string `toml:"headers"`
+}
+
+// Webhook posts a payload to a URL
+type Webhook struct {
+	name    string
+	url     string
+	headers map[string]string
+	tmpl    *template.Template
+	client  *http.Client
+}
+
+// templateFuncs are available in webhook templates.
+// json marshals a value so strings are safely quoted.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		bb, err := json.Marshal(v)
+		return string(bb), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// NewWebhook returns a Webhook.  If there is no template, the payload
+// is sent as JSON.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook: url is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.URL
+	}
+	wh := &Webhook{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{},
+	}
+	if strings.TrimSpace(cfg.Template) != "" {
+		t, err := template.New(cfg.Name).Funcs(templateFuncs).Parse(cfg.Template)
+		if err != nil {
+			return nil, errors.Wrapf(err, "webhook: %s: template", cfg.Name)
+		}
+		wh.tmpl = t
+	}
+	return wh, nil
+}
+
+// Name of the webhook
+func (wh *Webhook) Name() string {
+	return wh.name
+}
+
+// Body returns what will be posted for a payload
+func (wh *Webhook) Body(p Payload) ([]byte, error) {
+	if wh.tmpl == nil {
+		return json.Marshal(p)
+	}
+	var buf bytes.Buffer
+	err := wh.tmpl.Execute(&buf, p)
+	if err != nil {
+		return nil, errors.Wrap(err, "template.execute")
+	}
+	return buf.Bytes(), nil
+}
+
+// Send posts the payload.  Any non-2xx response is an error.
+func (wh *Webhook) Send(ctx context.Context, p Payload) error {
+	body, err := wh.Body(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "http.newrequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "isitsql")
+	for k, v := range wh.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "client.do")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+// Package otlp pushes metrics to an OpenTelemetry collector.
+// It uses OTLP/HTTP with the JSON encoding so it doesn't need the
+// OpenTelemetry SDK.  Points are held in memory and sent every interval.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/failure"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPoints is how many points we hold if the collector can't be reached
+const maxPoints = 50000
+
+// Config is the [otlp] section of isitsql.toml
+type Config struct {
+	Endpoint   string            `toml:"endpoint"`
+	Headers    map[string]string `toml:"headers"`
+	Interval   string            `toml:"interval"`
+	Timeout    string            `toml:"timeout"`
+	Attributes map[string]string `toml:"resource_attributes"`
+}
+
+// Point is one value of a gauge
+type Point struct {
+	Name       string
+	Unit       string
+	TS         time.Time
+	Attributes map[string]string
+	Value      float64
+}
+
+// Exporter holds points and pushes them to the collector
+type Exporter struct {
+	mu       sync.Mutex
+	url      string
+	headers  map[string]string
+	interval time.Duration
+	timeout  time.Duration
+	resource map[string]string
+	points   []Point
+	dropped  int
+	lastErr  string
+	client   *http.Client
+}
+
+// New returns an Exporter.  An endpoint without a path has /v1/metrics added.
+// The default interval is one minute.  service.name defaults to isitsql.
+func New(cfg Config, version string) (*Exporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("otlp: endpoint is required")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "otlp: endpoint")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("otlp: endpoint must be http or https: '%s'", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	e := &Exporter{
+		url:      u.String(),
+		headers:  cfg.Headers,
+		interval: time.Minute,
+		timeout:  10 * time.Second,
+		resource: map[string]string{"service.name": "isitsql"},
+		points:   make([]Point, 0),
+		client:   &http.Client{},
+	}
+	if version != "" {
+		e.resource["service.version"] = version
+	}
+	for k, v := range cfg.Attributes {
+		e.resource[k] = v
+	}
+	if cfg.Interval != "" {
+		e.interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, errors.Wrap(err, "otlp: interval")
+		}
+		if e.interval < time.Second {
+			return nil, fmt.Errorf("otlp: interval must be at least 1s: '%s'", cfg.Interval)
+		}
+	}
+	if cfg.Timeout != "" {
+		e.timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "otlp: timeout")
+		}
+	}
+	return e, nil
+}
+
+// URL that metrics are posted to
+func (e *Exporter) URL() string {
+	return e.url
+}
+
+// Interval between pushes
+func (e *Exporter) Interval() time.Duration {
+	return e.interval
+}
+
+// Record holds points until the next push.  If too many points
+// are held, the oldest are dropped.
+func (e *Exporter) Record(points ...Point) {
+	if e == nil || len(points) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.points = append(e.points, points...)
+	e.trim()
+}
+
+// trim drops the oldest points over the limit.  The caller must hold the lock.
+func (e *Exporter) trim() {
+	if over := len(e.points) - maxPoints; over > 0 {
+		e.points = append([]Point{}, e.points[over:]...)
+		e.dropped += over
+	}
+}
+
+// Pending returns the number of points waiting to be sent
+func (e *Exporter) Pending() int {
+	if e == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.points)
+}
+
+// Run pushes the held points every interval until the context is cancelled.
+// It should be called in a GO routine.
+func (e *Exporter) Run(ctx context.Context) {
+	defer failure.HandlePanic()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sendCtx, cancel := context.WithTimeout(ctx, e.timeout)
+			_, err := e.Flush(sendCtx)
+			cancel()
+			e.logState(err)
+		}
+	}
+}
+
+// logState only logs when the error changes so a down collector doesn't flood the log
+func (e *Exporter) logState(err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	e.mu.Lock()
+	changed := msg != e.lastErr
+	e.lastErr = msg
+	dropped := e.dropped
+	e.dropped = 0
+	e.mu.Unlock()
+	if dropped > 0 {
+		logrus.Errorf("otlp: dropped %d point(s)", dropped)
+	}
+	if !changed {
+		return
+	}
+	if err != nil {
+		logrus.Error(fmt.Errorf("otlp: %s: %w", e.url, err))
+		return
+	}
+	logrus.Infof("otlp: %s: sending", e.url)
+}
+
+// Flush sends the held points.  If the send fails, they are
+// kept for the next try.  It returns the number of points sent.
+func (e *Exporter) Flush(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	points := e.points
+	e.points = make([]Point, 0)
+	e.mu.Unlock()
+	if len(points) == 0 {
+		return 0, nil
+	}
+	err := e.send(ctx, points)
+	if err != nil {
+		// put them back ahead of anything recorded while we were sending
+		e.mu.Lock()
+		e.points = append(points, e.points...)
+		e.trim()
+		e.mu.Unlock()
+		return 0, err
+	}
+	return len(points), nil
+}
+
+func (e *Exporter) send(ctx context.Context, points []Point) error {
+	body, err := e.Body(points)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "http.newrequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "isitsql")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "client.do")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http status: %s", resp.Status)
+	}
+	return nil
+}
+
+// Body returns the export request for the points.
+// Points are grouped into one gauge per metric name.
+func (e *Exporter) Body(points []Point) ([]byte, error) {
+	metrics := make(map[string]*metric)
+	names := make([]string, 0)
+	for _, p := range points {
+		m, ok := metrics[p.Name]
+		if !ok {
+			m = &metric{Name: p.Name, Unit: p.Unit}
+			metrics[p.Name] = m
+			names = append(names, p.Name)
+		}
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, dataPoint{
+			Attributes:   keyValues(p.Attributes),
+			TimeUnixNano: strconv.FormatInt(p.TS.UnixNano(), 10),
+			AsDouble:     p.Value,
+		})
+	}
+	sort.Strings(names)
+	sm := scopeMetrics{
+		Scope:   scope{Name: "isitsql", Version: e.resource["service.version"]},
+		Metrics: make([]metric, 0, len(names)),
+	}
+	for _, name := range names {
+		sm.Metrics = append(sm.Metrics, *metrics[name])
+	}
+	req := exportRequest{
+		ResourceMetrics: []resourceMetrics{{
+			Resource:     resource{Attributes: keyValues(e.resource)},
+			ScopeMetrics: []scopeMetrics{sm},
+		}},
+	}
+	bb, err := json.Marshal(req)
+	return bb, errors.Wrap(err, "json.marshal")
+}
+
+// keyValues converts a map to sorted OTLP attributes
+func keyValues(m map[string]string) []keyValue {
+	kv := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		kv = append(kv, keyValue{Key: k, Value: anyValue{StringValue: v}})
+	}
+	sort.Slice(kv, func(i, j int) bool { return kv[i].Key < kv[j].Key })
+	return kv
+}
+
+// These are the parts of ExportMetricsServiceRequest that we use
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+
+type exportRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type metric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit,omitempty"`
+	Gauge gauge  `json:"gauge"`
+}
+
+type gauge struct {
+	DataPoints []dataPoint `json:"dataPoints"`
+}
+
+type dataPoint struct {
+	Attributes   []keyValue `json:"attributes,omitempty"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	AsDouble     float64    `json:"asDouble"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
@@ -0,0 +1,177 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a stand-in for an OTLP collector
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	bodies   []exportRequest
+	headers  []http.Header
+	paths    []string
+	srv      *httptest.Server
+	requests int
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{status: http.StatusOK}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		if r.status != http.StatusOK {
+			w.WriteHeader(r.status)
+			return
+		}
+		bb, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		var er exportRequest
+		require.NoError(t, json.Unmarshal(bb, &er))
+		r.bodies = append(r.bodies, er)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.paths = append(r.paths, req.URL.Path)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func attr(kv []keyValue, key string) string {
+	for _, a := range kv {
+		if a.Key == key {
+			return a.Value.StringValue
+		}
+	}
+	return ""
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	e, err := New(Config{Endpoint: "http://localhost:4318"}, "")
+	require.NoError(t, err)
+	assert.Equal("http://localhost:4318/v1/metrics", e.URL())
+	assert.Equal(time.Minute, e.Interval())
+
+	e, err = New(Config{Endpoint: "https://otel.example.com/custom/path", Interval: "15s"}, "")
+	require.NoError(t, err)
+	assert.Equal("https://otel.example.com/custom/path", e.URL())
+	assert.Equal(15*time.Second, e.Interval())
+
+	bad := []Config{
+		{},
+		{Endpoint: "localhost:4318"},
+		{Endpoint: "http://localhost:4318", Interval: "soon"},
+		{Endpoint: "http://localhost:4318", Interval: "10ms"},
+		{Endpoint: "http://localhost:4318", Timeout: "soon"},
+	}
+	for _, cfg := range bad {
+		_, err = New(cfg, "")
+		assert.Error(err, cfg.Endpoint)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiver(t)
+	e, err := New(Config{
+		Endpoint:   r.srv.URL,
+		Headers:    map[string]string{"Authorization": "Bearer abc"},
+		Attributes: map[string]string{"deployment.environment": "prod"},
+	}, "2.5.1")
+	require.NoError(t, err)
+
+	ts := time.Unix(1700000000, 0)
+	server := map[string]string{"server_key": "srv1"}
+	e.Record(
+		Point{Name: "mssql.cpu_sql_pct", Unit: "%", TS: ts, Attributes: server, Value: 40},
+		Point{Name: "mssql.page_life_expectancy", Unit: "s", TS: ts, Attributes: server, Value: 300},
+		Point{Name: "mssql.cpu_sql_pct", Unit: "%", TS: ts.Add(time.Minute), Attributes: server, Value: 45},
+	)
+	assert.Equal(3, e.Pending())
+
+	n, err := e.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(3, n)
+	assert.Equal(0, e.Pending())
+
+	require.Len(t, r.bodies, 1)
+	assert.Equal("/v1/metrics", r.paths[0])
+	assert.Equal("Bearer abc", r.headers[0].Get("Authorization"))
+	assert.Equal("application/json", r.headers[0].Get("Content-Type"))
+
+	rm := r.bodies[0].ResourceMetrics
+	require.Len(t, rm, 1)
+	assert.Equal("isitsql", attr(rm[0].Resource.Attributes, "service.name"))
+	assert.Equal("2.5.1", attr(rm[0].Resource.Attributes, "service.version"))
+	assert.Equal("prod", attr(rm[0].Resource.Attributes, "deployment.environment"))
+
+	metrics := rm[0].ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 2)
+	assert.Equal("mssql.cpu_sql_pct", metrics[0].Name)
+	assert.Equal("%", metrics[0].Unit)
+	require.Len(t, metrics[0].Gauge.DataPoints, 2)
+	dp := metrics[0].Gauge.DataPoints[0]
+	assert.Equal("1700000000000000000", dp.TimeUnixNano)
+	assert.Equal(40.0, dp.AsDouble)
+	assert.Equal("srv1", attr(dp.Attributes, "server_key"))
+	assert.Equal("mssql.page_life_expectancy", metrics[1].Name)
+
+	// nothing held, nothing sent
+	n, err = e.Flush(context.Background())
+	assert.NoError(err)
+	assert.Equal(0, n)
+	assert.Equal(1, r.requests)
+}
+
+func TestFlushFailure(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiver(t)
+	r.status = http.StatusServiceUnavailable
+	e, err := New(Config{Endpoint: r.srv.URL}, "")
+	require.NoError(t, err)
+
+	e.Record(Point{Name: "a", TS: time.Unix(1, 0), Value: 1})
+	_, err = e.Flush(context.Background())
+	assert.Error(err)
+	assert.Equal(1, e.Pending())
+
+	// the held points go first when it comes back
+	e.Record(Point{Name: "b", TS: time.Unix(2, 0), Value: 2})
+	r.mu.Lock()
+	r.status = http.StatusOK
+	r.mu.Unlock()
+	n, err := e.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(2, n)
+	require.Len(t, r.bodies, 1)
+	assert.Equal("a", r.bodies[0].ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Name)
+}
+
+func TestRecordLimit(t *testing.T) {
+	assert := assert.New(t)
+	e, err := New(Config{Endpoint: "http://localhost:4318"}, "")
+	require.NoError(t, err)
+	points := make([]Point, maxPoints+10)
+	for i := range points {
+		points[i] = Point{Name: "a", Value: float64(i)}
+	}
+	e.Record(points...)
+	assert.Equal(maxPoints, e.Pending())
+	assert.Equal(10.0, e.points[0].Value)
+	assert.Equal(10, e.dropped)
+
+	var nilExporter *Exporter
+	nilExporter.Record(Point{Name: "a"})
+	assert.Equal(0, nilExporter.Pending())
+}
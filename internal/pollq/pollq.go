@@ -0,0 +1,377 @@
+/*
+Package pollq schedules the polls of many targets on an optional number of workers.
+
+Each target is due on an interval.  When it is due it waits in a queue
+until a worker is free.  With no limit, each poll starts when it is due.  The queue puts the targets that started a poll
+the longest time ago first so a slow or overloaded scheduler spreads the
+delay across every target.  A target is never queued or polled twice at
+the same time.
+*/
+package pollq
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Tick is how often the scheduler looks for targets that are due
+var Tick = 100 * time.Millisecond
+
+// RunFunc polls a target.  It returns how long until the next poll.
+// Zero or less keeps the target on its interval.
+type RunFunc func(key string) time.Duration
+
+// Scheduler runs a RunFunc for each target on a bounded or unlimited number of workers
+type Scheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	workers  int // zero is no limit
+	interval time.Duration
+	run      RunFunc
+	started  bool
+	targets  map[string]*target
+	ready    readyQueue
+	seq      uint64
+}
+
+type target struct {
+	key       string
+	due       time.Time
+	queued    bool
+	running   bool
+	enqueued  time.Time
+	lastStart time.Time
+	lastEnd   time.Time
+	wait      time.Duration
+	polls     int64
+	seq       uint64
+	index     int // in the ready queue
+}
+
+// New returns a Scheduler.  Workers less than one is no limit.
+func New(workers int, interval time.Duration, run RunFunc) *Scheduler {
+	if workers < 0 {
+		workers = 0
+	}
+	s := &Scheduler{
+		workers:  workers,
+		interval: interval,
+		run:      run,
+		targets:  make(map[string]*target),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// SetWorkers sets how many polls can run at once.  It only
+// applies before Start.  Less than one is no limit.
+func (s *Scheduler) SetWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.workers = n
+	}
+}
+
+// Workers returns how many polls can run at once.  Zero is no limit.
+func (s *Scheduler) Workers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers
+}
+
+// Interval returns how often each target is polled
+func (s *Scheduler) Interval() time.Duration {
+	return s.interval
+}
+
+// Start launches the workers.  They stop when ctx is done.
+// Targets added before Start are polled once it runs.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	workers := s.workers
+	s.mu.Unlock()
+
+	if workers == 0 {
+		go s.launcher(ctx)
+	}
+	for i := 0; i < workers; i++ {
+		go s.worker(ctx)
+	}
+	go s.dispatch(ctx)
+}
+
+// Add schedules a target to poll now.  A target that already
+// exists keeps its schedule.
+func (s *Scheduler) Add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.targets[key]; ok {
+		return
+	}
+	s.targets[key] = &target{key: key, due: time.Now(), index: -1}
+}
+
+// Remove stops polling a target.  A poll that is running finishes.
+func (s *Scheduler) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.targets[key]
+	if !ok {
+		return
+	}
+	if t.queued {
+		heap.Remove(&s.ready, t.index)
+		t.queued = false
+	}
+	delete(s.targets, key)
+}
+
+// Trigger queues a target to poll now even if it isn't due.  It returns
+// false if the target doesn't exist or is already queued or polling.
+func (s *Scheduler) Trigger(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.targets[key]
+	if !ok || t.queued || t.running {
+		return false
+	}
+	now := time.Now()
+	t.due = now
+	s.seq++
+	t.seq = s.seq
+	t.queued = true
+	t.enqueued = now
+	heap.Push(&s.ready, t)
+	s.cond.Broadcast()
+	return true
+}
+
+// dispatch queues the targets that are due
+func (s *Scheduler) dispatch(ctx context.Context) {
+	ticker := time.NewTicker(Tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		case <-ticker.C:
+			s.enqueueDue(time.Now())
+		}
+	}
+}
+
+func (s *Scheduler) enqueueDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, t := range s.targets {
+		if t.queued || t.running || t.due.After(now) {
+			continue
+		}
+		s.seq++
+		t.seq = s.seq
+		t.queued = true
+		t.enqueued = now
+		heap.Push(&s.ready, t)
+		n++
+	}
+	if n > 0 {
+		s.cond.Broadcast()
+	}
+}
+
+func (s *Scheduler) worker(ctx context.Context) {
+	for {
+		t := s.next(ctx)
+		if t == nil {
+			return
+		}
+		s.poll(t)
+	}
+}
+
+// launcher starts each poll as it is queued when there is no worker limit
+func (s *Scheduler) launcher(ctx context.Context) {
+	for {
+		t := s.next(ctx)
+		if t == nil {
+			return
+		}
+		go s.poll(t)
+	}
+}
+
+// next waits for a queued target and marks it running.
+// It returns nil once ctx is done.
+func (s *Scheduler) next(ctx context.Context) *target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.ready) == 0 && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	t := heap.Pop(&s.ready).(*target)
+	start := time.Now()
+	t.queued = false
+	t.running = true
+	t.wait = start.Sub(t.enqueued)
+	t.lastStart = start
+	return t
+}
+
+// poll runs a target and schedules its next poll
+func (s *Scheduler) poll(t *target) {
+	next := s.run(t.key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := time.Now()
+	t.running = false
+	t.lastEnd = end
+	t.polls++
+	if next > 0 {
+		t.due = end.Add(next)
+	} else {
+		// stay on the interval and skip the polls that were missed
+		t.due = t.due.Add(s.interval)
+		for !t.due.After(t.lastStart) {
+			t.due = t.due.Add(s.interval)
+		}
+	}
+}
+
+// Stats is how polling is going for a target
+type Stats struct {
+	Key       string    `json:"key"`
+	Queued    bool      `json:"queued"`
+	Running   bool      `json:"running"`
+	Due       time.Time `json:"due"`
+	LastStart time.Time `json:"last_start"`
+	LastEnd   time.Time `json:"last_end"`
+	Polls     int64     `json:"polls"`
+	// Wait is how long the last poll waited for a worker.
+	// If the target is queued, it is the wait so far.
+	Wait time.Duration `json:"wait"`
+	// Lag is how far past due a target is that isn't running
+	Lag time.Duration `json:"lag"`
+}
+
+func (t *target) stats(now time.Time) Stats {
+	st := Stats{
+		Key:       t.key,
+		Queued:    t.queued,
+		Running:   t.running,
+		Due:       t.due,
+		LastStart: t.lastStart,
+		LastEnd:   t.lastEnd,
+		Polls:     t.polls,
+		Wait:      t.wait,
+	}
+	if t.queued {
+		st.Wait = now.Sub(t.enqueued)
+	}
+	if !t.running && now.After(t.due) {
+		st.Lag = now.Sub(t.due)
+	}
+	return st
+}
+
+// Stats returns how polling is going for a target
+func (s *Scheduler) Stats(key string) (Stats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.targets[key]
+	if !ok {
+		return Stats{}, false
+	}
+	return t.stats(time.Now()), true
+}
+
+// Summary is how polling is going for all the targets
+type Summary struct {
+	Workers  int           `json:"workers"`
+	Interval time.Duration `json:"interval"`
+	Targets  int           `json:"targets"`
+	Running  int           `json:"running"`
+	Queued   int           `json:"queued"`
+	MaxWait  time.Duration `json:"max_wait"`
+	MaxLag   time.Duration `json:"max_lag"`
+}
+
+// Summary returns how polling is going for all the targets
+func (s *Scheduler) Summary() Summary {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := Summary{
+		Workers:  s.workers,
+		Interval: s.interval,
+		Targets:  len(s.targets),
+		Queued:   len(s.ready),
+	}
+	for _, t := range s.targets {
+		st := t.stats(now)
+		if st.Running {
+			sum.Running++
+		}
+		if st.Wait > sum.MaxWait {
+			sum.MaxWait = st.Wait
+		}
+		if st.Lag > sum.MaxLag {
+			sum.MaxLag = st.Lag
+		}
+	}
+	return sum
+}
+
+// readyQueue puts the target that started a poll the longest time ago
+// first.  Targets that haven't polled come before all of them.  Ties
+// go in the order they were queued.
+type readyQueue []*target
+
+func (q readyQueue) Len() int { return len(q) }
+
+func (q readyQueue) Less(i, j int) bool {
+	if !q[i].lastStart.Equal(q[j].lastStart) {
+		return q[i].lastStart.Before(q[j].lastStart)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q readyQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *readyQueue) Push(x any) {
+	t := x.(*target)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *readyQueue) Pop() any {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*q = old[:n-1]
+	return t
+}
@@ -0,0 +1,187 @@
+package pollq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	Tick = 5 * time.Millisecond
+}
+
+func TestWorkerLimit(t *testing.T) {
+	assert := assert.New(t)
+	var running, most, polls int32
+	s := New(3, time.Hour, func(key string) time.Duration {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&polls, 1)
+		return 0
+	})
+	for i := 0; i < 12; i++ {
+		s.Add(fmt.Sprintf("server%02d", i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	assert.Eventually(func() bool { return atomic.LoadInt32(&polls) == 12 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(int32(3), atomic.LoadInt32(&most))
+	sum := s.Summary()
+	assert.Equal(3, sum.Workers)
+	assert.Equal(12, sum.Targets)
+	assert.Equal(0, sum.Queued)
+	assert.True(sum.MaxWait > 0)
+}
+
+func TestNoWorkerLimit(t *testing.T) {
+	assert := assert.New(t)
+	var running, most, polls int32
+	s := New(0, time.Hour, func(key string) time.Duration {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&polls, 1)
+		return 0
+	})
+	for i := 0; i < 12; i++ {
+		s.Add(fmt.Sprintf("server%02d", i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	// every server polls at once
+	assert.Eventually(func() bool { return atomic.LoadInt32(&polls) == 12 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(int32(12), atomic.LoadInt32(&most))
+	assert.Equal(0, s.Summary().Workers)
+}
+
+func TestPriority(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	order := make([]string, 0)
+	s := New(1, time.Hour, func(key string) time.Duration {
+		mu.Lock()
+		order = append(order, key)
+		mu.Unlock()
+		return 0
+	})
+
+	// "old" polled longer ago than "recent" and "new" never polled
+	now := time.Now()
+	s.Add("recent")
+	s.Add("old")
+	s.Add("new")
+	s.targets["recent"].lastStart = now.Add(-10 * time.Second)
+	s.targets["old"].lastStart = now.Add(-time.Minute)
+	s.enqueueDue(now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 3
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal([]string{"new", "old", "recent"}, order)
+}
+
+func TestScheduleAndRemove(t *testing.T) {
+	assert := assert.New(t)
+	var a, b int32
+	s := New(2, 20*time.Millisecond, func(key string) time.Duration {
+		if key == "a" {
+			atomic.AddInt32(&a, 1)
+			return 0
+		}
+		atomic.AddInt32(&b, 1)
+		return time.Hour
+	})
+	s.Add("a")
+	s.Add("b")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	assert.Eventually(func() bool { return atomic.LoadInt32(&a) >= 3 }, 2*time.Second, 5*time.Millisecond)
+	// b asked to wait an hour
+	assert.Equal(int32(1), atomic.LoadInt32(&b))
+	st, ok := s.Stats("b")
+	assert.True(ok)
+	assert.True(time.Until(st.Due) > 50*time.Minute)
+	assert.Equal(time.Duration(0), st.Lag)
+
+	s.Remove("a")
+	_, ok = s.Stats("a")
+	assert.False(ok)
+	time.Sleep(10 * time.Millisecond) // let a running poll finish
+	count := atomic.LoadInt32(&a)
+	time.Sleep(80 * time.Millisecond)
+	assert.Equal(count, atomic.LoadInt32(&a))
+}
+
+func TestStopAndLag(t *testing.T) {
+	assert := assert.New(t)
+	s := New(1, time.Hour, func(key string) time.Duration { return 0 })
+	s.Add("a")
+	s.enqueueDue(time.Now())
+	time.Sleep(10 * time.Millisecond)
+	st, ok := s.Stats("a")
+	assert.True(ok)
+	assert.True(st.Queued)
+	assert.True(st.Wait > 0)
+	assert.True(st.Lag > 0)
+
+	s.Remove("a")
+	assert.Equal(0, s.Summary().Queued)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	cancel()
+	s.SetWorkers(5) // ignored after Start
+	assert.Equal(1, s.Workers())
+}
+
+func TestTrigger(t *testing.T) {
+	assert := assert.New(t)
+	var polls int32
+	s := New(1, time.Hour, func(key string) time.Duration {
+		atomic.AddInt32(&polls, 1)
+		return time.Hour
+	})
+	assert.False(s.Trigger("a"))
+	s.Add("a")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	assert.Eventually(func() bool { return atomic.LoadInt32(&polls) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Eventually(func() bool {
+		st, _ := s.Stats("a")
+		return !st.Running
+	}, time.Second, 5*time.Millisecond)
+
+	// the next poll is an hour away
+	assert.True(s.Trigger("a"))
+	assert.Eventually(func() bool { return atomic.LoadInt32(&polls) == 2 }, time.Second, 5*time.Millisecond)
+}
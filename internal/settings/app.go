@@ -6,13 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
 
 	"github.com/billgraziano/dpapi"
-	"github.com/kardianos/osext"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
+	"github.com/scalesql/isitsql/internal/store"
 	"github.com/sirupsen/logrus"
 )
 
@@ -64,27 +63,20 @@ func (a *AppConfig) Save() error {
 		return errors.Wrap(err, "validate")
 	}
 
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		return errors.Wrap(err, "executableFolder")
-	}
-
-	file := filepath.Join(wd, "config", "settings.json")
-
 	b, err := json.MarshalIndent(a, "", "\t")
 	if err != nil {
 		return errors.Wrap(err, "marshal")
 	}
 
-	err = os.WriteFile(file, b, 0600)
+	err = writeSetting(keySettings, b)
 	if err != nil {
-		return errors.Wrap(err, "writefile")
+		return errors.Wrap(err, "writesetting")
 	}
 
 	return nil
 }
 
-// ReadConfig returns the configuration setting from the file
+// ReadConfig returns the configuration settings from the store
 func ReadConfig() (AppConfig, error) {
 
 	var a AppConfig
@@ -101,15 +93,11 @@ func ReadConfig() (AppConfig, error) {
 	a.MetricHost = "metrics.isitsql.com"
 	a.HomePageURL = "/"
 
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		return a, errors.Wrap(err, "executableFolder")
-	}
+	// Read the settings
+	fileBody, err := readSetting(keySettings)
 
-	fileName := filepath.Join(wd, "config", "settings.json")
-
-	// if the file doesn't exist, then create it with defaults
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+	// if they don't exist, then create them with defaults
+	if errors.Is(err, store.ErrNotFound) {
 		// TODO set a default session key and encrypt it
 		key, err := newEncryptedSessionKey()
 		if err != nil {
@@ -119,12 +107,8 @@ func ReadConfig() (AppConfig, error) {
 		a.Save()
 		return a, nil
 	}
-
-	// Read the file
-	/* #nosec G304 */
-	fileBody, err := os.ReadFile(fileName)
 	if err != nil {
-		return a, errors.Wrap(err, "readfile")
+		return a, errors.Wrap(err, "readsetting")
 	}
 	//fmt.Println("fileBody len: ", len(fileBody))
 
@@ -2,13 +2,11 @@ package settings
 
 import (
 	"encoding/json"
-	"os"
-	"path/filepath"
 	"sync"
 
 	"github.com/billgraziano/dpapi"
-	"github.com/kardianos/osext"
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/store"
 )
 
 var mu sync.RWMutex
@@ -19,33 +17,25 @@ type Connections struct {
 	SQLServers     map[string]*SQLServer     `json:"servers"`
 }
 
-// ReadConnections returns the configuration setting from the file
+// ReadConnections returns the connections from the store
 func ReadConnections() (Connections, error) {
 	mu.RLock()
 	defer mu.RUnlock()
 
 	a := newConnections()
 
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		return a, errors.Wrap(err, "executableFolder")
-	}
+	fileBody, err := readSetting(keyConnections)
 
-	fileName := filepath.Join(wd, "config", "connections.json")
-
-	// if the file doesn't exist, then create it with defaults
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+	// if they don't exist, then create them with defaults
+	if errors.Is(err, store.ErrNotFound) {
 		err := a.save()
 		if err != nil {
 			return a, errors.Wrap(err, "save")
 		}
 		return a, nil
 	}
-
-	/* #nosec G304 */
-	fileBody, err := os.ReadFile(fileName)
 	if err != nil {
-		return a, errors.Wrap(err, "readfile")
+		return a, errors.Wrap(err, "readsetting")
 	}
 
 	err = json.Unmarshal(fileBody, &a)
@@ -107,21 +97,14 @@ func (a *Connections) Save() error {
 }
 
 func (a *Connections) save() error {
-	wd, err := osext.ExecutableFolder()
-	if err != nil {
-		return errors.Wrap(err, "executableFolder")
-	}
-
-	file := filepath.Join(wd, "config", "connections.json")
-
 	b, err := json.MarshalIndent(a, "", "\t")
 	if err != nil {
 		return errors.Wrap(err, "marshal")
 	}
 
-	err = os.WriteFile(file, b, 0600)
+	err = writeSetting(keyConnections, b)
 	if err != nil {
-		return errors.Wrap(err, "writefile")
+		return errors.Wrap(err, "writesetting")
 	}
 
 	return nil
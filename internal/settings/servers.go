@@ -23,6 +23,7 @@ type SQLServer struct {
 	CustomConnectionString string   `json:"connectionString,omitempty"`
 	IgnoreBackups          bool
 	IgnoreBackupsList      []string
+	Repository             *bool `json:"repository,omitempty"`
 }
 
 // Types of authorizations
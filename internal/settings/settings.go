@@ -166,3 +166,20 @@ func GetLocalIPs() ([]string, error) {
 	}
 	return ips, nil
 }
+
+// writeFile writes a file to a temporary name and renames it so
+// readers never see a partial file.  It is used for the config files
+// until the store is open.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	tmp := name + ".tmp"
+	err := os.WriteFile(tmp, data, perm)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/store"
 )
 
 func TestMain(m *testing.M) {
@@ -32,3 +34,96 @@ func printJSON() error {
 
 	return nil
 }
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "settings.json")
+	if err := writeFile(name, []byte("one"), 0600); err != nil {
+		t.Error("writeFile: ", err)
+	}
+	if err := writeFile(name, []byte("two"), 0600); err != nil {
+		t.Error("writeFile: ", err)
+	}
+	bb, err := os.ReadFile(name)
+	if err != nil {
+		t.Error("ReadFile: ", err)
+	}
+	if string(bb) != "two" {
+		t.Errorf("got %q; want %q", bb, "two")
+	}
+	if _, err = os.Stat(name + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the temporary file is still there")
+	}
+}
+
+func TestImportConfig(t *testing.T) {
+	dir := t.TempDir()
+	st := store.NewMemory()
+	if err := st.Put(store.BucketSettings, keyConnections, []byte("kept")); err != nil {
+		t.Fatal("Put: ", err)
+	}
+	for _, key := range []string{keySettings, keyConnections} {
+		if err := os.WriteFile(filepath.Join(dir, key+".json"), []byte(key), 0600); err != nil {
+			t.Fatal("WriteFile: ", err)
+		}
+	}
+
+	n, err := importConfig(st, dir)
+	if err != nil {
+		t.Fatal("importConfig: ", err)
+	}
+	if n != 1 {
+		t.Errorf("imported %d; want 1", n)
+	}
+	for key, want := range map[string]string{keySettings: "settings", keyConnections: "kept"} {
+		bb, err := st.Get(store.BucketSettings, key)
+		if err != nil {
+			t.Error("Get: ", err)
+		}
+		if string(bb) != want {
+			t.Errorf("%s: got %q; want %q", key, bb, want)
+		}
+		if _, err = os.Stat(filepath.Join(dir, key+".json")); !os.IsNotExist(err) {
+			t.Errorf("%s.json is still there", key)
+		}
+	}
+
+	// a second start finds nothing to import
+	n, err = importConfig(st, dir)
+	if err != nil {
+		t.Fatal("importConfig: ", err)
+	}
+	if n != 0 {
+		t.Errorf("imported %d; want 0", n)
+	}
+}
+
+func TestConnectionsInStore(t *testing.T) {
+	st := store.NewMemory()
+	storeMu.Lock()
+	cfgStore = st
+	storeMu.Unlock()
+	defer func() {
+		storeMu.Lock()
+		cfgStore = nil
+		storeMu.Unlock()
+	}()
+
+	a, err := ReadConnections()
+	if err != nil {
+		t.Fatal("ReadConnections: ", err)
+	}
+	a.SQLServers["one"] = &SQLServer{FQDN: "sql01"}
+	if err = a.Save(); err != nil {
+		t.Fatal("Save: ", err)
+	}
+	if _, err = st.Get(store.BucketSettings, keyConnections); err != nil {
+		t.Error("Get: ", err)
+	}
+	b, err := ReadConnections()
+	if err != nil {
+		t.Fatal("ReadConnections: ", err)
+	}
+	if len(b.SQLServers) != 1 || b.SQLServers["one"].FQDN != "sql01" {
+		t.Errorf("got %+v; want one server", b.SQLServers)
+	}
+}
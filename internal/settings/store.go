@@ -0,0 +1,118 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kardianos/osext"
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/store"
+)
+
+// Keys for the settings in store.BucketSettings.  Before the store
+// is set, each is kept in config/{key}.json.
+const (
+	keySettings    = "settings"
+	keyConnections = "connections"
+)
+
+var (
+	storeMu  sync.RWMutex
+	cfgStore store.Store
+)
+
+// UseStore keeps the settings and connections in st.  If config/settings.json
+// or config/connections.json is still there, it is moved into the store and
+// removed so this only happens once.  A value already in the store is kept.
+func UseStore(st store.Store) (int, error) {
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	wd, err := osext.ExecutableFolder()
+	if err != nil {
+		return 0, errors.Wrap(err, "osext.executablefolder")
+	}
+	n, err := importConfig(st, filepath.Join(wd, "config"))
+	if err != nil {
+		return n, errors.Wrap(err, "importconfig")
+	}
+	cfgStore = st
+	return n, nil
+}
+
+// importConfig moves settings.json and connections.json in dir into the store
+func importConfig(st store.Store, dir string) (int, error) {
+	var n int
+	for _, key := range []string{keySettings, keyConnections} {
+		file := filepath.Join(dir, key+".json")
+		/* #nosec G304 */
+		bb, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return n, errors.Wrap(err, "os.readfile")
+		}
+		_, err = st.Get(store.BucketSettings, key)
+		if errors.Is(err, store.ErrNotFound) {
+			err = st.Put(store.BucketSettings, key, bb)
+			if err != nil {
+				return n, errors.Wrapf(err, "put: %s", key)
+			}
+			n++
+		} else if err != nil {
+			return n, errors.Wrapf(err, "get: %s", key)
+		}
+		err = os.Remove(file)
+		if err != nil {
+			return n, errors.Wrap(err, "os.remove")
+		}
+	}
+	return n, nil
+}
+
+// readSetting returns the value for the key or store.ErrNotFound
+func readSetting(key string) ([]byte, error) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	if cfgStore != nil {
+		return cfgStore.Get(store.BucketSettings, key)
+	}
+	file, err := configFile(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "configfile")
+	}
+	/* #nosec G304 */
+	bb, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, store.ErrNotFound
+	}
+	return bb, err
+}
+
+// writeSetting saves the value for the key
+func writeSetting(key string, data []byte) error {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	if cfgStore != nil {
+		return cfgStore.Put(store.BucketSettings, key, data)
+	}
+	file, err := configFile(key)
+	if err != nil {
+		return errors.Wrap(err, "configfile")
+	}
+	return writeFile(file, data, 0600)
+}
+
+func configFile(key string) (string, error) {
+	wd, err := osext.ExecutableFolder()
+	if err != nil {
+		return "", errors.Wrap(err, "osext.executablefolder")
+	}
+	return filepath.Join(wd, "config", key+".json"), nil
+}
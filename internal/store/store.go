@@ -0,0 +1,223 @@
+/*
+Package store keeps the state IsItSQL saves between restarts in one
+embedded key-value file.  Each write is a transaction so a crash can't
+leave a partially written value behind.
+*/
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// FileName is the name of the store in the cache folder
+const FileName = "isitsql.db"
+
+// Buckets used by IsItSQL
+const (
+	BucketServers  = "servers"  // the cached SqlServer for each key
+	BucketRings    = "rings"    // the CPU, metric, and wait rings for each key
+	BucketSettings = "settings" // settings and connections from the config folder
+)
+
+// ErrNotFound is returned by Get for a missing key
+var ErrNotFound = errors.New("not found")
+
+// Store is a small key-value store.  Values are usually JSON.
+type Store interface {
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte) error
+	Delete(bucket, key string) error
+	Keys(bucket string) ([]string, error)
+	Close() error
+}
+
+// Bolt is a Store in a bbolt file
+type Bolt struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the store.  It fails if another process has it open.
+func Open(path string) (*Bolt, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.mkdirall")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "bolt.open")
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Get returns a copy of the value or ErrNotFound
+func (b *Bolt) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return ErrNotFound
+		}
+		v := bkt.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// Put writes the value and creates the bucket if needed
+func (b *Bolt) Put(bucket, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return errors.Wrap(err, "createbucket")
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+// Delete removes the key.  A missing key isn't an error.
+func (b *Bolt) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+// Keys returns the keys in a bucket in order
+func (b *Bolt) Keys(bucket string) ([]string, error) {
+	keys := make([]string, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Close closes the file
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// Memory is a Store that isn't saved.  It is used when the file can't be opened.
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory returns an empty Memory store
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+// Get returns a copy of the value or ErrNotFound
+func (m *Memory) Get(bucket, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+// Put writes a copy of the value
+func (m *Memory) Put(bucket, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string][]byte)
+	}
+	m.buckets[bucket][key] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes the key
+func (m *Memory) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+// Keys returns the keys in a bucket in order
+func (m *Memory) Keys(bucket string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	for k := range m.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Close does nothing
+func (m *Memory) Close() error { return nil }
+
+// Import is a set of files to move into a bucket.  The key is the
+// part of the file name between Prefix and Suffix.
+type Import struct {
+	Bucket string
+	Prefix string // server.
+	Suffix string // .json
+}
+
+// Migrate moves matching files in dir into the store.  Each file is
+// removed after it is written so this only happens once.  Keys that
+// are already in the store are kept and the file is removed.
+func Migrate(st Store, dir string, imports ...Import) (int, error) {
+	var n int
+	for _, imp := range imports {
+		files, err := filepath.Glob(filepath.Join(dir, imp.Prefix+"*"+imp.Suffix))
+		if err != nil {
+			return n, errors.Wrap(err, "filepath.glob")
+		}
+		for _, file := range files {
+			base := filepath.Base(file)
+			key := strings.TrimSuffix(strings.TrimPrefix(base, imp.Prefix), imp.Suffix)
+			if key == "" {
+				continue
+			}
+			_, err = st.Get(imp.Bucket, key)
+			if errors.Is(err, ErrNotFound) {
+				/* #nosec G304 */
+				bb, err := os.ReadFile(file)
+				if err != nil {
+					return n, errors.Wrap(err, "os.readfile")
+				}
+				err = st.Put(imp.Bucket, key, bb)
+				if err != nil {
+					return n, errors.Wrapf(err, "put: %s", base)
+				}
+				n++
+			} else if err != nil {
+				return n, errors.Wrapf(err, "get: %s", base)
+			}
+			err = os.Remove(file)
+			if err != nil {
+				return n, errors.Wrap(err, "os.remove")
+			}
+		}
+	}
+	return n, nil
+}
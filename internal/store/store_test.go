@@ -0,0 +1,93 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, st Store) {
+	assert := assert.New(t)
+	_, err := st.Get(BucketServers, "a")
+	assert.ErrorIs(err, ErrNotFound)
+
+	assert.NoError(st.Put(BucketServers, "b", []byte("2")))
+	assert.NoError(st.Put(BucketServers, "a", []byte("1")))
+	assert.NoError(st.Put(BucketRings, "a", []byte("x")))
+	v, err := st.Get(BucketServers, "a")
+	assert.NoError(err)
+	assert.Equal([]byte("1"), v)
+
+	keys, err := st.Keys(BucketServers)
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b"}, keys)
+
+	assert.NoError(st.Delete(BucketServers, "a"))
+	assert.NoError(st.Delete(BucketServers, "missing"))
+	assert.NoError(st.Delete("nobucket", "a"))
+	_, err = st.Get(BucketServers, "a")
+	assert.ErrorIs(err, ErrNotFound)
+	keys, err = st.Keys("nobucket")
+	assert.NoError(err)
+	assert.Empty(keys)
+}
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", FileName)
+	st, err := Open(path)
+	require.NoError(t, err)
+	testStore(t, st)
+	require.NoError(t, st.Close())
+
+	// values survive a reopen
+	st, err = Open(path)
+	require.NoError(t, err)
+	defer st.Close()
+	v, err := st.Get(BucketServers, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), v)
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+	write := func(name, body string) {
+		require.NoError(os.WriteFile(filepath.Join(dir, name), []byte(body), 0600))
+	}
+	write("server.a.json", `{"a":1}`)
+	write("server.b.json", `{"b":1}`)
+	write("rings.a.json", `{"r":1}`)
+	write("other.json", `{}`)
+
+	st := NewMemory()
+	require.NoError(st.Put(BucketServers, "b", []byte(`{"b":2}`)))
+	imports := []Import{
+		{Bucket: BucketServers, Prefix: "server.", Suffix: ".json"},
+		{Bucket: BucketRings, Prefix: "rings.", Suffix: ".json"},
+	}
+	n, err := Migrate(st, dir, imports...)
+	assert.NoError(err)
+	assert.Equal(2, n)
+
+	v, _ := st.Get(BucketServers, "a")
+	assert.Equal(`{"a":1}`, string(v))
+	v, _ = st.Get(BucketServers, "b")
+	assert.Equal(`{"b":2}`, string(v)) // the store wins
+	v, _ = st.Get(BucketRings, "a")
+	assert.Equal(`{"r":1}`, string(v))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal([]string{filepath.Join(dir, "other.json")}, files)
+
+	n, err = Migrate(st, dir, imports...)
+	assert.NoError(err)
+	assert.Zero(n)
+}
@@ -0,0 +1,369 @@
+// Package threshold evaluates user-defined rules over the metrics
+// that are already collected.  A rule only changes state after the
+// value has been over the threshold for the rule's duration.
+package threshold
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	"github.com/scalesql/isitsql/internal/tags"
+)
+
+// Metric names that rules can use
+const (
+	MetricCPU             = "cpu"
+	MetricCPUSQL          = "cpu_sql"
+	MetricCPUOther        = "cpu_other"
+	MetricPLE             = "ple"
+	MetricBatches         = "batches"
+	MetricReadLatency     = "read_latency"
+	MetricWriteLatency    = "write_latency"
+	MetricMemoryAvailable = "memory_available_pct"
+)
+
+// metricInfo describes each metric.  Low values are bad for some metrics.
+var metricInfo = map[string]struct {
+	Below bool
+	Unit  string
+}{
+	MetricCPU:             {Unit: "%"},
+	MetricCPUSQL:          {Unit: "%"},
+	MetricCPUOther:        {Unit: "%"},
+	MetricPLE:             {Below: true, Unit: "s"},
+	MetricBatches:         {Unit: "/s"},
+	MetricReadLatency:     {Unit: "ms"},
+	MetricWriteLatency:    {Unit: "ms"},
+	MetricMemoryAvailable: {Below: true, Unit: "%"},
+}
+
+// Metrics returns the metric names rules can use
+func Metrics() []string {
+	names := make([]string, 0, len(metricInfo))
+	for k := range metricInfo {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Level is the state of a rule for a server
+type Level int
+
+const (
+	LevelOK Level = iota
+	LevelWarning
+	LevelCritical
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelWarning:
+		return "warning"
+	case LevelCritical:
+		return "critical"
+	}
+	return "ok"
+}
+
+// Rule is a user-defined threshold
+type Rule struct {
+	Name     string   `toml:"name"`
+	Metric   string   `toml:"metric"`
+	Warning  *float64 `toml:"warning"`
+	Critical *float64 `toml:"critical"`
+	For      string   `toml:"for"`
+	Tags     []string `toml:"tags"`
+	Servers  []string `toml:"servers"`
+
+	duration time.Duration
+}
+
+// Validate checks the rule and normalizes the names
+func (r *Rule) Validate() error {
+	r.Metric = strings.ToLower(strings.TrimSpace(r.Metric))
+	if r.Name == "" {
+		r.Name = r.Metric
+	}
+	if _, ok := metricInfo[r.Metric]; !ok {
+		return fmt.Errorf("threshold: %s: invalid metric: '%s' (use %s)", r.Name, r.Metric, strings.Join(Metrics(), ", "))
+	}
+	if r.Warning == nil && r.Critical == nil {
+		return fmt.Errorf("threshold: %s: warning or critical is required", r.Name)
+	}
+	if r.For != "" {
+		d, err := time.ParseDuration(r.For)
+		if err != nil {
+			return errors.Wrapf(err, "threshold: %s: for", r.Name)
+		}
+		if d < 0 {
+			return fmt.Errorf("threshold: %s: for can't be negative", r.Name)
+		}
+		r.duration = d
+	}
+	r.Tags = tags.Merge(&r.Tags)
+	r.Servers = tags.Merge(&r.Servers)
+	return nil
+}
+
+// Duration the value must be over the threshold
+func (r Rule) Duration() time.Duration {
+	return r.duration
+}
+
+// Scope describes what the rule applies to
+func (r Rule) Scope() string {
+	switch {
+	case len(r.Servers) > 0:
+		return "servers: " + strings.Join(r.Servers, ", ")
+	case len(r.Tags) > 0:
+		return "tags: " + strings.Join(r.Tags, ", ")
+	}
+	return "global"
+}
+
+// specificity is how closely the rule targets a server.
+// It returns -1 if the rule doesn't apply.
+func (r Rule) specificity(key string, serverTags []string) int {
+	if len(r.Servers) > 0 {
+		for _, s := range r.Servers {
+			if strings.EqualFold(s, key) {
+				return 2
+			}
+		}
+		return -1
+	}
+	if len(r.Tags) > 0 {
+		for _, t := range r.Tags {
+			for _, st := range serverTags {
+				if strings.EqualFold(t, st) {
+					return 1
+				}
+			}
+		}
+		return -1
+	}
+	return 0
+}
+
+// level returns the level for a value without the duration
+func (r Rule) level(v float64) Level {
+	below := metricInfo[r.Metric].Below
+	over := func(limit *float64) bool {
+		if limit == nil {
+			return false
+		}
+		if below {
+			return v < *limit
+		}
+		return v > *limit
+	}
+	if over(r.Critical) {
+		return LevelCritical
+	}
+	if over(r.Warning) {
+		return LevelWarning
+	}
+	return LevelOK
+}
+
+// Result is the state of one rule for one server
+type Result struct {
+	Rule   string    `json:"rule"`
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	Level  Level     `json:"level"`
+	Since  time.Time `json:"since"`
+}
+
+// String describes the result
+func (r Result) String() string {
+	return fmt.Sprintf("%s: %s %s%s", r.Level, r.Rule, formatValue(r.Value), metricInfo[r.Metric].Unit)
+}
+
+func formatValue(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// breach tracks when a value first went over each threshold
+type breach struct {
+	warnSince time.Time
+	critSince time.Time
+	result    Result
+}
+
+// Evaluator holds the rules and the state for each server
+type Evaluator struct {
+	mu      sync.RWMutex
+	clock   clock.Clock
+	rules   []Rule
+	breach  map[string]map[string]*breach // server -> rule -> breach
+	results map[string][]Result           // server -> current non-OK results
+}
+
+// NewEvaluator returns an Evaluator with no rules
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		clock:   clock.New(),
+		rules:   make([]Rule, 0),
+		breach:  make(map[string]map[string]*breach),
+		results: make(map[string][]Result),
+	}
+}
+
+// SetRules replaces the rules and clears any state.  The rules should be validated.
+func (e *Evaluator) SetRules(rules []Rule) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append([]Rule{}, rules...)
+	e.breach = make(map[string]map[string]*breach)
+	e.results = make(map[string][]Result)
+}
+
+// Rules returns the configured rules
+func (e *Evaluator) Rules() []Rule {
+	if e == nil {
+		return []Rule{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Rule{}, e.rules...)
+}
+
+// applicable returns the most specific rule for each metric and server
+func (e *Evaluator) applicable(key string, serverTags []string) []Rule {
+	best := make(map[string]int)
+	picked := make(map[string][]Rule)
+	for _, r := range e.rules {
+		spec := r.specificity(key, serverTags)
+		if spec < 0 {
+			continue
+		}
+		current, ok := best[r.Metric]
+		if !ok || spec > current {
+			best[r.Metric] = spec
+			picked[r.Metric] = []Rule{r}
+		} else if spec == current {
+			picked[r.Metric] = append(picked[r.Metric], r)
+		}
+	}
+	rules := make([]Rule, 0)
+	for _, m := range Metrics() {
+		rules = append(rules, picked[m]...)
+	}
+	return rules
+}
+
+// Evaluate the rules for a server with its current values.
+// Metrics that are missing from values are skipped.
+// It returns the warning and critical results.
+func (e *Evaluator) Evaluate(key string, serverTags []string, values map[string]float64) []Result {
+	if e == nil {
+		return []Result{}
+	}
+	now := e.clock.Now()
+	key = strings.ToLower(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.breach[key]
+	if !ok {
+		state = make(map[string]*breach)
+		e.breach[key] = state
+	}
+	seen := make(map[string]bool)
+	results := make([]Result, 0)
+	for _, r := range e.applicable(key, serverTags) {
+		v, ok := values[r.Metric]
+		if !ok {
+			continue
+		}
+		seen[r.Name] = true
+		b, ok := state[r.Name]
+		if !ok {
+			b = &breach{}
+			state[r.Name] = b
+		}
+		lvl := r.level(v)
+		if lvl >= LevelWarning {
+			if b.warnSince.IsZero() {
+				b.warnSince = now
+			}
+		} else {
+			b.warnSince = time.Time{}
+		}
+		if lvl == LevelCritical {
+			if b.critSince.IsZero() {
+				b.critSince = now
+			}
+		} else {
+			b.critSince = time.Time{}
+		}
+
+		res := Result{Rule: r.Name, Metric: r.Metric, Value: v, Level: LevelOK}
+		switch {
+		case !b.critSince.IsZero() && now.Sub(b.critSince) >= r.duration:
+			res.Level = LevelCritical
+			res.Since = b.critSince
+		case !b.warnSince.IsZero() && now.Sub(b.warnSince) >= r.duration:
+			res.Level = LevelWarning
+			res.Since = b.warnSince
+		}
+		b.result = res
+		if res.Level > LevelOK {
+			results = append(results, res)
+		}
+	}
+	// drop state for rules that no longer apply
+	for name := range state {
+		if !seen[name] {
+			delete(state, name)
+		}
+	}
+	e.results[key] = results
+	return append([]Result{}, results...)
+}
+
+// Forget clears the state for a server.  Use it when a server can't be polled.
+func (e *Evaluator) Forget(key string) {
+	if e == nil {
+		return
+	}
+	key = strings.ToLower(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.breach, key)
+	delete(e.results, key)
+}
+
+// Results returns the warning and critical results for a server
+func (e *Evaluator) Results(key string) []Result {
+	if e == nil {
+		return []Result{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Result{}, e.results[strings.ToLower(key)]...)
+}
+
+// Level returns the highest level for a server
+func (e *Evaluator) Level(key string) Level {
+	lvl := LevelOK
+	for _, r := range e.Results(key) {
+		if r.Level > lvl {
+			lvl = r.Level
+		}
+	}
+	return lvl
+}
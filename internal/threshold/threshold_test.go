@@ -0,0 +1,126 @@
+package threshold
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func f(v float64) *float64 { return &v }
+
+func testEvaluator(t *testing.T, rules ...Rule) (*Evaluator, *clock.Mock) {
+	for i := range rules {
+		require.NoError(t, rules[i].Validate())
+	}
+	e := NewEvaluator()
+	clk := clock.NewMock()
+	e.clock = clk
+	e.SetRules(rules)
+	return e, clk
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	r := Rule{Metric: " CPU ", Warning: f(80), For: "5m", Tags: []string{"Prod"}}
+	assert.NoError(r.Validate())
+	assert.Equal("cpu", r.Name)
+	assert.Equal(5*time.Minute, r.Duration())
+	assert.Equal([]string{"prod"}, r.Tags)
+
+	bad := []Rule{
+		{Metric: "nope", Warning: f(1)},
+		{Metric: "cpu"},
+		{Metric: "cpu", Warning: f(1), For: "soon"},
+		{Metric: "cpu", Warning: f(1), For: "-1m"},
+	}
+	for _, r := range bad {
+		assert.Error(r.Validate(), r.Metric)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	assert := assert.New(t)
+	e, clk := testEvaluator(t, Rule{Name: "cpu-high", Metric: MetricCPU, Warning: f(80), Critical: f(95), For: "5m"})
+
+	// a single spike doesn't fire
+	assert.Len(e.Evaluate("srv1", nil, map[string]float64{"cpu": 99}), 0)
+	clk.Add(time.Minute)
+	assert.Len(e.Evaluate("srv1", nil, map[string]float64{"cpu": 10}), 0)
+
+	// sustained
+	start := clk.Now()
+	for i := 0; i < 5; i++ {
+		assert.Len(e.Evaluate("srv1", nil, map[string]float64{"cpu": 90}), 0)
+		clk.Add(time.Minute)
+	}
+	res := e.Evaluate("srv1", nil, map[string]float64{"cpu": 97})
+	require.Len(t, res, 1)
+	assert.Equal(LevelWarning, res[0].Level)
+	assert.Equal(start, res[0].Since)
+	assert.Equal(LevelWarning, e.Level("SRV1"))
+	assert.Equal("warning: cpu-high 97%", res[0].String())
+
+	clk.Add(5 * time.Minute)
+	res = e.Evaluate("srv1", nil, map[string]float64{"cpu": 97})
+	require.Len(t, res, 1)
+	assert.Equal(LevelCritical, res[0].Level)
+
+	// clears right away
+	assert.Len(e.Evaluate("srv1", nil, map[string]float64{"cpu": 50}), 0)
+	assert.Equal(LevelOK, e.Level("srv1"))
+}
+
+func TestBelow(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEvaluator(t, Rule{Metric: MetricPLE, Warning: f(300), Critical: f(60)})
+	res := e.Evaluate("srv1", nil, map[string]float64{"ple": 120})
+	assert.Len(res, 1)
+	assert.Equal(LevelWarning, res[0].Level)
+	res = e.Evaluate("srv1", nil, map[string]float64{"ple": 30})
+	assert.Equal(LevelCritical, res[0].Level)
+	assert.Len(e.Evaluate("srv1", nil, map[string]float64{"ple": 3000}), 0)
+}
+
+func TestScope(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEvaluator(t,
+		Rule{Name: "global", Metric: MetricCPU, Warning: f(50)},
+		Rule{Name: "prod", Metric: MetricCPU, Warning: f(70), Tags: []string{"prod"}},
+		Rule{Name: "big", Metric: MetricCPU, Warning: f(90), Servers: []string{"big1"}},
+		Rule{Name: "latency", Metric: MetricReadLatency, Critical: f(20), Tags: []string{"prod"}},
+	)
+	values := map[string]float64{"cpu": 80, "read_latency": 25}
+
+	res := e.Evaluate("dev1", []string{"dev"}, values)
+	assert.Len(res, 1)
+	assert.Equal("global", res[0].Rule)
+
+	res = e.Evaluate("prod1", []string{"prod"}, values)
+	assert.Len(res, 2)
+	assert.Equal("prod", res[0].Rule)
+	assert.Equal("latency", res[1].Rule)
+
+	// server rule wins over the tag rule
+	res = e.Evaluate("big1", []string{"prod"}, values)
+	assert.Len(res, 1)
+	assert.Equal("latency", res[0].Rule)
+
+	assert.Equal("tags: prod", e.Rules()[1].Scope())
+}
+
+func TestMissingValues(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := testEvaluator(t, Rule{Metric: MetricWriteLatency, Warning: f(10)})
+	assert.Len(e.Evaluate("srv1", nil, map[string]float64{}), 0)
+	e.Evaluate("srv1", nil, map[string]float64{"write_latency": 15})
+	assert.Len(e.Results("srv1"), 1)
+	e.Forget("srv1")
+	assert.Len(e.Results("srv1"), 0)
+
+	var nilEval *Evaluator
+	assert.Len(nilEval.Evaluate("srv1", nil, nil), 0)
+	assert.Equal(LevelOK, nilEval.Level("srv1"))
+}
@@ -0,0 +1,111 @@
+/*
+Package tier sizes the history kept by the CPU and metric rings.
+
+The first hour is kept at full resolution.  Points that age out of it
+are averaged into 1-minute points until MinuteHours and then into
+5-minute points until the end of the window.  Each tier is a fixed
+size ring so the memory per server is bounded.
+*/
+package tier
+
+import (
+	"time"
+)
+
+// MinuteHours is how far back the 1-minute tier reaches
+const MinuteHours = 6
+
+// MaxHours is the longest history window
+const MaxHours = 7 * 24
+
+// Tier periods
+const (
+	Minute     = time.Minute
+	FiveMinute = 5 * time.Minute
+)
+
+// Sizes are the number of points in the older tiers.  Zero turns a tier off.
+type Sizes struct {
+	Minute int
+	Five   int
+}
+
+// Default is used by rings as they are created.  Changing it only
+// affects rings created after it is changed.
+var Default Sizes
+
+// defaultHours is the window set by SetHours
+var defaultHours = 1
+
+// For returns the sizes for a history window in hours
+func For(hours int) Sizes {
+	hours = clamp(hours)
+	var s Sizes
+	if hours <= 1 {
+		return s
+	}
+	s.Minute = (min(hours, MinuteHours) - 1) * 60
+	if hours > MinuteHours {
+		s.Five = (hours - MinuteHours) * 12
+	}
+	return s
+}
+
+// SetHours sets the history window for new rings.  Zero keeps one hour.
+func SetHours(hours int) {
+	defaultHours = clamp(hours)
+	Default = For(defaultHours)
+}
+
+// Window returns the history window set by SetHours
+func Window() time.Duration {
+	return time.Duration(defaultHours) * time.Hour
+}
+
+func clamp(hours int) int {
+	if hours < 1 {
+		return 1
+	}
+	if hours > MaxHours {
+		return MaxHours
+	}
+	return hours
+}
+
+// Bucket collects points that fall in the same period
+type Bucket[T any] struct {
+	Period time.Duration
+	start  time.Time
+	points []T
+}
+
+// Add adds a point.  When the point starts a new period the points of
+// the previous period and its start time are returned to be averaged.
+func (b *Bucket[T]) Add(ts time.Time, v T) (time.Time, []T) {
+	start := ts.Truncate(b.Period)
+	var done []T
+	var doneStart time.Time
+	if len(b.points) > 0 && !start.Equal(b.start) {
+		done = b.points
+		doneStart = b.start
+		b.points = nil
+	}
+	b.start = start
+	b.points = append(b.points, v)
+	return doneStart, done
+}
+
+// Pending returns the points waiting for their period to end
+func (b *Bucket[T]) Pending() []T {
+	return b.points
+}
+
+// Span returns the time before now to chart.  An empty or invalid
+// span is one hour.  It is capped at the history window.
+func Span(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Hour
+	}
+	return min(d, max(Window(), time.Hour))
+}
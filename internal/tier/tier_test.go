@@ -0,0 +1,47 @@
+package tier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFor(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(Sizes{}, For(0))
+	assert.Equal(Sizes{}, For(1))
+	assert.Equal(Sizes{Minute: 60}, For(2))
+	assert.Equal(Sizes{Minute: 300}, For(MinuteHours))
+	assert.Equal(Sizes{Minute: 300, Five: 216}, For(24))
+	assert.Equal(For(MaxHours), For(MaxHours+100))
+}
+
+func TestSpan(t *testing.T) {
+	assert := assert.New(t)
+	defer SetHours(0)
+	SetHours(0)
+	assert.Equal(time.Hour, Span(""))
+	assert.Equal(time.Hour, Span("6h"))
+	assert.Equal(30*time.Minute, Span("30m"))
+	SetHours(24)
+	assert.Equal(24*time.Hour, Window())
+	assert.Equal(6*time.Hour, Span("6h"))
+	assert.Equal(24*time.Hour, Span("48h"))
+	assert.Equal(time.Hour, Span("bad"))
+}
+
+func TestBucket(t *testing.T) {
+	assert := assert.New(t)
+	b := Bucket[int]{Period: FiveMinute}
+	ts := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, done := b.Add(ts.Add(time.Duration(i)*time.Minute), i)
+		assert.Nil(done)
+	}
+	assert.Len(b.Pending(), 5)
+	start, done := b.Add(ts.Add(5*time.Minute), 5)
+	assert.Equal(ts, start)
+	assert.Equal([]int{0, 1, 2, 3, 4}, done)
+	assert.Equal([]int{5}, b.Pending())
+}
@@ -19,44 +19,8 @@ type WaitLine struct {
 	Payload Waits  `json:"payload"`
 }
 
-// WriteWaits appends a Waits struct to a file named for
-// the server key.  It rolls over files after 10 minutes.
-func WriteWaitFile(key string, ww Waits) error {
-	wl := WaitLine{
-		MapKey:  key,
-		Payload: ww,
-	}
-	start := time.Now()
-	bb, err := json.Marshal(wl)
-	if err != nil {
-		return errors.Wrap(err, "se.marshal")
-	}
-	exe, err := os.Executable()
-	if err != nil {
-		return errors.Wrap(err, "os.executable")
-	}
-	waitFileName := filepath.Join(filepath.Dir(exe), "cache", fmt.Sprintf("store.waits.%s.%s.ndjson", key, time.Now().Round(10*time.Minute).Format("20060102_150400")))
-	sf, err := os.OpenFile(waitFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return errors.Wrap(err, "os.openfile")
-	}
-	defer sf.Close()
-	_, err = sf.Write(bb)
-	if err != nil {
-		return errors.Wrap(err, "sf.write")
-	}
-	_, err = sf.WriteString("\n")
-	if err != nil {
-		return errors.Wrap(err, "sf.writestring")
-	}
-	if time.Since(start) > time.Duration(100*time.Millisecond) {
-		logrus.Debugf("waitmap: write: [%s] bytes=%d (%s)", key, len(bb), time.Since(start))
-	}
-
-	return nil
-}
-
-// ReadWaitFiles reads the wait files for a key and returns []Waits
+// ReadWaitFiles reads the wait files for a key and returns []Waits.
+// Older versions wrote these.  The waits are now saved with the other rings.
 func ReadWaitFiles(key string) ([]Waits, error) {
 	results := make([]Waits, 0, 240)
 	defer failure.HandlePanic()
@@ -109,8 +73,9 @@ func ReadWaitFiles(key string) ([]Waits, error) {
 	return results, nil
 }
 
-// PurgeWaitFiles purges wait files more than 85 minutes old
-func PurgeWaitFiles(key string) error {
+// PurgeWaitFiles purges wait files more than retain old.
+// Zero removes all of them.
+func PurgeWaitFiles(key string, retain time.Duration) error {
 	start := time.Now()
 	exe, err := os.Executable()
 	if err != nil {
@@ -135,7 +100,7 @@ func PurgeWaitFiles(key string) error {
 		return errors.Wrap(err, "filepath.glob")
 	}
 	//sort.Strings(files)
-	purgeThreshold := time.Now().Add(-85 * time.Minute)
+	purgeThreshold := time.Now().Add(-retain)
 	purged := 0
 	for _, name := range files {
 		fi, err := os.Stat(name)
@@ -146,7 +111,7 @@ func PurgeWaitFiles(key string) error {
 			return errors.Wrap(err, "os.stat")
 		}
 		ts := fi.ModTime()
-		if ts.Before(purgeThreshold) {
+		if retain == 0 || ts.Before(purgeThreshold) {
 			purged++
 			logrus.Tracef("purging: %s", name)
 			err = os.Remove(name)
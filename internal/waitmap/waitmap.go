@@ -103,6 +103,32 @@ func (w *Waits) SetWaitGroups() error {
 	return nil
 }
 
+// GroupTotals sums the cumulative wait time in milliseconds by wait group.
+// It uses the same mapping as WaitSummary but since the server started.
+func (w *Waits) GroupTotals() map[string]int64 {
+	totals := make(map[string]int64)
+	Mapping.RLock()
+	defer Mapping.RUnlock()
+	for key, value := range w.Waits {
+		if value.WaitTime <= 0 {
+			continue
+		}
+		mapTo := key
+		wm, ok := Mapping.Mappings[key]
+		if ok {
+			if wm.Excluded {
+				continue
+			}
+			mapTo = wm.MappedTo
+		}
+		if mapTo == "" {
+			continue
+		}
+		totals[mapTo] += value.WaitTime
+	}
+	return totals
+}
+
 func checkForUserWaitsFile() error {
 
 	wd, err := osext.ExecutableFolder()
@@ -77,7 +77,54 @@ function getDefaultChartOptions() {
     };
 }
 
-function NewWaitsChart(whichAPI, server, container) {
+// historyUrl returns the repository history API for a chart
+// range is a query string like "from=2025-01-02T03:00&to=2025-01-02T05:00"
+function historyUrl(server, chart, range) {
+    return "/api/history/" + server + "/" + chart + "?" + range;
+}
+
+// isSpan is true for a range like span=24h that reads the history kept in memory
+function isSpan(range) {
+    return !!range && range.indexOf("span=") === 0;
+}
+
+// chartUrl returns the API for a chart.  A from/to range reads the repository.
+function chartUrl(server, chart, range) {
+    if (isSpan(range)) {
+        return "/api/" + chart + "/" + server + "?" + range;
+    }
+    return range ? historyUrl(server, chart, range) : "/api/" + chart + "/" + server;
+}
+
+// applySpan sets the x-axis to start at the beginning of a span like span=24h
+function applySpan(options, range) {
+    const m = /^span=(\d+)([hm])$/.exec(decodeURIComponent(range));
+    if (!m) {
+        return options;
+    }
+    const ms = parseInt(m[1], 10) * (m[2] === "h" ? 3600000 : 60000);
+    options.scales.x.suggestedMin = Date.now() - ms;
+    if (ms > 6 * 3600000) {
+        options.scales.x.time.unit = 'hour';
+    }
+    return options;
+}
+
+// applyHistoryRange sets the x-axis to the range returned by the history API
+function applyHistoryRange(options, json) {
+    if (!json || !json.from || !json.to) {
+        return options;
+    }
+    const span = json.to - json.from;
+    options.scales.x.suggestedMin = undefined;
+    options.scales.x.min = json.from;
+    options.scales.x.max = json.to;
+    options.scales.x.time.unit = span > 2 * 86400000 ? 'day' : (span > 6 * 3600000 ? 'hour' : 'minute');
+    options.scales.x.time.displayFormats.day = 'MM-dd';
+    return options;
+}
+
+function NewWaitsChart(whichAPI, server, container, range) {
     //console.log("NewWaitsChart: " + whichAPI + " " + server + " " + container)
     Chart.register(Chart.Colors);
     const defaultOptions = getDefaultChartOptions();
@@ -108,9 +155,14 @@ function NewWaitsChart(whichAPI, server, container) {
     // console.log(options)
 
     const ctx = document.getElementById(container);
-    const apiUrl = "/api/" + whichAPI + "/" + server + "?keepsort=1"
+    const apiUrl = range ? historyUrl(server, whichAPI === "waits2" ? "waits" : "serverwaits", range) : "/api/" + whichAPI + "/" + server + "?keepsort=1"
     //console.log(apiUrl)
     $.getJSON(apiUrl, function(json) {
+        if (isSpan(range)) {
+            applySpan(options, range);
+        } else if (range) {
+            applyHistoryRange(options, json);
+        }
         // Check if json.series is valid
         if (!json || !Array.isArray(json.series)) {
             console.log(`NewWaitsChart: Invalid or missing 'series' data in API response from ${apiUrl}.`);
@@ -156,9 +208,9 @@ function NewWaitsChart(whichAPI, server, container) {
     });
 }
 
-function NewDiskChart(server, container) {
+function NewDiskChart(server, container, range) {
     const ctx = document.getElementById(container);
-    const apiUrl = "/api/disk/" + server;
+    const apiUrl = chartUrl(server, "disk", range);
 
     const defaultOptions = getDefaultChartOptions();
     // Chart.js options
@@ -186,6 +238,11 @@ function NewDiskChart(server, container) {
     const options = lodash.merge({}, defaultOptions, chartOptions);
 
     $.getJSON(apiUrl, function(json) {
+        if (isSpan(range)) {
+            applySpan(options, range);
+        } else if (range) {
+            applyHistoryRange(options, json);
+        }
         // Extract the first series data (array of x, y pairs)
         const dataReads =   json.series[0].data;
         const dataWrites =  json.series[1].data;
@@ -241,10 +298,10 @@ function roundToNearestMinute(timestamp) {
     return Math.round(timestamp / msPerMinute) * msPerMinute;
 }
 
-function NewCPUChart(server, container) {
+function NewCPUChart(server, container, range) {
     Chart.register(Chart.Colors);
     const ctx = document.getElementById(container);
-    const apiUrl = "/api/cpu/" + server;
+    const apiUrl = chartUrl(server, "cpu", range);
 
     const defaultOptions = getDefaultChartOptions();
     const chartOptions = {
@@ -277,6 +334,11 @@ function NewCPUChart(server, container) {
         const sqlbatches = json.series[2].data; // Batches/sec
         
         const options = lodash.merge({}, defaultOptions, chartOptions);
+        if (isSpan(range)) {
+            applySpan(options, range);
+        } else if (range) {
+            applyHistoryRange(options, json);
+        }
 
         new Chart(ctx, {
             type: 'line',
@@ -323,3 +385,58 @@ function NewCPUChart(server, container) {
         console.error("NewCPUChart: Failed to load data from " + apiUrl);
     });
 }
+
+// NewMetricChart charts a custom metric from the HCL files.
+// Only a span is supported.  The repository range isn't.
+function NewMetricChart(server, metric, label, container, span) {
+    const ctx = document.getElementById(container);
+    const apiUrl = "/api/metrics/" + server + "/" + metric + (isSpan(span) ? "?" + span : "");
+
+    const defaultOptions = getDefaultChartOptions();
+    const chartOptions = {
+        scales: {
+            y: {
+                title: {
+                    display: true,
+                    text: label,
+                },
+            },
+            y1: {
+                display: false,
+            },
+        }
+    };
+
+    const options = lodash.merge({}, defaultOptions, chartOptions);
+
+    $.getJSON(apiUrl, function(json) {
+        if (isSpan(span)) {
+            applySpan(options, span);
+        }
+        if (!json || !Array.isArray(json.series) || json.series.length === 0) {
+            console.log(`NewMetricChart: Invalid or missing 'series' data in API response from ${apiUrl}.`);
+            return;
+        }
+
+        new Chart(ctx, {
+            type: 'line',
+            data: {
+                datasets: [
+                    {
+                        label: json.series[0].name,
+                        data: json.series[0].data,
+                        borderWidth: 1,
+                        borderColor: 'rgba(54, 162, 235, 1)',
+                        backgroundColor: 'rgba(54, 162, 235, 0.2)',
+                        tension: 0.4, // Smooth line
+                        pointRadius: 0, // Remove the little circles
+                        fill: 'origin',
+                    }
+                ]
+            },
+            options: options
+        });
+    }).fail(function() {
+        console.error("NewMetricChart: Failed to load data from " + apiUrl);
+    });
+}